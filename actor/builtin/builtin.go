@@ -7,6 +7,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/actor/builtin/account"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/miner"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/paymentbroker"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/reward"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/storagemarket"
 	"github.com/filecoin-project/go-filecoin/exec"
 	"github.com/filecoin-project/go-filecoin/types"
@@ -23,4 +24,5 @@ func init() {
 	Actors[types.PaymentBrokerActorCodeCid] = &paymentbroker.Actor{}
 	Actors[types.MinerActorCodeCid] = &miner.Actor{}
 	Actors[types.BootstrapMinerActorCodeCid] = &miner.Actor{Bootstrap: true}
+	Actors[types.RewardActorCodeCid] = &reward.Actor{}
 }
@@ -0,0 +1,148 @@
+// Package reward implements the actor holding the block reward schedule.
+package reward
+
+import (
+	"fmt"
+	"math/big"
+
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	cbor "gx/ipfs/QmRoARq3nkUb13HSKZGepCZSWe5GrVPwx7xURJGZ7KWv9V/go-ipld-cbor"
+
+	"github.com/filecoin-project/go-filecoin/abi"
+	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/exec"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm/errors"
+)
+
+func init() {
+	cbor.RegisterCborType(State{})
+	cbor.RegisterCborType(Schedule{})
+}
+
+// DefaultInitialReward is the block reward of the default schedule.
+var DefaultInitialReward = types.NewAttoFILFromFIL(1000)
+
+// Schedule is the emission schedule of block rewards: the reward of the
+// blocks at height h is InitialReward decreased by DecayPercent percent
+// h / DecayInterval times. A DecayPercent of 50 halves the reward every
+// DecayInterval blocks, a DecayInterval of 0 keeps it flat.
+type Schedule struct {
+	InitialReward *types.AttoFIL
+	DecayPercent  uint64
+	DecayInterval uint64
+}
+
+// DefaultSchedule returns a flat schedule paying DefaultInitialReward per
+// block.
+func DefaultSchedule() *Schedule {
+	return &Schedule{InitialReward: DefaultInitialReward}
+}
+
+// Validate returns an error if the schedule is not a valid one.
+func (s *Schedule) Validate() error {
+	if s.InitialReward == nil || s.InitialReward.IsNegative() {
+		return fmt.Errorf("initial reward must not be negative")
+	}
+	if s.DecayPercent > 100 {
+		return fmt.Errorf("decay percent must be at most 100, got %d", s.DecayPercent)
+	}
+	return nil
+}
+
+// Reward returns the reward of the blocks at height h.
+func (s *Schedule) Reward(h *types.BlockHeight) *types.AttoFIL {
+	reward := s.InitialReward
+	if s.DecayInterval == 0 || s.DecayPercent == 0 {
+		return reward
+	}
+
+	periods := big.NewInt(0).Div(h.AsBigInt(), big.NewInt(0).SetUint64(s.DecayInterval))
+	keep := big.NewInt(0).SetUint64(100 - s.DecayPercent)
+	hundred := big.NewInt(100)
+	// the reward reaches zero long before periods overflows in practice
+	for i := big.NewInt(0); i.Cmp(periods) < 0 && !reward.IsZero(); i.Add(i, big.NewInt(1)) {
+		reward = reward.MulBigInt(keep).DivBigInt(hundred)
+	}
+	return reward
+}
+
+// Actor is the builtin actor holding the block reward schedule chosen in the
+// genesis block. The rewards themselves are paid from the network actor.
+type Actor struct{}
+
+// State is the reward actor's storage.
+type State struct {
+	Schedule *Schedule
+}
+
+// NewActor returns a new reward actor.
+func NewActor() (*actor.Actor, error) {
+	return actor.NewActor(types.RewardActorCodeCid, types.NewZeroAttoFIL()), nil
+}
+
+// InitializeState stores the schedule given as initializerData, or the
+// DefaultSchedule if it is nil.
+func (ra *Actor) InitializeState(storage exec.Storage, initializerData interface{}) error {
+	schedule := DefaultSchedule()
+	if initializerData != nil {
+		s, ok := initializerData.(*Schedule)
+		if !ok {
+			return errors.NewFaultError("Initial state to reward actor is not a reward.Schedule struct")
+		}
+		if s != nil {
+			schedule = s
+		}
+	}
+	if err := schedule.Validate(); err != nil {
+		return err
+	}
+
+	stateBytes, err := cbor.DumpObject(&State{Schedule: schedule})
+	if err != nil {
+		return err
+	}
+
+	id, err := storage.Put(stateBytes)
+	if err != nil {
+		return err
+	}
+
+	return storage.Commit(id, cid.Undef)
+}
+
+var _ exec.ExecutableActor = (*Actor)(nil)
+
+// Exports returns the actors exports.
+func (ra *Actor) Exports() exec.Exports {
+	return rewardExports
+}
+
+var rewardExports = exec.Exports{
+	"getBlockReward": &exec.FunctionSignature{
+		Params: []abi.Type{abi.BlockHeight},
+		Return: []abi.Type{abi.AttoFIL},
+	},
+}
+
+// GetBlockReward returns the reward of the blocks at the given height.
+func (ra *Actor) GetBlockReward(vmctx exec.VMContext, h *types.BlockHeight) (*types.AttoFIL, uint8, error) {
+	if err := vmctx.Charge(100); err != nil {
+		return nil, exec.ErrInsufficientGas, errors.RevertErrorWrap(err, "Insufficient gas")
+	}
+
+	var state State
+	ret, err := actor.WithState(vmctx, &state, func() (interface{}, error) {
+		return state.Schedule.Reward(h), nil
+	})
+	if err != nil {
+		return nil, errors.CodeError(err), err
+	}
+
+	reward, ok := ret.(*types.AttoFIL)
+	if !ok {
+		return nil, 1, fmt.Errorf("expected *types.AttoFIL to be returned, but got %T instead", ret)
+	}
+
+	return reward, 0, nil
+}
@@ -0,0 +1,79 @@
+package reward_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-filecoin/abi"
+	"github.com/filecoin-project/go-filecoin/actor"
+	. "github.com/filecoin-project/go-filecoin/actor/builtin/reward"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/core"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduleReward(t *testing.T) {
+	t.Parallel()
+
+	t.Run("flat schedules always pay the initial reward", func(t *testing.T) {
+		assert := assert.New(t)
+
+		s := DefaultSchedule()
+		assert.Equal(DefaultInitialReward, s.Reward(types.NewBlockHeight(0)))
+		assert.Equal(DefaultInitialReward, s.Reward(types.NewBlockHeight(1000000)))
+
+		s = &Schedule{InitialReward: types.NewAttoFILFromFIL(10), DecayPercent: 50}
+		assert.Equal(types.NewAttoFILFromFIL(10), s.Reward(types.NewBlockHeight(1000000)))
+	})
+
+	t.Run("halving schedules halve the reward every interval", func(t *testing.T) {
+		assert := assert.New(t)
+
+		s := &Schedule{InitialReward: types.NewAttoFILFromFIL(1000), DecayPercent: 50, DecayInterval: 100}
+		assert.Equal(types.NewAttoFILFromFIL(1000), s.Reward(types.NewBlockHeight(0)))
+		assert.Equal(types.NewAttoFILFromFIL(1000), s.Reward(types.NewBlockHeight(99)))
+		assert.Equal(types.NewAttoFILFromFIL(500), s.Reward(types.NewBlockHeight(100)))
+		assert.Equal(types.NewAttoFILFromFIL(250), s.Reward(types.NewBlockHeight(250)))
+		// the reward runs out eventually
+		assert.True(s.Reward(types.NewBlockHeight(100000000)).IsZero())
+	})
+
+	t.Run("decaying schedules take a percentage off every interval", func(t *testing.T) {
+		assert := assert.New(t)
+
+		s := &Schedule{InitialReward: types.NewAttoFILFromFIL(1000), DecayPercent: 10, DecayInterval: 10}
+		assert.Equal(types.NewAttoFILFromFIL(900), s.Reward(types.NewBlockHeight(10)))
+		assert.Equal(types.NewAttoFILFromFIL(810), s.Reward(types.NewBlockHeight(20)))
+	})
+
+	t.Run("invalid schedules", func(t *testing.T) {
+		assert := assert.New(t)
+
+		assert.NoError(DefaultSchedule().Validate())
+		assert.Error((&Schedule{}).Validate())
+		assert.Error((&Schedule{InitialReward: types.NewAttoFILFromFIL(1), DecayPercent: 101}).Validate())
+	})
+}
+
+func TestRewardActorGetBlockReward(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	st, vms := core.CreateStorages(ctx, t)
+
+	pdata := actor.MustConvertParams(types.NewBlockHeight(10))
+	msg := types.NewMessage(address.TestAddress, address.RewardAddress, 0, nil, "getBlockReward", pdata)
+	result, err := th.ApplyTestMessage(st, vms, msg, types.NewBlockHeight(0))
+	require.NoError(err)
+	require.NoError(result.ExecutionError)
+
+	val, err := abi.Deserialize(result.Receipt.Return[0], abi.AttoFIL)
+	require.NoError(err)
+	assert.True(DefaultInitialReward.Equal(val.Val.(*types.AttoFIL)))
+}
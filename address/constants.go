@@ -42,6 +42,10 @@ var (
 	StorageMarketAddress Address
 	// PaymentBrokerAddress is the hard-coded address of the filecoin storage market
 	PaymentBrokerAddress Address
+	// RewardAddress is the hard-coded address of the actor holding the block reward schedule
+	RewardAddress Address
+	// BurntFundsAddress is the address funds are sent to to take them out of circulation
+	BurntFundsAddress Address
 )
 
 func init() {
@@ -59,4 +63,10 @@ func init() {
 
 	p := Hash([]byte("payments"))
 	PaymentBrokerAddress = NewMainnet(p)
+
+	r := Hash([]byte("rewards"))
+	RewardAddress = NewMainnet(r)
+
+	b := Hash([]byte("burnt"))
+	BurntFundsAddress = NewMainnet(b)
 }
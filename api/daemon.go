@@ -32,7 +32,7 @@ type DaemonInitConfig struct {
 	DevnetNightly bool
 	// DevnetUser, if set, sets the config to enable bootstrapping to the user devnet.
 	DevnetUser bool
-	// AutoSealIntervalSeconds, when set, configures the daemon to check for and seal any staged sectors on an interval
+	// AutoSealIntervalSeconds, when set, configures the daemon to seal staged sectors once they have waited that many seconds for pieces
 	AutoSealIntervalSeconds uint
 	DefaultAddress          address.Address
 }
@@ -89,7 +89,7 @@ func DevnetUser(doit bool) DaemonInitOpt {
 	}
 }
 
-// AutoSealIntervalSeconds configures the daemon to check for and seal any staged sectors on an interval.
+// AutoSealIntervalSeconds configures the daemon to seal staged sectors once they have waited that many seconds for pieces.
 func AutoSealIntervalSeconds(autoSealIntervalSeconds uint) DaemonInitOpt {
 	return func(dc *DaemonInitConfig) {
 		dc.AutoSealIntervalSeconds = autoSealIntervalSeconds
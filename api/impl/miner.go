@@ -9,7 +9,9 @@ import (
 	"gx/ipfs/QmY5Grm8pJdiSSVsYxx4uNRgweY72EmYwuSDbRnbFok3iY/go-libp2p-peer"
 
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/api"
 	"github.com/filecoin-project/go-filecoin/porcelain"
+	"github.com/filecoin-project/go-filecoin/protocol/storage"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
@@ -129,3 +131,49 @@ func (nm *nodeMiner) GetTotalPower(ctx context.Context) (*big.Int, error) {
 
 	return power, nil
 }
+
+func (nm *nodeMiner) Sectors(ctx context.Context, minerAddr address.Address) ([]*storage.SectorInfo, error) {
+	ss, err := nm.sectorStore(minerAddr)
+	if err != nil {
+		return nil, err
+	}
+	return ss.List()
+}
+
+func (nm *nodeMiner) Sector(ctx context.Context, minerAddr address.Address, sectorID uint64) (*storage.SectorInfo, error) {
+	ss, err := nm.sectorStore(minerAddr)
+	if err != nil {
+		return nil, err
+	}
+	return ss.Get(sectorID)
+}
+
+func (nm *nodeMiner) Sealing(ctx context.Context, minerAddr address.Address) (*api.SealingStatus, error) {
+	minerAddr, err := nm.minerAddr(minerAddr)
+	if err != nil {
+		return nil, err
+	}
+	stats, queue, err := nm.api.node.SealingStats(ctx, minerAddr)
+	if err != nil {
+		return nil, err
+	}
+	return &api.SealingStatus{SealingStats: stats, Queue: queue}, nil
+}
+
+// sectorStore returns the sector store of minerAddr, or of the default miner
+// if minerAddr is empty.
+func (nm *nodeMiner) sectorStore(minerAddr address.Address) (*storage.SectorStore, error) {
+	minerAddr, err := nm.minerAddr(minerAddr)
+	if err != nil {
+		return nil, err
+	}
+	return storage.NewSectorStore(nm.api.node.MinerDatastore(minerAddr)), nil
+}
+
+// minerAddr returns minerAddr, or the default miner if minerAddr is empty.
+func (nm *nodeMiner) minerAddr(minerAddr address.Address) (address.Address, error) {
+	if minerAddr.Empty() {
+		return nm.api.node.MiningAddress()
+	}
+	return minerAddr, nil
+}
@@ -2,7 +2,9 @@ package impl
 
 import (
 	"context"
+
 	"github.com/filecoin-project/go-filecoin/actor/builtin"
+	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/chain"
 	"github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/mining"
@@ -57,7 +59,14 @@ func (api *nodeMining) Once(ctx context.Context) (*types.Block, error) {
 	getAncestors := func(ctx context.Context, ts types.TipSet, newBlockHeight *types.BlockHeight) ([]types.TipSet, error) {
 		return chain.GetRecentAncestors(ctx, ts, nd.ChainReader, newBlockHeight, consensus.AncestorRoundsNeeded, consensus.LookBackParameter)
 	}
-	worker := mining.NewDefaultWorker(nd.MsgPool, getState, getWeight, getAncestors, consensus.NewDefaultProcessor(), nd.PowerTable, nd.Blockstore, nd.CborStore(), miningAddr, blockTime)
+	var worker *mining.DefaultWorker
+	if sb := nd.SectorBuilder(); sb != nil {
+		createPoST := mining.NewPoSTFunc(sb, blockTime)
+		worker = mining.NewDefaultWorkerWithDeps(nd.MsgPool, getState, getWeight, getAncestors, consensus.NewDefaultProcessor(), nd.PowerTable, nd.Blockstore, nd.CborStore(), miningAddr, blockTime, createPoST)
+	} else {
+		worker = mining.NewDefaultWorker(nd.MsgPool, getState, getWeight, getAncestors, consensus.NewDefaultProcessor(), nd.PowerTable, nd.Blockstore, nd.CborStore(), miningAddr, blockTime)
+	}
+	worker.RecordRounds(nd.MiningHistory)
 
 	res, err := mining.MineOnce(ctx, worker, mineDelay, ts)
 	if err != nil {
@@ -74,11 +83,17 @@ func (api *nodeMining) Once(ctx context.Context) (*types.Block, error) {
 	return res.NewBlock, nil
 }
 
-func (api *nodeMining) Start(ctx context.Context) error {
-	return node.StartMining(ctx, api.api.node)
+func (api *nodeMining) Start(ctx context.Context, minerAddr address.Address) error {
+	if minerAddr.Empty() {
+		return node.StartMining(ctx, api.api.node)
+	}
+	return api.api.node.StartMiner(ctx, minerAddr)
 }
 
-func (api *nodeMining) Stop(ctx context.Context) error {
-	api.api.node.StopMining(ctx)
-	return nil
+func (api *nodeMining) Stop(ctx context.Context, minerAddr address.Address) error {
+	if minerAddr.Empty() {
+		api.api.node.StopMining(ctx)
+		return nil
+	}
+	return api.api.node.StopMiner(ctx, minerAddr)
 }
@@ -8,6 +8,8 @@ import (
 	"gx/ipfs/QmY5Grm8pJdiSSVsYxx4uNRgweY72EmYwuSDbRnbFok3iY/go-libp2p-peer"
 
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/proofs/sectorbuilder"
+	"github.com/filecoin-project/go-filecoin/protocol/storage"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
@@ -20,4 +22,19 @@ type Miner interface {
 	GetPledge(ctx context.Context, minerAddr address.Address) (*big.Int, error)
 	GetPower(ctx context.Context, minerAddr address.Address) (*big.Int, error)
 	GetTotalPower(ctx context.Context) (*big.Int, error)
+	// Sectors and Sector return the sectors of minerAddr, or of the default
+	// miner of the node if minerAddr is empty.
+	Sectors(ctx context.Context, minerAddr address.Address) ([]*storage.SectorInfo, error)
+	Sector(ctx context.Context, minerAddr address.Address, sectorID uint64) (*storage.SectorInfo, error)
+	// Sealing returns the sealing queue of minerAddr, or of the default miner
+	// of the node if minerAddr is empty.
+	Sealing(ctx context.Context, minerAddr address.Address) (*SealingStatus, error)
+}
+
+// SealingStatus is the sealing queue of a miner.
+type SealingStatus struct {
+	sectorbuilder.SealingStats
+	// Queue is the sectors waiting for a seal to be started, those with the
+	// earliest deadlines first.
+	Queue []sectorbuilder.QueuedSector
 }
@@ -3,12 +3,17 @@ package api
 import (
 	"context"
 
+	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
 // Mining is the interface that defines methods to manage mining operations.
 type Mining interface {
 	Once(ctx context.Context) (*types.Block, error)
-	Start(ctx context.Context) error
-	Stop(ctx context.Context) error
+	// Start starts mining for minerAddr, or for all the miners of the node if
+	// minerAddr is empty.
+	Start(ctx context.Context, minerAddr address.Address) error
+	// Stop stops mining for minerAddr, or for all the miners of the node if
+	// minerAddr is empty.
+	Stop(ctx context.Context, minerAddr address.Address) error
 }
@@ -0,0 +1,197 @@
+package chain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+	"gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore"
+	"gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore/query"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// ErrAddressIndexDisabled is returned when querying the history of an address
+// from a store that does not index addresses.
+var ErrAddressIndexDisabled = errors.New("the address index is disabled, set chain.indexAddresses in the config to enable it")
+
+// addressIndexPrefix is the datastore namespace of the index from addresses to
+// the messages of the chain sent to or from them.
+const addressIndexPrefix = "/chain/address"
+
+// MessageDirection is the direction of a message relative to an address.
+type MessageDirection string
+
+const (
+	// DirectionIn is a message sent to the address.
+	DirectionIn = MessageDirection("in")
+	// DirectionOut is a message sent from the address.
+	DirectionOut = MessageDirection("out")
+	// DirectionSelf is a message sent from the address to itself.
+	DirectionSelf = MessageDirection("self")
+)
+
+// AddressHistoryEntry is a message of the chain sent to or from an address.
+type AddressHistoryEntry struct {
+	Message   cid.Cid
+	Height    uint64
+	Direction MessageDirection
+	// HasReceipt is false if the receipt of the message in the tipset
+	// including it is unknown, or if the message failed in conflict with
+	// another message of the tipset, in which case ExitCode is meaningless.
+	HasReceipt bool
+	ExitCode   uint8
+}
+
+// EnableAddressIndex makes the store index the messages of the chain by
+// sender and recipient.  It must be called before the store is loaded.
+func (store *DefaultStore) EnableAddressIndex() {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.indexAddresses = true
+}
+
+// AddressHistory returns the messages of the chain ending in the head sent to
+// or from addr at height since or above, ordered by height.  If limit is not
+// zero about limit entries are returned: all the entries at the height of the
+// last one are included so that the next page starts one above it.
+func (store *DefaultStore) AddressHistory(ctx context.Context, addr address.Address, since uint64, limit int) ([]AddressHistoryEntry, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	if !store.indexAddresses {
+		return nil, ErrAddressIndexDisabled
+	}
+
+	// The keys start with the zero-padded height, so in key order the
+	// entries are ordered by height and only the requested page is read.
+	prefix := datastore.NewKey(addressIndexPrefix).ChildString(addr.String()).String() + "/"
+	res, err := store.ds.Query(query.Query{
+		Prefix:   prefix,
+		KeysOnly: true,
+		Orders:   []query.Order{query.OrderByKey{}},
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to query history of %s", addr)
+	}
+	defer res.Close() // nolint: errcheck
+
+	var entries []AddressHistoryEntry
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, errors.Wrapf(r.Error, "failed to read history of %s", addr)
+		}
+		k, err := parseAddressIndexKey(prefix, r.Key)
+		if err != nil {
+			return nil, err
+		}
+		if k.height < since {
+			continue
+		}
+		// All the entries at the height of the last one are included.
+		if limit > 0 && len(entries) >= limit && entries[len(entries)-1].Height != k.height {
+			break
+		}
+		val, err := store.ds.Get(datastore.NewKey(k.key))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read history entry %s", k.key)
+		}
+		var entry AddressHistoryEntry
+		if err := json.Unmarshal(val, &entry); err != nil {
+			return nil, errors.Wrapf(err, "failed to cast history entry %s", k.key)
+		}
+		// Skip entries left behind by a tipset that could not be unindexed.
+		loc, err := store.readMessageIndex(entry.Message)
+		if err == datastore.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read message index for %s", entry.Message)
+		}
+		if loc.Height != entry.Height || !store.isIndexedTipSet(loc.TipSet, loc.Height) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// addressIndexEntryKey is the datastore key of an entry of the address index
+// and the height it encodes.
+type addressIndexEntryKey struct {
+	key    string
+	height uint64
+}
+
+// parseAddressIndexKey parses a key written by addressIndexKey under prefix.
+func parseAddressIndexKey(prefix, key string) (addressIndexEntryKey, error) {
+	parts := strings.Split(strings.TrimPrefix(key, prefix), "/")
+	if len(parts) != 2 {
+		return addressIndexEntryKey{}, errors.Errorf("malformed address index key %s", key)
+	}
+	h, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return addressIndexEntryKey{}, errors.Wrapf(err, "malformed address index key %s", key)
+	}
+	return addressIndexEntryKey{key: key, height: h}, nil
+}
+
+// indexMessageAddresses adds msg, included at height h with receipt rcpt, to
+// the history of its sender and recipient.
+// Precondition: the caller holds the store's lock.
+func (store *DefaultStore) indexMessageAddresses(msg *types.SignedMessage, msgCid cid.Cid, h uint64, rcpt *types.MessageReceipt) error {
+	if !store.indexAddresses {
+		return nil
+	}
+	entry := AddressHistoryEntry{
+		Message: msgCid,
+		Height:  h,
+	}
+	if rcpt != nil {
+		entry.HasReceipt = true
+		entry.ExitCode = rcpt.ExitCode
+	}
+
+	directions := map[address.Address]MessageDirection{
+		msg.From: DirectionOut,
+		msg.To:   DirectionIn,
+	}
+	if msg.From == msg.To {
+		directions = map[address.Address]MessageDirection{msg.From: DirectionSelf}
+	}
+	for addr, dir := range directions {
+		entry.Direction = dir
+		val, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if err := store.ds.Put(addressIndexKey(addr, h, msgCid), val); err != nil {
+			return errors.Wrapf(err, "failed to write history of %s", addr)
+		}
+	}
+	return nil
+}
+
+// unindexMessageAddresses removes msg, included at height h, from the history
+// of its sender and recipient.
+// Precondition: the caller holds the store's lock.
+func (store *DefaultStore) unindexMessageAddresses(msg *types.SignedMessage, msgCid cid.Cid, h uint64) error {
+	if !store.indexAddresses {
+		return nil
+	}
+	for _, addr := range []address.Address{msg.From, msg.To} {
+		if err := store.ds.Delete(addressIndexKey(addr, h, msgCid)); err != nil && err != datastore.ErrNotFound {
+			return errors.Wrapf(err, "failed to delete history of %s", addr)
+		}
+	}
+	return nil
+}
+
+func addressIndexKey(addr address.Address, h uint64, msgCid cid.Cid) datastore.Key {
+	return datastore.NewKey(addressIndexPrefix).ChildString(addr.String()).ChildString(fmt.Sprintf("%020d", h)).ChildString(msgCid.String())
+}
@@ -0,0 +1,161 @@
+package chain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddressHistory(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+
+	ki := types.MustGenerateKeyInfo(2, types.GenerateKeyInfoSeed())
+	mockSigner := types.NewMockSigner(ki)
+	alice, bob := mockSigner.Addresses[0], mockSigner.Addresses[1]
+	requireMessage := func(from, to address.Address, nonce uint64) *types.SignedMessage {
+		msg := types.NewMessage(from, to, nonce, types.NewAttoFILFromFIL(1), "", nil)
+		smsg, err := types.NewSignedMessage(*msg, &mockSigner, types.NewGasPrice(0), types.NewGasUnits(0))
+		require.NoError(err)
+		return smsg
+	}
+	m1, m2, m3, m4 := requireMessage(alice, bob, 0), requireMessage(bob, alice, 0), requireMessage(bob, bob, 1), requireMessage(alice, alice, 1)
+
+	tr, tipsets := requireExportTestChain(ctx, require, 0)
+	tr.store.EnableAddressIndex()
+	genTS := tipsets[0]
+	requireChild := func(parent types.TipSet, nonce uint64, msgs ...*types.SignedMessage) types.TipSet {
+		blk := RequireMkFakeChild(require, FakeChildParams{
+			GenesisCid: tr.store.GenesisCid(),
+			Parent:     parent,
+			StateRoot:  genTS.ToSlice()[0].StateRoot,
+			Nonce:      nonce,
+		})
+		blk.Messages = msgs
+		blk.MessageReceipts = make([]*types.MessageReceipt, len(msgs))
+		for i := range msgs {
+			blk.MessageReceipts[i] = &types.MessageReceipt{ExitCode: uint8(i)}
+		}
+		ts := MustNewTipSet(blk)
+		RequirePutTsas(ctx, require, tr.store, &TipSetAndState{TipSet: ts, TipSetStateRoot: blk.StateRoot})
+		return ts
+	}
+	requireHistory := func(store *DefaultStore, addr address.Address, since uint64, limit int, expected ...AddressHistoryEntry) {
+		history, err := store.AddressHistory(ctx, addr, since, limit)
+		require.NoError(err)
+		assert.Equal(expected, history)
+	}
+	entry := func(msg *types.SignedMessage, h uint64, dir MessageDirection, exitCode uint8) AddressHistoryEntry {
+		c, err := msg.Cid()
+		require.NoError(err)
+		return AddressHistoryEntry{
+			Message:    c,
+			Height:     h,
+			Direction:  dir,
+			HasReceipt: true,
+			ExitCode:   exitCode,
+		}
+	}
+
+	a1 := requireChild(genTS, 0, m1)
+	a2 := requireChild(a1, 0, m2, m3)
+	a3 := requireChild(a2, 0, m4)
+	require.NoError(tr.store.SetHead(ctx, a3))
+
+	requireHistory(tr.store, alice, 0, 0,
+		entry(m1, 1, DirectionOut, 0),
+		entry(m2, 2, DirectionIn, 0),
+		entry(m4, 3, DirectionSelf, 0),
+	)
+	history, err := tr.store.AddressHistory(ctx, bob, 0, 0)
+	require.NoError(err)
+	assert.Len(history, 3)
+	assert.Equal(entry(m1, 1, DirectionIn, 0), history[0])
+	requireHistory(tr.store, alice, 3, 0,
+		entry(m4, 3, DirectionSelf, 0),
+	)
+	// Pages end at a height boundary.
+	requireHistory(tr.store, alice, 0, 1,
+		entry(m1, 1, DirectionOut, 0),
+	)
+	history, err = tr.store.AddressHistory(ctx, bob, 2, 1)
+	require.NoError(err)
+	assert.Len(history, 2)
+
+	// Reorg to a fork in which only m2 is included.
+	b1 := requireChild(genTS, 1)
+	b2 := requireChild(b1, 1)
+	b3 := requireChild(b2, 1, m2)
+	require.NoError(tr.store.SetHead(ctx, b3))
+	requireHistory(tr.store, alice, 0, 0,
+		entry(m2, 3, DirectionIn, 0),
+	)
+
+	// Disabling the index on a reloaded store rebuilds the other indexes
+	// without it.
+	reloaded := NewDefaultStore(tr.r.ChainDatastore(), tr.cst, tr.store.GenesisCid())
+	require.NoError(reloaded.Load(ctx))
+	_, err = reloaded.AddressHistory(ctx, alice, 0, 0)
+	assert.Equal(ErrAddressIndexDisabled, err)
+
+	// Enabling it again indexes the whole chain.
+	reloaded = NewDefaultStore(tr.r.ChainDatastore(), tr.cst, tr.store.GenesisCid())
+	reloaded.EnableAddressIndex()
+	require.NoError(reloaded.Load(ctx))
+	requireHistory(reloaded, bob, 0, 0,
+		entry(m2, 3, DirectionOut, 0),
+	)
+}
+
+func TestAddressHistoryUsesTipSetReceipts(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+
+	ki := types.MustGenerateKeyInfo(2, types.GenerateKeyInfoSeed())
+	mockSigner := types.NewMockSigner(ki)
+	alice, bob := mockSigner.Addresses[0], mockSigner.Addresses[1]
+	msg := types.NewMessage(alice, bob, 0, types.NewAttoFILFromFIL(1), "", nil)
+	m1, err := types.NewSignedMessage(*msg, &mockSigner, types.NewGasPrice(0), types.NewGasUnits(0))
+	require.NoError(err)
+	m1Cid, err := m1.Cid()
+	require.NoError(err)
+
+	tr, tipsets := requireExportTestChain(ctx, require, 0)
+	tr.store.EnableAddressIndex()
+	genTS := tipsets[0]
+	requireBlock := func(nonce uint64, msgs ...*types.SignedMessage) *types.Block {
+		blk := RequireMkFakeChild(require, FakeChildParams{
+			GenesisCid: tr.store.GenesisCid(),
+			Parent:     genTS,
+			StateRoot:  genTS.ToSlice()[0].StateRoot,
+			Nonce:      nonce,
+		})
+		blk.Messages = msgs
+		for range msgs {
+			blk.MessageReceipts = append(blk.MessageReceipts, &types.MessageReceipt{ExitCode: 0})
+		}
+		return blk
+	}
+
+	// m1 has another receipt when the whole tipset is applied
+	ts := MustNewTipSet(requireBlock(0, m1), requireBlock(1))
+	RequirePutTsas(ctx, require, tr.store, &TipSetAndState{
+		TipSet:          ts,
+		TipSetStateRoot: genTS.ToSlice()[0].StateRoot,
+		Receipts:        map[string]*types.MessageReceipt{m1Cid.String(): {ExitCode: 3}},
+	})
+	require.NoError(tr.store.SetHead(ctx, ts))
+
+	history, err := tr.store.AddressHistory(ctx, bob, 0, 0)
+	require.NoError(err)
+	require.Len(history, 1)
+	assert.True(history[0].HasReceipt)
+	assert.Equal(uint8(3), history[0].ExitCode)
+}
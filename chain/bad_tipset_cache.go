@@ -1,39 +1,210 @@
 package chain
 
 import (
+	"container/list"
+	"encoding/json"
+	"sort"
 	"sync"
+	"time"
+
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+	"gx/ipfs/QmY5Grm8pJdiSSVsYxx4uNRgweY72EmYwuSDbRnbFok3iY/go-libp2p-peer"
+	"gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore"
+	"gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore/query"
 
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
-// badTipSetCache keeps track of bad tipsets that the syncer should not try to
-// download.  Readers and writers grab a lock.
-// TODO: this needs to be limited.
-type badTipSetCache struct {
-	mu  sync.Mutex
-	bad map[string]struct{}
+// DefaultBadTipSetCacheSize is the default maximum number of tipsets
+// remembered by a BadTipSetCache.
+const DefaultBadTipSetCacheSize = 4096
+
+// badTipSetDatastorePrefix is the datastore namespace under which bad tipsets
+// are persisted.
+const badTipSetDatastorePrefix = "/chain/badTipSets"
+
+// ErrBadTipSetNotFound is returned when removing a tipset that is not in the
+// bad tipset cache.
+var ErrBadTipSetNotFound = errors.New("tipset is not in the bad tipset cache")
+
+// BadTipSet records why a tipset was rejected by the syncer.
+type BadTipSet struct {
+	// TipSet is the key of the rejected tipset.
+	TipSet string
+	// Reason is a description of the error that caused the rejection.
+	Reason string
+	// Peer is the peer that sent the tipset, it is empty for tipsets
+	// originating on this node.
+	Peer string
+	// Added is the time at which the tipset was rejected.
+	Added time.Time
+}
+
+// BadTipSetCache keeps track of bad tipsets that the syncer should not try to
+// download.  The cache is bounded: once it is full the least recently added
+// or hit tipset is evicted.  Entries are persisted to a datastore so they
+// survive restarts.  Readers and writers grab a lock.
+type BadTipSetCache struct {
+	mu      sync.Mutex
+	ds      datastore.Datastore
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
 }
 
-// AddChain adds the chain of tipsets to the badTipSetCache.  For now it just
-// does the simplest thing and adds all blocks of the chain to the cache.
-// TODO: might want to cache a random subset once cache size is limited.
-func (cache *badTipSetCache) AddChain(chain []types.TipSet) {
+// NewBadTipSetCache returns a BadTipSetCache holding at most size tipsets
+// that is populated with the entries previously persisted in ds.
+func NewBadTipSetCache(ds datastore.Datastore, size int) (*BadTipSetCache, error) {
+	cache := &BadTipSetCache{
+		ds:      ds,
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+	if err := cache.load(); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+// AddChain adds the chain of tipsets to the BadTipSetCache.  For now it just
+// does the simplest thing and adds all tipsets of the chain to the cache.
+// TODO: might want to cache a random subset to avoid flushing the cache with
+// a single long chain.
+func (cache *BadTipSetCache) AddChain(chain []types.TipSet, reason string, from peer.ID) {
 	for _, ts := range chain {
-		cache.Add(ts.String())
+		cache.Add(ts.String(), reason, from)
 	}
 }
 
-// Add adds a single tipset key to the badTipSetCache.
-func (cache *badTipSetCache) Add(tsKey string) {
+// Add adds a single tipset key to the BadTipSetCache along with the reason it
+// was rejected and the peer that sent it.
+func (cache *BadTipSetCache) Add(tsKey string, reason string, from peer.ID) {
 	cache.mu.Lock()
 	defer cache.mu.Unlock()
-	cache.bad[tsKey] = struct{}{}
+
+	entry := &BadTipSet{
+		TipSet: tsKey,
+		Reason: reason,
+		Peer:   peerString(from),
+		Added:  time.Now(),
+	}
+	if elem, ok := cache.entries[tsKey]; ok {
+		elem.Value = entry
+		cache.order.MoveToFront(elem)
+	} else {
+		cache.entries[tsKey] = cache.order.PushFront(entry)
+	}
+	if err := cache.persist(entry); err != nil {
+		logSyncer.Warningf("failed to persist bad tipset %s: %s", tsKey, err)
+	}
+
+	for cache.order.Len() > cache.size {
+		if err := cache.evict(cache.order.Back()); err != nil {
+			logSyncer.Warningf("failed to evict bad tipset: %s", err)
+		}
+	}
 }
 
-// Has checks for membership in the badTipSetCache.
-func (cache *badTipSetCache) Has(tsKey string) bool {
+// Has checks for membership in the BadTipSetCache.
+func (cache *BadTipSetCache) Has(tsKey string) bool {
 	cache.mu.Lock()
 	defer cache.mu.Unlock()
-	_, ok := cache.bad[tsKey]
+	elem, ok := cache.entries[tsKey]
+	if ok {
+		cache.order.MoveToFront(elem)
+	}
 	return ok
 }
+
+// Get returns the record of a bad tipset, it returns false if the tipset is
+// not in the cache.
+func (cache *BadTipSetCache) Get(tsKey string) (BadTipSet, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	elem, ok := cache.entries[tsKey]
+	if !ok {
+		return BadTipSet{}, false
+	}
+	return *elem.Value.(*BadTipSet), true
+}
+
+// Remove unmarks a tipset, allowing the syncer to process it again.
+func (cache *BadTipSetCache) Remove(tsKey string) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	elem, ok := cache.entries[tsKey]
+	if !ok {
+		return ErrBadTipSetNotFound
+	}
+	return cache.evict(elem)
+}
+
+// List returns the records of all bad tipsets, most recent first.
+func (cache *BadTipSetCache) List() []BadTipSet {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	var out []BadTipSet
+	for elem := cache.order.Front(); elem != nil; elem = elem.Next() {
+		out = append(out, *elem.Value.(*BadTipSet))
+	}
+	return out
+}
+
+// evict removes an element from the cache and its datastore.
+// Precondition: the caller holds the cache's lock.
+func (cache *BadTipSetCache) evict(elem *list.Element) error {
+	entry := cache.order.Remove(elem).(*BadTipSet)
+	delete(cache.entries, entry.TipSet)
+	if err := cache.ds.Delete(badTipSetKey(entry.TipSet)); err != nil {
+		return errors.Wrapf(err, "failed to delete bad tipset %s from datastore", entry.TipSet)
+	}
+	return nil
+}
+
+func (cache *BadTipSetCache) persist(entry *BadTipSet) error {
+	val, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return cache.ds.Put(badTipSetKey(entry.TipSet), val)
+}
+
+// load reads the persisted entries, restoring their order by time added.
+func (cache *BadTipSetCache) load() error {
+	res, err := cache.ds.Query(query.Query{
+		Prefix: badTipSetDatastorePrefix,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to query bad tipsets from datastore")
+	}
+
+	var loaded []*BadTipSet
+	for entry := range res.Next() {
+		if entry.Error != nil {
+			return errors.Wrap(entry.Error, "failed to read bad tipset from datastore")
+		}
+		var bad BadTipSet
+		if err := json.Unmarshal(entry.Value, &bad); err != nil {
+			return errors.Wrap(err, "failed to unmarshal bad tipset from datastore")
+		}
+		loaded = append(loaded, &bad)
+	}
+
+	sort.Slice(loaded, func(i, j int) bool {
+		return loaded[i].Added.Before(loaded[j].Added)
+	})
+	for _, bad := range loaded {
+		cache.entries[bad.TipSet] = cache.order.PushFront(bad)
+	}
+	for cache.order.Len() > cache.size {
+		if err := cache.evict(cache.order.Back()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func badTipSetKey(tsKey string) datastore.Key {
+	return datastore.NewKey(badTipSetDatastorePrefix).ChildString(tsKey)
+}
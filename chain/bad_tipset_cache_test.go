@@ -0,0 +1,68 @@
+package chain
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/go-filecoin/repo"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadTipSetCacheAddAndRemove(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cache, err := NewBadTipSetCache(repo.NewInMemoryRepo().ChainDatastore(), DefaultBadTipSetCacheSize)
+	require.NoError(err)
+
+	cache.Add("{ a }", "bad ticket", "")
+	assert.True(cache.Has("{ a }"))
+	assert.False(cache.Has("{ b }"))
+
+	bad, ok := cache.Get("{ a }")
+	require.True(ok)
+	assert.Equal("bad ticket", bad.Reason)
+
+	require.NoError(cache.Remove("{ a }"))
+	assert.False(cache.Has("{ a }"))
+	assert.Equal(ErrBadTipSetNotFound, cache.Remove("{ a }"))
+}
+
+func TestBadTipSetCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cache, err := NewBadTipSetCache(repo.NewInMemoryRepo().ChainDatastore(), 2)
+	require.NoError(err)
+
+	cache.Add("{ a }", "reason", "")
+	cache.Add("{ b }", "reason", "")
+	// hitting a moves it to the front so b is evicted next
+	assert.True(cache.Has("{ a }"))
+	cache.Add("{ c }", "reason", "")
+
+	assert.True(cache.Has("{ a }"))
+	assert.False(cache.Has("{ b }"))
+	assert.True(cache.Has("{ c }"))
+	assert.Equal(2, len(cache.List()))
+}
+
+func TestBadTipSetCacheLoadsFromDatastore(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ds := repo.NewInMemoryRepo().ChainDatastore()
+	cache, err := NewBadTipSetCache(ds, DefaultBadTipSetCacheSize)
+	require.NoError(err)
+	cache.Add("{ a }", "first", "")
+	cache.Add("{ b }", "second", "")
+	require.NoError(cache.Remove("{ a }"))
+
+	loaded, err := NewBadTipSetCache(ds, DefaultBadTipSetCacheSize)
+	require.NoError(err)
+	assert.False(loaded.Has("{ a }"))
+	bad, ok := loaded.Get("{ b }")
+	require.True(ok)
+	assert.Equal("second", bad.Reason)
+}
@@ -36,6 +36,8 @@ type DefaultStore struct {
 	// simplify checking the security guarantee that only tipsets of a
 	// validated chain are stored in the filecoin node's DefaultStore.
 	privateStore *hamt.CborIpldStore
+	// blockstore is the blockstore backing the privateStore.
+	blockstore bstore.Blockstore
 	// stateStore is the on disk storage used for loading states.  It can be
 	// shared with the rest of the filecoin node.
 	stateStore *hamt.CborIpldStore
@@ -62,6 +64,10 @@ type DefaultStore struct {
 	// Tracks tipsets by height/parentset for use by expected consensus.
 	tipIndex *TipIndex
 
+	// indexAddresses is true if messages are indexed by sender and
+	// recipient, see EnableAddressIndex.
+	indexAddresses bool
+
 	// TODO block cache should go here
 }
 
@@ -74,6 +80,7 @@ func NewDefaultStore(ds repo.Datastore, stateStore *hamt.CborIpldStore, genesisC
 	priv := hamt.CborIpldStore{Blocks: bserv.New(bs, offline.Exchange(bs))}
 	return &DefaultStore{
 		privateStore: &priv,
+		blockstore:   bs,
 		stateStore:   stateStore,
 		headEvents:   pubsub.New(128),
 		ds:           ds,
@@ -159,8 +166,18 @@ func (store *DefaultStore) Load(ctx context.Context) error {
 	}
 
 	logStore.Infof("finished loading %d tipsets from %s", startHeight, headTs.String())
+	reindex, err := store.resetStaleIndexes()
+	if err != nil {
+		return err
+	}
 	// Set actual head.
-	return store.SetHead(ctx, headTs)
+	if err := store.SetHead(ctx, headTs); err != nil {
+		return err
+	}
+	if reindex {
+		return store.writeIndexVersion()
+	}
+	return nil
 }
 
 // loadHead loads the latest known head from disk.
@@ -225,6 +242,12 @@ func (store *DefaultStore) PutTipSetAndState(ctx context.Context, tsas *TipSetAn
 	if err = store.writeTipSetAndState(tsas); err != nil {
 		return err
 	}
+	// The receipts of single block tipsets are the receipts of their block.
+	if len(tsas.TipSet) > 1 && tsas.Receipts != nil {
+		if err = store.writeTipSetReceipts(tsas.TipSet.String(), tsas.Receipts); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
@@ -327,6 +350,10 @@ func (store *DefaultStore) setHeadPersistent(ctx context.Context, ts types.TipSe
 	store.mu.Lock()
 	defer store.mu.Unlock()
 
+	if err := store.updateHeightIndex(ctx, store.head, ts); err != nil {
+		return errors.Wrap(err, "failed to update height index")
+	}
+
 	// Ensure consistency by storing this new head on disk.
 	if errInner := store.writeHead(ctx, ts.ToSortedCidSet()); errInner != nil {
 		return errors.Wrap(errInner, "failed to write new Head to datastore")
@@ -2,12 +2,16 @@ package chain
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"time"
 
 	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
 	"gx/ipfs/QmRXf2uUSdGSunRJsM9wXSUNVwLUGCY3So5fAs7h2CBJVf/go-hamt-ipld"
+	bstore "gx/ipfs/QmS2aqUZLJp8kF1ihE5rvDGE5LvmKDPnx32w9Z1BW9xLV5/go-ipfs-blockstore"
 	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+	"gx/ipfs/QmY5Grm8pJdiSSVsYxx4uNRgweY72EmYwuSDbRnbFok3iY/go-libp2p-peer"
+	bserv "gx/ipfs/QmYPZzd9VqmJDwxUnThfeSbV1Y5o53aVPDijTB7j7rS9Ep/go-blockservice"
 	logging "gx/ipfs/QmcuXC5cxs79ro2cUuHs4HQ2bkDLJUYokwL8aivcX6HW3C/go-log"
 
 	"github.com/filecoin-project/go-filecoin/actor/builtin"
@@ -26,6 +30,8 @@ var (
 	ErrNewChainTooLong = errors.New("input chain forked from best chain too far in the past")
 	// ErrUnexpectedStoreState indicates that the syncer's chain store is violating expected invariants.
 	ErrUnexpectedStoreState = errors.New("the chain store is in an unexpected state")
+	// ErrParentStatePruned is returned when processing a chain forking off a tipset whose state was pruned by garbage collection.
+	ErrParentStatePruned = errors.New("input chain forked from a tipset whose state has been pruned")
 )
 
 var logSyncer = logging.Logger("chain.syncer")
@@ -57,8 +63,10 @@ type DefaultSyncer struct {
 	cstOnline *hamt.CborIpldStore
 	// cstOffline is the node's shared offline storage.
 	cstOffline *hamt.CborIpldStore
-	// badTipSetCache is used to filter out collections of invalid blocks.
-	badTipSets *badTipSetCache
+	// badTipSets is used to filter out collections of invalid blocks.
+	badTipSets *BadTipSetCache
+	// status records the progress of the current sync for reporting.
+	status     *syncStatusTracker
 	consensus  consensus.Protocol
 	chainStore Store
 }
@@ -66,13 +74,12 @@ type DefaultSyncer struct {
 var _ Syncer = (*DefaultSyncer)(nil)
 
 // NewDefaultSyncer constructs a DefaultSyncer ready for use.
-func NewDefaultSyncer(online, offline *hamt.CborIpldStore, c consensus.Protocol, s Store) Syncer {
+func NewDefaultSyncer(online, offline *hamt.CborIpldStore, c consensus.Protocol, s Store, bad *BadTipSetCache) Syncer {
 	return &DefaultSyncer{
 		cstOnline:  online,
 		cstOffline: offline,
-		badTipSets: &badTipSetCache{
-			bad: make(map[string]struct{}),
-		},
+		badTipSets: bad,
+		status:     &syncStatusTracker{},
 		consensus:  c,
 		chainStore: s,
 	}
@@ -122,9 +129,10 @@ func (syncer *DefaultSyncer) getBlksMaybeFromNet(ctx context.Context, blkCids []
 //
 // collectChain is the entrypoint to the code that interacts with the network.
 // It does NOT add tipsets to the store.
-func (syncer *DefaultSyncer) collectChain(ctx context.Context, blkCids []cid.Cid) ([]types.TipSet, types.TipSet, error) {
+func (syncer *DefaultSyncer) collectChain(ctx context.Context, from peer.ID, blkCids []cid.Cid) ([]types.TipSet, types.TipSet, error) {
 	var chain []types.TipSet
 	defer logSyncer.Info("chain synced")
+	first := true
 	for {
 		var blks []*types.Block
 		// check the cache for bad tipsets before doing anything
@@ -143,18 +151,32 @@ func (syncer *DefaultSyncer) collectChain(ctx context.Context, blkCids []cid.Cid
 
 		ts, err := syncer.consensus.NewValidTipSet(ctx, blks)
 		if err != nil {
-			syncer.badTipSets.Add(tsKey)
-			syncer.badTipSets.AddChain(chain)
+			// Only tipsets failing validation are cached as bad, a chain
+			// forking off a pruned state is refused below without being
+			// cached as it may be valid.
+			syncer.badTipSets.Add(tsKey, err.Error(), from)
+			syncer.badTipSets.AddChain(chain, fmt.Sprintf("descends from bad tipset %s: %s", tsKey, err), from)
 			return nil, nil, err
 		}
 
 		height, _ := ts.Height()
+		if first {
+			syncer.status.setTargetHeight(height)
+			first = false
+		}
 		if len(chain)%500 == 0 {
 			logSyncer.Infof("syncing the chain, currently at block height %d", height)
 		}
 
 		// Finish traversal if the tipset made is tracked in the store.
 		if syncer.chainStore.HasTipSetAndState(ctx, tsKey) {
+			pruned, err := syncer.isStatePruned(ctx, tsKey)
+			if err != nil {
+				return nil, nil, err
+			}
+			if pruned {
+				return nil, nil, ErrParentStatePruned
+			}
 			return chain, ts, nil
 		}
 
@@ -168,6 +190,25 @@ func (syncer *DefaultSyncer) collectChain(ctx context.Context, blkCids []cid.Cid
 	}
 }
 
+// isStatePruned returns true if the state root of the tipset is not in the
+// node's offline storage anymore.  Precondition: the tipset must be in the
+// store
+func (syncer *DefaultSyncer) isStatePruned(ctx context.Context, tsKey string) (bool, error) {
+	tsas, err := syncer.chainStore.GetTipSetAndState(ctx, tsKey)
+	if err != nil {
+		return false, err
+	}
+	_, err = syncer.cstOffline.Blocks.GetBlock(ctx, tsas.TipSetStateRoot)
+	// the offline exchange reports missing blocks through the block service
+	if err == bserv.ErrNotFound || err == bstore.ErrNotFound {
+		return true, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "failed to read state root")
+	}
+	return false, nil
+}
+
 // tipSetState returns the state resulting from applying the input tipset to
 // the chain.  Precondition: the tipset must be in the store
 func (syncer *DefaultSyncer) tipSetState(ctx context.Context, tsKey string) (state.Tree, error) {
@@ -214,7 +255,7 @@ func (syncer *DefaultSyncer) syncOne(ctx context.Context, parent, next types.Tip
 
 	// Run a state transition to validate the tipset and compute
 	// a new state to add to the store.
-	st, err = syncer.consensus.RunStateTransition(ctx, next, ancestors, st)
+	st, receipts, err := syncer.consensus.RunStateTransition(ctx, next, ancestors, st)
 	if err != nil {
 		return err
 	}
@@ -225,6 +266,7 @@ func (syncer *DefaultSyncer) syncOne(ctx context.Context, parent, next types.Tip
 	err = syncer.chainStore.PutTipSetAndState(ctx, &TipSetAndState{
 		TipSet:          next,
 		TipSetStateRoot: root,
+		Receipts:        receipts,
 	})
 	if err != nil {
 		return err
@@ -317,7 +359,7 @@ func (syncer *DefaultSyncer) widen(ctx context.Context, ts types.TipSet) (types.
 // represent a valid extension. It limits the length of new chains it will
 // attempt to validate and caches invalid blocks it has encountered to
 // help prevent DOS.
-func (syncer *DefaultSyncer) HandleNewBlocks(ctx context.Context, blkCids []cid.Cid) error {
+func (syncer *DefaultSyncer) HandleNewBlocks(ctx context.Context, from peer.ID, blkCids []cid.Cid) (err error) {
 	// ********** WARNING **********
 	//
 	// This concurrency model is flawed.  The mutex is held during a possibly
@@ -334,10 +376,15 @@ func (syncer *DefaultSyncer) HandleNewBlocks(ctx context.Context, blkCids []cid.
 		return nil
 	}
 
+	syncer.status.start(from, types.NewSortedCidSet(blkCids...).String())
+	defer func() {
+		syncer.status.finish(err)
+	}()
+
 	// Walk the chain given by the input blocks back to a known tipset in
 	// the store. This is the only code that may go to the network to
 	// resolve cids to blocks.
-	chain, parent, err := syncer.collectChain(ctx, blkCids)
+	chain, parent, err := syncer.collectChain(ctx, from, blkCids)
 	if err != nil {
 		return err
 	}
@@ -367,3 +414,21 @@ func (syncer *DefaultSyncer) HandleNewBlocks(ctx context.Context, blkCids []cid.
 	}
 	return nil
 }
+
+// Status returns a snapshot of the syncer's progress.  It does not take the
+// syncer's lock so it may be called while a sync is in progress.
+func (syncer *DefaultSyncer) Status() SyncStatus {
+	status := syncer.status.get()
+	if h, err := syncer.chainStore.Head().Height(); err == nil {
+		status.CurrentHeight = h
+	}
+	return status
+}
+
+// CollectGarbage prunes old state from the chain store and bs.  It holds the
+// syncer's lock so that no state is written while garbage is collected.
+func (syncer *DefaultSyncer) CollectGarbage(ctx context.Context, bs bstore.Blockstore, opts GCOptions) (GCResult, error) {
+	syncer.mu.Lock()
+	defer syncer.mu.Unlock()
+	return syncer.chainStore.CollectGarbage(ctx, bs, opts)
+}
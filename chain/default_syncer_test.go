@@ -204,7 +204,9 @@ func initSyncTest(require *require.Assertions, con consensus.Protocol, genFunc f
 	chain := NewDefaultStore(chainDS, cst, calcGenBlk.Cid())
 
 	// chain.Syncer
-	syncer := NewDefaultSyncer(cst, cst, con, chain) // note we use same cst for on and offline for tests
+	badTipSets, err := NewBadTipSetCache(chainDS, DefaultBadTipSetCacheSize)
+	require.NoError(err)
+	syncer := NewDefaultSyncer(cst, cst, con, chain, badTipSets) // note we use same cst for on and offline for tests
 
 	// Initialize stores to contain genesis block and state
 	calcGenTS := testhelpers.RequireNewTipSet(require, calcGenBlk)
@@ -314,7 +316,7 @@ func TestSyncOneBlock(t *testing.T) {
 	expectedTs := testhelpers.RequireNewTipSet(require, link1blk1)
 
 	cids := requirePutBlocks(require, cst, link1blk1)
-	err := syncer.HandleNewBlocks(ctx, cids)
+	err := syncer.HandleNewBlocks(ctx, "", cids)
 	assert.NoError(err)
 
 	assertTsAdded(assert, chain, expectedTs)
@@ -329,7 +331,7 @@ func TestSyncOneTipSet(t *testing.T) {
 	ctx := context.Background()
 
 	cids := requirePutBlocks(require, cst, link1blk1, link1blk2)
-	err := syncer.HandleNewBlocks(ctx, cids)
+	err := syncer.HandleNewBlocks(ctx, "", cids)
 	assert.NoError(err)
 
 	assertTsAdded(assert, chain, link1)
@@ -346,13 +348,13 @@ func TestSyncTipSetBlockByBlock(t *testing.T) {
 	expTs1 := testhelpers.RequireNewTipSet(require, link1blk1)
 
 	cids := requirePutBlocks(require, cst, link1blk1, link1blk2)
-	err := syncer.HandleNewBlocks(ctx, []cid.Cid{cids[0]})
+	err := syncer.HandleNewBlocks(ctx, "", []cid.Cid{cids[0]})
 	assert.NoError(err)
 
 	assertTsAdded(assert, chain, expTs1)
 	assertHead(assert, chain, expTs1)
 
-	err = syncer.HandleNewBlocks(ctx, []cid.Cid{cids[1]})
+	err = syncer.HandleNewBlocks(ctx, "", []cid.Cid{cids[1]})
 	assert.NoError(err)
 
 	assertTsAdded(assert, chain, link1)
@@ -371,22 +373,22 @@ func TestSyncChainTipSetByTipSet(t *testing.T) {
 	cids3 := requirePutBlocks(require, cst, link3.ToSlice()...)
 	cids4 := requirePutBlocks(require, cst, link4.ToSlice()...)
 
-	err := syncer.HandleNewBlocks(ctx, cids1)
+	err := syncer.HandleNewBlocks(ctx, "", cids1)
 	assert.NoError(err)
 	assertTsAdded(assert, chain, link1)
 	assertHead(assert, chain, link1)
 
-	err = syncer.HandleNewBlocks(ctx, cids2)
+	err = syncer.HandleNewBlocks(ctx, "", cids2)
 	assert.NoError(err)
 	assertTsAdded(assert, chain, link2)
 	assertHead(assert, chain, link2)
 
-	err = syncer.HandleNewBlocks(ctx, cids3)
+	err = syncer.HandleNewBlocks(ctx, "", cids3)
 	assert.NoError(err)
 	assertTsAdded(assert, chain, link3)
 	assertHead(assert, chain, link3)
 
-	err = syncer.HandleNewBlocks(ctx, cids4)
+	err = syncer.HandleNewBlocks(ctx, "", cids4)
 	assert.NoError(err)
 	assertTsAdded(assert, chain, link4)
 	assertHead(assert, chain, link4)
@@ -404,7 +406,7 @@ func TestSyncChainHead(t *testing.T) {
 	_ = requirePutBlocks(require, cst, link3.ToSlice()...)
 	cids4 := requirePutBlocks(require, cst, link4.ToSlice()...)
 
-	err := syncer.HandleNewBlocks(ctx, cids4)
+	err := syncer.HandleNewBlocks(ctx, "", cids4)
 	assert.NoError(err)
 	assertTsAdded(assert, chain, link4)
 	assertTsAdded(assert, chain, link3)
@@ -433,13 +435,13 @@ func TestSyncIgnoreLightFork(t *testing.T) {
 	forkCids1 := requirePutBlocks(require, cst, forklink1.ToSlice()...)
 
 	// Sync heaviest branch first.
-	err := syncer.HandleNewBlocks(ctx, cids4)
+	err := syncer.HandleNewBlocks(ctx, "", cids4)
 	assert.NoError(err)
 	assertTsAdded(assert, chain, link4)
 	assertHead(assert, chain, link4)
 
 	// lighter fork should be processed but not change head.
-	assert.NoError(syncer.HandleNewBlocks(ctx, forkCids1))
+	assert.NoError(syncer.HandleNewBlocks(ctx, "", forkCids1))
 	assertTsAdded(assert, chain, forklink1)
 	assertHead(assert, chain, link4)
 }
@@ -483,13 +485,13 @@ func TestHeavierFork(t *testing.T) {
 	_ = requirePutBlocks(require, cst, forklink2.ToSlice()...)
 	forkHead := requirePutBlocks(require, cst, forklink3.ToSlice()...)
 
-	err := syncer.HandleNewBlocks(ctx, cids4)
+	err := syncer.HandleNewBlocks(ctx, "", cids4)
 	assert.NoError(err)
 	assertTsAdded(assert, chain, link4)
 	assertHead(assert, chain, link4)
 
 	// heavier fork updates head
-	err = syncer.HandleNewBlocks(ctx, forkHead)
+	err = syncer.HandleNewBlocks(ctx, "", forkHead)
 	assert.NoError(err)
 	assertTsAdded(assert, chain, forklink1)
 	assertTsAdded(assert, chain, forklink2)
@@ -507,11 +509,70 @@ func TestBlocksNotATipSet(t *testing.T) {
 	_ = requirePutBlocks(require, cst, link1.ToSlice()...)
 	_ = requirePutBlocks(require, cst, link2.ToSlice()...)
 	badCids := []cid.Cid{link1blk1.Cid(), link2blk1.Cid()}
-	err := syncer.HandleNewBlocks(ctx, badCids)
+	err := syncer.HandleNewBlocks(ctx, "", badCids)
 	assert.Error(err)
 	assertNoAdd(assert, chain, badCids)
 }
 
+// Syncer remembers bad tipsets across restarts.
+func TestBadTipSetPersistsAcrossLoad(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	syncer, _, cst, r := initSyncTestDefault(require)
+	ctx := context.Background()
+
+	_ = requirePutBlocks(require, cst, link1.ToSlice()...)
+	_ = requirePutBlocks(require, cst, link2.ToSlice()...)
+	badCids := []cid.Cid{link1blk1.Cid(), link2blk1.Cid()}
+	require.Error(syncer.HandleNewBlocks(ctx, "", badCids))
+
+	loadSyncer, loadCst := loadSyncerFromRepo(require, r)
+	_ = requirePutBlocks(require, loadCst, link1.ToSlice()...)
+	_ = requirePutBlocks(require, loadCst, link2.ToSlice()...)
+	err := loadSyncer.HandleNewBlocks(ctx, "", badCids)
+	assert.Equal(ErrChainHasBadTipSet, err)
+}
+
+/* sync status reporting */
+
+// Syncer reports the target and height of a completed sync.
+func TestSyncStatusAfterSync(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	syncer, _, cst, _ := initSyncTestDefault(require)
+	ctx := context.Background()
+
+	_ = requirePutBlocks(require, cst, link1.ToSlice()...)
+	cids2 := requirePutBlocks(require, cst, link2.ToSlice()...)
+	require.NoError(syncer.HandleNewBlocks(ctx, "", cids2))
+
+	status := syncer.Status()
+	assert.False(status.Syncing)
+	assert.Equal(link2.String(), status.TargetTipSet)
+	assert.Equal(uint64(2), status.TargetHeight)
+	assert.Equal(uint64(2), status.CurrentHeight)
+	assert.Empty(status.RecentErrors)
+}
+
+// Syncer records errors of failed syncs.
+func TestSyncStatusRecordsErrors(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	syncer, _, cst, _ := initSyncTestDefault(require)
+	ctx := context.Background()
+
+	_ = requirePutBlocks(require, cst, link1.ToSlice()...)
+	_ = requirePutBlocks(require, cst, link2.ToSlice()...)
+	badCids := []cid.Cid{link1blk1.Cid(), link2blk1.Cid()}
+	require.Error(syncer.HandleNewBlocks(ctx, "", badCids))
+
+	status := syncer.Status()
+	assert.False(status.Syncing)
+	assert.Equal(uint64(0), status.CurrentHeight)
+	require.Equal(1, len(status.RecentErrors))
+	assert.Equal(types.NewSortedCidSet(badCids...).String(), status.RecentErrors[0].TargetTipSet)
+}
+
 /* particularly tricky edge cases relating to subtle Expected Consensus requirements */
 
 // Syncer is capable of recovering from a fork reorg after Load.
@@ -524,7 +585,7 @@ func TestLoadFork(t *testing.T) {
 	// Set up chain store to have standard chain up to link2
 	_ = requirePutBlocks(require, cst, link1.ToSlice()...)
 	cids2 := requirePutBlocks(require, cst, link2.ToSlice()...)
-	err := syncer.HandleNewBlocks(ctx, cids2)
+	err := syncer.HandleNewBlocks(ctx, "", cids2)
 	require.NoError(err)
 
 	// Now sync the store with a heavier fork, forking off link1.
@@ -554,7 +615,7 @@ func TestLoadFork(t *testing.T) {
 	_ = requirePutBlocks(require, cst, forklink1.ToSlice()...)
 	_ = requirePutBlocks(require, cst, forklink2.ToSlice()...)
 	forkHead := requirePutBlocks(require, cst, forklink3.ToSlice()...)
-	err = syncer.HandleNewBlocks(ctx, forkHead)
+	err = syncer.HandleNewBlocks(ctx, "", forkHead)
 	require.NoError(err)
 	requireHead(require, chain, forklink3)
 
@@ -563,7 +624,7 @@ func TestLoadFork(t *testing.T) {
 
 	// Test that the syncer can sync a block on the old chain
 	cids3 := requirePutBlocks(require, loadCst, link3.ToSlice()...)
-	err = loadSyncer.HandleNewBlocks(ctx, cids3)
+	err = loadSyncer.HandleNewBlocks(ctx, "", cids3)
 	assert.NoError(err)
 }
 
@@ -590,7 +651,7 @@ func TestSubsetParent(t *testing.T) {
 	// Set up store to have standard chain up to link2
 	_ = requirePutBlocks(require, cst, link1.ToSlice()...)
 	cids2 := requirePutBlocks(require, cst, link2.ToSlice()...)
-	err := syncer.HandleNewBlocks(ctx, cids2)
+	err := syncer.HandleNewBlocks(ctx, "", cids2)
 	require.NoError(err)
 
 	// Sync one tipset with a parent equal to a subset of an existing
@@ -602,7 +663,7 @@ func TestSubsetParent(t *testing.T) {
 		FakeChildParams{Parent: forkbase, GenesisCid: genCid, StateRoot: genStateRoot, Nonce: uint64(1)})
 	forklink := testhelpers.RequireNewTipSet(require, forkblk1, forkblk2)
 	forkHead := requirePutBlocks(require, cst, forklink.ToSlice()...)
-	err = syncer.HandleNewBlocks(ctx, forkHead)
+	err = syncer.HandleNewBlocks(ctx, "", forkHead)
 	assert.NoError(err)
 
 	// Sync another tipset with a parent equal to a subset of the tipset
@@ -611,7 +672,7 @@ func TestSubsetParent(t *testing.T) {
 	newForkblk := RequireMkFakeChild(require, FakeChildParams{Parent: newForkbase, GenesisCid: genCid, StateRoot: genStateRoot})
 	newForklink := testhelpers.RequireNewTipSet(require, newForkblk)
 	newForkHead := requirePutBlocks(require, cst, newForklink.ToSlice()...)
-	err = syncer.HandleNewBlocks(ctx, newForkHead)
+	err = syncer.HandleNewBlocks(ctx, "", newForkHead)
 	assert.NoError(err)
 }
 
@@ -635,13 +696,13 @@ func TestWidenChainAncestor(t *testing.T) {
 	intersectCids := requirePutBlocks(require, cst, link2intersect.ToSlice()...)
 
 	// Sync the subset of link2 first
-	err := syncer.HandleNewBlocks(ctx, intersectCids)
+	err := syncer.HandleNewBlocks(ctx, "", intersectCids)
 	assert.NoError(err)
 	assertTsAdded(assert, chain, link2intersect)
 	assertHead(assert, chain, link2intersect)
 
 	// Sync chain with head at link4
-	err = syncer.HandleNewBlocks(ctx, cids4)
+	err = syncer.HandleNewBlocks(ctx, "", cids4)
 	assert.NoError(err)
 	assertTsAdded(assert, chain, link4)
 	assertHead(assert, chain, link4)
@@ -737,11 +798,11 @@ func TestHeaviestIsWidenedAncestor(t *testing.T) {
 	forkhead := requirePutBlocks(require, cst, forklink3.ToSlice()...)
 
 	// Put testhead
-	err = syncer.HandleNewBlocks(ctx, testhead)
+	err = syncer.HandleNewBlocks(ctx, "", testhead)
 	assert.NoError(err)
 
 	// Put forkhead
-	err = syncer.HandleNewBlocks(ctx, forkhead)
+	err = syncer.HandleNewBlocks(ctx, "", forkhead)
 	assert.NoError(err)
 
 	// Assert that widened chain is the new head
@@ -812,7 +873,9 @@ func TestTipSetWeightDeep(t *testing.T) {
 	// Now sync the chain with consensus using a MarketView.
 	verifier = proofs.NewFakeVerifier(true, nil)
 	con = consensus.NewExpected(cst, bs, testhelpers.NewTestProcessor(), &consensus.MarketView{}, calcGenBlk.Cid(), verifier)
-	syncer := NewDefaultSyncer(cst, cst, con, chain)
+	badTipSets, err := NewBadTipSetCache(r.ChainDatastore(), DefaultBadTipSetCacheSize)
+	require.NoError(err)
+	syncer := NewDefaultSyncer(cst, cst, con, chain, badTipSets)
 	baseTS := chain.Head() // this is the last block of the bootstrapping chain creating miners
 	require.Equal(1, len(baseTS))
 	bootstrapStateRoot := baseTS.ToSlice()[0].StateRoot
@@ -856,7 +919,7 @@ func TestTipSetWeightDeep(t *testing.T) {
 
 	// Sync first tipset, should have weight 22 + starting
 	sharedCids := requirePutBlocks(require, cst, f1b1, f2b1)
-	err = syncer.HandleNewBlocks(ctx, sharedCids)
+	err = syncer.HandleNewBlocks(ctx, "", sharedCids)
 	require.NoError(err)
 	assertHead(assert, chain, tsShared)
 	measuredWeight, err := wFun(chain.Head())
@@ -879,7 +942,7 @@ func TestTipSetWeightDeep(t *testing.T) {
 
 	f1 := testhelpers.RequireNewTipSet(require, f1b2a, f1b2b)
 	f1Cids := requirePutBlocks(require, cst, f1.ToSlice()...)
-	err = syncer.HandleNewBlocks(ctx, f1Cids)
+	err = syncer.HandleNewBlocks(ctx, "", f1Cids)
 	require.NoError(err)
 	assertHead(assert, chain, f1)
 	measuredWeight, err = wFun(chain.Head())
@@ -895,7 +958,7 @@ func TestTipSetWeightDeep(t *testing.T) {
 
 	f2 := testhelpers.RequireNewTipSet(require, f2b2)
 	f2Cids := requirePutBlocks(require, cst, f2.ToSlice()...)
-	err = syncer.HandleNewBlocks(ctx, f2Cids)
+	err = syncer.HandleNewBlocks(ctx, "", f2Cids)
 	require.NoError(err)
 	assertHead(assert, chain, f2)
 	measuredWeight, err = wFun(chain.Head())
@@ -903,3 +966,22 @@ func TestTipSetWeightDeep(t *testing.T) {
 	expectedWeight = startingWeight + uint64(119000)
 	assert.Equal(expectedWeight, measuredWeight)
 }
+
+// Syncer refuses chains forking off a pruned state without caching them as bad.
+func TestSyncForkOffPrunedState(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	syncer, chain, cst, _ := initSyncTestDefault(require)
+	ctx := context.Background()
+
+	cids1 := requirePutBlocks(require, cst, link1.ToSlice()...)
+	require.NoError(syncer.HandleNewBlocks(ctx, "", cids1))
+
+	// simulate garbage collection pruning the state of link1
+	RequirePutTsas(ctx, require, chain, &TipSetAndState{TipSet: link1, TipSetStateRoot: types.SomeCid()})
+
+	cids2 := requirePutBlocks(require, cst, link2.ToSlice()...)
+	err := syncer.HandleNewBlocks(ctx, "", cids2)
+	assert.Equal(ErrParentStatePruned, err)
+	assert.False(syncer.(*DefaultSyncer).badTipSets.Has(link2.String()))
+}
@@ -0,0 +1,350 @@
+package chain
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	car "gx/ipfs/QmRa5sdhUGtLptMNYSHFWcU3axEJntpKht3LngrBpuurv1/go-car"
+	cbor "gx/ipfs/QmRoARq3nkUb13HSKZGepCZSWe5GrVPwx7xURJGZ7KWv9V/go-ipld-cbor"
+	bstore "gx/ipfs/QmS2aqUZLJp8kF1ihE5rvDGE5LvmKDPnx32w9Z1BW9xLV5/go-ipfs-blockstore"
+	dag "gx/ipfs/QmTQdH4848iTVCJmKXYyRiK72HufWTLYQQ8iN3JaQ8K1Hq/go-merkledag"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+	bserv "gx/ipfs/QmYPZzd9VqmJDwxUnThfeSbV1Y5o53aVPDijTB7j7rS9Ep/go-blockservice"
+	"gx/ipfs/QmYZwey1thDTynSrvd6qQkX24UpTka6TFhQ2v569UpoqxD/go-ipfs-exchange-offline"
+	ipld "gx/ipfs/QmcKKBwfz6FyQdHR2jsXrrF6XeSBXYL86anmWNewpFpoF5/go-ipld-format"
+
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+func init() {
+	cbor.RegisterCborType(Snapshot{})
+	cbor.RegisterCborType(SnapshotTipSet{})
+	cbor.RegisterCborType(carHeader{})
+}
+
+var (
+	// ErrSnapshotMissingHeadState is returned when importing a snapshot that
+	// does not contain the state of its head.
+	ErrSnapshotMissingHeadState = errors.New("snapshot does not contain the state of its head")
+	// ErrSnapshotWrongGenesis is returned when importing a snapshot of a
+	// chain with a different genesis block than the store.
+	ErrSnapshotWrongGenesis = errors.New("snapshot genesis does not match the chain store genesis")
+	// ErrSnapshotLighter is returned when importing a snapshot whose head is
+	// lighter than the store's head.
+	ErrSnapshotLighter = errors.New("snapshot head is lighter than the chain store head")
+)
+
+// Snapshot is the root object of an exported chain archive.  It lists the
+// exported tipsets from the head back to genesis along with the root of the
+// aggregate state after each tipset, which is not recorded in any block.
+type Snapshot struct {
+	Head    types.SortedCidSet
+	TipSets []SnapshotTipSet
+}
+
+// SnapshotTipSet records the blocks of an exported tipset and its state root.
+// HasState is true iff the state tree rooted at StateRoot is in the archive.
+type SnapshotTipSet struct {
+	Blocks    types.SortedCidSet
+	StateRoot cid.Cid
+	HasState  bool
+}
+
+// ExportOptions controls which state trees are written by Export.  Blocks,
+// and so messages and receipts, of the whole chain are always exported so
+// that the chain can be traced back to genesis.
+type ExportOptions struct {
+	// StateOnly limits the export to the state tree of the head tipset.
+	StateOnly bool
+	// RecentStates, if non zero, limits the export to the state trees of
+	// that many of the most recent tipsets.  Zero exports all state trees.
+	RecentStates uint64
+}
+
+// carHeader is the header of a CAR archive.
+type carHeader struct {
+	Roots   []cid.Cid `refmt:"roots"`
+	Version uint64    `refmt:"version"`
+}
+
+// Export writes a CAR archive of the chain ending in head to w.  The single
+// root of the archive is a Snapshot.
+func Export(ctx context.Context, store ReadStore, bs bstore.Blockstore, head types.TipSet, opts ExportOptions, w io.Writer) error {
+	var tipsets []types.TipSet
+	for raw := range store.BlockHistory(ctx, head) {
+		switch v := raw.(type) {
+		case error:
+			return errors.Wrap(v, "failed to walk chain")
+		case types.TipSet:
+			tipsets = append(tipsets, v)
+		}
+	}
+
+	snap := Snapshot{Head: head.ToSortedCidSet()}
+	for i, ts := range tipsets {
+		tsas, err := store.GetTipSetAndState(ctx, ts.String())
+		if err != nil {
+			return errors.Wrapf(err, "failed to get state of tipset %s", ts.String())
+		}
+		hasState := opts.includesState(i)
+		if hasState && i > 0 {
+			// Leave out old states removed by garbage collection.
+			if hasState, err = bs.Has(tsas.TipSetStateRoot); err != nil {
+				return err
+			}
+		}
+		snap.TipSets = append(snap.TipSets, SnapshotTipSet{
+			Blocks:    ts.ToSortedCidSet(),
+			StateRoot: tsas.TipSetStateRoot,
+			HasState:  hasState,
+		})
+	}
+
+	snapNode, err := cbor.WrapObject(snap, types.DefaultHashFunction, -1)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode snapshot")
+	}
+
+	cw := &carWriter{
+		w:     w,
+		bs:    bs,
+		dserv: dag.NewDAGService(bserv.New(bs, offline.Exchange(bs))),
+		seen:  cid.NewSet(),
+	}
+	if err := cw.writeHeader(snapNode.Cid()); err != nil {
+		return err
+	}
+	if err := cw.writeNode(snapNode); err != nil {
+		return err
+	}
+	for i, ts := range tipsets {
+		for _, blk := range ts.ToSlice() {
+			if err := cw.writeNode(blk.ToNode()); err != nil {
+				return err
+			}
+		}
+		if snap.TipSets[i].HasState {
+			if err := cw.writeDAG(ctx, snap.TipSets[i].StateRoot); err != nil {
+				return errors.Wrapf(err, "failed to export state of tipset %s", ts.String())
+			}
+		}
+	}
+	return nil
+}
+
+// includesState returns true if the state of the tipset at distance i from
+// the head should be exported.
+func (opts ExportOptions) includesState(i int) bool {
+	if opts.StateOnly {
+		return i == 0
+	}
+	return opts.RecentStates == 0 || uint64(i) < opts.RecentStates
+}
+
+// Import loads a CAR archive written by Export into bs and adds its tipsets
+// to the store, setting the store's head to the snapshot's head.  The
+// snapshot must trace back to the store's genesis block, contain the state
+// of its head and not be lighter than the store's head, comparing the parent
+// weights and then the heights recorded in the head blocks.  Import trusts
+// the state transitions and weights recorded in the snapshot.
+func Import(ctx context.Context, store Store, bs bstore.Blockstore, r io.Reader) (types.TipSet, error) {
+	ch, err := car.LoadCar(bs, r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load car")
+	}
+	if len(ch.Roots) != 1 {
+		return nil, errors.New("expected car with only a single root")
+	}
+
+	var snap Snapshot
+	if err := getCbor(bs, ch.Roots[0], &snap); err != nil {
+		return nil, errors.Wrap(err, "failed to decode snapshot")
+	}
+	if len(snap.TipSets) == 0 {
+		return nil, errors.New("snapshot contains no tipsets")
+	}
+	if !snap.TipSets[0].Blocks.Equals(snap.Head) {
+		return nil, errors.New("snapshot head is not its first tipset")
+	}
+	if !snap.TipSets[0].HasState {
+		return nil, ErrSnapshotMissingHeadState
+	}
+
+	snapHead, err := loadSnapshotTipSet(bs, snap.Head)
+	if err != nil {
+		return nil, err
+	}
+	if head := store.Head(); head != nil {
+		lighter, err := isLighter(snapHead, head)
+		if err != nil {
+			return nil, err
+		}
+		if lighter {
+			return nil, ErrSnapshotLighter
+		}
+	}
+
+	// Check and add tipsets in order from genesis to head so that every
+	// tipset's parent is known when it is added.
+	var parent types.TipSet
+	for i := len(snap.TipSets) - 1; i >= 0; i-- {
+		entry := snap.TipSets[i]
+		ts, err := loadSnapshotTipSet(bs, entry.Blocks)
+		if err != nil {
+			return nil, err
+		}
+
+		pSet, err := ts.Parents()
+		if err != nil {
+			return nil, err
+		}
+		if parent == nil {
+			if len(ts) != 1 || !ts.ToSlice()[0].Cid().Equals(store.GenesisCid()) {
+				return nil, ErrSnapshotWrongGenesis
+			}
+		} else if !pSet.Equals(parent.ToSortedCidSet()) {
+			return nil, errors.Errorf("tipset %s does not link to its parent %s", ts.String(), parent.String())
+		}
+
+		if entry.HasState {
+			has, err := bs.Has(entry.StateRoot)
+			if err != nil {
+				return nil, err
+			}
+			if !has {
+				return nil, errors.Errorf("snapshot is missing state root %s of tipset %s", entry.StateRoot, ts.String())
+			}
+		}
+
+		if err := store.PutTipSetAndState(ctx, &TipSetAndState{
+			TipSet:          ts,
+			TipSetStateRoot: entry.StateRoot,
+		}); err != nil {
+			return nil, errors.Wrapf(err, "failed to put tipset %s", ts.String())
+		}
+		parent = ts
+	}
+
+	if err := store.SetHead(ctx, parent); err != nil {
+		return nil, errors.Wrap(err, "failed to set head")
+	}
+	return parent, nil
+}
+
+// isLighter returns true if a has a lower parent weight than b, or the same
+// parent weight and a lower height.
+func isLighter(a, b types.TipSet) (bool, error) {
+	aW, err := a.ParentWeight()
+	if err != nil {
+		return false, err
+	}
+	bW, err := b.ParentWeight()
+	if err != nil {
+		return false, err
+	}
+	if aW != bW {
+		return aW < bW, nil
+	}
+	aH, err := a.Height()
+	if err != nil {
+		return false, err
+	}
+	bH, err := b.Height()
+	if err != nil {
+		return false, err
+	}
+	return aH < bH, nil
+}
+
+func loadSnapshotTipSet(bs bstore.Blockstore, blkCids types.SortedCidSet) (types.TipSet, error) {
+	var blks []*types.Block
+	for it := blkCids.Iter(); !it.Complete(); it.Next() {
+		var blk types.Block
+		if err := getCbor(bs, it.Value(), &blk); err != nil {
+			return nil, errors.Wrapf(err, "failed to load block %s", it.Value())
+		}
+		blks = append(blks, &blk)
+	}
+	return types.NewTipSet(blks...)
+}
+
+func getCbor(bs bstore.Blockstore, c cid.Cid, out interface{}) error {
+	blk, err := bs.Get(c)
+	if err != nil {
+		return err
+	}
+	return cbor.DecodeInto(blk.RawData(), out)
+}
+
+// carWriter writes nodes to a CAR archive, skipping nodes already written.
+type carWriter struct {
+	w     io.Writer
+	bs    bstore.Blockstore
+	dserv ipld.DAGService
+	seen  *cid.Set
+}
+
+func (cw *carWriter) writeHeader(root cid.Cid) error {
+	hb, err := cbor.DumpObject(&carHeader{Roots: []cid.Cid{root}, Version: 1})
+	if err != nil {
+		return errors.Wrap(err, "failed to encode car header")
+	}
+	return cw.ldWrite(hb)
+}
+
+// writeDAG writes all nodes reachable from root that are in the blockstore.
+// Like walkStoredDAG, it only follows cbor nodes: the links to the code of
+// the builtin actors are raw nodes no blockstore holds.
+func (cw *carWriter) writeDAG(ctx context.Context, root cid.Cid) error {
+	if root.Type() != cid.DagCBOR || cw.seen.Has(root) {
+		return nil
+	}
+	has, err := cw.bs.Has(root)
+	if err != nil {
+		return err
+	}
+	if !has {
+		return nil
+	}
+	nd, err := cw.dserv.Get(ctx, root)
+	if err != nil {
+		return err
+	}
+	if err := cw.writeNode(nd); err != nil {
+		return err
+	}
+	for _, l := range nd.Links() {
+		if err := cw.writeDAG(ctx, l.Cid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cw *carWriter) writeNode(nd ipld.Node) error {
+	if !cw.seen.Visit(nd.Cid()) {
+		return nil
+	}
+	return cw.ldWrite(nd.Cid().Bytes(), nd.RawData())
+}
+
+// ldWrite writes the concatenation of data prefixed by its varint length.
+func (cw *carWriter) ldWrite(data ...[]byte) error {
+	var size int
+	for _, d := range data {
+		size += len(d)
+	}
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, uint64(size))
+	if _, err := cw.w.Write(buf[:n]); err != nil {
+		return err
+	}
+	for _, d := range data {
+		if _, err := cw.w.Write(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
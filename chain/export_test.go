@@ -0,0 +1,216 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	"gx/ipfs/QmRXf2uUSdGSunRJsM9wXSUNVwLUGCY3So5fAs7h2CBJVf/go-hamt-ipld"
+	bstore "gx/ipfs/QmS2aqUZLJp8kF1ihE5rvDGE5LvmKDPnx32w9Z1BW9xLV5/go-ipfs-blockstore"
+	bserv "gx/ipfs/QmYPZzd9VqmJDwxUnThfeSbV1Y5o53aVPDijTB7j7rS9Ep/go-blockservice"
+	"gx/ipfs/QmYZwey1thDTynSrvd6qQkX24UpTka6TFhQ2v569UpoqxD/go-ipfs-exchange-offline"
+
+	"github.com/filecoin-project/go-filecoin/actor/builtin"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/filecoin-project/go-filecoin/repo"
+	"github.com/filecoin-project/go-filecoin/state"
+	"github.com/filecoin-project/go-filecoin/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type exportTestRepo struct {
+	store *DefaultStore
+	bs    bstore.Blockstore
+	cst   *hamt.CborIpldStore
+	r     repo.Repo
+}
+
+func newExportTestRepo(genesisCid cid.Cid) *exportTestRepo {
+	r := repo.NewInMemoryRepo()
+	bs := bstore.NewBlockstore(r.Datastore())
+	cst := &hamt.CborIpldStore{Blocks: bserv.New(bs, offline.Exchange(bs))}
+	return &exportTestRepo{
+		store: NewDefaultStore(r.ChainDatastore(), cst, genesisCid),
+		bs:    bs,
+		cst:   cst,
+		r:     r,
+	}
+}
+
+// requireExportTestChain creates a store holding a genesis block followed by
+// n tipsets, each with its own state root.  It returns the store and the
+// tipsets from genesis to head.
+func requireExportTestChain(ctx context.Context, require *require.Assertions, n int) (*exportTestRepo, []types.TipSet) {
+	r := repo.NewInMemoryRepo()
+	bs := bstore.NewBlockstore(r.Datastore())
+	cst := &hamt.CborIpldStore{Blocks: bserv.New(bs, offline.Exchange(bs))}
+	genesis, err := consensus.InitGenesis(cst, bs)
+	require.NoError(err)
+
+	tr := &exportTestRepo{
+		store: NewDefaultStore(r.ChainDatastore(), cst, genesis.Cid()),
+		bs:    bs,
+		cst:   cst,
+		r:     r,
+	}
+
+	genTS := MustNewTipSet(genesis)
+	RequirePutTsas(ctx, require, tr.store, &TipSetAndState{TipSet: genTS, TipSetStateRoot: genesis.StateRoot})
+	tipsets := []types.TipSet{genTS}
+	for i := 0; i < n; i++ {
+		stateRoot, err := cst.Put(ctx, fmt.Sprintf("state %d", i))
+		require.NoError(err)
+		blk := RequireMkFakeChild(require, FakeChildParams{
+			GenesisCid: genesis.Cid(),
+			Parent:     tipsets[len(tipsets)-1],
+			StateRoot:  stateRoot,
+		})
+		ts := MustNewTipSet(blk)
+		RequirePutTsas(ctx, require, tr.store, &TipSetAndState{TipSet: ts, TipSetStateRoot: stateRoot})
+		tipsets = append(tipsets, ts)
+	}
+	require.NoError(tr.store.SetHead(ctx, tipsets[len(tipsets)-1]))
+	return tr, tipsets
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+
+	src, tipsets := requireExportTestChain(ctx, require, 3)
+	head := tipsets[len(tipsets)-1]
+
+	var buf bytes.Buffer
+	require.NoError(Export(ctx, src.store, src.bs, head, ExportOptions{}, &buf))
+
+	dst := newExportTestRepo(src.store.GenesisCid())
+	imported, err := Import(ctx, dst.store, dst.bs, &buf)
+	require.NoError(err)
+	assert.True(head.Equals(imported))
+	assert.True(head.Equals(dst.store.Head()))
+
+	for _, ts := range tipsets {
+		want := requireGetTsas(ctx, require, src.store, ts.String())
+		got := requireGetTsas(ctx, require, dst.store, ts.String())
+		assert.Equal(want.TipSetStateRoot, got.TipSetStateRoot)
+		has, err := dst.bs.Has(got.TipSetStateRoot)
+		require.NoError(err)
+		assert.True(has)
+	}
+
+	// The imported chain survives a restart.
+	reloaded := NewDefaultStore(dst.r.ChainDatastore(), dst.cst, src.store.GenesisCid())
+	require.NoError(reloaded.Load(ctx))
+	assert.True(head.Equals(reloaded.Head()))
+}
+
+func TestExportRecentStates(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+
+	src, tipsets := requireExportTestChain(ctx, require, 3)
+	head := tipsets[len(tipsets)-1]
+
+	var buf bytes.Buffer
+	require.NoError(Export(ctx, src.store, src.bs, head, ExportOptions{RecentStates: 2}, &buf))
+
+	dst := newExportTestRepo(src.store.GenesisCid())
+	_, err := Import(ctx, dst.store, dst.bs, &buf)
+	require.NoError(err)
+
+	for i, ts := range tipsets {
+		tsas := requireGetTsas(ctx, require, dst.store, ts.String())
+		has, err := dst.bs.Has(tsas.TipSetStateRoot)
+		require.NoError(err)
+		assert.Equal(i >= len(tipsets)-2, has, "tipset at height %d", i)
+	}
+}
+
+func TestExportStateOnly(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+
+	src, tipsets := requireExportTestChain(ctx, require, 2)
+	// Export from the middle of the chain.
+	exported := tipsets[1]
+
+	var buf bytes.Buffer
+	require.NoError(Export(ctx, src.store, src.bs, exported, ExportOptions{StateOnly: true}, &buf))
+
+	dst := newExportTestRepo(src.store.GenesisCid())
+	imported, err := Import(ctx, dst.store, dst.bs, &buf)
+	require.NoError(err)
+	assert.True(exported.Equals(imported))
+
+	genTsas := requireGetTsas(ctx, require, dst.store, tipsets[0].String())
+	has, err := dst.bs.Has(genTsas.TipSetStateRoot)
+	require.NoError(err)
+	assert.False(has)
+
+	_, err = dst.store.GetTipSetAndState(ctx, tipsets[2].String())
+	assert.Error(err)
+}
+
+func TestExportGenesisState(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+
+	// the genesis state holds builtin actors, whose code is not in the
+	// blockstore
+	src, tipsets := requireExportTestChain(ctx, require, 0)
+	genesis := tipsets[0]
+
+	var buf bytes.Buffer
+	require.NoError(Export(ctx, src.store, src.bs, genesis, ExportOptions{}, &buf))
+
+	dst := newExportTestRepo(src.store.GenesisCid())
+	_, err := Import(ctx, dst.store, dst.bs, &buf)
+	require.NoError(err)
+
+	tsas := requireGetTsas(ctx, require, dst.store, genesis.String())
+	st, err := state.LoadStateTree(ctx, dst.cst, tsas.TipSetStateRoot, builtin.Actors)
+	require.NoError(err)
+	act, err := st.GetActor(ctx, address.StorageMarketAddress)
+	require.NoError(err)
+	assert.True(act.Code.Equals(types.StorageMarketActorCodeCid))
+}
+
+func TestImportWrongGenesis(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+
+	src, tipsets := requireExportTestChain(ctx, require, 1)
+
+	var buf bytes.Buffer
+	require.NoError(Export(ctx, src.store, src.bs, tipsets[1], ExportOptions{}, &buf))
+
+	dst := newExportTestRepo(types.SomeCid())
+	_, err := Import(ctx, dst.store, dst.bs, &buf)
+	assert.Equal(ErrSnapshotWrongGenesis, err)
+}
+
+func TestImportRefusesLighterChain(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+
+	src, tipsets := requireExportTestChain(ctx, require, 3)
+
+	var buf bytes.Buffer
+	require.NoError(Export(ctx, src.store, src.bs, tipsets[1], ExportOptions{}, &buf))
+
+	// src's head is two tipsets ahead of the snapshot
+	_, err := Import(ctx, src.store, src.bs, &buf)
+	assert.Equal(ErrSnapshotLighter, err)
+	assert.True(tipsets[3].Equals(src.store.Head()))
+}
@@ -0,0 +1,281 @@
+package chain
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	bstore "gx/ipfs/QmS2aqUZLJp8kF1ihE5rvDGE5LvmKDPnx32w9Z1BW9xLV5/go-ipfs-blockstore"
+	dag "gx/ipfs/QmTQdH4848iTVCJmKXYyRiK72HufWTLYQQ8iN3JaQ8K1Hq/go-merkledag"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+	bserv "gx/ipfs/QmYPZzd9VqmJDwxUnThfeSbV1Y5o53aVPDijTB7j7rS9Ep/go-blockservice"
+	"gx/ipfs/QmYZwey1thDTynSrvd6qQkX24UpTka6TFhQ2v569UpoqxD/go-ipfs-exchange-offline"
+	ipld "gx/ipfs/QmcKKBwfz6FyQdHR2jsXrrF6XeSBXYL86anmWNewpFpoF5/go-ipld-format"
+	"gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore"
+	"gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore/query"
+
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// ErrPruningDisabled is returned when collecting garbage without a number of
+// recent states to keep.
+var ErrPruningDisabled = errors.New("state pruning is disabled, the number of recent states to keep must be greater than zero")
+
+// tipSetStatePrefix is the datastore prefix of the keys mapping tipsets to
+// their state roots, see makeKey.
+const tipSetStatePrefix = "/p-"
+
+// GCOptions controls which states are kept by garbage collection.
+type GCOptions struct {
+	// KeepRecent is the number of most recent tipsets of the chain whose
+	// state is kept.  It must be greater than zero.
+	KeepRecent uint64
+	// CheckpointInterval, if non zero, keeps the state of every tipset of the
+	// chain whose height is a multiple of it.
+	CheckpointInterval uint64
+}
+
+// GCResult summarizes the work done by a garbage collection.
+type GCResult struct {
+	// PrunedStates is the number of tipset states removed.
+	PrunedStates int
+	// RemovedTipSets is the number of old tipsets, not on the chain, removed.
+	RemovedTipSets int
+	// RemovedNodes is the number of state nodes removed from the blockstore.
+	RemovedNodes int
+}
+
+// keepsState returns true if the state of the tipset at distance i from the
+// head and height h should be kept.  The genesis state is always kept.
+func (opts GCOptions) keepsState(i int, h uint64) bool {
+	if uint64(i) < opts.KeepRecent || h == 0 {
+		return true
+	}
+	return opts.CheckpointInterval != 0 && h%opts.CheckpointInterval == 0
+}
+
+// CollectGarbage prunes the state kept in bs and the store.  It keeps the
+// blocks of the chain ending in the head and the state of the tipsets selected
+// by opts.  The state trees of other tipsets of the chain are removed from bs,
+// except for nodes shared with a kept state.  Tipsets not on the chain and
+// older than the oldest kept recent state are removed from the store along
+// with their blocks and state.
+//
+// Only nodes reachable from the state root of a tipset are considered for
+// removal, so intermediate states never recorded in the store are not
+// collected.  Callers must ensure no state is being written to bs while
+// collecting garbage.
+func (store *DefaultStore) CollectGarbage(ctx context.Context, bs bstore.Blockstore, opts GCOptions) (GCResult, error) {
+	var result GCResult
+	if opts.KeepRecent == 0 {
+		return result, ErrPruningDisabled
+	}
+
+	chainBlocks := cid.NewSet()
+	chainTipSets := make(map[string]struct{})
+	var liveRoots, deadRoots []cid.Cid
+	var cutoff uint64
+	i := 0
+	for raw := range store.BlockHistory(ctx, store.Head()) {
+		var ts types.TipSet
+		switch v := raw.(type) {
+		case error:
+			return result, errors.Wrap(v, "failed to walk chain")
+		case types.TipSet:
+			ts = v
+		}
+		h, err := ts.Height()
+		if err != nil {
+			return result, err
+		}
+		tsas, err := store.GetTipSetAndState(ctx, ts.String())
+		if err != nil {
+			return result, errors.Wrapf(err, "failed to get state of tipset %s", ts.String())
+		}
+
+		for _, blk := range ts.ToSlice() {
+			chainBlocks.Add(blk.Cid())
+		}
+		chainTipSets[ts.String()] = struct{}{}
+		if uint64(i) < opts.KeepRecent {
+			cutoff = h
+		}
+		if opts.keepsState(i, h) {
+			liveRoots = append(liveRoots, tsas.TipSetStateRoot)
+		} else {
+			deadRoots = append(deadRoots, tsas.TipSetStateRoot)
+		}
+		i++
+	}
+
+	forkRoots, err := store.removeOldForks(bs, chainTipSets, chainBlocks, cutoff)
+	if err != nil {
+		return result, err
+	}
+	result.RemovedTipSets = len(forkRoots)
+	deadRoots = append(deadRoots, forkRoots...)
+
+	dserv := dag.NewDAGService(bserv.New(bs, offline.Exchange(bs)))
+	live := cid.NewSet()
+	for _, root := range liveRoots {
+		if err := walkStoredDAG(ctx, dserv, bs, root, live); err != nil {
+			return result, errors.Wrapf(err, "failed to mark state %s", root)
+		}
+	}
+
+	// Walk the states being pruned, stopping at nodes shared with a kept
+	// state, then delete everything visited that is not live.
+	visited := cid.NewSet()
+	live.ForEach(func(c cid.Cid) error { // nolint: errcheck
+		visited.Add(c)
+		return nil
+	})
+	for _, root := range deadRoots {
+		if visited.Has(root) {
+			continue
+		}
+		has, err := bs.Has(root)
+		if err != nil {
+			return result, err
+		}
+		if !has {
+			// already pruned
+			continue
+		}
+		if err := walkStoredDAG(ctx, dserv, bs, root, visited); err != nil {
+			return result, errors.Wrapf(err, "failed to walk state %s", root)
+		}
+		result.PrunedStates++
+	}
+	err = visited.ForEach(func(c cid.Cid) error {
+		if live.Has(c) {
+			return nil
+		}
+		result.RemovedNodes++
+		return bs.DeleteBlock(c)
+	})
+	if err != nil {
+		return result, errors.Wrap(err, "failed to delete state node")
+	}
+
+	logStore.Infof("garbage collection pruned %d states and %d old tipsets, removing %d nodes", result.PrunedStates, result.RemovedTipSets, result.RemovedNodes)
+	return result, nil
+}
+
+// removeOldForks removes the tipsets below height cutoff that are not on the
+// chain from the store.  Blocks not on the chain are removed from the store's
+// blockstore and from bs.  It returns the state roots of the removed tipsets.
+func (store *DefaultStore) removeOldForks(bs bstore.Blockstore, chainTipSets map[string]struct{}, chainBlocks *cid.Set, cutoff uint64) ([]cid.Cid, error) {
+	res, err := store.ds.Query(query.Query{
+		Prefix: tipSetStatePrefix,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query tipsets from datastore")
+	}
+
+	// Collect the entries before deleting any so that the query is not
+	// iterated while it is being modified.
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read tipsets from datastore")
+	}
+
+	var stateRoots []cid.Cid
+	for _, entry := range entries {
+		tsKey, h, err := parseTipSetStateKey(entry.Key)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := chainTipSets[tsKey]; ok || h >= cutoff {
+			continue
+		}
+
+		blkCids, err := parseTipSetKey(tsKey)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range blkCids {
+			if chainBlocks.Has(c) {
+				continue
+			}
+			if err := store.blockstore.DeleteBlock(c); err != nil && err != bstore.ErrNotFound {
+				return nil, errors.Wrapf(err, "failed to delete block %s", c)
+			}
+			if err := bs.DeleteBlock(c); err != nil && err != bstore.ErrNotFound {
+				return nil, errors.Wrapf(err, "failed to delete block %s", c)
+			}
+		}
+
+		var stateRoot cid.Cid
+		if err := json.Unmarshal(entry.Value, &stateRoot); err != nil {
+			return nil, errors.Wrapf(err, "failed to cast state root of tipset %s", tsKey)
+		}
+		if err := store.ds.Delete(datastore.NewKey(entry.Key)); err != nil {
+			return nil, errors.Wrapf(err, "failed to delete tipset %s", tsKey)
+		}
+		if err := store.ds.Delete(receiptsKey(tsKey)); err != nil && err != datastore.ErrNotFound {
+			return nil, errors.Wrapf(err, "failed to delete receipts of tipset %s", tsKey)
+		}
+		store.tipIndex.Remove(tsKey)
+		stateRoots = append(stateRoots, stateRoot)
+	}
+	return stateRoots, nil
+}
+
+// walkStoredDAG adds root and all the cbor nodes reachable from it that are in
+// bs to visited.  Nodes already in visited are not walked again.  Other nodes,
+// such as actor code objects, are never collected.
+func walkStoredDAG(ctx context.Context, dserv ipld.DAGService, bs bstore.Blockstore, root cid.Cid, visited *cid.Set) error {
+	if root.Type() != cid.DagCBOR || visited.Has(root) {
+		return nil
+	}
+	has, err := bs.Has(root)
+	if err != nil {
+		return err
+	}
+	if !has {
+		return nil
+	}
+	visited.Add(root)
+
+	nd, err := dserv.Get(ctx, root)
+	if err != nil {
+		return err
+	}
+	for _, l := range nd.Links() {
+		if err := walkStoredDAG(ctx, dserv, bs, l.Cid, visited); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseTipSetStateKey returns the tipset key and height encoded in a
+// datastore key written by writeTipSetAndState.
+func parseTipSetStateKey(key string) (string, uint64, error) {
+	idx := strings.LastIndex(key, " h-")
+	if !strings.HasPrefix(key, tipSetStatePrefix) || idx < 0 {
+		return "", 0, errors.Errorf("malformed tipset state key %s", key)
+	}
+	h, err := strconv.ParseUint(key[idx+len(" h-"):], 10, 64)
+	if err != nil {
+		return "", 0, errors.Wrapf(err, "malformed tipset state key %s", key)
+	}
+	return key[len(tipSetStatePrefix):idx], h, nil
+}
+
+// parseTipSetKey returns the block cids of a tipset key as produced by
+// TipSet.String.
+func parseTipSetKey(tsKey string) ([]cid.Cid, error) {
+	var out []cid.Cid
+	for _, s := range strings.Fields(strings.Trim(tsKey, "{}")) {
+		c, err := cid.Decode(s)
+		if err != nil {
+			return nil, errors.Wrapf(err, "malformed tipset key %s", tsKey)
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
@@ -0,0 +1,86 @@
+package chain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectGarbagePrunesOldStates(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+
+	tr, tipsets := requireExportTestChain(ctx, require, 5)
+
+	res, err := tr.store.CollectGarbage(ctx, tr.bs, GCOptions{KeepRecent: 2, CheckpointInterval: 2})
+	require.NoError(err)
+	assert.Equal(2, res.PrunedStates)
+	assert.Equal(2, res.RemovedNodes)
+	assert.Equal(0, res.RemovedTipSets)
+
+	// genesis, the checkpoint at height 2 and the two most recent states are kept
+	expectKept := []bool{true, false, true, false, true, true}
+	for h, ts := range tipsets {
+		tsas := requireGetTsas(ctx, require, tr.store, ts.String())
+		has, err := tr.bs.Has(tsas.TipSetStateRoot)
+		require.NoError(err)
+		assert.Equal(expectKept[h], has, "state at height %d", h)
+	}
+
+	// Collecting again has nothing to do and the chain still loads.
+	res, err = tr.store.CollectGarbage(ctx, tr.bs, GCOptions{KeepRecent: 2, CheckpointInterval: 2})
+	require.NoError(err)
+	assert.Equal(GCResult{}, res)
+
+	reloaded := NewDefaultStore(tr.r.ChainDatastore(), tr.cst, tr.store.GenesisCid())
+	require.NoError(reloaded.Load(ctx))
+	assert.True(tipsets[5].Equals(reloaded.Head()))
+}
+
+func TestCollectGarbageRemovesOldForks(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+
+	tr, tipsets := requireExportTestChain(ctx, require, 4)
+
+	forkState, err := tr.cst.Put(ctx, "fork state")
+	require.NoError(err)
+	forkBlk := RequireMkFakeChild(require, FakeChildParams{
+		GenesisCid: tr.store.GenesisCid(),
+		Parent:     tipsets[0],
+		StateRoot:  forkState,
+		Nonce:      1,
+	})
+	fork := MustNewTipSet(forkBlk)
+	RequirePutTsas(ctx, require, tr.store, &TipSetAndState{TipSet: fork, TipSetStateRoot: forkState})
+
+	res, err := tr.store.CollectGarbage(ctx, tr.bs, GCOptions{KeepRecent: 2})
+	require.NoError(err)
+	assert.Equal(1, res.RemovedTipSets)
+	assert.Equal(3, res.PrunedStates)
+
+	assert.False(tr.store.HasTipSetAndState(ctx, fork.String()))
+	assert.False(tr.store.HasBlock(ctx, forkBlk.Cid()))
+	has, err := tr.bs.Has(forkState)
+	require.NoError(err)
+	assert.False(has)
+
+	// blocks of the chain are kept
+	for _, ts := range tipsets {
+		assert.True(tr.store.HasTipSetAndState(ctx, ts.String()))
+		assert.True(tr.store.HasBlock(ctx, ts.ToSlice()[0].Cid()))
+	}
+}
+
+func TestCollectGarbageRequiresRecentStates(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	tr, _ := requireExportTestChain(ctx, require, 1)
+	_, err := tr.store.CollectGarbage(ctx, tr.bs, GCOptions{})
+	require.Equal(ErrPruningDisabled, err)
+}
@@ -0,0 +1,186 @@
+package chain
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+	"gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore"
+
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// heightIndexPrefix is the datastore namespace of the index from heights to
+// the tipsets of the chain ending in the head.
+const heightIndexPrefix = "/chain/height"
+
+// GetTipSetByHeight returns the tipset at height h of the chain ending in the
+// head.  If no tipset was mined at height h, i.e. h is a null round, the
+// closest tipset below h is returned.
+func (store *DefaultStore) GetTipSetByHeight(ctx context.Context, h uint64) (types.TipSet, error) {
+	// Hold the read lock so that the index is not read while a change of
+	// head rewrites it.
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	headHeight, err := store.head.Height()
+	if err != nil {
+		return nil, err
+	}
+	if h > headHeight {
+		return nil, errors.Errorf("height %d is above the head at height %d", h, headHeight)
+	}
+
+	for {
+		blkCids, err := store.readHeightIndex(h)
+		if err == datastore.ErrNotFound && h > 0 {
+			h--
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read height index at height %d", h)
+		}
+
+		tsas, err := store.GetTipSetAndState(ctx, blkCids.String())
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get tipset %s", blkCids.String())
+		}
+		return tsas.TipSet, nil
+	}
+}
+
+// updateHeightIndex rewrites the height index for a change of head.  Entries
+// of the old chain above the new head are removed, then the new chain is
+// indexed from the head back to the first tipset that is already indexed, or
+// to the first tipset whose parent is not in the store.  The message index is
+// updated along with it.
+// Precondition: the caller holds the store's lock.
+func (store *DefaultStore) updateHeightIndex(ctx context.Context, oldHead, newHead types.TipSet) (retErr error) {
+	if len(newHead) == 0 {
+		return nil
+	}
+	newHeight, err := newHead.Height()
+	if err != nil {
+		return err
+	}
+	if len(oldHead) > 0 {
+		oldHeight, err := oldHead.Height()
+		if err != nil {
+			return err
+		}
+		for h := newHeight + 1; h <= oldHeight; h++ {
+			if err := store.deleteHeightIndex(ctx, h); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Messages are indexed once the height index is up to date, from the
+	// lowest new tipset up, so that the lowest inclusion of a message wins.
+	var toIndex []types.TipSet
+	var toIndexHeights []uint64
+	defer func() {
+		for i := len(toIndex) - 1; i >= 0; i-- {
+			if err := store.indexTipSetMessages(toIndex[i], toIndexHeights[i]); err != nil && retErr == nil {
+				retErr = err
+			}
+		}
+	}()
+
+	ts := newHead
+	above := newHeight + 1
+	for {
+		h, err := ts.Height()
+		if err != nil {
+			return err
+		}
+		// Heights between a tipset and its child are null rounds.
+		for n := h + 1; n < above; n++ {
+			if err := store.deleteHeightIndex(ctx, n); err != nil {
+				return err
+			}
+		}
+
+		indexed, err := store.readHeightIndex(h)
+		if err == nil && indexed.Equals(ts.ToSortedCidSet()) {
+			return nil
+		}
+		if err != nil && err != datastore.ErrNotFound {
+			return errors.Wrapf(err, "failed to read height index at height %d", h)
+		}
+		if err == nil {
+			if err := store.unindexTipSetMessages(ctx, indexed); err != nil {
+				return err
+			}
+		}
+		if err := store.writeHeightIndex(h, ts.ToSortedCidSet()); err != nil {
+			return err
+		}
+		toIndex = append(toIndex, ts)
+		toIndexHeights = append(toIndexHeights, h)
+		above = h
+
+		pSet, err := ts.Parents()
+		if err != nil {
+			return err
+		}
+		if pSet.Empty() {
+			return nil
+		}
+		tsas, err := store.GetTipSetAndState(ctx, pSet.String())
+		if err != nil {
+			// The store does not require the ancestors of the head to be
+			// known, in which case the index stops here.
+			logStore.Warningf("height index stops at height %d, parent tipset %s is not in the store", h, pSet.String())
+			return nil
+		}
+		ts = tsas.TipSet
+	}
+}
+
+func (store *DefaultStore) readHeightIndex(h uint64) (types.SortedCidSet, error) {
+	var blkCids types.SortedCidSet
+	bb, err := store.ds.Get(heightIndexKey(h))
+	if err != nil {
+		return blkCids, err
+	}
+	if err := json.Unmarshal(bb, &blkCids); err != nil {
+		return blkCids, errors.Wrapf(err, "failed to cast height index at height %d", h)
+	}
+	return blkCids, nil
+}
+
+func (store *DefaultStore) writeHeightIndex(h uint64, blkCids types.SortedCidSet) error {
+	val, err := json.Marshal(blkCids)
+	if err != nil {
+		return err
+	}
+	if err := store.ds.Put(heightIndexKey(h), val); err != nil {
+		return errors.Wrapf(err, "failed to write height index at height %d", h)
+	}
+	return nil
+}
+
+// deleteHeightIndex removes the entry at height h of the height index and the
+// messages of its tipset from the message index.
+func (store *DefaultStore) deleteHeightIndex(ctx context.Context, h uint64) error {
+	indexed, err := store.readHeightIndex(h)
+	if err == datastore.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to read height index at height %d", h)
+	}
+	if err := store.unindexTipSetMessages(ctx, indexed); err != nil {
+		return err
+	}
+	if err := store.ds.Delete(heightIndexKey(h)); err != nil && err != datastore.ErrNotFound {
+		return errors.Wrapf(err, "failed to delete height index at height %d", h)
+	}
+	return nil
+}
+
+func heightIndexKey(h uint64) datastore.Key {
+	return datastore.NewKey(heightIndexPrefix).ChildString(strconv.FormatUint(h, 10))
+}
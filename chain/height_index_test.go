@@ -0,0 +1,65 @@
+package chain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-filecoin/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTipSetByHeight(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+
+	tr, tipsets := requireExportTestChain(ctx, require, 3)
+
+	for h, ts := range tipsets {
+		got, err := tr.store.GetTipSetByHeight(ctx, uint64(h))
+		require.NoError(err)
+		assert.True(ts.Equals(got), "tipset at height %d", h)
+	}
+
+	_, err := tr.store.GetTipSetByHeight(ctx, uint64(len(tipsets)))
+	assert.Error(err)
+}
+
+func TestGetTipSetByHeightAfterReorg(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+
+	tr, tipsets := requireExportTestChain(ctx, require, 3)
+
+	// Reorg to a shorter chain with a null round at height 1.
+	forkBlk := RequireMkFakeChild(require, FakeChildParams{
+		GenesisCid:     tr.store.GenesisCid(),
+		Parent:         tipsets[0],
+		StateRoot:      tipsets[0].ToSlice()[0].StateRoot,
+		Nonce:          1,
+		NullBlockCount: 1,
+	})
+	fork := MustNewTipSet(forkBlk)
+	RequirePutTsas(ctx, require, tr.store, &TipSetAndState{TipSet: fork, TipSetStateRoot: forkBlk.StateRoot})
+	require.NoError(tr.store.SetHead(ctx, fork))
+
+	requireTipSetAtHeight := func(store *DefaultStore, h uint64, expected types.TipSet) {
+		got, err := store.GetTipSetByHeight(ctx, h)
+		require.NoError(err)
+		assert.True(expected.Equals(got), "tipset at height %d", h)
+	}
+	requireTipSetAtHeight(tr.store, 2, fork)
+	requireTipSetAtHeight(tr.store, 1, tipsets[0])
+	requireTipSetAtHeight(tr.store, 0, tipsets[0])
+	_, err := tr.store.GetTipSetByHeight(ctx, 3)
+	assert.Error(err)
+
+	// The index is persisted.
+	reloaded := NewDefaultStore(tr.r.ChainDatastore(), tr.cst, tr.store.GenesisCid())
+	require.NoError(reloaded.Load(ctx))
+	requireTipSetAtHeight(reloaded, 2, fork)
+	requireTipSetAtHeight(reloaded, 1, tipsets[0])
+}
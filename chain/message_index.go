@@ -0,0 +1,278 @@
+package chain
+
+import (
+	"context"
+	"encoding/json"
+
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+	"gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore"
+	"gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore/query"
+
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// ErrMessageNotFound is returned when a message is not in the chain ending in
+// the head.
+var ErrMessageNotFound = errors.New("message not found on chain")
+
+// messageIndexPrefix is the datastore namespace of the index from message cids
+// to their location in the chain ending in the head.
+const messageIndexPrefix = "/chain/messages"
+
+// receiptsPrefix is the datastore namespace of the receipts of the messages
+// of tipsets of more than one block, which differ from the receipts in their
+// blocks when messages of the blocks conflict.
+const receiptsPrefix = "/chain/receipts"
+
+// indexVersionKey records the version of the chain indexes in the datastore
+// and whether addresses are indexed.  Indexes written by older versions or
+// with another address index setting are rebuilt when the chain is loaded.
+var indexVersionKey = datastore.NewKey("/chain/indexVersion")
+
+const indexVersion = "2"
+
+// indexVersion returns the version of the indexes maintained by the store.
+func (store *DefaultStore) indexVersion() string {
+	if store.indexAddresses {
+		return indexVersion + "+addresses"
+	}
+	return indexVersion
+}
+
+// MessageLocation is the location of a message in the chain.
+type MessageLocation struct {
+	// TipSet is the key of the tipset containing the message.
+	TipSet types.SortedCidSet
+	// Height is the height of the tipset.
+	Height uint64
+	// Block is the cid of the block containing the message.
+	Block cid.Cid
+	// Index is the index of the message in the block's messages.
+	Index int
+	// Receipt is the receipt of the message when the tipset is applied.  It
+	// is nil if the message failed in conflict with another message of the
+	// tipset.
+	Receipt *types.MessageReceipt
+	// HasReceipt is false if the receipt is unknown, which happens for
+	// tipsets of more than one block the node did not apply itself, such as
+	// tipsets imported from a snapshot.
+	HasReceipt bool
+}
+
+// GetMessageLocation returns the location of the message with cid msgCid in
+// the chain ending in the head, or ErrMessageNotFound if it is not in the
+// chain.  When a message is included more than once the lowest tipset
+// including it is returned.
+func (store *DefaultStore) GetMessageLocation(ctx context.Context, msgCid cid.Cid) (*MessageLocation, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	loc, err := store.readMessageIndex(msgCid)
+	if err == datastore.ErrNotFound {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read message index for %s", msgCid)
+	}
+	if !store.isIndexedTipSet(loc.TipSet, loc.Height) {
+		return nil, ErrMessageNotFound
+	}
+	return loc, nil
+}
+
+// indexTipSetMessages adds the messages of ts at height h to the message
+// index.  Messages already indexed in a lower tipset of the chain keep their
+// location.
+// Precondition: the caller holds the store's lock.
+func (store *DefaultStore) indexTipSetMessages(ts types.TipSet, h uint64) error {
+	var receipts map[string]*types.MessageReceipt
+	if len(ts) > 1 {
+		var err error
+		receipts, err = store.readTipSetReceipts(ts.String())
+		if err != nil && err != datastore.ErrNotFound {
+			return errors.Wrapf(err, "failed to read receipts of tipset %s", ts.String())
+		}
+	}
+	for _, blk := range ts.ToSlice() {
+		for i, msg := range blk.Messages {
+			msgCid, err := msg.Cid()
+			if err != nil {
+				return err
+			}
+			indexed, err := store.readMessageIndex(msgCid)
+			if err != nil && err != datastore.ErrNotFound {
+				return errors.Wrapf(err, "failed to read message index for %s", msgCid)
+			}
+			if err == nil && indexed.Height < h && store.isIndexedTipSet(indexed.TipSet, indexed.Height) {
+				continue
+			}
+			if err == nil && indexed.Height == h && indexed.TipSet.Equals(ts.ToSortedCidSet()) {
+				// A duplicate of a message in another block of the tipset.
+				continue
+			}
+			loc := &MessageLocation{
+				TipSet: ts.ToSortedCidSet(),
+				Height: h,
+				Block:  blk.Cid(),
+				Index:  i,
+			}
+			if len(ts) == 1 {
+				if i < len(blk.MessageReceipts) {
+					loc.Receipt = blk.MessageReceipts[i]
+				}
+				loc.HasReceipt = true
+			} else if receipts != nil {
+				loc.Receipt = receipts[msgCid.String()]
+				loc.HasReceipt = true
+			}
+			if err := store.writeMessageIndex(msgCid, loc); err != nil {
+				return err
+			}
+			if err := store.indexMessageAddresses(msg, msgCid, h, loc.Receipt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// unindexTipSetMessages removes the messages of the tipset with block cids
+// blkCids from the message index.  Messages indexed at another tipset are
+// left alone.
+// Precondition: the caller holds the store's lock.
+func (store *DefaultStore) unindexTipSetMessages(ctx context.Context, blkCids types.SortedCidSet) error {
+	blks, err := store.GetBlocks(ctx, blkCids)
+	if err != nil {
+		// Entries left behind point at a tipset that is no longer in the
+		// height index and are ignored by indexTipSetMessages.
+		logStore.Warningf("failed to remove messages of tipset %s from the index: %s", blkCids.String(), err)
+		return nil
+	}
+	for _, blk := range blks {
+		for _, msg := range blk.Messages {
+			msgCid, err := msg.Cid()
+			if err != nil {
+				return err
+			}
+			indexed, err := store.readMessageIndex(msgCid)
+			if err == datastore.ErrNotFound {
+				continue
+			}
+			if err != nil {
+				return errors.Wrapf(err, "failed to read message index for %s", msgCid)
+			}
+			if !indexed.TipSet.Equals(blkCids) {
+				continue
+			}
+			if err := store.ds.Delete(messageIndexKey(msgCid)); err != nil && err != datastore.ErrNotFound {
+				return errors.Wrapf(err, "failed to delete message index for %s", msgCid)
+			}
+			if err := store.unindexMessageAddresses(msg, msgCid, indexed.Height); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// isIndexedTipSet returns true if the height index maps h to blkCids.
+func (store *DefaultStore) isIndexedTipSet(blkCids types.SortedCidSet, h uint64) bool {
+	indexed, err := store.readHeightIndex(h)
+	return err == nil && indexed.Equals(blkCids)
+}
+
+// resetStaleIndexes removes the height, message and address indexes if they
+// were written by an older version or with another address index setting, so
+// that they are rebuilt when the head is next set.  It returns true if the indexes were reset.
+func (store *DefaultStore) resetStaleIndexes() (bool, error) {
+	version, err := store.ds.Get(indexVersionKey)
+	if err == nil && string(version) == store.indexVersion() {
+		return false, nil
+	}
+	if err != nil && err != datastore.ErrNotFound {
+		return false, errors.Wrap(err, "failed to read chain index version")
+	}
+
+	logStore.Infof("rebuilding chain indexes")
+	for _, prefix := range []string{heightIndexPrefix, messageIndexPrefix, addressIndexPrefix} {
+		res, err := store.ds.Query(query.Query{
+			Prefix:   prefix,
+			KeysOnly: true,
+		})
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to query %s", prefix)
+		}
+		entries, err := res.Rest()
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to read %s", prefix)
+		}
+		for _, entry := range entries {
+			if err := store.ds.Delete(datastore.NewKey(entry.Key)); err != nil {
+				return false, errors.Wrapf(err, "failed to delete %s", entry.Key)
+			}
+		}
+	}
+	return true, nil
+}
+
+func (store *DefaultStore) writeIndexVersion() error {
+	if err := store.ds.Put(indexVersionKey, []byte(store.indexVersion())); err != nil {
+		return errors.Wrap(err, "failed to write chain index version")
+	}
+	return nil
+}
+
+func (store *DefaultStore) readMessageIndex(msgCid cid.Cid) (*MessageLocation, error) {
+	bb, err := store.ds.Get(messageIndexKey(msgCid))
+	if err != nil {
+		return nil, err
+	}
+	var loc MessageLocation
+	if err := json.Unmarshal(bb, &loc); err != nil {
+		return nil, errors.Wrapf(err, "failed to cast message index for %s", msgCid)
+	}
+	return &loc, nil
+}
+
+func (store *DefaultStore) writeMessageIndex(msgCid cid.Cid, loc *MessageLocation) error {
+	val, err := json.Marshal(loc)
+	if err != nil {
+		return err
+	}
+	if err := store.ds.Put(messageIndexKey(msgCid), val); err != nil {
+		return errors.Wrapf(err, "failed to write message index for %s", msgCid)
+	}
+	return nil
+}
+
+func (store *DefaultStore) readTipSetReceipts(tsKey string) (map[string]*types.MessageReceipt, error) {
+	bb, err := store.ds.Get(receiptsKey(tsKey))
+	if err != nil {
+		return nil, err
+	}
+	var receipts map[string]*types.MessageReceipt
+	if err := json.Unmarshal(bb, &receipts); err != nil {
+		return nil, errors.Wrapf(err, "failed to cast receipts of tipset %s", tsKey)
+	}
+	return receipts, nil
+}
+
+func (store *DefaultStore) writeTipSetReceipts(tsKey string, receipts map[string]*types.MessageReceipt) error {
+	val, err := json.Marshal(receipts)
+	if err != nil {
+		return err
+	}
+	if err := store.ds.Put(receiptsKey(tsKey), val); err != nil {
+		return errors.Wrapf(err, "failed to write receipts of tipset %s", tsKey)
+	}
+	return nil
+}
+
+func receiptsKey(tsKey string) datastore.Key {
+	return datastore.NewKey(receiptsPrefix).ChildString(tsKey)
+}
+
+func messageIndexKey(msgCid cid.Cid) datastore.Key {
+	return datastore.NewKey(messageIndexPrefix).ChildString(msgCid.String())
+}
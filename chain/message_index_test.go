@@ -0,0 +1,129 @@
+package chain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-filecoin/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetMessageLocationAfterReorg(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+
+	ki := types.MustGenerateKeyInfo(1, types.GenerateKeyInfoSeed())
+	mockSigner := types.NewMockSigner(ki)
+	newSignedMessage := types.NewSignedMessageForTestGetter(mockSigner)
+	m1, m2, m3 := newSignedMessage(), newSignedMessage(), newSignedMessage()
+
+	tr, tipsets := requireExportTestChain(ctx, require, 0)
+	genTS := tipsets[0]
+
+	requireChild := func(parent types.TipSet, nonce uint64, msgs ...*types.SignedMessage) types.TipSet {
+		blk := RequireMkFakeChild(require, FakeChildParams{
+			GenesisCid: tr.store.GenesisCid(),
+			Parent:     parent,
+			StateRoot:  genTS.ToSlice()[0].StateRoot,
+			Nonce:      nonce,
+		})
+		blk.Messages = msgs
+		ts := MustNewTipSet(blk)
+		RequirePutTsas(ctx, require, tr.store, &TipSetAndState{TipSet: ts, TipSetStateRoot: blk.StateRoot})
+		return ts
+	}
+	requireLocation := func(store *DefaultStore, msg *types.SignedMessage, ts types.TipSet, index int) {
+		msgCid, err := msg.Cid()
+		require.NoError(err)
+		loc, err := store.GetMessageLocation(ctx, msgCid)
+		require.NoError(err)
+		assert.True(ts.ToSortedCidSet().Equals(loc.TipSet))
+		h, err := ts.Height()
+		require.NoError(err)
+		assert.Equal(h, loc.Height)
+		assert.Equal(ts.ToSlice()[0].Cid(), loc.Block)
+		assert.Equal(index, loc.Index)
+	}
+	requireNotFound := func(store *DefaultStore, msg *types.SignedMessage) {
+		msgCid, err := msg.Cid()
+		require.NoError(err)
+		_, err = store.GetMessageLocation(ctx, msgCid)
+		assert.Equal(ErrMessageNotFound, err)
+	}
+
+	// m1 and m2 land in the first chain, m2 twice.
+	a1 := requireChild(genTS, 0, m1, m2)
+	a2 := requireChild(a1, 0, m2)
+	require.NoError(tr.store.SetHead(ctx, a2))
+	requireLocation(tr.store, m1, a1, 0)
+	requireLocation(tr.store, m2, a1, 1)
+	requireNotFound(tr.store, m3)
+
+	// Reorg to a heavier fork containing m2 and m3 only.
+	b1 := requireChild(genTS, 1, m3)
+	b2 := requireChild(b1, 1)
+	b3 := requireChild(b2, 1, m2)
+	require.NoError(tr.store.SetHead(ctx, b3))
+	requireNotFound(tr.store, m1)
+	requireLocation(tr.store, m2, b3, 0)
+	requireLocation(tr.store, m3, b1, 0)
+
+	// The index is persisted.
+	reloaded := NewDefaultStore(tr.r.ChainDatastore(), tr.cst, tr.store.GenesisCid())
+	require.NoError(reloaded.Load(ctx))
+	requireNotFound(reloaded, m1)
+	requireLocation(reloaded, m2, b3, 0)
+	requireLocation(reloaded, m3, b1, 0)
+}
+
+func TestGetMessageLocationRecordsTipSetReceipts(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+
+	ki := types.MustGenerateKeyInfo(1, types.GenerateKeyInfoSeed())
+	newSignedMessage := types.NewSignedMessageForTestGetter(types.NewMockSigner(ki))
+	m1, m2 := newSignedMessage(), newSignedMessage()
+	m1Cid, err := m1.Cid()
+	require.NoError(err)
+	m2Cid, err := m2.Cid()
+	require.NoError(err)
+
+	tr, tipsets := requireExportTestChain(ctx, require, 0)
+	genTS := tipsets[0]
+
+	requireBlock := func(nonce uint64, msgs ...*types.SignedMessage) *types.Block {
+		blk := RequireMkFakeChild(require, FakeChildParams{
+			GenesisCid: tr.store.GenesisCid(),
+			Parent:     genTS,
+			StateRoot:  genTS.ToSlice()[0].StateRoot,
+			Nonce:      nonce,
+		})
+		blk.Messages = msgs
+		blk.MessageReceipts = []*types.MessageReceipt{{ExitCode: 1}}
+		return blk
+	}
+
+	// m2 failed in conflict with m1 when the tipset was applied
+	ts := MustNewTipSet(requireBlock(0, m1), requireBlock(1, m2))
+	receipt := &types.MessageReceipt{ExitCode: 0}
+	RequirePutTsas(ctx, require, tr.store, &TipSetAndState{
+		TipSet:          ts,
+		TipSetStateRoot: genTS.ToSlice()[0].StateRoot,
+		Receipts:        map[string]*types.MessageReceipt{m1Cid.String(): receipt},
+	})
+	require.NoError(tr.store.SetHead(ctx, ts))
+
+	loc, err := tr.store.GetMessageLocation(ctx, m1Cid)
+	require.NoError(err)
+	assert.True(loc.HasReceipt)
+	assert.Equal(receipt, loc.Receipt)
+
+	loc, err = tr.store.GetMessageLocation(ctx, m2Cid)
+	require.NoError(err)
+	assert.True(loc.HasReceipt)
+	assert.Nil(loc.Receipt)
+}
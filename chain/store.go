@@ -4,9 +4,11 @@ import (
 	"context"
 
 	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	bstore "gx/ipfs/QmS2aqUZLJp8kF1ihE5rvDGE5LvmKDPnx32w9Z1BW9xLV5/go-ipfs-blockstore"
 	"gx/ipfs/QmdbxjQWogRCHRaxhhGnYdT1oQJzL9GdqSKzCdqWr85AP2/pubsub"
 	"gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore"
 
+	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/state"
 	"github.com/filecoin-project/go-filecoin/types"
 )
@@ -41,6 +43,16 @@ type ReadStore interface {
 	LatestState(ctx context.Context) (state.Tree, error)
 
 	BlockHistory(ctx context.Context, tips types.TipSet) <-chan interface{}
+	// GetTipSetByHeight returns the tipset at the given height of the chain
+	// ending in the head, or the closest tipset below it if the height is a
+	// null round.
+	GetTipSetByHeight(ctx context.Context, h uint64) (types.TipSet, error)
+	// GetMessageLocation returns the location of a message in the chain
+	// ending in the head, or ErrMessageNotFound.
+	GetMessageLocation(ctx context.Context, msgCid cid.Cid) (*MessageLocation, error)
+	// AddressHistory returns the messages of the chain sent to or from an
+	// address, or ErrAddressIndexDisabled.
+	AddressHistory(ctx context.Context, addr address.Address, since uint64, limit int) ([]AddressHistoryEntry, error)
 	GenesisCid() cid.Cid
 }
 
@@ -73,4 +85,8 @@ type Store interface {
 
 	// SetHead sets the internally tracked  head to the provided tipset.
 	SetHead(ctx context.Context, s types.TipSet) error
+
+	// CollectGarbage prunes old state from the store and the blockstore
+	// holding the chain's state.
+	CollectGarbage(ctx context.Context, bs bstore.Blockstore, opts GCOptions) (GCResult, error)
 }
@@ -0,0 +1,101 @@
+package chain
+
+import (
+	"sync"
+	"time"
+
+	"gx/ipfs/QmY5Grm8pJdiSSVsYxx4uNRgweY72EmYwuSDbRnbFok3iY/go-libp2p-peer"
+)
+
+// maxRecentSyncErrors bounds the number of errors remembered by the sync
+// status tracker.
+const maxRecentSyncErrors = 10
+
+// SyncStatus is a snapshot of what the syncer is doing.
+type SyncStatus struct {
+	// Syncing is true iff the syncer is currently processing a chain.
+	Syncing bool
+	// Started is the time at which the current (or last) sync began.
+	Started time.Time
+	// TargetTipSet is the key of the tipset the syncer is (or was last) syncing to.
+	TargetTipSet string
+	// TargetHeight is the height of the target tipset, it is zero until the
+	// target's blocks have been fetched.
+	TargetHeight uint64
+	// CurrentHeight is the height of the head of the chain store.
+	CurrentHeight uint64
+	// Peer is the peer that provided the target tipset.  It is empty if the
+	// target was produced locally.
+	Peer string
+	// RecentErrors holds the most recent sync failures, oldest first.
+	RecentErrors []SyncError
+}
+
+// SyncError records a failed attempt to sync a tipset.
+type SyncError struct {
+	Time         time.Time
+	TargetTipSet string
+	Peer         string
+	Error        string
+}
+
+// syncStatusTracker records the progress of the syncer.  Readers and writers
+// grab a lock.
+type syncStatusTracker struct {
+	mu     sync.Mutex
+	status SyncStatus
+}
+
+// start marks the beginning of a sync to the given tipset key.
+func (t *syncStatusTracker) start(from peer.ID, tsKey string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.status.Syncing = true
+	t.status.Started = time.Now()
+	t.status.TargetTipSet = tsKey
+	t.status.TargetHeight = 0
+	t.status.Peer = peerString(from)
+}
+
+// setTargetHeight records the height of the tipset being synced to.
+func (t *syncStatusTracker) setTargetHeight(h uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.status.TargetHeight = h
+}
+
+// finish marks the end of the current sync, recording err if it is not nil.
+func (t *syncStatusTracker) finish(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.status.Syncing = false
+	if err == nil {
+		return
+	}
+	t.status.RecentErrors = append(t.status.RecentErrors, SyncError{
+		Time:         time.Now(),
+		TargetTipSet: t.status.TargetTipSet,
+		Peer:         t.status.Peer,
+		Error:        err.Error(),
+	})
+	if len(t.status.RecentErrors) > maxRecentSyncErrors {
+		t.status.RecentErrors = t.status.RecentErrors[len(t.status.RecentErrors)-maxRecentSyncErrors:]
+	}
+}
+
+// get returns a copy of the current status.
+func (t *syncStatusTracker) get() SyncStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	status := t.status
+	status.RecentErrors = make([]SyncError, len(t.status.RecentErrors))
+	copy(status.RecentErrors, t.status.RecentErrors)
+	return status
+}
+
+func peerString(p peer.ID) string {
+	if p == "" {
+		return ""
+	}
+	return p.Pretty()
+}
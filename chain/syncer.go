@@ -4,6 +4,8 @@ import (
 	"context"
 
 	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	bstore "gx/ipfs/QmS2aqUZLJp8kF1ihE5rvDGE5LvmKDPnx32w9Z1BW9xLV5/go-ipfs-blockstore"
+	"gx/ipfs/QmY5Grm8pJdiSSVsYxx4uNRgweY72EmYwuSDbRnbFok3iY/go-libp2p-peer"
 )
 
 // Syncer handles new blocks, either from the network or the local node's
@@ -18,5 +20,14 @@ import (
 // example a syncer might decide to cut off traversal of an unknown fork
 // after too many blocks.
 type Syncer interface {
-	HandleNewBlocks(ctx context.Context, blkCids []cid.Cid) error
+	// HandleNewBlocks syncs the chain ending in the tipset of the given
+	// blocks.  from is the peer that announced the blocks, it is empty for
+	// blocks originating on this node.
+	HandleNewBlocks(ctx context.Context, from peer.ID, blkCids []cid.Cid) error
+	// Status returns a snapshot of the syncer's progress.
+	Status() SyncStatus
+	// CollectGarbage prunes old state from the chain store and bs, which
+	// must be the blockstore holding the chain's state.  No chain is synced
+	// while garbage is being collected.
+	CollectGarbage(ctx context.Context, bs bstore.Blockstore, opts GCOptions) (GCResult, error)
 }
@@ -22,6 +22,10 @@ type TipSetAndState struct {
 	// root of aggregate state after applying tipset
 	TipSetStateRoot cid.Cid
 	TipSet          types.TipSet
+	// Receipts maps the cids of the messages of the tipset to their receipts
+	// when the tipset is applied, if known.  Messages failing in conflict
+	// with another message of the tipset have no receipt.
+	Receipts map[string]*types.MessageReceipt
 }
 
 type tsasByTipSetID map[string]*TipSetAndState
@@ -75,6 +79,32 @@ func (ti *TipIndex) Put(tsas *TipSetAndState) error {
 	return nil
 }
 
+// Remove removes the tipset with the input ID from both of TipIndex's internal
+// indexes.  It is a no-op if the tipset is not in the index.
+func (ti *TipIndex) Remove(tsKey string) {
+	ti.mu.Lock()
+	defer ti.mu.Unlock()
+	tsas, ok := ti.tsasByID[tsKey]
+	if !ok {
+		return
+	}
+	delete(ti.tsasByID, tsKey)
+
+	pSet, err := tsas.TipSet.Parents()
+	if err != nil {
+		return
+	}
+	h, err := tsas.TipSet.Height()
+	if err != nil {
+		return
+	}
+	key := makeKey(pSet.String(), h)
+	delete(ti.tsasByParentsAndHeight[key], tsKey)
+	if len(ti.tsasByParentsAndHeight[key]) == 0 {
+		delete(ti.tsasByParentsAndHeight, key)
+	}
+}
+
 // Get returns the tipset given by the input ID and its state.
 func (ti *TipIndex) Get(tsKey string) (*TipSetAndState, error) {
 	ti.mu.Lock()
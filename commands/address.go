@@ -8,6 +8,7 @@ import (
 	"gx/ipfs/Qmde5VP1qUkyQXKCfmEUA7bP64V2HAptbJ7phuPp7jXWwg/go-ipfs-cmdkit"
 
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/chain"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
@@ -28,9 +29,10 @@ var addrsCmd = &cmds.Command{
 		Tagline: "Interact with addresses",
 	},
 	Subcommands: map[string]*cmds.Command{
-		"ls":     addrsLsCmd,
-		"new":    addrsNewCmd,
-		"lookup": addrsLookupCmd,
+		"history": addrsHistoryCmd,
+		"ls":      addrsLsCmd,
+		"new":     addrsNewCmd,
+		"lookup":  addrsLookupCmd,
 	},
 }
 
@@ -113,6 +115,52 @@ var addrsLookupCmd = &cmds.Command{
 	},
 }
 
+var addrsHistoryCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the messages sent to or from an address",
+		ShortDescription: `
+Lists the messages of the chain sent to or from an address, ordered by height.
+At most about --limit messages are listed, all the messages of the height of
+the last one are included. To list the next page, pass one more than the
+last height to --since. Requires the chain.indexAddresses config option.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("address", true, false, "Address to list the messages of"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.Uint64Option("since", "List messages at this height or above").WithDefault(uint64(0)),
+		cmdkit.UintOption("limit", "Number of messages to list, 0 lists all of them").WithDefault(uint(50)),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		addr, err := address.NewFromString(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		since, _ := req.Options["since"].(uint64)
+		limit, _ := req.Options["limit"].(uint)
+
+		entries, err := GetPorcelainAPI(env).AddressHistory(req.Context, addr, since, int(limit))
+		if err != nil {
+			return err
+		}
+		return re.Emit(entries)
+	},
+	Type: []chain.AddressHistoryEntry{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, entries *[]chain.AddressHistoryEntry) error {
+			for _, entry := range *entries {
+				exitCode := "-"
+				if entry.HasReceipt {
+					exitCode = fmt.Sprintf("%d", entry.ExitCode)
+				}
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", entry.Height, entry.Direction, entry.Message, exitCode) // nolint: errcheck
+			}
+			return nil
+		}),
+	},
+}
+
 var balanceCmd = &cmds.Command{
 	Arguments: []cmdkit.Argument{
 		cmdkit.StringArg("address", true, false, "Address to get balance for"),
@@ -115,3 +115,34 @@ func TestWalletLoadFromFile(t *testing.T) {
 	wb := d.RunSuccess("wallet", "balance", fixtures.TestAddresses[0]).ReadStdoutTrimNewlines()
 	assert.Contains(wb, "10000")
 }
+
+func TestAddrsHistory(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	d := th.NewDaemon(
+		t,
+		th.WithMiner(fixtures.TestMiners[0]),
+		th.KeyFile(fixtures.KeyFilePaths()[0]),
+	).Start()
+	defer d.ShutdownSuccess()
+
+	d.RunFail("address index is disabled", "address", "history", fixtures.TestAddresses[0])
+
+	d.RunSuccess("config", "chain.indexAddresses", "true")
+	d.Restart()
+
+	msgCid := d.RunSuccess(
+		"message", "send",
+		"--from", fixtures.TestAddresses[0],
+		"--price", "0", "--limit", "300",
+		"--value=10",
+		fixtures.TestAddresses[1],
+	).ReadStdoutTrimNewlines()
+	d.RunSuccess("mining", "once")
+
+	out := d.RunSuccess("address", "history", fixtures.TestAddresses[0]).ReadStdout()
+	assert.Contains(out, "out\t"+msgCid)
+	out = d.RunSuccess("address", "history", fixtures.TestAddresses[1]).ReadStdout()
+	assert.Contains(out, "in\t"+msgCid)
+}
@@ -6,22 +6,166 @@ import (
 	"io"
 	"strconv"
 	"strings"
+	"time"
 
 	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
 	"gx/ipfs/Qma6uuSyjkecGhMFFLfzyJDPyoDtNJSHJNweDccZhaWkgU/go-ipfs-cmds"
 	"gx/ipfs/Qmde5VP1qUkyQXKCfmEUA7bP64V2HAptbJ7phuPp7jXWwg/go-ipfs-cmdkit"
 
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/node"
+	"github.com/filecoin-project/go-filecoin/plumbing/supply"
+	"github.com/filecoin-project/go-filecoin/repo"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
+// chainStatusWatchPeriod is the interval at which `chain status --watch`
+// reports the syncer's status.
+const chainStatusWatchPeriod = time.Second
+
 var chainCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Inspect the filecoin blockchain",
 	},
 	Subcommands: map[string]*cmds.Command{
-		"head": chainHeadCmd,
-		"ls":   chainLsCmd,
+		"bad":    chainBadCmd,
+		"export": chainExportCmd,
+		"get":    chainGetCmd,
+		"head":   chainHeadCmd,
+		"import": chainImportCmd,
+		"ls":     chainLsCmd,
+		"status": chainStatusCmd,
+		"supply": chainSupplyCmd,
+	},
+}
+
+var chainBadCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Inspect and manage the tipsets rejected by the syncer",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"ls": chainBadLsCmd,
+		"rm": chainBadRmCmd,
+	},
+}
+
+var chainExportCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Export the blockchain as a CAR archive",
+		ShortDescription: `
+Writes a CAR archive of the chain ending in the given tipset to stdout. The
+archive holds every block, message and receipt back to genesis along with the
+state trees of the exported tipsets. By default the state after every tipset
+is exported, use --state-only to export only the state of the tipset given and
+--recent to export the state of that many of the most recent tipsets.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("tipset", "Comma separated CIDs of the blocks of the tipset to export, defaults to the head"),
+		cmdkit.BoolOption("state-only", "Only export the state tree of the exported tipset"),
+		cmdkit.UintOption("recent", "Only export the state trees of this many of the most recent tipsets"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		stateOnly, _ := req.Options["state-only"].(bool)
+		recent, _ := req.Options["recent"].(uint)
+		if stateOnly && recent != 0 {
+			return errors.New("--state-only and --recent may not be used together")
+		}
+
+		head := GetPorcelainAPI(env).ChainHead(req.Context)
+		if tsOpt, ok := req.Options["tipset"].(string); ok {
+			var blkCids types.SortedCidSet
+			for _, s := range strings.Split(tsOpt, ",") {
+				c, err := cid.Decode(strings.TrimSpace(s))
+				if err != nil {
+					return errors.Wrapf(err, "invalid block cid %s", s)
+				}
+				blkCids.Add(c)
+			}
+			ts, err := GetPorcelainAPI(env).ChainGetTipSet(req.Context, blkCids.String())
+			if err != nil {
+				return err
+			}
+			head = ts
+		}
+
+		opts := chain.ExportOptions{
+			StateOnly:    stateOnly,
+			RecentStates: uint64(recent),
+		}
+		reader, writer := io.Pipe()
+		go func() {
+			err := GetPorcelainAPI(env).ChainExport(req.Context, head, opts, writer)
+			writer.CloseWithError(err) // nolint: errcheck
+		}()
+
+		return re.Emit(reader)
+	},
+}
+
+var chainImportCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Import a CAR archive written by chain export",
+		ShortDescription: `
+Loads a chain snapshot into the local repo and makes its head the head of the
+repo's chain. The repo must have been initialized with the snapshot's genesis
+block and the daemon must not be running. A snapshot whose head is lighter
+than the head of the repo's chain is refused. The snapshot's state transitions
+and weights are not re-validated, only import snapshots from a trusted source.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.FileArg("file", true, false, "Path to the CAR archive to import").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) (err error) {
+		fi, err := req.Files.NextFile()
+		if err != nil {
+			return err
+		}
+
+		rep, err := repo.OpenFSRepo(getRepoDir(req))
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if closeErr := rep.Close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+		}()
+
+		head, err := node.ImportChain(req.Context, rep, fi)
+		if err != nil {
+			return err
+		}
+
+		return re.Emit(head.ToSortedCidSet())
+	},
+	Type: []cid.Cid{},
+}
+
+var chainGetCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline:          "Get the CIDs of the tipset at a height of the chain",
+		ShortDescription: `Prints the block CIDs of the tipset at the given height of the chain ending in the head. If no blocks were mined at that height the closest tipset below it is printed.`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.Uint64Option("height", "Height of the tipset to get"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		height, ok := req.Options["height"].(uint64)
+		if !ok {
+			return errors.New("--height is required")
+		}
+
+		ts, err := GetPorcelainAPI(env).ChainGetTipSetByHeight(req.Context, height)
+		if err != nil {
+			return err
+		}
+
+		return re.Emit(ts.ToSortedCidSet())
 	},
+	Type: []cid.Cid{},
 }
 
 var chainHeadCmd = &cmds.Command{
@@ -102,3 +246,133 @@ var chainLsCmd = &cmds.Command{
 		}),
 	},
 }
+
+var chainStatusCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline:          "Show the status of chain syncing",
+		ShortDescription: `Reports whether the node is syncing, the tipset and height it is syncing to, the current height of its chain, the peer it is syncing from and recent sync errors.`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("watch", "w", "Keep reporting the status every second until interrupted"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		watch, _ := req.Options["watch"].(bool)
+
+		if err := re.Emit(GetPorcelainAPI(env).ChainSyncStatus()); err != nil {
+			return err
+		}
+		if !watch {
+			return nil
+		}
+
+		ticker := time.NewTicker(chainStatusWatchPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-req.Context.Done():
+				return nil
+			case <-ticker.C:
+				if err := re.Emit(GetPorcelainAPI(env).ChainSyncStatus()); err != nil {
+					return err
+				}
+			}
+		}
+	},
+	Type: chain.SyncStatus{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, status *chain.SyncStatus) error {
+			var output strings.Builder
+
+			fmt.Fprintf(&output, "Syncing:        %t\n", status.Syncing)                      // nolint: errcheck
+			fmt.Fprintf(&output, "Current Height: %d\n", status.CurrentHeight)                // nolint: errcheck
+			fmt.Fprintf(&output, "Target Height:  %d\n", status.TargetHeight)                 // nolint: errcheck
+			fmt.Fprintf(&output, "Target TipSet:  %s\n", status.TargetTipSet)                 // nolint: errcheck
+			fmt.Fprintf(&output, "Peer:           %s\n", status.Peer)                         // nolint: errcheck
+			fmt.Fprintf(&output, "Started:        %s\n", status.Started.Format(time.RFC3339)) // nolint: errcheck
+			for _, syncErr := range status.RecentErrors {
+				fmt.Fprintf(&output, "Error:          %s %s %s\n", syncErr.Time.Format(time.RFC3339), syncErr.TargetTipSet, syncErr.Error) // nolint: errcheck
+			}
+
+			_, err := fmt.Fprintln(w, output.String())
+			return err
+		}),
+	},
+}
+
+var chainSupplyCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the FIL supply",
+		ShortDescription: `
+Shows how the FIL of the state of the head is split. Circulating FIL is held
+by accounts, locked FIL by other actors such as miners, the storage market and
+payment channels, burnt FIL was sent to the burnt funds address and unemitted
+FIL is left in the network actor to pay future block rewards.
+`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		s, err := GetPorcelainAPI(env).ChainSupply(req.Context)
+		if err != nil {
+			return err
+		}
+		return re.Emit(s)
+	},
+	Type: supply.Supply{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, s *supply.Supply) error {
+			var output strings.Builder
+
+			fmt.Fprintf(&output, "Circulating: %s FIL\n", s.Circulating.String()) // nolint: errcheck
+			fmt.Fprintf(&output, "Locked:      %s FIL\n", s.Locked.String())      // nolint: errcheck
+			fmt.Fprintf(&output, "Burnt:       %s FIL\n", s.Burnt.String())       // nolint: errcheck
+			fmt.Fprintf(&output, "Unemitted:   %s FIL\n", s.Unemitted.String())   // nolint: errcheck
+			fmt.Fprintf(&output, "Total:       %s FIL\n", s.Total.String())       // nolint: errcheck
+
+			_, err := fmt.Fprint(w, output.String())
+			return err
+		}),
+	},
+}
+
+var chainBadLsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline:          "List tipsets rejected by the syncer",
+		ShortDescription: `Lists the tipsets the syncer will refuse to sync, most recent first, with the reason they were rejected and the peer that sent them.`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		return re.Emit(GetPorcelainAPI(env).ChainLsBadTipSets())
+	},
+	Type: []chain.BadTipSet{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, badTipSets *[]chain.BadTipSet) error {
+			for _, bad := range *badTipSets {
+				_, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", bad.TipSet, bad.Added.Format(time.RFC3339), bad.Peer, bad.Reason)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
+	},
+}
+
+var chainBadRmCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline:          "Unmark a tipset rejected by the syncer",
+		ShortDescription: `Removes the tipset made of the given block CIDs from the bad tipset cache so that the syncer will consider it again, e.g. after a bug fix.`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cids", true, true, "The CIDs of the blocks in the tipset"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		var tsCids types.SortedCidSet
+		for _, arg := range req.Arguments {
+			c, err := cid.Decode(arg)
+			if err != nil {
+				return errors.Wrapf(err, "invalid block cid %s", arg)
+			}
+			tsCids.Add(c)
+		}
+
+		return GetPorcelainAPI(env).ChainRemoveBadTipSet(tsCids.String())
+	},
+}
@@ -8,7 +8,10 @@ import (
 
 	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
 
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/fixtures"
+	"github.com/filecoin-project/go-filecoin/plumbing/supply"
 	th "github.com/filecoin-project/go-filecoin/testhelpers"
 	"github.com/filecoin-project/go-filecoin/types"
 
@@ -110,4 +113,94 @@ func TestChainDaemon(t *testing.T) {
 		assert.Contains(chainLsResult, "1")
 		assert.Contains(chainLsResult, "0")
 	})
+	t.Run("chain status reports an idle syncer", func(t *testing.T) {
+		t.Parallel()
+		assert := assert.New(t)
+		require := require.New(t)
+
+		d := th.NewDaemon(t).Start()
+		defer d.ShutdownSuccess()
+
+		op := d.RunSuccess("chain", "status", "--enc", "json")
+
+		var status chain.SyncStatus
+		require.NoError(json.Unmarshal([]byte(op.ReadStdoutTrimNewlines()), &status))
+		assert.False(status.Syncing)
+		assert.Equal(uint64(0), status.CurrentHeight)
+	})
+	t.Run("chain bad ls is empty and chain bad rm fails for unknown tipsets", func(t *testing.T) {
+		t.Parallel()
+		assert := assert.New(t)
+
+		d := th.NewDaemon(t).Start()
+		defer d.ShutdownSuccess()
+
+		op := d.RunSuccess("chain", "bad", "ls")
+		assert.Equal("", op.ReadStdoutTrimNewlines())
+
+		genesis := d.RunSuccess("chain", "ls").ReadStdoutTrimNewlines()
+		d.RunFail(chain.ErrBadTipSetNotFound.Error(), "chain", "bad", "rm", genesis)
+	})
+	t.Run("chain export writes a car archive", func(t *testing.T) {
+		t.Parallel()
+		assert := assert.New(t)
+
+		d := th.NewDaemon(t).Start()
+		defer d.ShutdownSuccess()
+
+		op := d.RunSuccess("chain", "export")
+		assert.NotEmpty(op.ReadStdout())
+
+		genesis := d.RunSuccess("chain", "ls").ReadStdoutTrimNewlines()
+		op = d.RunSuccess("chain", "export", "--tipset", genesis, "--state-only")
+		assert.NotEmpty(op.ReadStdout())
+
+		d.RunFail("may not be used together", "chain", "export", "--state-only", "--recent", "2")
+	})
+	t.Run("chain get returns the tipset at a height", func(t *testing.T) {
+		t.Parallel()
+		assert := assert.New(t)
+		require := require.New(t)
+
+		d := th.NewDaemon(t, th.WithMiner(fixtures.TestMiners[0])).Start()
+		defer d.ShutdownSuccess()
+
+		genesis, err := cid.Parse(d.RunSuccess("chain", "ls").ReadStdoutTrimNewlines())
+		require.NoError(err)
+		mined, err := cid.Parse(d.RunSuccess("mining", "once", "--enc", "text").ReadStdoutTrimNewlines())
+		require.NoError(err)
+
+		var tsCids []cid.Cid
+		op := d.RunSuccess("chain", "get", "--height", "0", "--enc", "json")
+		require.NoError(json.Unmarshal([]byte(op.ReadStdoutTrimNewlines()), &tsCids))
+		assert.Equal([]cid.Cid{genesis}, tsCids)
+
+		op = d.RunSuccess("chain", "get", "--height", "1", "--enc", "json")
+		require.NoError(json.Unmarshal([]byte(op.ReadStdoutTrimNewlines()), &tsCids))
+		assert.Equal([]cid.Cid{mined}, tsCids)
+
+		d.RunFail("above the head", "chain", "get", "--height", "2")
+	})
+	t.Run("chain supply shows the block rewards leaving the network actor", func(t *testing.T) {
+		t.Parallel()
+		assert := assert.New(t)
+		require := require.New(t)
+
+		d := th.NewDaemon(t, th.WithMiner(fixtures.TestMiners[0])).Start()
+		defer d.ShutdownSuccess()
+
+		var before, after supply.Supply
+		require.NoError(json.Unmarshal([]byte(d.RunSuccess("chain", "supply", "--enc", "json").ReadStdoutTrimNewlines()), &before))
+		d.RunSuccess("mining", "once")
+		require.NoError(json.Unmarshal([]byte(d.RunSuccess("chain", "supply", "--enc", "json").ReadStdoutTrimNewlines()), &after))
+
+		reward := consensus.NewDefaultBlockRewarder().BlockRewardAmount()
+		assert.True(before.Total.Equal(after.Total))
+		assert.True(before.Unemitted.Sub(reward).Equal(after.Unemitted))
+		assert.True(before.Circulating.Add(before.Locked).Add(reward).Equal(after.Circulating.Add(after.Locked)))
+		assert.True(after.Burnt.IsZero())
+
+		op := d.RunSuccess("chain", "supply")
+		assert.Contains(op.ReadStdout(), "Circulating:")
+	})
 }
@@ -15,3 +15,14 @@ func optionalAddr(o interface{}) (ret address.Address, err error) {
 	}
 	return
 }
+
+// optionalMinerAddr parses the address given to a --miner option, if any.
+func optionalMinerAddr(o interface{}) (ret address.Address, err error) {
+	if o != nil {
+		ret, err = address.NewFromString(o.(string))
+		if err != nil {
+			err = errors.Wrap(err, "miner must be an address")
+		}
+	}
+	return
+}
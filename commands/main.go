@@ -19,6 +19,7 @@ import (
 	"gx/ipfs/Qmde5VP1qUkyQXKCfmEUA7bP64V2HAptbJ7phuPp7jXWwg/go-ipfs-cmdkit"
 
 	"github.com/filecoin-project/go-filecoin/api/impl"
+	"github.com/filecoin-project/go-filecoin/plumbing/msg"
 	"github.com/filecoin-project/go-filecoin/repo"
 	"github.com/filecoin-project/go-filecoin/types"
 )
@@ -39,7 +40,7 @@ const (
 	// ELStdout tells the daemon to write event logs to stdout.
 	ELStdout = "elstdout"
 
-	// AutoSealIntervalSeconds configures the daemon to check for and seal any staged sectors on an interval.
+	// AutoSealIntervalSeconds configures the daemon to seal staged sectors once they have waited that many seconds for pieces.
 	AutoSealIntervalSeconds = "auto-seal-interval-seconds"
 
 	// SwarmAddress is the multiaddr for this Filecoin node
@@ -164,6 +165,7 @@ var rootSubcmdsDaemon = map[string]*cmds.Command{
 	"mpool":            mpoolCmd,
 	"paych":            paymentChannelCmd,
 	"ping":             pingCmd,
+	"repo":             repoCmd,
 	"retrieval-client": retrievalClientCmd,
 	"show":             showCmd,
 	"swarm":            swarmCmd,
@@ -296,6 +298,14 @@ func requiresDaemon(req *cmds.Request) bool {
 		return false
 	}
 
+	if req.Command == chainImportCmd {
+		return false
+	}
+
+	if req.Command == msgSignCmd {
+		return false
+	}
+
 	return true
 }
 
@@ -319,9 +329,18 @@ func isConnectionRefused(err error) bool {
 
 var priceOption = cmdkit.StringOption("price", "Price (FIL e.g. 0.00013) to pay for each GasUnits consumed mining this message")
 var limitOption = cmdkit.Uint64Option("limit", "Maximum number of GasUnits this message is allowed to consume")
+var gasAutoOption = cmdkit.BoolOption("gas-auto", "Estimate the gas limit and suggest a gas price instead of passing --price and --limit")
 var previewOption = cmdkit.BoolOption("preview", "Preview the Gas cost of this command without actually executing it")
 
 func parseGasOptions(req *cmds.Request) (types.AttoFIL, types.GasUnits, bool, error) {
+	if isGasAuto(req) {
+		if req.Options["price"] != nil || req.Options["limit"] != nil {
+			return types.AttoFIL{}, types.NewGasUnits(0), false, errors.New("gas-auto option cannot be used with the price and limit options")
+		}
+		preview, _ := req.Options["preview"].(bool)
+		return types.AttoFIL{}, types.NewGasUnits(0), preview, nil
+	}
+
 	priceOption := req.Options["price"]
 	if priceOption == nil {
 		return types.AttoFIL{}, types.NewGasUnits(0), false, errors.New("price option is required")
@@ -347,3 +366,21 @@ func parseGasOptions(req *cmds.Request) (types.AttoFIL, types.GasUnits, bool, er
 
 	return *price, types.NewGasUnits(gasLimitInt), preview, nil
 }
+
+// isGasAuto returns true if the gas limit and price of the message sent by
+// the command are to be estimated, in which case they are left to zero by
+// parseGasOptions and estimateGasOptions gives them.
+func isGasAuto(req *cmds.Request) bool {
+	gasAuto, _ := req.Options["gas-auto"].(bool)
+	return gasAuto
+}
+
+// estimateGasOptions returns the suggested gas price and the gas limit of a
+// message using usedGas when previewed.
+func estimateGasOptions(ctx context.Context, env cmds.Environment, usedGas types.GasUnits) (types.AttoFIL, types.GasUnits, error) {
+	gasPrice, err := GetPorcelainAPI(env).MessageSuggestGasPrice(ctx)
+	if err != nil {
+		return types.AttoFIL{}, types.NewGasUnits(0), errors.Wrap(err, "failed to suggest a gas price")
+	}
+	return gasPrice, msg.GasLimitWithMargin(usedGas), nil
+}
@@ -1,10 +1,12 @@
 package commands
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"strconv"
+	"strings"
 
 	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
 	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
@@ -13,9 +15,13 @@ import (
 
 	"github.com/filecoin-project/go-filecoin/abi"
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/chain"
 	"github.com/filecoin-project/go-filecoin/exec"
+	"github.com/filecoin-project/go-filecoin/plumbing/msg"
 	"github.com/filecoin-project/go-filecoin/plumbing/mthdsig"
+	"github.com/filecoin-project/go-filecoin/repo"
 	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/wallet"
 )
 
 var msgCmd = &cmds.Command{
@@ -24,8 +30,14 @@ var msgCmd = &cmds.Command{
 		Tagline: "Manage messages",
 	},
 	Subcommands: map[string]*cmds.Command{
-		"send": msgSendCmd,
-		"wait": msgWaitCmd,
+		"broadcast":  msgBroadcastCmd,
+		"create":     msgCreateCmd,
+		"replace":    msgReplaceCmd,
+		"send":       msgSendCmd,
+		"send-batch": msgSendBatchCmd,
+		"sign":       msgSignCmd,
+		"status":     msgStatusCmd,
+		"wait":       msgWaitCmd,
 	},
 }
 
@@ -48,6 +60,7 @@ var msgSendCmd = &cmds.Command{
 		cmdkit.StringOption("from", "Address to send message from"),
 		priceOption,
 		limitOption,
+		gasAutoOption,
 		previewOption,
 		// TODO: (per dignifiedquire) add an option to set the nonce and method explicitly
 	},
@@ -82,7 +95,7 @@ var msgSendCmd = &cmds.Command{
 			method = ""
 		}
 
-		if preview {
+		if preview || isGasAuto(req) {
 			usedGas, err := GetPorcelainAPI(env).MessagePreview(
 				req.Context,
 				fromAddr,
@@ -92,11 +105,17 @@ var msgSendCmd = &cmds.Command{
 			if err != nil {
 				return err
 			}
-			return re.Emit(&msgSendResult{
-				Cid:     cid.Cid{},
-				GasUsed: usedGas,
-				Preview: true,
-			})
+			if preview {
+				return re.Emit(&msgSendResult{
+					Cid:     cid.Cid{},
+					GasUsed: usedGas,
+					Preview: true,
+				})
+			}
+			gasPrice, gasLimit, err = estimateGasOptions(req.Context, env, usedGas)
+			if err != nil {
+				return err
+			}
 		}
 
 		c, err := GetPorcelainAPI(env).MessageSendWithDefaultAddress(
@@ -131,6 +150,127 @@ var msgSendCmd = &cmds.Command{
 	},
 }
 
+// msgSendBatchResult is the outcome of one message of a batch.
+type msgSendBatchResult struct {
+	Cid     cid.Cid
+	Receipt *types.MessageReceipt
+}
+
+var msgSendBatchCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Send a batch of messages from one address",
+		ShortDescription: `
+Sends a message for each entry of a JSON file, with consecutive nonces, and
+prints the cid of each message in the order of the entries. The file holds an
+array of entries like:
+
+  [{"to": "<address>", "value": "1.5", "method": "", "params": null}]
+
+where value is in FIL and params, if any, are the base64 encoded ABI encoding
+of the method's parameters. All messages of the batch use the same gas price
+and gas limit; with --gas-auto the limit is estimated from the message that
+uses the most gas in a preview. With --wait the command waits for each message to be mined and
+prints its exit code after its cid.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.FileArg("file", true, false, "Path to the JSON file of messages to send").EnableStdin(),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("from", "Address to send the messages from"),
+		priceOption,
+		limitOption,
+		gasAutoOption,
+		cmdkit.BoolOption("wait", "Wait for all messages to be mined"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		fi, err := req.Files.NextFile()
+		if err != nil {
+			return err
+		}
+
+		var entries []msg.BatchEntry
+		if err := json.NewDecoder(fi).Decode(&entries); err != nil {
+			return errors.Wrap(err, "invalid batch file")
+		}
+		if len(entries) == 0 {
+			return errors.New("batch file has no messages")
+		}
+
+		var fromAddr address.Address
+		if o, ok := req.Options["from"].(string); ok {
+			fromAddr, err = address.NewFromString(o)
+			if err != nil {
+				return errors.Wrap(err, "invalid from address")
+			}
+		} else {
+			fromAddr, err = GetPorcelainAPI(env).GetAndMaybeSetDefaultSenderAddress()
+			if err != nil {
+				return err
+			}
+		}
+
+		gasPrice, gasLimit, _, err := parseGasOptions(req)
+		if err != nil {
+			return err
+		}
+
+		if isGasAuto(req) {
+			maxGas := types.NewGasUnits(0)
+			for i, entry := range entries {
+				usedGas, err := GetPorcelainAPI(env).MessagePreviewEncoded(req.Context, fromAddr, entry.To, entry.Method, entry.Params)
+				if err != nil {
+					return errors.Wrapf(err, "failed to preview message %d", i)
+				}
+				if usedGas > maxGas {
+					maxGas = usedGas
+				}
+			}
+			gasPrice, gasLimit, err = estimateGasOptions(req.Context, env, maxGas)
+			if err != nil {
+				return err
+			}
+		}
+
+		cids, err := GetPorcelainAPI(env).MessageSendBatch(req.Context, fromAddr, gasPrice, gasLimit, entries)
+		if err != nil {
+			for _, c := range cids {
+				re.Emit(&msgSendBatchResult{Cid: c}) // nolint: errcheck
+			}
+			return err
+		}
+
+		wait, _ := req.Options["wait"].(bool)
+		for _, c := range cids {
+			res := &msgSendBatchResult{Cid: c}
+			if wait {
+				err := GetPorcelainAPI(env).MessageWait(req.Context, c, func(_ *types.Block, _ *types.SignedMessage, receipt *types.MessageReceipt) error {
+					res.Receipt = receipt
+					return nil
+				})
+				if err != nil {
+					return errors.Wrapf(err, "failed to wait for message %s", c)
+				}
+			}
+			if err := re.Emit(res); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: msgSendBatchResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res *msgSendBatchResult) error {
+			if res.Receipt != nil {
+				fmt.Fprintf(w, "%s\t%d\n", res.Cid, res.Receipt.ExitCode) // nolint: errcheck
+				return nil
+			}
+			fmt.Fprintf(w, "%s\n", res.Cid) // nolint: errcheck
+			return nil
+		}),
+	},
+}
+
 // WaitResult is the result of a message wait call.
 type WaitResult struct {
 	Message   *types.SignedMessage
@@ -220,6 +360,339 @@ var msgWaitCmd = &cmds.Command{
 	},
 }
 
+var msgReplaceCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Replace a pending message by a copy paying a higher gas price",
+		ShortDescription: `
+Signs a copy of a message in the message pool with the same nonce and a new gas
+price, and broadcasts it. Message pools replace the original by the copy if the
+new gas price is at least mpool.replaceByFeePercent higher. Prints the cid of
+the replacement.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cid", true, false, "The cid of the pending message to replace"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("gas-price", "Price (FIL e.g. 0.00013) the replacement pays for each GasUnits consumed mining it"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		msgCid, err := cid.Parse(req.Arguments[0])
+		if err != nil {
+			return errors.Wrap(err, "invalid message cid")
+		}
+
+		priceOption, ok := req.Options["gas-price"].(string)
+		if !ok {
+			return errors.New("gas-price option is required")
+		}
+		gasPrice, ok := types.NewAttoFILFromFILString(priceOption)
+		if !ok {
+			return errors.New("invalid gas price (specify FIL as a decimal number)")
+		}
+
+		c, err := GetPorcelainAPI(env).MessageReplace(req.Context, msgCid, *gasPrice)
+		if err != nil {
+			return err
+		}
+		return re.Emit(c)
+	},
+	Type: cid.Cid{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, c cid.Cid) error {
+			return PrintString(w, c)
+		}),
+	},
+}
+
+var msgCreateCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Create an unsigned message",
+		ShortDescription: `
+Prints as JSON an unsigned message from --from to the target, invoking
+--method, with the next nonce of the sender unless --nonce is given. The
+message can be signed with 'message sign' on a repo holding the key of the
+sender, for instance on an offline machine, and broadcast with 'message
+broadcast'.
+
+Messages created before the previous one is broadcast get the same nonce, use
+--nonce to create several messages in a row.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("target", true, false, "Address of the actor to send the message to"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.IntOption("value", "Value to send with message, in AttoFIL"),
+		cmdkit.StringOption("from", "Address to send message from"),
+		cmdkit.StringOption("method", "The method to invoke on the target actor"),
+		cmdkit.Uint64Option("nonce", "Nonce of the message, defaults to the next nonce of the sender"),
+		priceOption,
+		limitOption,
+		gasAutoOption,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		target, err := address.NewFromString(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+
+		fromOption, ok := req.Options["from"].(string)
+		if !ok {
+			return errors.New("from option is required")
+		}
+		fromAddr, err := address.NewFromString(fromOption)
+		if err != nil {
+			return errors.Wrap(err, "invalid from address")
+		}
+
+		val, ok := req.Options["value"].(int)
+		if !ok {
+			val = 0
+		}
+
+		method, _ := req.Options["method"].(string)
+
+		gasPrice, gasLimit, _, err := parseGasOptions(req)
+		if err != nil {
+			return err
+		}
+		if isGasAuto(req) {
+			usedGas, err := GetPorcelainAPI(env).MessagePreview(req.Context, fromAddr, target, method)
+			if err != nil {
+				return err
+			}
+			gasPrice, gasLimit, err = estimateGasOptions(req.Context, env, usedGas)
+			if err != nil {
+				return err
+			}
+		}
+
+		msg, err := GetPorcelainAPI(env).MessageCreate(
+			req.Context,
+			fromAddr,
+			target,
+			types.NewAttoFILFromFIL(uint64(val)),
+			gasPrice,
+			gasLimit,
+			method,
+		)
+		if err != nil {
+			return err
+		}
+		if nonce, ok := req.Options["nonce"].(uint64); ok {
+			msg.Nonce = types.Uint64(nonce)
+		}
+
+		return re.Emit(msg)
+	},
+	Type: &types.MeteredMessage{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, msg *types.MeteredMessage) error {
+			return json.NewEncoder(w).Encode(msg)
+		}),
+	},
+}
+
+var msgSignCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Sign a message created by 'message create'",
+		ShortDescription: `
+Signs a message given as JSON with the key of its sender in the wallet of the
+repo, without a daemon: the daemon of the repo must not be running, and the
+machine does not need to be online. Prints the signed message as JSON, or as
+hex encoded CBOR with --cbor.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("message", true, false, "The unsigned message as JSON").EnableStdin(),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("cbor", "Print the signed message as hex encoded CBOR"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) (err error) {
+		var msg types.MeteredMessage
+		if err := json.Unmarshal([]byte(req.Arguments[0]), &msg); err != nil {
+			return errors.Wrap(err, "invalid message")
+		}
+
+		rep, err := repo.OpenFSRepo(getRepoDir(req))
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if closeErr := rep.Close(); closeErr != nil && err == nil {
+				err = closeErr
+			}
+		}()
+
+		backend, err := wallet.NewDSBackend(rep.WalletDatastore())
+		if err != nil {
+			return errors.Wrap(err, "failed to open wallet")
+		}
+		smsg, err := types.NewSignedMessage(msg.Message, wallet.New(backend), msg.GasPrice, msg.GasLimit)
+		if err != nil {
+			return errors.Wrap(err, "failed to sign message")
+		}
+		return re.Emit(smsg)
+	},
+	Type: &types.SignedMessage{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, smsg *types.SignedMessage) error {
+			if asCBOR, _ := req.Options["cbor"].(bool); asCBOR {
+				data, err := smsg.Marshal()
+				if err != nil {
+					return err
+				}
+				_, err = fmt.Fprintln(w, hex.EncodeToString(data))
+				return err
+			}
+			return json.NewEncoder(w).Encode(smsg)
+		}),
+	},
+}
+
+var msgBroadcastCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Broadcast a message signed by 'message sign'",
+		ShortDescription: `
+Adds a signed message, given as JSON or as hex encoded CBOR, to the message pool
+and publishes it to the network. The message is rejected if its signature is
+invalid or if the message pool does not admit it. Prints the cid of the
+message.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("message", true, false, "The signed message as JSON or hex encoded CBOR").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		smsg, err := parseSignedMessage(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		if !smsg.VerifySignature() {
+			return errors.New("invalid message signature")
+		}
+
+		c, err := GetPorcelainAPI(env).MessageBroadcast(req.Context, smsg)
+		if err != nil {
+			return err
+		}
+		return re.Emit(c)
+	},
+	Type: cid.Cid{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, c cid.Cid) error {
+			return PrintString(w, c)
+		}),
+	},
+}
+
+// parseSignedMessage decodes a signed message given as JSON or as hex encoded
+// CBOR.
+func parseSignedMessage(s string) (*types.SignedMessage, error) {
+	var smsg types.SignedMessage
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "{") {
+		if err := json.Unmarshal([]byte(s), &smsg); err != nil {
+			return nil, errors.Wrap(err, "invalid JSON message")
+		}
+		return &smsg, nil
+	}
+
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "message is neither JSON nor hex encoded CBOR")
+	}
+	if err := smsg.Unmarshal(data); err != nil {
+		return nil, errors.Wrap(err, "invalid CBOR message")
+	}
+	return &smsg, nil
+}
+
+// MessageStatusResult is the status of a message as reported by message status.
+type MessageStatusResult struct {
+	OnChain  bool
+	InPool   bool
+	Message  *types.SignedMessage
+	Location *chain.MessageLocation
+	Receipt  *types.MessageReceipt
+}
+
+var msgStatusCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show whether a message is on chain or in the message pool",
+		ShortDescription: `
+Looks the message up in the chain's message index and reports the tipset and
+block that include it along with its receipt. A message that is not on chain
+is reported as pending if it is in the message pool.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cid", true, false, "The cid of the message"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		msgCid, err := cid.Parse(req.Arguments[0])
+		if err != nil {
+			return errors.Wrap(err, "invalid message cid")
+		}
+
+		found, err := GetPorcelainAPI(env).MessageFind(req.Context, msgCid)
+		if err == nil {
+			return re.Emit(&MessageStatusResult{
+				OnChain:  true,
+				Message:  found.Message,
+				Location: found.Location,
+				Receipt:  found.Receipt,
+			})
+		}
+		if err != chain.ErrMessageNotFound {
+			return err
+		}
+
+		pending, err := GetAPI(env).Mpool().View(req.Context, 0)
+		if err != nil {
+			return err
+		}
+		res := &MessageStatusResult{}
+		for _, msg := range pending {
+			c, err := msg.Cid()
+			if err != nil {
+				return err
+			}
+			if c.Equals(msgCid) {
+				res.InPool = true
+				res.Message = msg
+				break
+			}
+		}
+		return re.Emit(res)
+	},
+	Type: MessageStatusResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res *MessageStatusResult) error {
+			switch {
+			case res.OnChain:
+				fmt.Fprintln(w, "Status:    on chain")                          // nolint: errcheck
+				fmt.Fprintf(w, "TipSet:    %s\n", res.Location.TipSet.String()) // nolint: errcheck
+				fmt.Fprintf(w, "Height:    %d\n", res.Location.Height)          // nolint: errcheck
+				fmt.Fprintf(w, "Block:     %s\n", res.Location.Block.String())  // nolint: errcheck
+				fmt.Fprintf(w, "Index:     %d\n", res.Location.Index)           // nolint: errcheck
+				if res.Receipt != nil {
+					fmt.Fprintf(w, "Exit Code: %d\n", res.Receipt.ExitCode) // nolint: errcheck
+				} else {
+					fmt.Fprintln(w, "Exit Code: none, the message was not applied") // nolint: errcheck
+				}
+			case res.InPool:
+				fmt.Fprintln(w, "Status:    pending in message pool") // nolint: errcheck
+			default:
+				fmt.Fprintln(w, "Status:    unknown") // nolint: errcheck
+			}
+			return nil
+		}),
+	},
+}
+
 func appendJSON(val interface{}, out []byte) ([]byte, error) {
 	m, err := json.MarshalIndent(val, "", "\t")
 	if err != nil {
@@ -2,10 +2,13 @@ package commands
 
 import (
 	"encoding/json"
+	"io/ioutil"
+	"os"
 	"strconv"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -51,6 +54,21 @@ func TestMessageSend(t *testing.T) {
 		"--price", "0", "--limit", "300",
 		"--value=10", fixtures.TestAddresses[1],
 	)
+
+	t.Log("[success] with estimated gas")
+	d.RunSuccess("message", "send",
+		"--from", fixtures.TestAddresses[0],
+		"--gas-auto",
+		"--value=10", fixtures.TestAddresses[1],
+	)
+
+	t.Log("[failure] with estimated gas and a limit")
+	d.RunFail("gas-auto option cannot be used",
+		"message", "send",
+		"--from", fixtures.TestAddresses[0],
+		"--gas-auto", "--limit", "300",
+		"--value=10", fixtures.TestAddresses[1],
+	)
 }
 
 func TestMessageWait(t *testing.T) {
@@ -97,6 +115,80 @@ func TestMessageWait(t *testing.T) {
 	})
 }
 
+func TestMessageStatus(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	require := require.New(t)
+
+	d := th.NewDaemon(
+		t,
+		th.WithMiner(fixtures.TestMiners[0]),
+		th.KeyFile(fixtures.KeyFilePaths()[0]),
+	).Start()
+	defer d.ShutdownSuccess()
+
+	msgcid := d.RunSuccess(
+		"message", "send",
+		"--from", fixtures.TestAddresses[0],
+		"--price", "0", "--limit", "300",
+		"--value=10",
+		fixtures.TestAddresses[1],
+	).ReadStdoutTrimNewlines()
+
+	out := d.RunSuccess("message", "status", msgcid).ReadStdout()
+	assert.Contains(out, "pending in message pool")
+
+	blockCid := d.RunSuccess("mining", "once").ReadStdoutTrimNewlines()
+
+	var status MessageStatusResult
+	out = d.RunSuccess("message", "status", "--enc", "json", msgcid).ReadStdout()
+	require.NoError(json.Unmarshal([]byte(out), &status))
+	assert.True(status.OnChain)
+	assert.False(status.InPool)
+	assert.Equal(blockCid, status.Location.Block.String())
+	require.NotNil(status.Receipt)
+	assert.Equal(uint8(0), status.Receipt.ExitCode)
+
+	out = d.RunSuccess("message", "status", types.SomeCid().String()).ReadStdout()
+	assert.Contains(out, "unknown")
+}
+
+func TestMessageReplace(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	d := th.NewDaemon(
+		t,
+		th.WithMiner(fixtures.TestMiners[0]),
+		th.KeyFile(fixtures.KeyFilePaths()[0]),
+	).Start()
+	defer d.ShutdownSuccess()
+
+	msgCid := d.RunSuccess(
+		"message", "send",
+		"--from", fixtures.TestAddresses[0],
+		"--price", "0", "--limit", "300",
+		"--value=10",
+		fixtures.TestAddresses[1],
+	).ReadStdoutTrimNewlines()
+
+	d.RunFail("gas-price option is required", "message", "replace", msgCid)
+
+	replacementCid := d.RunSuccess("message", "replace", msgCid, "--gas-price", "0.0001").ReadStdoutTrimNewlines()
+	assert.NotEqual(msgCid, replacementCid)
+
+	pending := d.RunSuccess("mpool", "ls").ReadStdoutTrimNewlines()
+	assert.Equal(replacementCid, pending)
+
+	// The original is no longer in the pool, and replacing the replacement
+	// requires paying 10% more.
+	d.RunFail("not in the message pool", "message", "replace", msgCid, "--gas-price", "0.001")
+	d.RunFail("too low", "message", "replace", replacementCid, "--gas-price", "0.000105")
+
+	d.RunSuccess("mining", "once")
+	d.RunSuccess("message", "wait", replacementCid)
+}
+
 func TestMessageSendBlockGasLimit(t *testing.T) {
 	t.Parallel()
 
@@ -142,3 +234,113 @@ func TestMessageSendBlockGasLimit(t *testing.T) {
 		assert.NotEmpty(t, result.Messages, "msg under the block gas limit passes validation and is run in the block")
 	})
 }
+
+func TestMessageCreateSignBroadcast(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	require := require.New(t)
+
+	d := th.NewDaemon(
+		t,
+		th.WithMiner(fixtures.TestMiners[0]),
+		th.KeyFile(fixtures.KeyFilePaths()[0]),
+	).Start()
+	defer d.ShutdownSuccess()
+
+	// Messages are signed in the repo of a daemon that is not running.
+	signer := th.NewDaemon(t, th.KeyFile(fixtures.KeyFilePaths()[0])).Start()
+	signer.Stop()
+	defer os.RemoveAll(signer.RepoDir()) // nolint: errcheck
+
+	unsigned := d.RunSuccess("message", "create",
+		"--from", fixtures.TestAddresses[0],
+		"--price", "0", "--limit", "300",
+		"--value=10", fixtures.TestAddresses[1],
+	).ReadStdoutTrimNewlines()
+	var msg types.MeteredMessage
+	require.NoError(json.Unmarshal([]byte(unsigned), &msg))
+	assert.Equal(fixtures.TestAddresses[1], msg.To.String())
+
+	d.RunFail("from option is required", "message", "create", "--price", "0", "--limit", "300", fixtures.TestAddresses[1])
+
+	// Sign as JSON and broadcast.
+	signed := signer.RunSuccess("message", "sign", unsigned).ReadStdoutTrimNewlines()
+	msgCid := d.RunSuccess("message", "broadcast", signed).ReadStdoutTrimNewlines()
+	assert.Equal(msgCid, d.RunSuccess("mpool", "ls").ReadStdoutTrimNewlines())
+
+	// Sign the next message as CBOR and broadcast it.
+	unsigned = d.RunSuccess("message", "create",
+		"--from", fixtures.TestAddresses[0],
+		"--price", "0", "--limit", "300",
+		"--value=10", fixtures.TestAddresses[1],
+	).ReadStdoutTrimNewlines()
+	require.NoError(json.Unmarshal([]byte(unsigned), &msg))
+	assert.Equal(types.Uint64(1), msg.Nonce)
+	signed = signer.RunSuccess("message", "sign", "--cbor", unsigned).ReadStdoutTrimNewlines()
+	d.RunSuccess("message", "broadcast", signed)
+	assert.Len(strings.Split(d.RunSuccess("mpool", "ls").ReadStdoutTrimNewlines(), "\n"), 2)
+
+	d.RunFail("neither JSON nor hex encoded CBOR", "message", "broadcast", "not a message")
+}
+
+func TestMessageSendBatch(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	require := require.New(t)
+
+	d := th.NewDaemon(
+		t,
+		th.WithMiner(fixtures.TestMiners[0]),
+		th.KeyFile(fixtures.KeyFilePaths()[0]),
+	).Start()
+	defer d.ShutdownSuccess()
+
+	fi, err := ioutil.TempFile("", "sendbatch")
+	require.NoError(err)
+	defer os.Remove(fi.Name()) // nolint: errcheck
+	_, err = fi.WriteString(`[
+		{"to": "` + fixtures.TestAddresses[1] + `", "value": "10"},
+		{"to": "` + fixtures.TestAddresses[2] + `", "value": "20"}
+	]`)
+	require.NoError(err)
+	require.NoError(fi.Close())
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		out := d.RunSuccess("message", "send-batch",
+			"--from", fixtures.TestAddresses[0],
+			"--price", "0", "--limit", "300",
+			"--wait",
+			fi.Name(),
+		)
+		lines := strings.Split(out.ReadStdoutTrimNewlines(), "\n")
+		require.Len(lines, 2)
+		for _, line := range lines {
+			assert.True(strings.HasSuffix(line, "\t0"), line)
+		}
+	}()
+
+	// Wait for both messages to be in the pool before mining them.
+	deadline := time.Now().Add(30 * time.Second)
+	for len(strings.Fields(d.RunSuccess("mpool", "ls").ReadStdout())) < 2 {
+		require.True(time.Now().Before(deadline), "timed out waiting for the batch in the message pool")
+		time.Sleep(100 * time.Millisecond)
+	}
+	d.RunSuccess("mining", "once")
+	wg.Wait()
+
+	t.Log("[failure] empty batch")
+	empty, err := ioutil.TempFile("", "sendbatch")
+	require.NoError(err)
+	defer os.Remove(empty.Name()) // nolint: errcheck
+	_, err = empty.WriteString("[]")
+	require.NoError(err)
+	require.NoError(empty.Close())
+	d.RunFail("batch file has no messages", "message", "send-batch", "--from", fixtures.TestAddresses[0], empty.Name())
+
+	t.Log("[success] gas-auto")
+	out := d.RunSuccess("message", "send-batch", "--from", fixtures.TestAddresses[0], "--gas-auto", fi.Name())
+	assert.Len(strings.Split(out.ReadStdoutTrimNewlines(), "\n"), 2)
+}
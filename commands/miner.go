@@ -5,6 +5,7 @@ import (
 	"io"
 	"math/big"
 	"strconv"
+	"time"
 
 	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
 	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
@@ -13,7 +14,10 @@ import (
 	"gx/ipfs/Qmde5VP1qUkyQXKCfmEUA7bP64V2HAptbJ7phuPp7jXWwg/go-ipfs-cmdkit"
 
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/api"
+	"github.com/filecoin-project/go-filecoin/config"
 	"github.com/filecoin-project/go-filecoin/porcelain"
+	"github.com/filecoin-project/go-filecoin/protocol/storage"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
@@ -23,10 +27,13 @@ var minerCmd = &cmds.Command{
 	},
 	Subcommands: map[string]*cmds.Command{
 		"create":        minerCreateCmd,
+		"deal-policy":   minerDealPolicyCmd,
 		"add-ask":       minerAddAskCmd,
 		"owner":         minerOwnerCmd,
 		"pledge":        minerPledgeCmd,
 		"power":         minerPowerCmd,
+		"sealing":       minerSealingCmd,
+		"sectors":       minerSectorsCmd,
 		"set-price":     minerSetPriceCmd,
 		"update-peerid": minerUpdatePeerIDCmd,
 	},
@@ -81,6 +88,7 @@ Collateral must be greater than 0.001 FIL per pledged sector.`,
 		cmdkit.StringOption("peerid", "Base58-encoded libp2p peer ID that the miner will operate"),
 		priceOption,
 		limitOption,
+		gasAutoOption,
 		previewOption,
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
@@ -115,7 +123,7 @@ Collateral must be greater than 0.001 FIL per pledged sector.`,
 			return err
 		}
 
-		if preview {
+		if preview || isGasAuto(req) {
 			usedGas, err := GetPorcelainAPI(env).MinerPreviewCreate(
 				req.Context,
 				fromAddr,
@@ -126,11 +134,17 @@ Collateral must be greater than 0.001 FIL per pledged sector.`,
 			if err != nil {
 				return err
 			}
-			return re.Emit(&MinerCreateResult{
-				Address: address.Address{},
-				GasUsed: usedGas,
-				Preview: true,
-			})
+			if preview {
+				return re.Emit(&MinerCreateResult{
+					Address: address.Address{},
+					GasUsed: usedGas,
+					Preview: true,
+				})
+			}
+			gasPrice, gasLimit, err = estimateGasOptions(req.Context, env, usedGas)
+			if err != nil {
+				return err
+			}
 		}
 
 		addr, err := GetAPI(env).Miner().Create(req.Context, fromAddr, gasPrice, gasLimit, pledge, pid, collateral)
@@ -178,6 +192,7 @@ This command waits for the ask to be mined.`,
 		cmdkit.StringOption("miner", "The address of the miner owning the ask"),
 		priceOption,
 		limitOption,
+		gasAutoOption,
 		previewOption,
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
@@ -209,7 +224,7 @@ This command waits for the ask to be mined.`,
 			return err
 		}
 
-		if preview {
+		if preview || isGasAuto(req) {
 			usedGas, err := GetPorcelainAPI(env).MinerPreviewSetPrice(
 				req.Context,
 				fromAddr,
@@ -219,11 +234,17 @@ This command waits for the ask to be mined.`,
 			if err != nil {
 				return err
 			}
-			return re.Emit(&minerSetPriceResult{
-				GasUsed:               usedGas,
-				Preview:               true,
-				MinerSetPriceResponse: porcelain.MinerSetPriceResponse{},
-			})
+			if preview {
+				return re.Emit(&minerSetPriceResult{
+					GasUsed:               usedGas,
+					Preview:               true,
+					MinerSetPriceResponse: porcelain.MinerSetPriceResponse{},
+				})
+			}
+			gasPrice, gasLimit, err = estimateGasOptions(req.Context, env, usedGas)
+			if err != nil {
+				return err
+			}
 		}
 
 		res, err := GetPorcelainAPI(env).MinerSetPrice(
@@ -285,6 +306,7 @@ var minerUpdatePeerIDCmd = &cmds.Command{
 		cmdkit.StringOption("from", "Address to send from"),
 		priceOption,
 		limitOption,
+		gasAutoOption,
 		previewOption,
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
@@ -308,7 +330,7 @@ var minerUpdatePeerIDCmd = &cmds.Command{
 			return err
 		}
 
-		if preview {
+		if preview || isGasAuto(req) {
 			usedGas, err := GetPorcelainAPI(env).MessagePreview(
 				req.Context,
 				fromAddr,
@@ -320,11 +342,17 @@ var minerUpdatePeerIDCmd = &cmds.Command{
 				return err
 			}
 
-			return re.Emit(&minerUpdatePeerIDResult{
-				Cid:     cid.Cid{},
-				GasUsed: usedGas,
-				Preview: true,
-			})
+			if preview {
+				return re.Emit(&minerUpdatePeerIDResult{
+					Cid:     cid.Cid{},
+					GasUsed: usedGas,
+					Preview: true,
+				})
+			}
+			gasPrice, gasLimit, err = estimateGasOptions(req.Context, env, usedGas)
+			if err != nil {
+				return err
+			}
 		}
 
 		c, err := GetAPI(env).Miner().UpdatePeerID(req.Context, fromAddr, minerAddr, gasPrice, gasLimit, newPid)
@@ -370,6 +398,7 @@ var minerAddAskCmd = &cmds.Command{
 		cmdkit.StringOption("from", "Address to send the ask from"),
 		priceOption,
 		limitOption,
+		gasAutoOption,
 		previewOption,
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
@@ -398,7 +427,7 @@ var minerAddAskCmd = &cmds.Command{
 			return err
 		}
 
-		if preview {
+		if preview || isGasAuto(req) {
 			usedGas, err := GetPorcelainAPI(env).MessagePreview(
 				req.Context,
 				fromAddr,
@@ -410,11 +439,17 @@ var minerAddAskCmd = &cmds.Command{
 			if err != nil {
 				return err
 			}
-			return re.Emit(&minerAddAskResult{
-				Cid:     cid.Cid{},
-				GasUsed: usedGas,
-				Preview: true,
-			})
+			if preview {
+				return re.Emit(&minerAddAskResult{
+					Cid:     cid.Cid{},
+					GasUsed: usedGas,
+					Preview: true,
+				})
+			}
+			gasPrice, gasLimit, err = estimateGasOptions(req.Context, env, usedGas)
+			if err != nil {
+				return err
+			}
 		}
 
 		c, err := GetAPI(env).Miner().AddAsk(req.Context, fromAddr, minerAddr, gasPrice, gasLimit, price, expiry)
@@ -501,3 +536,340 @@ Values will be output as a ratio where the first number is the miner power and s
 		}),
 	},
 }
+
+var minerSealingCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the sealing queue of the node's miner",
+		ShortDescription: `
+Prints how many sectors are staged, waiting to be sealed and being sealed, how
+many seals succeeded and failed since the node started, and the sectors
+waiting to be sealed, those with the earliest deadlines first. Staged sectors
+are queued for sealing according to the mining.sealPolicy config, or right
+away when one of their deals is less than mining.sealUrgencySeconds from its
+deadline. Queued sectors are sealed right away, along with all the staged
+sectors, and sectors are also sealed as soon as they are full. Sectors whose
+seal failed to start stay queued until the next try.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("miner", "The address of the miner, defaults to the default miner of the node"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		minerAddr, err := optionalMinerAddr(req.Options["miner"])
+		if err != nil {
+			return err
+		}
+		status, err := GetAPI(env).Miner().Sealing(req.Context, minerAddr)
+		if err != nil {
+			return err
+		}
+		return re.Emit(status)
+	},
+	Type: api.SealingStatus{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, status *api.SealingStatus) error {
+			fmt.Fprintf(w, "Staged:\t%d\n", status.Staged)            // nolint: errcheck
+			fmt.Fprintf(w, "Queued:\t%d\n", status.Queued)            // nolint: errcheck
+			fmt.Fprintf(w, "Sealing:\t%d\n", status.Sealing)          // nolint: errcheck
+			fmt.Fprintf(w, "Sealed:\t%d\n", status.Sealed)            // nolint: errcheck
+			fmt.Fprintf(w, "Failed:\t%d\n", status.Failed)            // nolint: errcheck
+			fmt.Fprintf(w, "Longest wait:\t%s\n", status.LongestWait) // nolint: errcheck
+			fmt.Fprintln(w, "Queue:")                                 // nolint: errcheck
+			for _, s := range status.Queue {
+				var deadline string
+				if !s.Deadline.IsZero() {
+					deadline = s.Deadline.Format(time.RFC3339)
+				}
+				fmt.Fprintf(w, "\t%d\t%s\t%s\n", s.SectorID, s.QueuedAt.Format(time.RFC3339), deadline) // nolint: errcheck
+			}
+			return nil
+		}),
+	},
+}
+
+var minerSectorsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Follow the sectors of the node's miner",
+		ShortDescription: `
+Shows the sectors of the node's miner and the deals they hold. A sector goes
+through the states:
+
+  staged       pieces of deals are being added to the sector
+  sealing      the sector is being sealed
+  sealed       the sector is sealed and its commitment not yet sent
+  commit-sent  the commitSector message is waiting to be included in the chain
+  committed    the commitment of the sector is in the chain
+  proving      the sector was included in a proof-of-spacetime
+  faulty       the sector failed to be sealed, committed or proven
+  expired      all the deals of the sector have ended
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"ls":   minerSectorsLsCmd,
+		"show": minerSectorsShowCmd,
+	},
+}
+
+var minerSectorsLsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline:          "List the sectors of the node's miner",
+		ShortDescription: `Prints the id, state, number of deals and time of the last state change of each sector.`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("miner", "The address of the miner, defaults to the default miner of the node"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		minerAddr, err := optionalMinerAddr(req.Options["miner"])
+		if err != nil {
+			return err
+		}
+		sectors, err := GetAPI(env).Miner().Sectors(req.Context, minerAddr)
+		if err != nil {
+			return err
+		}
+		for _, sector := range sectors {
+			if err := re.Emit(sector); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: storage.SectorInfo{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, sector *storage.SectorInfo) error {
+			var changed string
+			if len(sector.History) > 0 {
+				changed = sector.History[len(sector.History)-1].Time.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%d\t%s\n", sector.SectorID, sector.State, len(sector.Deals), changed) // nolint: errcheck
+			return nil
+		}),
+	},
+}
+
+var minerSectorsShowCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline:          "Show a sector of the node's miner",
+		ShortDescription: `Prints the state, deals, commitSector messages and state history of a sector.`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("id", true, false, "The id of the sector"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("miner", "The address of the miner, defaults to the default miner of the node"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		minerAddr, err := optionalMinerAddr(req.Options["miner"])
+		if err != nil {
+			return err
+		}
+		sectorID, err := strconv.ParseUint(req.Arguments[0], 10, 64)
+		if err != nil {
+			return errors.Wrap(err, "invalid sector id")
+		}
+		sector, err := GetAPI(env).Miner().Sector(req.Context, minerAddr, sectorID)
+		if err != nil {
+			return err
+		}
+		return re.Emit(sector)
+	},
+	Type: storage.SectorInfo{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, sector *storage.SectorInfo) error {
+			fmt.Fprintf(w, "Sector:\t%d\n", sector.SectorID) // nolint: errcheck
+			fmt.Fprintf(w, "State:\t%s\n", sector.State)     // nolint: errcheck
+			if sector.ExpiresAt != 0 {
+				fmt.Fprintf(w, "Expires:\t%d\n", sector.ExpiresAt) // nolint: errcheck
+			}
+			fmt.Fprintln(w, "Deals:") // nolint: errcheck
+			for _, c := range sector.Deals {
+				fmt.Fprintf(w, "\t%s\n", c) // nolint: errcheck
+			}
+			if len(sector.CommitMessages) > 0 {
+				fmt.Fprintln(w, "Commit messages:") // nolint: errcheck
+				for _, c := range sector.CommitMessages {
+					fmt.Fprintf(w, "\t%s\n", c) // nolint: errcheck
+				}
+			}
+			fmt.Fprintln(w, "History:") // nolint: errcheck
+			for _, t := range sector.History {
+				fmt.Fprintf(w, "\t%s\t%s\t%s\n", t.Time.Format(time.RFC3339), t.State, t.Message) // nolint: errcheck
+			}
+			return nil
+		}),
+	},
+}
+
+var minerDealPolicyCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage the policy the node's miners apply to storage deals",
+		ShortDescription: `
+A proposed storage deal is rejected when its client is blocked, or when clients
+are allowed and its client is not one of them, when its piece is smaller or
+larger than the size limits, when it lasts less than the minimum duration or
+when its price is lower than the minimum price, or the storage price of the
+miner when the minimum price is zero. A limit of zero is no limit. When a
+filter command is set, deals passing those checks are handed to it as JSON on
+its standard input, and rejected when it exits with an error.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"show":  minerDealPolicyShowCmd,
+		"set":   minerDealPolicySetCmd,
+		"allow": minerDealPolicyAllowCmd,
+		"block": minerDealPolicyBlockCmd,
+	},
+}
+
+var dealPolicyEncoders = cmds.EncoderMap{
+	cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, policy *config.DealPolicyConfig) error {
+		fmt.Fprintf(w, "Min piece size:\t%d\n", policy.MinPieceSize)         // nolint: errcheck
+		fmt.Fprintf(w, "Max piece size:\t%d\n", policy.MaxPieceSize)         // nolint: errcheck
+		fmt.Fprintf(w, "Min duration:\t%d\n", policy.MinDuration)            // nolint: errcheck
+		fmt.Fprintf(w, "Min price:\t%s\n", policy.MinPrice)                  // nolint: errcheck
+		fmt.Fprintf(w, "Filter command:\t%s\n", policy.FilterCommand)        // nolint: errcheck
+		fmt.Fprintf(w, "Allowed clients:\t%d\n", len(policy.AllowedClients)) // nolint: errcheck
+		for _, a := range policy.AllowedClients {
+			fmt.Fprintf(w, "\t%s\n", a) // nolint: errcheck
+		}
+		fmt.Fprintf(w, "Blocked clients:\t%d\n", len(policy.BlockedClients)) // nolint: errcheck
+		for _, a := range policy.BlockedClients {
+			fmt.Fprintf(w, "\t%s\n", a) // nolint: errcheck
+		}
+		return nil
+	}),
+}
+
+var minerDealPolicyShowCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the deal policy of the node's miners",
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		policy, err := GetPorcelainAPI(env).DealPolicyGet()
+		if err != nil {
+			return err
+		}
+		return re.Emit(policy)
+	},
+	Type:     config.DealPolicyConfig{},
+	Encoders: dealPolicyEncoders,
+}
+
+var minerDealPolicySetCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline:          "Change the limits of the deal policy of the node's miners",
+		ShortDescription: `Only the limits given as options are changed. Pass an empty --filter-command to remove the filter command.`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.Uint64Option("min-piece-size", "Smallest piece size in bytes, 0 for no limit"),
+		cmdkit.Uint64Option("max-piece-size", "Largest piece size in bytes, 0 for no limit"),
+		cmdkit.Uint64Option("min-duration", "Shortest deal duration in blocks, 0 for no limit"),
+		cmdkit.StringOption("min-price", "Lowest price per byte per block in FIL, 0 to use the storage price of the miner"),
+		cmdkit.StringOption("filter-command", "Shell command deciding on deals, which exits with an error to reject them"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		var minPrice *types.AttoFIL
+		if o, ok := req.Options["min-price"].(string); ok {
+			var valid bool
+			minPrice, valid = types.NewAttoFILFromFILString(o)
+			if !valid {
+				return ErrInvalidPrice
+			}
+		}
+
+		policy, err := GetPorcelainAPI(env).DealPolicyUpdate(func(p *config.DealPolicyConfig) {
+			if v, ok := req.Options["min-piece-size"].(uint64); ok {
+				p.MinPieceSize = v
+			}
+			if v, ok := req.Options["max-piece-size"].(uint64); ok {
+				p.MaxPieceSize = v
+			}
+			if v, ok := req.Options["min-duration"].(uint64); ok {
+				p.MinDuration = v
+			}
+			if minPrice != nil {
+				p.MinPrice = minPrice
+			}
+			if v, ok := req.Options["filter-command"].(string); ok {
+				p.FilterCommand = v
+			}
+		})
+		if err != nil {
+			return err
+		}
+		return re.Emit(policy)
+	},
+	Type:     config.DealPolicyConfig{},
+	Encoders: dealPolicyEncoders,
+}
+
+var minerDealPolicyAllowCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline:          "Add <client> to the clients the node's miners take deals from",
+		ShortDescription: `Once a client is allowed, deals from clients that are not allowed are rejected.`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("client", true, false, "Address of the client"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("remove", "Remove the client from the allowed clients instead"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		return updateDealPolicyClients(req, re, env, func(p *config.DealPolicyConfig) *[]address.Address {
+			return &p.AllowedClients
+		})
+	},
+	Type:     config.DealPolicyConfig{},
+	Encoders: dealPolicyEncoders,
+}
+
+var minerDealPolicyBlockCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline:          "Add <client> to the clients the node's miners reject deals from",
+		ShortDescription: `Blocked clients are rejected even when they are allowed.`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("client", true, false, "Address of the client"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("remove", "Remove the client from the blocked clients instead"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		return updateDealPolicyClients(req, re, env, func(p *config.DealPolicyConfig) *[]address.Address {
+			return &p.BlockedClients
+		})
+	},
+	Type:     config.DealPolicyConfig{},
+	Encoders: dealPolicyEncoders,
+}
+
+// updateDealPolicyClients adds the client of req to, or removes it from, the
+// list of clients of the deal policy clients points to.
+func updateDealPolicyClients(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment, clients func(*config.DealPolicyConfig) *[]address.Address) error {
+	client, err := address.NewFromString(req.Arguments[0])
+	if err != nil {
+		return errors.Wrap(err, "client must be an address")
+	}
+	remove, _ := req.Options["remove"].(bool)
+
+	policy, err := GetPorcelainAPI(env).DealPolicyUpdate(func(p *config.DealPolicyConfig) {
+		list := clients(p)
+		var updated []address.Address
+		for _, a := range *list {
+			if a != client {
+				updated = append(updated, a)
+			}
+		}
+		if !remove {
+			updated = append(updated, client)
+		}
+		if updated == nil {
+			updated = []address.Address{}
+		}
+		*list = updated
+	})
+	if err != nil {
+		return err
+	}
+	return re.Emit(policy)
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/filecoin-project/go-filecoin/api"
+	"github.com/filecoin-project/go-filecoin/config"
 	"github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/types"
 	"io/ioutil"
@@ -300,9 +301,9 @@ func TestMinerSetPrice(t *testing.T) {
 	setPrice := d1.RunSuccess("miner", "set-price", "62", "6", "--price", "0", "--limit", "300")
 	assert.Contains(setPrice.ReadStdoutTrimNewlines(), fmt.Sprintf("Set price for miner %s to 62.", fixtures.TestMiners[0]))
 
-	configuredPrice := d1.RunSuccess("config", "mining.storagePrice")
+	configuredPrices := d1.RunSuccess("config", "mining.storagePrices")
 
-	assert.Equal(`"62"`, configuredPrice.ReadStdoutTrimNewlines())
+	assert.Equal(fmt.Sprintf(`{"%s":"62"}`, fixtures.TestMiners[0]), configuredPrices.ReadStdoutTrimNewlines())
 }
 
 func TestMinerAddAskSuccess(t *testing.T) {
@@ -524,3 +525,58 @@ var testConfig = &gengen.GenesisCfg{
 		},
 	},
 }
+
+func TestMinerSectors(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	d := th.NewDaemon(t, th.WithMiner(fixtures.TestMiners[0]), th.KeyFile(fixtures.KeyFilePaths()[0])).Start()
+	defer d.ShutdownSuccess()
+
+	assert.Equal("", d.RunSuccess("miner", "sectors", "ls").ReadStdoutTrimNewlines())
+	d.RunFail("sector not found", "miner", "sectors", "show", "1")
+	d.RunFail("invalid sector id", "miner", "sectors", "show", "x")
+}
+
+func TestMinerSealing(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	d := th.NewDaemon(t, th.WithMiner(fixtures.TestMiners[0]), th.KeyFile(fixtures.KeyFilePaths()[0])).Start()
+	defer d.ShutdownSuccess()
+
+	out := d.RunSuccess("miner", "sealing").ReadStdout()
+	assert.Contains(out, "Staged:\t0")
+	assert.Contains(out, "Sealing:\t0")
+	d.RunFail("miner must be an address", "miner", "sealing", "--miner", "x")
+}
+
+func TestMinerDealPolicy(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	require := require.New(t)
+
+	d := th.NewDaemon(t, th.WithMiner(fixtures.TestMiners[0]), th.KeyFile(fixtures.KeyFilePaths()[0])).Start()
+	defer d.ShutdownSuccess()
+
+	out := d.RunSuccess("miner", "deal-policy", "show").ReadStdout()
+	assert.Contains(out, "Min piece size:\t0")
+	assert.Contains(out, "Blocked clients:\t0")
+
+	d.RunSuccess("miner", "deal-policy", "set", "--min-piece-size", "100", "--min-duration", "10", "--min-price", "0.5")
+	d.RunSuccess("miner", "deal-policy", "block", fixtures.TestAddresses[1])
+	d.RunSuccess("miner", "deal-policy", "allow", fixtures.TestAddresses[2])
+	d.RunSuccess("miner", "deal-policy", "allow", fixtures.TestAddresses[2], "--remove")
+	d.RunFail("client must be an address", "miner", "deal-policy", "block", "x")
+	d.RunFail(ErrInvalidPrice.Error(), "miner", "deal-policy", "set", "--min-price", "x")
+
+	var policy config.DealPolicyConfig
+	require.NoError(json.Unmarshal([]byte(d.RunSuccess("miner", "deal-policy", "show", "--enc", "json").ReadStdout()), &policy))
+	assert.Equal(uint64(100), policy.MinPieceSize)
+	assert.Equal(uint64(0), policy.MaxPieceSize)
+	assert.Equal(uint64(10), policy.MinDuration)
+	assert.Equal("0.5", policy.MinPrice.String())
+	require.Len(policy.BlockedClients, 1)
+	assert.Equal(fixtures.TestAddresses[1], policy.BlockedClients[0].String())
+	assert.Empty(policy.AllowedClients)
+}
@@ -3,10 +3,13 @@ package commands
 import (
 	"fmt"
 	"io"
+	"time"
 
 	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
 	"gx/ipfs/Qma6uuSyjkecGhMFFLfzyJDPyoDtNJSHJNweDccZhaWkgU/go-ipfs-cmds"
 	cmdkit "gx/ipfs/Qmde5VP1qUkyQXKCfmEUA7bP64V2HAptbJ7phuPp7jXWwg/go-ipfs-cmdkit"
+
+	"github.com/filecoin-project/go-filecoin/porcelain"
 )
 
 var miningCmd = &cmds.Command{
@@ -14,9 +17,11 @@ var miningCmd = &cmds.Command{
 		Tagline: "Manage all mining operations for a node",
 	},
 	Subcommands: map[string]*cmds.Command{
-		"once":  miningOnceCmd,
-		"start": miningStartCmd,
-		"stop":  miningStopCmd,
+		"history": miningHistoryCmd,
+		"once":    miningOnceCmd,
+		"start":   miningStartCmd,
+		"status":  miningStatusCmd,
+		"stop":    miningStopCmd,
 	},
 }
 
@@ -38,8 +43,19 @@ var miningOnceCmd = &cmds.Command{
 }
 
 var miningStartCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline:          "Start mining for the miners of the node",
+		ShortDescription: `Starts mining for all the miners of the node, or only for the one given with --miner.`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("miner", "The address of the miner to start"),
+	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
-		if err := GetAPI(env).Mining().Start(req.Context); err != nil {
+		minerAddr, err := optionalMinerAddr(req.Options["miner"])
+		if err != nil {
+			return err
+		}
+		if err := GetAPI(env).Mining().Start(req.Context, minerAddr); err != nil {
 			return err
 		}
 		return re.Emit("Started mining")
@@ -49,8 +65,19 @@ var miningStartCmd = &cmds.Command{
 }
 
 var miningStopCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline:          "Stop mining for the miners of the node",
+		ShortDescription: `Stops mining for all the miners of the node, or only for the one given with --miner.`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("miner", "The address of the miner to stop"),
+	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
-		if err := GetAPI(env).Mining().Stop(req.Context); err != nil {
+		minerAddr, err := optionalMinerAddr(req.Options["miner"])
+		if err != nil {
+			return err
+		}
+		if err := GetAPI(env).Mining().Stop(req.Context, minerAddr); err != nil {
 			return err
 		}
 		return re.Emit("Stopped mining")
@@ -58,6 +85,95 @@ var miningStopCmd = &cmds.Command{
 	Encoders: stringEncoderMap,
 }
 
+var miningStatusCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show how the node's mining is going",
+		ShortDescription: `
+Prints the number of rounds the node mined since it started, of blocks it won,
+of those blocks in the chain and the rewards they earned, and the last round.
+Only the last rounds are kept, see mining history.
+`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		status, err := GetPorcelainAPI(env).MiningStatus(req.Context)
+		if err != nil {
+			return err
+		}
+		return re.Emit(status)
+	},
+	Type: porcelain.MiningStatus{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, status *porcelain.MiningStatus) error {
+			fmt.Fprintf(w, "Rounds:\t%d\n", status.Rounds)                 // nolint: errcheck
+			fmt.Fprintf(w, "Won:\t%d\n", status.Won)                       // nolint: errcheck
+			fmt.Fprintf(w, "Canonical:\t%d\n", status.Canonical)           // nolint: errcheck
+			fmt.Fprintf(w, "Orphaned:\t%d\n", status.Won-status.Canonical) // nolint: errcheck
+			fmt.Fprintf(w, "Rewards:\t%s FIL\n", status.Rewards.String())  // nolint: errcheck
+			if status.Last != nil {
+				fmt.Fprintf(w, "Last round:\t%s\n", formatMiningRound(status.Last)) // nolint: errcheck
+			}
+			return nil
+		}),
+	},
+}
+
+var miningHistoryCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the last rounds mined by the node",
+		ShortDescription: `
+Prints a line for each of the last rounds mined by the node, oldest first, with
+the time, miner and height of the round, its number of null blocks, whether the
+node won, and the block it mined, whether that block is in the chain and its
+reward. A block is superseded when the node mined again on a wider or newer
+base instead of publishing it.
+Use --enc=json to get the base tipsets and tickets.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.UintOption("limit", "Only show the last <limit> rounds"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		rounds, err := GetPorcelainAPI(env).MiningHistory(req.Context)
+		if err != nil {
+			return err
+		}
+		if limit, ok := req.Options["limit"].(uint); ok && int(limit) < len(rounds) {
+			rounds = rounds[len(rounds)-int(limit):]
+		}
+		for i := range rounds {
+			if err := re.Emit(&rounds[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: porcelain.MiningRound{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, round *porcelain.MiningRound) error {
+			fmt.Fprintln(w, formatMiningRound(round)) // nolint: errcheck
+			return nil
+		}),
+	},
+}
+
+// formatMiningRound formats round as tab separated fields.
+func formatMiningRound(round *porcelain.MiningRound) string {
+	out := fmt.Sprintf("%s\t%s\t%d\t%d", round.Time.Format(time.RFC3339), round.Miner, round.Height, round.NullBlocks)
+	switch {
+	case round.Err != "":
+		out += "\terror: " + round.Err
+	case !round.Won:
+		out += "\tlost"
+	case round.Superseded:
+		out += fmt.Sprintf("\twon\t%s\tsuperseded", round.Block)
+	case round.Canonical:
+		out += fmt.Sprintf("\twon\t%s\tcanonical\t%s FIL", round.Block, round.Reward.String())
+	default:
+		out += fmt.Sprintf("\twon\t%s\torphaned", round.Block)
+	}
+	return out
+}
+
 var stringEncoderMap = cmds.EncoderMap{
 	cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, t string) error {
 		fmt.Fprintln(w, t) // nolint: errcheck
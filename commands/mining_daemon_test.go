@@ -36,3 +36,14 @@ func TestMiningGenBlock(t *testing.T) {
 
 	assert.True(sum.Add(beforeBalance, big.NewInt(1000)).Cmp(afterBalance) == 0)
 }
+
+func TestMiningStartStopMiner(t *testing.T) {
+	t.Parallel()
+	d := th.NewDaemon(t, th.WithMiner(fixtures.TestMiners[0]), th.KeyFile(fixtures.KeyFilePaths()[0])).Start()
+	defer d.ShutdownSuccess()
+
+	d.RunSuccess("mining", "start", "--miner", fixtures.TestMiners[0])
+	d.RunFail("already mining", "mining", "start", "--miner", fixtures.TestMiners[0])
+	d.RunSuccess("mining", "stop", "--miner", fixtures.TestMiners[0])
+	d.RunFail("is not a miner of the node", "mining", "start", "--miner", fixtures.TestMiners[1])
+}
@@ -9,6 +9,7 @@ import (
 	"gx/ipfs/Qma6uuSyjkecGhMFFLfzyJDPyoDtNJSHJNweDccZhaWkgU/go-ipfs-cmds"
 	"gx/ipfs/Qmde5VP1qUkyQXKCfmEUA7bP64V2HAptbJ7phuPp7jXWwg/go-ipfs-cmdkit"
 
+	"github.com/filecoin-project/go-filecoin/core"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
@@ -17,8 +18,9 @@ var mpoolCmd = &cmds.Command{
 		Tagline: "Manage the message pool",
 	},
 	Subcommands: map[string]*cmds.Command{
-		"ls": mpoolLsCmd,
-		"rm": mpoolRemoveCmd,
+		"ls":  mpoolLsCmd,
+		"rm":  mpoolRemoveCmd,
+		"sub": mpoolSubCmd,
 	},
 }
 
@@ -72,3 +74,41 @@ var mpoolRemoveCmd = &cmds.Command{
 		return nil
 	},
 }
+
+var mpoolSubCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Stream the changes of the message pool",
+		ShortDescription: `
+Prints an event each time a message is added to the message pool or leaves it.
+The type of an event is one of:
+
+  add       the message was added to the pool
+  remove    the message was removed from the pool on request
+  included  the message was included in a block of the chain
+  evicted   the message expired or was replaced by another message
+
+The command fails if it falls behind the message pool and misses events, after
+which the pool should be listed again.
+
+Use --enc=json to get the events with their messages as JSON.
+`,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		for event := range GetPorcelainAPI(env).MessagePoolSubscribe(req.Context) {
+			if event.Type == core.MessageEventsDropped {
+				return errors.New("missed message pool events, list the pool again to resync")
+			}
+			if err := re.Emit(event); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: core.MessagePoolEvent{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, event *core.MessagePoolEvent) error {
+			fmt.Fprintf(w, "%s\t%s\n", event.Type, event.Cid) // nolint: errcheck
+			return nil
+		}),
+	},
+}
@@ -52,6 +52,7 @@ message to be mined to get the channelID.`,
 		cmdkit.StringOption("from", "Address to send from"),
 		priceOption,
 		limitOption,
+		gasAutoOption,
 		previewOption,
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
@@ -80,7 +81,7 @@ message to be mined to get the channelID.`,
 			return err
 		}
 
-		if preview {
+		if preview || isGasAuto(req) {
 			usedGas, err := GetPorcelainAPI(env).MessagePreview(
 				req.Context,
 				fromAddr,
@@ -91,11 +92,17 @@ message to be mined to get the channelID.`,
 			if err != nil {
 				return err
 			}
-			return re.Emit(&createChannelResult{
-				Cid:     cid.Cid{},
-				GasUsed: usedGas,
-				Preview: true,
-			})
+			if preview {
+				return re.Emit(&createChannelResult{
+					Cid:     cid.Cid{},
+					GasUsed: usedGas,
+					Preview: true,
+				})
+			}
+			gasPrice, gasLimit, err = estimateGasOptions(req.Context, env, usedGas)
+			if err != nil {
+				return err
+			}
 		}
 
 		c, err := GetAPI(env).Paych().Create(req.Context, fromAddr, gasPrice, gasLimit, target, eol, amount)
@@ -235,6 +242,7 @@ var redeemCmd = &cmds.Command{
 		cmdkit.StringOption("from", "Address of the channel target"),
 		priceOption,
 		limitOption,
+		gasAutoOption,
 		previewOption,
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
@@ -248,7 +256,7 @@ var redeemCmd = &cmds.Command{
 			return err
 		}
 
-		if preview {
+		if preview || isGasAuto(req) {
 			_, cborVoucher, err := multibase.Decode(req.Arguments[0])
 			if err != nil {
 				return err
@@ -270,11 +278,17 @@ var redeemCmd = &cmds.Command{
 			if err != nil {
 				return err
 			}
-			return re.Emit(&redeemResult{
-				Cid:     cid.Cid{},
-				GasUsed: usedGas,
-				Preview: true,
-			})
+			if preview {
+				return re.Emit(&redeemResult{
+					Cid:     cid.Cid{},
+					GasUsed: usedGas,
+					Preview: true,
+				})
+			}
+			gasPrice, gasLimit, err = estimateGasOptions(req.Context, env, usedGas)
+			if err != nil {
+				return err
+			}
 		}
 
 		c, err := GetAPI(env).Paych().Redeem(req.Context, fromAddr, gasPrice, gasLimit, req.Arguments[0])
@@ -318,6 +332,7 @@ var reclaimCmd = &cmds.Command{
 		cmdkit.StringOption("from", "Address of the channel creator"),
 		priceOption,
 		limitOption,
+		gasAutoOption,
 		previewOption,
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
@@ -336,7 +351,7 @@ var reclaimCmd = &cmds.Command{
 			return err
 		}
 
-		if preview {
+		if preview || isGasAuto(req) {
 			usedGas, err := GetPorcelainAPI(env).MessagePreview(
 				req.Context,
 				fromAddr,
@@ -347,11 +362,17 @@ var reclaimCmd = &cmds.Command{
 			if err != nil {
 				return err
 			}
-			return re.Emit(&reclaimResult{
-				Cid:     cid.Cid{},
-				GasUsed: usedGas,
-				Preview: true,
-			})
+			if preview {
+				return re.Emit(&reclaimResult{
+					Cid:     cid.Cid{},
+					GasUsed: usedGas,
+					Preview: true,
+				})
+			}
+			gasPrice, gasLimit, err = estimateGasOptions(req.Context, env, usedGas)
+			if err != nil {
+				return err
+			}
 		}
 
 		c, err := GetAPI(env).Paych().Reclaim(req.Context, fromAddr, gasPrice, gasLimit, channel)
@@ -395,6 +416,7 @@ var closeCmd = &cmds.Command{
 		cmdkit.StringOption("from", "Address of the channel target"),
 		priceOption,
 		limitOption,
+		gasAutoOption,
 		previewOption,
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
@@ -408,7 +430,7 @@ var closeCmd = &cmds.Command{
 			return err
 		}
 
-		if preview {
+		if preview || isGasAuto(req) {
 			_, cborVoucher, err := multibase.Decode(req.Arguments[0])
 			if err != nil {
 				return err
@@ -430,11 +452,17 @@ var closeCmd = &cmds.Command{
 			if err != nil {
 				return err
 			}
-			return re.Emit(&closeResult{
-				Cid:     cid.Cid{},
-				GasUsed: usedGas,
-				Preview: true,
-			})
+			if preview {
+				return re.Emit(&closeResult{
+					Cid:     cid.Cid{},
+					GasUsed: usedGas,
+					Preview: true,
+				})
+			}
+			gasPrice, gasLimit, err = estimateGasOptions(req.Context, env, usedGas)
+			if err != nil {
+				return err
+			}
 		}
 
 		c, err := GetAPI(env).Paych().Close(req.Context, fromAddr, gasPrice, gasLimit, req.Arguments[0])
@@ -480,6 +508,7 @@ var extendCmd = &cmds.Command{
 		cmdkit.StringOption("from", "Address of the channel creator"),
 		priceOption,
 		limitOption,
+		gasAutoOption,
 		previewOption,
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
@@ -508,7 +537,7 @@ var extendCmd = &cmds.Command{
 			return err
 		}
 
-		if preview {
+		if preview || isGasAuto(req) {
 			usedGas, err := GetPorcelainAPI(env).MessagePreview(
 				req.Context,
 				fromAddr,
@@ -519,11 +548,17 @@ var extendCmd = &cmds.Command{
 			if err != nil {
 				return err
 			}
-			return re.Emit(&extendResult{
-				Cid:     cid.Cid{},
-				GasUsed: usedGas,
-				Preview: true,
-			})
+			if preview {
+				return re.Emit(&extendResult{
+					Cid:     cid.Cid{},
+					GasUsed: usedGas,
+					Preview: true,
+				})
+			}
+			gasPrice, gasLimit, err = estimateGasOptions(req.Context, env, usedGas)
+			if err != nil {
+				return err
+			}
 		}
 
 		c, err := GetAPI(env).Paych().Extend(req.Context, fromAddr, gasPrice, gasLimit, channel, eol, amount)
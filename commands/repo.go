@@ -0,0 +1,75 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+	"gx/ipfs/Qma6uuSyjkecGhMFFLfzyJDPyoDtNJSHJNweDccZhaWkgU/go-ipfs-cmds"
+	"gx/ipfs/Qmde5VP1qUkyQXKCfmEUA7bP64V2HAptbJ7phuPp7jXWwg/go-ipfs-cmdkit"
+
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/config"
+)
+
+var repoCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage the filecoin repo",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"gc": repoGCCmd,
+	},
+}
+
+var repoGCCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Prune old chain state from the repo",
+		ShortDescription: `
+Removes the state trees of all but the most recent tipsets of the chain, and of
+checkpoint tipsets, along with old tipsets that are not on the chain. Blocks of
+the chain are always kept. The number of recent states to keep and the
+checkpoint interval default to the chain.pruneKeepRecent and
+chain.pruneCheckpointInterval config values.
+`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.UintOption("keep-recent", "Number of most recent tipsets whose state is kept"),
+		cmdkit.UintOption("checkpoint-interval", "Keep the state of tipsets whose height is a multiple of this interval, 0 keeps no checkpoints"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		chainCfg, err := GetPorcelainAPI(env).ConfigGet("chain")
+		if err != nil {
+			return err
+		}
+		cfg, ok := chainCfg.(*config.ChainConfig)
+		if !ok {
+			return errors.New("unexpected chain config type")
+		}
+
+		opts := chain.GCOptions{
+			KeepRecent:         cfg.PruneKeepRecent,
+			CheckpointInterval: cfg.PruneCheckpointInterval,
+		}
+		if keepRecent, ok := req.Options["keep-recent"].(uint); ok {
+			opts.KeepRecent = uint64(keepRecent)
+		}
+		if interval, ok := req.Options["checkpoint-interval"].(uint); ok {
+			opts.CheckpointInterval = uint64(interval)
+		}
+
+		res, err := GetPorcelainAPI(env).ChainCollectGarbage(req.Context, opts)
+		if err != nil {
+			return err
+		}
+		return re.Emit(res)
+	},
+	Type: chain.GCResult{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, res *chain.GCResult) error {
+			fmt.Fprintf(w, "Pruned States:    %d\n", res.PrunedStates)   // nolint: errcheck
+			fmt.Fprintf(w, "Removed TipSets:  %d\n", res.RemovedTipSets) // nolint: errcheck
+			fmt.Fprintf(w, "Removed Nodes:    %d\n", res.RemovedNodes)   // nolint: errcheck
+			return nil
+		}),
+	},
+}
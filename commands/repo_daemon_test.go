@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/fixtures"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepoGC(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	require := require.New(t)
+
+	d := th.NewDaemon(t, th.WithMiner(fixtures.TestMiners[0])).Start()
+	defer d.ShutdownSuccess()
+
+	d.RunFail(chain.ErrPruningDisabled.Error(), "repo", "gc")
+
+	d.RunSuccess("mining", "once")
+	d.RunSuccess("mining", "once")
+
+	op := d.RunSuccess("repo", "gc", "--keep-recent", "1", "--enc", "json")
+	var res chain.GCResult
+	require.NoError(json.Unmarshal([]byte(op.ReadStdoutTrimNewlines()), &res))
+	assert.Equal(1, res.PrunedStates)
+
+	// the head state is still available
+	d.RunSuccess("actor", "ls")
+}
@@ -17,13 +17,15 @@ import (
 
 // Config is an in memory representation of the filecoin configuration file
 type Config struct {
-	API       *APIConfig       `json:"api"`
-	Bootstrap *BootstrapConfig `json:"bootstrap"`
-	Datastore *DatastoreConfig `json:"datastore"`
-	Swarm     *SwarmConfig     `json:"swarm"`
-	Mining    *MiningConfig    `json:"mining"`
-	Wallet    *WalletConfig    `json:"wallet"`
-	Heartbeat *HeartbeatConfig `json:"heartbeat"`
+	API       *APIConfig         `json:"api"`
+	Bootstrap *BootstrapConfig   `json:"bootstrap"`
+	Datastore *DatastoreConfig   `json:"datastore"`
+	Swarm     *SwarmConfig       `json:"swarm"`
+	Mining    *MiningConfig      `json:"mining"`
+	Wallet    *WalletConfig      `json:"wallet"`
+	Heartbeat *HeartbeatConfig   `json:"heartbeat"`
+	Chain     *ChainConfig       `json:"chain"`
+	Mpool     *MessagePoolConfig `json:"mpool"`
 }
 
 // APIConfig holds all configuration options related to the api.
@@ -61,6 +63,7 @@ type DatastoreConfig struct {
 // being set matches the name given in this map.
 var Validators = map[string]func(string, string) error{
 	"heartbeat.nickname": validateLettersOnly,
+	"mining.sealPolicy":  validateSealPolicy,
 }
 
 func newDefaultDatastoreConfig() *DatastoreConfig {
@@ -100,16 +103,77 @@ func newDefaultBootstrapConfig() *BootstrapConfig {
 
 // MiningConfig holds all configuration options related to mining.
 type MiningConfig struct {
-	MinerAddress            address.Address `json:"minerAddress"`
-	AutoSealIntervalSeconds uint            `json:"autoSealIntervalSeconds"`
-	StoragePrice            *types.AttoFIL  `json:"storagePrice"`
+	// MinerAddress is the default miner of the node.
+	MinerAddress address.Address `json:"minerAddress"`
+	// MinerAddresses are the other miners of the node. Each has its own
+	// sectors, deals and mining worker, like the default miner.
+	MinerAddresses []address.Address `json:"minerAddresses,omitempty"`
+	// AutoSealIntervalSeconds is how long a staged sector sealed on timeout
+	// waits for pieces before it is sealed, 0 to never seal on timeout.
+	AutoSealIntervalSeconds uint `json:"autoSealIntervalSeconds"`
+	// SealPolicy is when staged sectors are sealed: "timeout" to seal them
+	// after AutoSealIntervalSeconds or "full" to seal them once full.
+	SealPolicy string `json:"sealPolicy"`
+	// SealUrgencySeconds makes a staged sector holding a deal less than
+	// SealUrgencySeconds from its deadline sealed right away.
+	SealUrgencySeconds uint `json:"sealUrgencySeconds"`
+	// StoragePrice is the price of storage of the miners without a price in
+	// StoragePrices.
+	StoragePrice *types.AttoFIL `json:"storagePrice"`
+	// StoragePrices are the prices of storage set for the miners of the node
+	// by address.
+	StoragePrices map[string]*types.AttoFIL `json:"storagePrices"`
+	// DealPolicy filters the storage deals proposed to the miners.
+	DealPolicy *DealPolicyConfig `json:"dealPolicy"`
+}
+
+// DealPolicyConfig holds the filters the miners apply to the storage deals
+// proposed to them before looking at their payment. The zero value of a
+// filter disables it.
+type DealPolicyConfig struct {
+	// MinPieceSize is the smallest piece, in bytes, the miners store.
+	MinPieceSize uint64 `json:"minPieceSize"`
+	// MaxPieceSize is the largest piece, in bytes, the miners store.
+	MaxPieceSize uint64 `json:"maxPieceSize"`
+	// MinDuration is the shortest deal, in blocks, the miners make.
+	MinDuration uint64 `json:"minDuration"`
+	// AllowedClients, when not empty, are the only clients the miners make
+	// deals with.
+	AllowedClients []address.Address `json:"allowedClients"`
+	// BlockedClients are clients the miners make no deals with.
+	BlockedClients []address.Address `json:"blockedClients"`
+	// MinPrice is the lowest price per byte per block the miners take. When
+	// zero, deals must pay at least the storage price.
+	MinPrice *types.AttoFIL `json:"minPrice"`
+	// FilterCommand is a shell command run for each proposal passing the
+	// other filters, with the proposal as JSON on its standard input. The
+	// proposal is rejected when it exits with a non-zero status, with the
+	// first line of its standard output as the reason sent to the client.
+	// Its standard error is only logged.
+	FilterCommand string `json:"filterCommand"`
+}
+
+func newDefaultDealPolicyConfig() *DealPolicyConfig {
+	return &DealPolicyConfig{
+		MinPieceSize:   0,
+		MaxPieceSize:   0,
+		MinDuration:    0,
+		AllowedClients: []address.Address{},
+		BlockedClients: []address.Address{},
+		MinPrice:       types.NewZeroAttoFIL(),
+		FilterCommand:  "",
+	}
 }
 
 func newDefaultMiningConfig() *MiningConfig {
 	return &MiningConfig{
 		MinerAddress:            address.Address{},
 		AutoSealIntervalSeconds: 120,
+		SealPolicy:              "timeout",
+		SealUrgencySeconds:      600,
 		StoragePrice:            types.NewZeroAttoFIL(),
+		StoragePrices:           map[string]*types.AttoFIL{},
+		DealPolicy:              newDefaultDealPolicyConfig(),
 	}
 }
 
@@ -147,6 +211,66 @@ func newDefaultHeartbeatConfig() *HeartbeatConfig {
 	}
 }
 
+// ChainConfig holds all configuration options related to the chain store.
+type ChainConfig struct {
+	// PruneKeepRecent is the number of most recent tipsets whose state is
+	// kept when garbage collecting.  Zero disables pruning.
+	PruneKeepRecent uint64 `json:"pruneKeepRecent"`
+	// PruneCheckpointInterval keeps the state of every tipset whose height is
+	// a multiple of it when garbage collecting.  Zero disables checkpoints.
+	PruneCheckpointInterval uint64 `json:"pruneCheckpointInterval"`
+	// GCPeriod is how often the node garbage collects, an empty period
+	// disables automatic garbage collection.
+	// Golang duration units are accepted.
+	GCPeriod string `json:"gcPeriod"`
+	// IndexAddresses enables the index of the messages of the chain by
+	// sender and recipient used by address history.
+	IndexAddresses bool `json:"indexAddresses"`
+}
+
+func newDefaultChainConfig() *ChainConfig {
+	return &ChainConfig{
+		PruneKeepRecent:         0,
+		PruneCheckpointInterval: 0,
+		GCPeriod:                "",
+		IndexAddresses:          false,
+	}
+}
+
+// MessagePoolConfig holds all configuration options related to the message
+// pool.
+type MessagePoolConfig struct {
+	// MaxPoolSize is the maximum number of messages in the pool.
+	MaxPoolSize int `json:"maxPoolSize"`
+	// MaxSenderMessages is the maximum number of messages in the pool sent
+	// from a single address.
+	MaxSenderMessages int `json:"maxSenderMessages"`
+	// MaxNonceGap is the largest difference allowed between the nonce of a
+	// message and the nonce of its sender in the head state.
+	MaxNonceGap uint64 `json:"maxNonceGap"`
+	// ReplaceByFeePercent is how much higher, in percent, the gas price of a
+	// message must be to replace a message in the pool with the same sender
+	// and nonce.
+	ReplaceByFeePercent uint64 `json:"replaceByFeePercent"`
+	// MessageTTL is the number of blocks after which a message still in the
+	// pool expires, zero disables expiry.
+	MessageTTL uint64 `json:"messageTTL"`
+	// RebroadcastPeriod is how often the pending messages sent by the node
+	// are published again, empty disables rebroadcast.
+	RebroadcastPeriod string `json:"rebroadcastPeriod"`
+}
+
+func newDefaultMessagePoolConfig() *MessagePoolConfig {
+	return &MessagePoolConfig{
+		MaxPoolSize:         10000,
+		MaxSenderMessages:   256,
+		MaxNonceGap:         100,
+		ReplaceByFeePercent: 10,
+		MessageTTL:          100,
+		RebroadcastPeriod:   "1m",
+	}
+}
+
 // NewDefaultConfig returns a config object with all the fields filled out to
 // their default values
 func NewDefaultConfig() *Config {
@@ -158,6 +282,8 @@ func NewDefaultConfig() *Config {
 		Mining:    newDefaultMiningConfig(),
 		Wallet:    newDefaultWalletConfig(),
 		Heartbeat: newDefaultHeartbeatConfig(),
+		Chain:     newDefaultChainConfig(),
+		Mpool:     newDefaultMessagePoolConfig(),
 	}
 }
 
@@ -290,3 +416,12 @@ func validateLettersOnly(key string, value string) error {
 	}
 	return nil
 }
+
+// validateSealPolicy validates that a given value is a seal policy the miners
+// know about.
+func validateSealPolicy(key string, value string) error {
+	if value != `"timeout"` && value != `"full"` {
+		return errors.Errorf(`"%s" must be "timeout" or "full"`, key)
+	}
+	return nil
+}
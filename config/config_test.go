@@ -66,7 +66,19 @@ func TestWriteFile(t *testing.T) {
 	"mining": {
 		"minerAddress": "",
 		"autoSealIntervalSeconds": 120,
-		"storagePrice": "0"
+		"sealPolicy": "timeout",
+		"sealUrgencySeconds": 600,
+		"storagePrice": "0",
+		"storagePrices": {},
+		"dealPolicy": {
+			"minPieceSize": 0,
+			"maxPieceSize": 0,
+			"minDuration": 0,
+			"allowedClients": [],
+			"blockedClients": [],
+			"minPrice": "0",
+			"filterCommand": ""
+		}
 	},
 	"wallet": {
 		"defaultAddress": ""
@@ -76,6 +88,20 @@ func TestWriteFile(t *testing.T) {
 		"beatPeriod": "3s",
 		"reconnectPeriod": "10s",
 		"nickname": ""
+	},
+	"chain": {
+		"pruneKeepRecent": 0,
+		"pruneCheckpointInterval": 0,
+		"gcPeriod": "",
+		"indexAddresses": false
+	},
+	"mpool": {
+		"maxPoolSize": 10000,
+		"maxSenderMessages": 256,
+		"maxNonceGap": 100,
+		"replaceByFeePercent": 10,
+		"messageTTL": 100,
+		"rebroadcastPeriod": "1m"
 	}
 }`,
 		string(content),
@@ -97,6 +123,15 @@ func TestSetRejectsInvalidNicks(t *testing.T) {
 	assert.Error(err)
 }
 
+func TestSetRejectsInvalidSealPolicies(t *testing.T) {
+	assert := assert.New(t)
+	cfg := NewDefaultConfig()
+
+	assert.NoError(cfg.Set("mining.sealPolicy", `"full"`))
+	assert.Equal("full", cfg.Mining.SealPolicy)
+	assert.Error(cfg.Set("mining.sealPolicy", `"never"`))
+}
+
 func TestConfigRoundtrip(t *testing.T) {
 	assert := assert.New(t)
 
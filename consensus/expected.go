@@ -6,6 +6,8 @@ import (
 	"encoding/binary"
 	"fmt"
 	"math/big"
+	"sort"
+	"strconv"
 	"strings"
 
 	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
@@ -15,6 +17,7 @@ import (
 	"gx/ipfs/QmcTzQXRcU2vf8yX5EEboz1BSvWC7wWmeYAKVQmhp8WZYU/sha256-simd"
 	logging "gx/ipfs/QmcuXC5cxs79ro2cUuHs4HQ2bkDLJUYokwL8aivcX6HW3C/go-log"
 
+	"github.com/filecoin-project/go-filecoin/abi"
 	"github.com/filecoin-project/go-filecoin/actor/builtin"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/miner"
 	"github.com/filecoin-project/go-filecoin/address"
@@ -231,10 +234,10 @@ func (c *Expected) IsHeavier(ctx context.Context, a, b types.TipSet, aSt, bSt st
 // starting state and a tipset to a new state.  It errors if the tipset was not
 // mined according to the EC rules, or if running the messages in the tipset
 // results in an error.
-func (c *Expected) RunStateTransition(ctx context.Context, ts types.TipSet, ancestors []types.TipSet, pSt state.Tree) (state.Tree, error) {
+func (c *Expected) RunStateTransition(ctx context.Context, ts types.TipSet, ancestors []types.TipSet, pSt state.Tree) (state.Tree, map[string]*types.MessageReceipt, error) {
 	err := c.validateMining(ctx, pSt, ts, ancestors[0])
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	sl := ts.ToSlice()
@@ -251,24 +254,29 @@ func (c *Expected) RunStateTransition(ctx context.Context, ts types.TipSet, ance
 	}
 
 	vms := vm.NewStorageMap(c.bstore)
-	st, err := c.runMessages(ctx, pSt, vms, ts, ancestors)
+	st, receipts, err := c.runMessages(ctx, pSt, vms, ts, ancestors)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	err = vms.Flush()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return st, nil
+	return st, receipts, nil
 }
 
 // validateMining checks validity of the block ticket, proof, and miner address.
 //    Returns an error if:
 //    	* any tipset's block was mined by an invalid miner address.
-//      * the block proof is invalid for the challenge
+//      * the block proof is invalid for the challenge and the miner's committed replicas
 //      * the block ticket is incorrectly computed
 //      * the block ticket fails the power check, i.e. is not a winning ticket
 //    Returns nil if all the above checks pass.
+// Proofs are verified against the miner's committed replicas with no faults,
+// which blocks don't carry. Bootstrap miners have no replicas to prove, see
+// MinerCommRs, so the blocks of the genesis miners are valid as before. Blocks
+// of other miners mined before this rule, whose proof was the challenge seed,
+// fail it: upgrading a network with such blocks requires a chain reset.
 // See https://github.com/filecoin-project/specs/blob/master/mining.md#chain-validation
 func (c *Expected) validateMining(ctx context.Context, st state.Tree, ts types.TipSet, parentTs types.TipSet) error {
 	for _, blk := range ts.ToSlice() {
@@ -283,7 +291,13 @@ func (c *Expected) validateMining(ctx context.Context, st state.Tree, ts types.T
 			return errors.Wrap(err, "couldn't create challengeSeed")
 		}
 
-		isValid, err := proofs.IsPoStValidWithVerifier(c.verifier, []proofs.CommR{}, challengeSeed, []uint64{}, blk.Proof)
+		commRs, err := MinerCommRs(ctx, st, c.bstore, blk.Miner)
+		if err != nil {
+			return errors.Wrap(err, "couldn't get the miner's sector commitments")
+		}
+
+		// Blocks don't carry faults, miners don't mine with faulty proofs.
+		isValid, err := proofs.IsPoStValidWithVerifier(c.verifier, commRs, challengeSeed, []uint64{}, blk.Proof)
 		if err != nil {
 			return errors.Wrap(err, "could not test the proof's validity")
 		}
@@ -373,57 +387,128 @@ func CreateTicket(proof proofs.PoStProof, minerAddr address.Address) []byte {
 	return h[:]
 }
 
+// MinerCommRs returns the replica commitments of the sectors the miner at
+// mAddr committed in st, ordered by sector id. The proofs of spacetime of the
+// blocks of the miner are over these replicas. Addresses that are not miners
+// have no commitments. Neither do bootstrap miners: like their seal proofs,
+// their proofs of spacetime are not checked, since the genesis block gives
+// them power with commitments to replicas that don't exist.
+func MinerCommRs(ctx context.Context, st state.Tree, bstore blockstore.Blockstore, mAddr address.Address) ([]proofs.CommR, error) {
+	act, err := st.GetActor(ctx, mAddr)
+	if state.IsActorNotFoundError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !act.Code.Equals(types.MinerActorCodeCid) {
+		return nil, nil
+	}
+
+	vms := vm.NewStorageMap(bstore)
+	rets, ec, err := CallQueryMethod(ctx, st, vms, mAddr, "getSectorCommitments", []byte{}, address.Address{}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if ec != 0 {
+		return nil, errors.Errorf("non-zero return code from query message: %d", ec)
+	}
+
+	val, err := abi.Deserialize(rets[0], abi.CommitmentsMap)
+	if err != nil {
+		return nil, err
+	}
+	commitments, ok := val.Val.(map[string]types.Commitments)
+	if !ok {
+		return nil, errors.Errorf("expected a map[string]types.Commitments, but got %T instead", val.Val)
+	}
+
+	sectorIDs := make([]uint64, 0, len(commitments))
+	commRsByID := make(map[uint64]proofs.CommR, len(commitments))
+	for k, v := range commitments {
+		sectorID, err := strconv.ParseUint(k, 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid sector id %s", k)
+		}
+		sectorIDs = append(sectorIDs, sectorID)
+		commRsByID[sectorID] = v.CommR
+	}
+	sort.Slice(sectorIDs, func(i, j int) bool { return sectorIDs[i] < sectorIDs[j] })
+
+	commRs := make([]proofs.CommR, len(sectorIDs))
+	for i, sectorID := range sectorIDs {
+		commRs[i] = commRsByID[sectorID]
+	}
+	return commRs, nil
+}
+
 // runMessages applies the messages of all blocks within the input
 // tipset to the input base state.  Messages are applied block by
 // block with blocks sorted by their ticket bytes.  The output state must be
 // flushed after calling to guarantee that the state transitions propagate.
+// The receipts of the messages of the tipset are returned along with the
+// state.
 //
 // An error is returned if individual blocks contain messages that do not
 // lead to successful state transitions.  An error is also returned if the node
 // faults while running aggregate state computation.
-func (c *Expected) runMessages(ctx context.Context, st state.Tree, vms vm.StorageMap, ts types.TipSet, ancestors []types.TipSet) (state.Tree, error) {
+func (c *Expected) runMessages(ctx context.Context, st state.Tree, vms vm.StorageMap, ts types.TipSet, ancestors []types.TipSet) (state.Tree, map[string]*types.MessageReceipt, error) {
 	var cpySt state.Tree
+	var receipts []*ApplicationResult
 
 	// TODO: order blocks in the tipset by ticket
 	// TODO: don't process messages twice
 	for _, blk := range ts.ToSlice() {
 		cpyCid, err := st.Flush(ctx)
 		if err != nil {
-			return nil, errors.Wrap(err, "error validating block state")
+			return nil, nil, errors.Wrap(err, "error validating block state")
 		}
 		// state copied so changes don't propagate between block validations
 		cpySt, err = state.LoadStateTree(ctx, c.cstore, cpyCid, builtin.Actors)
 		if err != nil {
-			return nil, errors.Wrap(err, "error validating block state")
+			return nil, nil, errors.Wrap(err, "error validating block state")
 		}
 
-		receipts, err := c.processor.ProcessBlock(ctx, cpySt, vms, blk, ancestors)
+		receipts, err = c.processor.ProcessBlock(ctx, cpySt, vms, blk, ancestors)
 		if err != nil {
-			return nil, errors.Wrap(err, "error validating block state")
+			return nil, nil, errors.Wrap(err, "error validating block state")
 		}
 		// TODO: check that receipts actually match
 		if len(receipts) != len(blk.MessageReceipts) {
-			return nil, fmt.Errorf("found invalid message receipts: %v %v", receipts, blk.MessageReceipts)
+			return nil, nil, fmt.Errorf("found invalid message receipts: %v %v", receipts, blk.MessageReceipts)
 		}
 
 		outCid, err := cpySt.Flush(ctx)
 		if err != nil {
-			return nil, errors.Wrap(err, "error validating block state")
+			return nil, nil, errors.Wrap(err, "error validating block state")
 		}
 		if !outCid.Equals(blk.StateRoot) {
-			return nil, ErrStateRootMismatch
+			return nil, nil, ErrStateRootMismatch
 		}
 	}
 	if len(ts) == 1 { // block validation state == aggregate parent state
-		return cpySt, nil
+		// all the messages of a valid block are applied
+		blk := ts.ToSlice()[0]
+		res := make(map[string]*types.MessageReceipt)
+		for i, msg := range blk.Messages {
+			if i >= len(receipts) {
+				break
+			}
+			msgCid, err := msg.Cid()
+			if err != nil {
+				return nil, nil, err
+			}
+			res[msgCid.String()] = receipts[i].Receipt
+		}
+		return cpySt, res, nil
 	}
 	// multiblock tipsets require reapplying messages to get aggregate state
 	// NOTE: It is possible to optimize further by applying block validation
 	// in sorted order to reuse first block transitions as the starting state
 	// for the tipSetProcessor.
-	_, err := c.processor.ProcessTipSet(ctx, st, vms, ts, ancestors)
+	res, err := c.processor.ProcessTipSet(ctx, st, vms, ts, ancestors)
 	if err != nil {
-		return nil, errors.Wrap(err, "error validating tipset")
+		return nil, nil, errors.Wrap(err, "error validating tipset")
 	}
-	return st, nil
+	return st, res.Receipts, nil
 }
@@ -3,14 +3,18 @@ package consensus_test
 import (
 	"context"
 	"encoding/hex"
+	"math/big"
 
+	"github.com/filecoin-project/go-filecoin/actor"
 	"github.com/filecoin-project/go-filecoin/actor/builtin"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/miner"
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/proofs"
 	"github.com/filecoin-project/go-filecoin/state"
 	"github.com/filecoin-project/go-filecoin/testhelpers"
 	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -127,7 +131,7 @@ func TestExpected_RunStateTransition_validateMining(t *testing.T) {
 		stateTree, err := state.LoadStateTree(ctx, cistore, genesisBlock.StateRoot, builtin.Actors)
 		require.NoError(err)
 
-		_, err = exp.RunStateTransition(ctx, tipSet, []types.TipSet{pTipSet}, stateTree)
+		_, _, err = exp.RunStateTransition(ctx, tipSet, []types.TipSet{pTipSet}, stateTree)
 		assert.NoError(err)
 	})
 
@@ -147,9 +151,72 @@ func TestExpected_RunStateTransition_validateMining(t *testing.T) {
 		stateTree, err := state.LoadStateTree(ctx, cistore, genesisBlock.StateRoot, builtin.Actors)
 		require.NoError(err)
 
-		_, err = exp.RunStateTransition(ctx, tipSet, []types.TipSet{pTipSet}, stateTree)
+		_, _, err = exp.RunStateTransition(ctx, tipSet, []types.TipSet{pTipSet}, stateTree)
 		assert.EqualError(err, "can't check for winning ticket: Couldn't get minerPower: something went wrong with the miner power")
 	})
+
+	t.Run("returns nil + mining error when the proof is invalid", func(t *testing.T) {
+
+		ptv := testhelpers.NewTestPowerTableView(1, 1)
+		exp := consensus.NewExpected(cistore, bstore, testhelpers.NewTestProcessor(), ptv, genesisBlock.Cid(), proofs.NewFakeVerifier(false, nil))
+
+		pTipSet, err := exp.NewValidTipSet(ctx, []*types.Block{genesisBlock})
+		require.NoError(err)
+
+		tipSet, err := exp.NewValidTipSet(ctx, makeSomeBlocks(pTipSet))
+		require.NoError(err)
+
+		stateTree, err := state.LoadStateTree(ctx, cistore, genesisBlock.StateRoot, builtin.Actors)
+		require.NoError(err)
+
+		_, _, err = exp.RunStateTransition(ctx, tipSet, []types.TipSet{pTipSet}, stateTree)
+		assert.EqualError(err, "invalid proof")
+	})
+}
+
+func TestMinerCommRs(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+
+	cst, bs, _ := setupCborBlockstoreProofs()
+	vms := vm.NewStorageMap(bs)
+	minerAddr := address.MakeTestAddress("miner")
+
+	minerState := miner.NewState(address.TestAddress, []byte{}, big.NewInt(1), testhelpers.RequireRandomPeerID(), types.NewZeroAttoFIL())
+	for i, id := range []string{"10", "2", "7"} {
+		minerState.SectorCommitments[id] = types.Commitments{CommR: proofs.CommR{byte(i)}}
+	}
+	minerActor := actor.NewActor(types.MinerActorCodeCid, types.NewZeroAttoFIL())
+	storage := vms.NewStorage(minerAddr, minerActor)
+	require.NoError((&miner.Actor{}).InitializeState(storage, minerState))
+	require.NoError(storage.Flush())
+
+	_, st := testhelpers.RequireMakeStateTree(require, cst, map[address.Address]*actor.Actor{
+		minerAddr:           minerActor,
+		address.TestAddress: testhelpers.RequireNewAccountActor(require, types.NewZeroAttoFIL()),
+	})
+
+	commRs, err := consensus.MinerCommRs(ctx, st, bs, minerAddr)
+	require.NoError(err)
+	assert.Equal([]proofs.CommR{{1}, {2}, {0}}, commRs) // ordered by sector id
+
+	// other actors have no commitments
+	commRs, err = consensus.MinerCommRs(ctx, st, bs, address.TestAddress)
+	require.NoError(err)
+	assert.Empty(commRs)
+	commRs, err = consensus.MinerCommRs(ctx, st, bs, address.MakeTestAddress("nobody"))
+	require.NoError(err)
+	assert.Empty(commRs)
+
+	// the commitments of bootstrap miners are not proven
+	minerActor.Code = types.BootstrapMinerActorCodeCid
+	_, st = testhelpers.RequireMakeStateTree(require, cst, map[address.Address]*actor.Actor{
+		minerAddr: minerActor,
+	})
+	commRs, err = consensus.MinerCommRs(ctx, st, bs, minerAddr)
+	require.NoError(err)
+	assert.Empty(commRs)
 }
 
 func TestIsWinningTicket(t *testing.T) {
@@ -10,6 +10,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/actor/builtin"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/account"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/paymentbroker"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/reward"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/storagemarket"
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/state"
@@ -37,6 +38,7 @@ type Config struct {
 	accounts map[address.Address]*types.AttoFIL
 	nonces   map[address.Address]uint64
 	actors   map[address.Address]*actor.Actor
+	schedule *reward.Schedule
 }
 
 // GenOption is a configuration option for the GenesisInitFunction.
@@ -68,6 +70,20 @@ func AddActor(addr address.Address, actor *actor.Actor) GenOption {
 	}
 }
 
+// RewardSchedule returns a config option that sets the block reward schedule,
+// the default one being used if it is nil.
+func RewardSchedule(schedule *reward.Schedule) GenOption {
+	return func(gc *Config) error {
+		if schedule != nil {
+			if err := schedule.Validate(); err != nil {
+				return err
+			}
+		}
+		gc.schedule = schedule
+		return nil
+	}
+}
+
 // NewEmptyConfig inits and returns an empty config
 func NewEmptyConfig() *Config {
 	return &Config{
@@ -112,7 +128,7 @@ func MakeGenesisFunc(opts ...GenOption) func(cst *hamt.CborIpldStore, bs blockst
 				return nil, err
 			}
 		}
-		if err := SetupDefaultActors(ctx, st, storageMap); err != nil {
+		if err := SetupDefaultActors(ctx, st, storageMap, genCfg.schedule); err != nil {
 			return nil, err
 		}
 		// Now add any other actors configured.
@@ -151,7 +167,9 @@ func InitGenesis(cst *hamt.CborIpldStore, bs blockstore.Blockstore) (*types.Bloc
 }
 
 // SetupDefaultActors inits the builtin actors that are required to run filecoin.
-func SetupDefaultActors(ctx context.Context, st state.Tree, storageMap vm.StorageMap) error {
+// The reward actor gets the given block reward schedule, or the default one if
+// it is nil.
+func SetupDefaultActors(ctx context.Context, st state.Tree, storageMap vm.StorageMap, schedule *reward.Schedule) error {
 	for addr, val := range defaultAccounts {
 		a, err := account.NewActor(val)
 		if err != nil {
@@ -183,5 +201,18 @@ func SetupDefaultActors(ctx context.Context, st state.Tree, storageMap vm.Storag
 
 	pbAct.Balance = types.NewAttoFILFromFIL(0)
 
-	return st.SetActor(ctx, address.PaymentBrokerAddress, pbAct)
+	if err := st.SetActor(ctx, address.PaymentBrokerAddress, pbAct); err != nil {
+		return err
+	}
+
+	rwAct, err := reward.NewActor()
+	if err != nil {
+		return err
+	}
+	err = (&reward.Actor{}).InitializeState(storageMap.NewStorage(address.RewardAddress, rwAct), schedule)
+	if err != nil {
+		return err
+	}
+
+	return st.SetActor(ctx, address.RewardAddress, rwAct)
 }
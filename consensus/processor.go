@@ -5,8 +5,10 @@ import (
 	"math/big"
 	"time"
 
+	"github.com/filecoin-project/go-filecoin/abi"
 	"github.com/filecoin-project/go-filecoin/actor"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/account"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/reward"
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/state"
 	"github.com/filecoin-project/go-filecoin/types"
@@ -23,8 +25,8 @@ type SignedMessageValidator interface {
 
 // BlockRewarder applies all rewards due to the miner for processing a block including block reward and gas
 type BlockRewarder interface {
-	// BlockReward pays out the mining reward
-	BlockReward(ctx context.Context, st state.Tree, minerAddr address.Address) error
+	// BlockReward pays out the mining reward of a block at height bh
+	BlockReward(ctx context.Context, st state.Tree, vms vm.StorageMap, minerAddr address.Address, bh *types.BlockHeight) error
 
 	// GasReward pays gas from the sender to the miner
 	GasReward(ctx context.Context, st state.Tree, minerAddr address.Address, msg *types.SignedMessage, cost *types.AttoFIL) error
@@ -46,6 +48,9 @@ type ProcessTipSetResponse struct {
 	Results   []*ApplicationResult
 	Successes types.SortedCidSet
 	Failures  types.SortedCidSet
+	// Receipts maps the cids of the successfully applied messages to their
+	// receipts.
+	Receipts map[string]*types.MessageReceipt
 }
 
 // DefaultProcessor handles all block processing.
@@ -139,6 +144,7 @@ func (p *DefaultProcessor) ProcessTipSet(ctx context.Context, st state.Tree, vms
 	}
 	bh := types.NewBlockHeight(h)
 	msgFilter := make(map[string]struct{})
+	res.Receipts = make(map[string]*types.MessageReceipt)
 
 	tips := ts.ToSlice()
 	types.SortBlocks(tips)
@@ -167,12 +173,13 @@ func (p *DefaultProcessor) ProcessTipSet(ctx context.Context, st state.Tree, vms
 			return &emptyRes, err
 		}
 		res.Results = append(res.Results, amRes.Results...)
-		for _, msg := range amRes.SuccessfulMessages {
+		for i, msg := range amRes.SuccessfulMessages {
 			mCid, err := msg.Cid()
 			if err != nil {
 				return &emptyRes, errors.FaultErrorWrap(err, "error getting message cid")
 			}
 			(&res.Successes).Add(mCid)
+			res.Receipts[mCid.String()] = amRes.Results[i].Receipt
 		}
 		for _, msg := range amRes.PermanentFailures {
 			mCid, err := msg.Cid()
@@ -519,7 +526,7 @@ func (p *DefaultProcessor) ApplyMessagesAndPayRewards(ctx context.Context, st st
 	var ret ApplyMessagesResponse
 
 	// transfer block reward to miner from network address.
-	if err := p.blockRewarder.BlockReward(ctx, st, minerAddr); err != nil {
+	if err := p.blockRewarder.BlockReward(ctx, st, vms, minerAddr, bh); err != nil {
 		return ApplyMessagesResponse{}, err
 	}
 
@@ -602,10 +609,28 @@ func NewDefaultBlockRewarder() *DefaultBlockRewarder {
 
 var _ BlockRewarder = (*DefaultBlockRewarder)(nil)
 
-// BlockReward transfers the block reward from the network actor to the miner.
-func (br *DefaultBlockRewarder) BlockReward(ctx context.Context, st state.Tree, minerAddr address.Address) error {
+// BlockReward transfers the block reward of height bh from the network actor to
+// the miner. The network actor pays what is left of its balance once it is
+// lower than the reward.
+func (br *DefaultBlockRewarder) BlockReward(ctx context.Context, st state.Tree, vms vm.StorageMap, minerAddr address.Address, bh *types.BlockHeight) error {
+	amount, err := BlockRewardAt(ctx, st, vms, bh)
+	if err != nil {
+		return errors.FaultErrorWrap(err, "failed to get block reward")
+	}
+
+	networkActor, err := st.GetActor(ctx, address.NetworkAddress)
+	if err != nil {
+		return errors.FaultErrorWrap(err, "could not retrieve network actor for block reward")
+	}
+	if networkActor.Balance.LessThan(amount) {
+		amount = networkActor.Balance
+	}
+	if amount.IsZero() {
+		return nil
+	}
+
 	cachedTree := state.NewCachedStateTree(st)
-	if err := rewardTransfer(ctx, address.NetworkAddress, minerAddr, br.BlockRewardAmount(), cachedTree); err != nil {
+	if err := rewardTransfer(ctx, address.NetworkAddress, minerAddr, amount, cachedTree); err != nil {
 		return errors.FaultErrorWrap(err, "Error attempting to pay block reward")
 	}
 	return cachedTree.Commit(ctx)
@@ -620,11 +645,45 @@ func (br *DefaultBlockRewarder) GasReward(ctx context.Context, st state.Tree, mi
 	return cachedTree.Commit(ctx)
 }
 
-// BlockRewardAmount returns the max FIL value miners can claim as the block reward.
-// TODO this is one of the system parameters that should be configured as part of
-// https://github.com/filecoin-project/go-filecoin/issues/884.
+// BlockRewardAmount returns the block reward of chains whose genesis has no
+// reward schedule.
 func (br *DefaultBlockRewarder) BlockRewardAmount() *types.AttoFIL {
-	return types.NewAttoFILFromFIL(1000)
+	return reward.DefaultInitialReward
+}
+
+// BlockRewardAt returns the block reward of height bh according to the
+// schedule of the reward actor in st, or BlockRewardAmount if there is no
+// reward actor.
+func BlockRewardAt(ctx context.Context, st state.Tree, vms vm.StorageMap, bh *types.BlockHeight) (*types.AttoFIL, error) {
+	_, err := st.GetActor(ctx, address.RewardAddress)
+	if state.IsActorNotFoundError(err) {
+		return NewDefaultBlockRewarder().BlockRewardAmount(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := abi.ToEncodedValues(bh)
+	if err != nil {
+		return nil, err
+	}
+	rets, ec, err := CallQueryMethod(ctx, st, vms, address.RewardAddress, "getBlockReward", params, address.Address{}, bh)
+	if err != nil {
+		return nil, err
+	}
+	if ec != 0 {
+		return nil, errors.NewFaultErrorf("non-zero return code from query message: %d", ec)
+	}
+
+	val, err := abi.Deserialize(rets[0], abi.AttoFIL)
+	if err != nil {
+		return nil, err
+	}
+	amount, ok := val.Val.(*types.AttoFIL)
+	if !ok {
+		return nil, errors.NewFaultErrorf("expected a *types.AttoFIL, but got %T instead", val.Val)
+	}
+	return amount, nil
 }
 
 // rewardTransfer retrieves two actors from the given addresses and attempts to transfer the given value from the balance of the first's to the second.
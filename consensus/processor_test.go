@@ -12,6 +12,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/abi"
 	"github.com/filecoin-project/go-filecoin/actor"
 	"github.com/filecoin-project/go-filecoin/actor/builtin"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/reward"
 	"github.com/filecoin-project/go-filecoin/address"
 	. "github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/state"
@@ -296,6 +297,60 @@ func TestProcessBlockReward(t *testing.T) {
 	assert.Equal(minerBalance.Add(blockRewardAmount), minerOwnerActor.Balance)
 }
 
+func TestDefaultBlockRewarderFollowsSchedule(t *testing.T) {
+	ctx := context.Background()
+
+	requireGenesisState := func(require *require.Assertions) (state.Tree, vm.StorageMap) {
+		cst := hamt.NewCborStore()
+		bs := blockstore.NewBlockstore(datastore.NewMapDatastore())
+		genesis, err := MakeGenesisFunc(RewardSchedule(&reward.Schedule{
+			InitialReward: types.NewAttoFILFromFIL(1000),
+			DecayPercent:  50,
+			DecayInterval: 100,
+		}))(cst, bs)
+		require.NoError(err)
+		st, err := state.LoadStateTree(ctx, cst, genesis.StateRoot, builtin.Actors)
+		require.NoError(err)
+		return st, vm.NewStorageMap(bs)
+	}
+
+	t.Run("pays the reward of the height of the block", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		st, vms := requireGenesisState(require)
+		minerAddr := address.NewForTestGetter()()
+
+		require.NoError(NewDefaultBlockRewarder().BlockReward(ctx, st, vms, minerAddr, types.NewBlockHeight(150)))
+		minerActor, err := st.GetActor(ctx, minerAddr)
+		require.NoError(err)
+		assert.True(types.NewAttoFILFromFIL(500).Equal(minerActor.Balance))
+	})
+
+	t.Run("pays at most what the network actor has left", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		st, vms := requireGenesisState(require)
+		newAddress := address.NewForTestGetter()
+		minerAddr, otherAddr := newAddress(), newAddress()
+		require.NoError(st.SetActor(ctx, address.NetworkAddress, th.RequireNewAccountActor(require, types.NewAttoFILFromFIL(300))))
+
+		require.NoError(NewDefaultBlockRewarder().BlockReward(ctx, st, vms, minerAddr, types.NewBlockHeight(0)))
+		minerActor, err := st.GetActor(ctx, minerAddr)
+		require.NoError(err)
+		assert.True(types.NewAttoFILFromFIL(300).Equal(minerActor.Balance))
+		networkActor, err := st.GetActor(ctx, address.NetworkAddress)
+		require.NoError(err)
+		assert.True(networkActor.Balance.IsZero())
+
+		// nothing is paid once the network actor is empty
+		require.NoError(NewDefaultBlockRewarder().BlockReward(ctx, st, vms, otherAddr, types.NewBlockHeight(1)))
+		_, err = st.GetActor(ctx, otherAddr)
+		assert.True(state.IsActorNotFoundError(err))
+	})
+}
+
 func TestProcessBlockVMErrors(t *testing.T) {
 	assert := assert.New(t)
 	require := require.New(t)
@@ -34,6 +34,8 @@ type Protocol interface {
 	// tipset b is heavier than tipset a.
 	IsHeavier(ctx context.Context, a, b types.TipSet, aSt, bSt state.Tree) (bool, error)
 	// RunStateTransition returns the state resulting from applying the input ts to the parent
-	// state pSt.  It returns an error if the transition is invalid.
-	RunStateTransition(ctx context.Context, ts types.TipSet, ancestors []types.TipSet, pSt state.Tree) (state.Tree, error)
+	// state pSt, and the receipts of the messages of ts keyed by message cid.  Messages that
+	// failed in conflict with another message of ts have no receipt.  It returns an error if
+	// the transition is invalid.
+	RunStateTransition(ctx context.Context, ts types.TipSet, ancestors []types.TipSet, pSt state.Tree) (state.Tree, map[string]*types.MessageReceipt, error)
 }
@@ -2,45 +2,159 @@ package core
 
 import (
 	"context"
+	"math/big"
 	"sort"
 	"sync"
 
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/config"
 	"github.com/filecoin-project/go-filecoin/types"
 	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
 	"gx/ipfs/QmRXf2uUSdGSunRJsM9wXSUNVwLUGCY3So5fAs7h2CBJVf/go-hamt-ipld"
 	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+	logging "gx/ipfs/QmcuXC5cxs79ro2cUuHs4HQ2bkDLJUYokwL8aivcX6HW3C/go-log"
+	"gx/ipfs/QmdbxjQWogRCHRaxhhGnYdT1oQJzL9GdqSKzCdqWr85AP2/pubsub"
 )
 
+var log = logging.Logger("core")
+
+// ErrMessagePoolFull is returned when a message is not added to the pool
+// because the pool is full of messages paying as much gas or more.
+var ErrMessagePoolFull = errors.New("message pool is full")
+
+// ErrReplacementUnderpriced is returned when a message is not added to the
+// pool because a message with the same sender and nonce pays about as much gas.
+var ErrReplacementUnderpriced = errors.New("replacement message gas price is too low")
+
+// ErrInvalidSignature is returned when a message is not added to the pool
+// because its signature is invalid.
+var ErrInvalidSignature = errors.New("message signature is invalid")
+
+// ErrSenderMessageLimit is returned when a message is not added to the pool
+// because its sender has too many messages in the pool.
+var ErrSenderMessageLimit = errors.New("sender has too many messages in the pool")
+
+// MessagePoolTopic is the topic on which the events of a message pool are
+// published.
+const MessagePoolTopic = "mpool"
+
+// MessagePoolEventType is the type of a change of the message pool.
+type MessagePoolEventType string
+
+const (
+	// MessageAdded is published when a message is added to the pool.
+	MessageAdded = MessagePoolEventType("add")
+	// MessageRemoved is published when a message is removed from the pool
+	// on request, for instance because it cannot be mined.
+	MessageRemoved = MessagePoolEventType("remove")
+	// MessageIncluded is published when a message leaves the pool because
+	// it is included in a block of the chain.
+	MessageIncluded = MessagePoolEventType("included")
+	// MessageEvicted is published when a message leaves the pool because it
+	// expired or was replaced by another message.
+	MessageEvicted = MessagePoolEventType("evicted")
+	// MessageEventsDropped is the last event of a subscription that fell
+	// behind and missed events.  The subscriber must list the pool again to
+	// resync.
+	MessageEventsDropped = MessagePoolEventType("dropped")
+)
+
+// MessagePoolEvent is a change of the message pool.
+type MessagePoolEvent struct {
+	Type    MessagePoolEventType
+	Cid     cid.Cid
+	Message *types.SignedMessage
+}
+
 // MessagePool keeps an unordered, de-duplicated set of Messages and supports removal by CID.
 // By 'de-duplicated' we mean that insertion of a message by cid that already
 // exists is a nop. We use a MessagePool to store all messages received by this node
 // via network or directly created via user command that have yet to be included
 // in a block. Messages are removed as they are processed.
 //
+// A configured MessagePool only admits messages accepted by its validator and
+// bounds the number of messages it holds, in total and per sender.  It holds a
+// single message per sender and nonce: a message replaces the pending message
+// with the same sender and nonce if it pays a high enough gas price.  Messages
+// pending for more blocks than its TTL expire.
+//
+// The changes of the pool are published as MessagePoolEvent to
+// MessagePoolTopic of its events, in order and without holding the pool's
+// lock.
+//
 // MessagePool is safe for concurrent access.
 type MessagePool struct {
 	lk sync.RWMutex
 
+	// validator, if not nil, checks messages before they are added.
+	validator MessagePoolValidator
+	// maxPoolSize and maxSenderMessages are the limits on the number of
+	// messages, zero is unlimited.
+	maxPoolSize       int
+	maxSenderMessages int
+	// replaceByFee is true if messages replace pending messages with the
+	// same sender and nonce when their gas price is at least
+	// replaceByFeePercent higher.
+	replaceByFee        bool
+	replaceByFeePercent uint64
+	// ttl is the number of blocks after which a message expires, zero is
+	// never.
+	ttl uint64
+	// height is the height of the head of the chain.
+	height uint64
+
 	pending map[cid.Cid]*types.SignedMessage // all pending messages
+	addedAt map[cid.Cid]uint64               // height at which pending messages were added
+
+	// events publishes the changes of the pool.
+	events *pubsub.PubSub
+	// queued are the events of the changes made while holding lk, which are
+	// published once it is released.  pubLk is held from releasing lk until
+	// they are published to keep the events in order.
+	queued []MessagePoolEvent
+	pubLk  sync.Mutex
 }
 
 // Add adds a message to the pool.
-func (pool *MessagePool) Add(msg *types.SignedMessage) (cid.Cid, error) {
-	pool.lk.Lock()
-	defer pool.lk.Unlock()
-
+func (pool *MessagePool) Add(ctx context.Context, msg *types.SignedMessage) (cid.Cid, error) {
 	c, err := msg.Cid()
 	if err != nil {
 		return cid.Undef, errors.Wrap(err, "failed to create CID")
 	}
 
+	if pool.has(c) {
+		return c, nil
+	}
+
 	// Reject messages with invalid signatires
 	if !msg.VerifySignature() {
-		return cid.Undef, errors.Errorf("failed to add message %s to pool: sig invalid", c.String())
+		return cid.Undef, errors.Wrapf(ErrInvalidSignature, "failed to add message %s to pool", c.String())
 	}
 
-	pool.pending[c] = msg
+	if pool.validator != nil {
+		if err := pool.validator.Validate(ctx, msg); err != nil {
+			return cid.Undef, errors.Wrapf(err, "failed to add message %s to pool", c.String())
+		}
+	}
+
+	pool.lk.Lock()
+	defer pool.unlockAndPublish()
+
+	if pool.replaceByFee {
+		replaced, err := pool.replace(c, msg)
+		if err != nil {
+			return cid.Undef, errors.Wrapf(err, "failed to add message %s to pool", c.String())
+		}
+		if replaced {
+			return c, nil
+		}
+	}
+
+	if err := pool.makeRoomFor(msg); err != nil {
+		return cid.Undef, errors.Wrapf(err, "failed to add message %s to pool", c.String())
+	}
+
+	pool.add(c, msg)
 	return c, nil
 }
 
@@ -58,16 +172,193 @@ func (pool *MessagePool) Pending() []*types.SignedMessage {
 
 // Remove removes the message by CID from the pending pool.
 func (pool *MessagePool) Remove(c cid.Cid) {
+	pool.removeAs(c, MessageRemoved)
+}
+
+// Events returns a pubsub interface publishing the changes of the pool on
+// MessagePoolTopic.  Subscribers must keep up with the events since a full
+// subscription eventually blocks the changes of the pool.
+func (pool *MessagePool) Events() *pubsub.PubSub {
+	return pool.events
+}
+
+// UpdateHeight records that the head of the chain is at height h, and
+// removes the messages that have been pending for more than the pool's TTL.
+func (pool *MessagePool) UpdateHeight(h uint64) {
 	pool.lk.Lock()
-	defer pool.lk.Unlock()
+	defer pool.unlockAndPublish()
+
+	pool.height = h
+	if pool.ttl == 0 {
+		return
+	}
+	for c, addedAt := range pool.addedAt {
+		if h > addedAt && h-addedAt > pool.ttl {
+			log.Debugf("message %s expired from the pool", c)
+			pool.remove(c, MessageEvicted)
+		}
+	}
+}
+
+// Get returns the pending message with the given cid.
+func (pool *MessagePool) Get(c cid.Cid) (*types.SignedMessage, bool) {
+	pool.lk.RLock()
+	defer pool.lk.RUnlock()
+
+	msg, ok := pool.pending[c]
+	return msg, ok
+}
+
+func (pool *MessagePool) has(c cid.Cid) bool {
+	pool.lk.RLock()
+	defer pool.lk.RUnlock()
+
+	_, ok := pool.pending[c]
+	return ok
+}
+
+// add adds msg with cid c at the current height.
+// Precondition: the caller holds the pool's lock.
+func (pool *MessagePool) add(c cid.Cid, msg *types.SignedMessage) {
+	pool.pending[c] = msg
+	pool.addedAt[c] = pool.height
+	pool.queued = append(pool.queued, MessagePoolEvent{Type: MessageAdded, Cid: c, Message: msg})
+}
+
+// removeAs removes the message with cid c, publishing an event of type typ.
+func (pool *MessagePool) removeAs(c cid.Cid, typ MessagePoolEventType) {
+	pool.lk.Lock()
+	defer pool.unlockAndPublish()
+
+	pool.remove(c, typ)
+}
+
+// unlockAndPublish releases the pool's lock and publishes the events of the
+// changes made while holding it.
+func (pool *MessagePool) unlockAndPublish() {
+	events := pool.queued
+	pool.queued = nil
+	pool.pubLk.Lock()
+	defer pool.pubLk.Unlock()
+	pool.lk.Unlock()
 
+	for _, event := range events {
+		pool.events.Pub(event, MessagePoolTopic)
+	}
+}
+
+// remove removes the message with cid c, publishing an event of type typ if
+// it was pending.
+// Precondition: the caller holds the pool's lock.
+func (pool *MessagePool) remove(c cid.Cid, typ MessagePoolEventType) {
+	msg, ok := pool.pending[c]
+	if !ok {
+		return
+	}
 	delete(pool.pending, c)
+	delete(pool.addedAt, c)
+	pool.queued = append(pool.queued, MessagePoolEvent{Type: typ, Cid: c, Message: msg})
 }
 
-// NewMessagePool constructs a new MessagePool.
+// replace adds msg with cid c in place of the pending message with the same
+// sender and nonce, if any.  It returns false if there is no such message and
+// ErrReplacementUnderpriced if msg does not pay enough to replace it.
+// Precondition: the caller holds the pool's lock.
+func (pool *MessagePool) replace(c cid.Cid, msg *types.SignedMessage) (bool, error) {
+	for oldCid, old := range pool.pending {
+		if old.From != msg.From || old.Nonce != msg.Nonce {
+			continue
+		}
+		// The new gas price must be higher and at least replaceByFeePercent
+		// above the old one: new * 100 >= old * (100 + percent).
+		scaledNew := msg.GasPrice.MulBigInt(big.NewInt(100))
+		scaledOld := old.GasPrice.MulBigInt(new(big.Int).SetUint64(100 + pool.replaceByFeePercent))
+		if !msg.GasPrice.GreaterThan(&old.GasPrice) || scaledNew.LessThan(scaledOld) {
+			return false, errors.Wrapf(ErrReplacementUnderpriced, "pending message %s pays %s", oldCid, old.GasPrice.String())
+		}
+		log.Infof("replacing message %s by %s", oldCid, c)
+		pool.remove(oldCid, MessageEvicted)
+		pool.add(c, msg)
+		return true, nil
+	}
+	return false, nil
+}
+
+// makeRoomFor evicts a message if the pool or the sender of msg are at their
+// limit and msg should take the place of the evicted message.  The sender's
+// highest nonce message is evicted for a message with a lower nonce.  When the
+// pool is full, the message paying the lowest gas price among the highest
+// nonce message of each sender is evicted for a message paying more.
+// Precondition: the caller holds the pool's lock.
+func (pool *MessagePool) makeRoomFor(msg *types.SignedMessage) error {
+	if pool.maxSenderMessages > 0 {
+		var count int
+		var last cid.Cid
+		var lastNonce types.Uint64
+		for c, m := range pool.pending {
+			if m.From != msg.From {
+				continue
+			}
+			count++
+			if !last.Defined() || m.Nonce > lastNonce {
+				last, lastNonce = c, m.Nonce
+			}
+		}
+		if count >= pool.maxSenderMessages {
+			if lastNonce <= msg.Nonce {
+				return ErrSenderMessageLimit
+			}
+			log.Debugf("evicting message %s from the pool for a lower nonce", last)
+			pool.remove(last, MessageEvicted)
+		}
+	}
+
+	if pool.maxPoolSize > 0 && len(pool.pending) >= pool.maxPoolSize {
+		lastBySender := make(map[address.Address]cid.Cid)
+		for c, m := range pool.pending {
+			if prev, ok := lastBySender[m.From]; !ok || m.Nonce > pool.pending[prev].Nonce {
+				lastBySender[m.From] = c
+			}
+		}
+		var cheapest cid.Cid
+		for _, c := range lastBySender {
+			if !cheapest.Defined() || pool.pending[c].GasPrice.LessThan(&pool.pending[cheapest].GasPrice) {
+				cheapest = c
+			}
+		}
+		if !cheapest.Defined() || !pool.pending[cheapest].GasPrice.LessThan(&msg.GasPrice) {
+			return ErrMessagePoolFull
+		}
+		log.Debugf("evicting message %s from the full pool", cheapest)
+		pool.remove(cheapest, MessageEvicted)
+	}
+	return nil
+}
+
+// NewMessagePool constructs a new MessagePool admitting every correctly
+// signed message.
 func NewMessagePool() *MessagePool {
 	return &MessagePool{
 		pending: make(map[cid.Cid]*types.SignedMessage),
+		addedAt: make(map[cid.Cid]uint64),
+		events:  pubsub.New(128),
+	}
+}
+
+// NewConfiguredMessagePool constructs a new MessagePool admitting the messages
+// accepted by validator within the limits of cfg, and expiring them after
+// the TTL of cfg.
+func NewConfiguredMessagePool(cfg *config.MessagePoolConfig, validator MessagePoolValidator) *MessagePool {
+	return &MessagePool{
+		validator:           validator,
+		maxPoolSize:         cfg.MaxPoolSize,
+		maxSenderMessages:   cfg.MaxSenderMessages,
+		replaceByFee:        true,
+		replaceByFeePercent: cfg.ReplaceByFeePercent,
+		ttl:                 cfg.MessageTTL,
+		pending:             make(map[cid.Cid]*types.SignedMessage),
+		addedAt:             make(map[cid.Cid]uint64),
+		events:              pubsub.New(128),
 	}
 }
 
@@ -135,9 +426,11 @@ func collectChainsMessagesToHeight(ctx context.Context, store *hamt.CborIpldStor
 // that the right model for keeping the message pool up to date is
 // to think about it like a garbage collector.
 //
-// TODO there is considerable functionality missing here: don't add
-//      messages that have expired, respect nonce, do this efficiently,
-//      etc.
+// Messages that have been pending for longer than the pool's TTL are
+// removed.
+//
+// TODO there is considerable functionality missing here: respect nonce,
+//      do this efficiently, etc.
 func UpdateMessagePool(ctx context.Context, pool *MessagePool, store *hamt.CborIpldStore, old, new types.TipSet) error {
 	// Strategy: walk head-of-chain pointers old and new back until they are at the same
 	// height, then walk back in lockstep to find the common ancesetor.
@@ -199,11 +492,11 @@ func UpdateMessagePool(ctx context.Context, pool *MessagePool, store *hamt.CborI
 		}
 	}
 
-	// Now actually update the pool.
+	// Now actually update the pool.  Messages of the old chain that are no
+	// longer admissible in the new head's state are dropped.
 	for _, m := range addToPool {
-		_, err := pool.Add(m)
-		if err != nil {
-			return err
+		if _, err := pool.Add(ctx, m); err != nil {
+			log.Debugf("not returning message to the pool: %s", err)
 		}
 	}
 	// m.Cid() can error, so collect all the Cids before
@@ -216,9 +509,11 @@ func UpdateMessagePool(ctx context.Context, pool *MessagePool, store *hamt.CborI
 		removeCids[i] = cid
 	}
 	for _, cid := range removeCids {
-		pool.Remove(cid)
+		pool.removeAs(cid, MessageIncluded)
 	}
 
+	pool.UpdateHeight(newHeight)
+
 	return nil
 }
 
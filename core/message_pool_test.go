@@ -7,11 +7,13 @@ import (
 	"testing"
 
 	hamt "gx/ipfs/QmRXf2uUSdGSunRJsM9wXSUNVwLUGCY3So5fAs7h2CBJVf/go-hamt-ipld"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/config"
 	"github.com/filecoin-project/go-filecoin/types"
 )
 
@@ -33,10 +35,10 @@ func TestMessagePoolAddRemove(t *testing.T) {
 	assert.NoError(err)
 
 	assert.Len(pool.Pending(), 0)
-	_, err = pool.Add(msg1)
+	_, err = pool.Add(context.Background(), msg1)
 	assert.NoError(err)
 	assert.Len(pool.Pending(), 1)
-	_, err = pool.Add(msg2)
+	_, err = pool.Add(context.Background(), msg2)
 	assert.NoError(err)
 	assert.Len(pool.Pending(), 2)
 
@@ -53,7 +55,7 @@ func TestMessagePoolAddBadSignature(t *testing.T) {
 	smsg := newSignedMessage()
 	smsg.Message.Nonce = types.Uint64(uint64(smsg.Message.Nonce) + uint64(1)) // invalidate message
 
-	c, err := pool.Add(smsg)
+	c, err := pool.Add(context.Background(), smsg)
 	assert.False(c.Defined())
 	assert.Error(err)
 }
@@ -65,15 +67,185 @@ func TestMessagePoolDedup(t *testing.T) {
 	msg1 := newSignedMessage()
 
 	assert.Len(pool.Pending(), 0)
-	_, err := pool.Add(msg1)
+	_, err := pool.Add(context.Background(), msg1)
 	assert.NoError(err)
 	assert.Len(pool.Pending(), 1)
 
-	_, err = pool.Add(msg1)
+	_, err = pool.Add(context.Background(), msg1)
 	assert.NoError(err)
 	assert.Len(pool.Pending(), 1)
 }
 
+type rejectingValidator struct{}
+
+func (rejectingValidator) Validate(ctx context.Context, msg *types.SignedMessage) error {
+	return fmt.Errorf("rejected")
+}
+
+func TestMessagePoolValidates(t *testing.T) {
+	assert := assert.New(t)
+
+	pool := NewConfiguredMessagePool(&config.MessagePoolConfig{}, rejectingValidator{})
+	_, err := pool.Add(context.Background(), newSignedMessage())
+	assert.Error(err)
+	assert.Len(pool.Pending(), 0)
+}
+
+func TestMessagePoolLimits(t *testing.T) {
+	ctx := context.Background()
+	alice, bob, carol := mockSigner.Addresses[0], mockSigner.Addresses[1], mockSigner.Addresses[2]
+	newMessage := func(from address.Address, nonce uint64, gasPrice int64) *types.SignedMessage {
+		msg := types.NewMessage(from, address.MakeTestAddress("to"), nonce, types.NewAttoFILFromFIL(0), "", nil)
+		smsg, err := types.NewSignedMessage(*msg, &mockSigner, types.NewGasPrice(gasPrice), types.NewGasUnits(0))
+		require.NoError(t, err)
+		return smsg
+	}
+	requirePending := func(pool *MessagePool, expected ...*types.SignedMessage) {
+		pending := pool.Pending()
+		require.Len(t, pending, len(expected))
+		for _, msg := range expected {
+			found := false
+			for _, p := range pending {
+				found = found || types.SmsgCidsEqual(msg, p)
+			}
+			assert.True(t, found)
+		}
+	}
+
+	t.Run("per sender limit evicts the highest nonce for a lower one", func(t *testing.T) {
+		pool := NewConfiguredMessagePool(&config.MessagePoolConfig{MaxSenderMessages: 2}, nil)
+		m1, m2, m3, m0 := newMessage(alice, 1, 0), newMessage(alice, 2, 0), newMessage(alice, 3, 0), newMessage(alice, 0, 0)
+		MustAdd(pool, m1, m2)
+
+		_, err := pool.Add(ctx, m3)
+		assert.Equal(t, ErrSenderMessageLimit, errors.Cause(err))
+		MustAdd(pool, newMessage(bob, 0, 0))
+
+		MustAdd(pool, m0)
+		requirePending(pool, m0, m1, newMessage(bob, 0, 0))
+	})
+
+	t.Run("full pool evicts the last message of the cheapest sender", func(t *testing.T) {
+		pool := NewConfiguredMessagePool(&config.MessagePoolConfig{MaxPoolSize: 3}, nil)
+		a0, a1, b0 := newMessage(alice, 0, 5), newMessage(alice, 1, 1), newMessage(bob, 0, 3)
+		MustAdd(pool, a0, a1, b0)
+
+		// Not paying more than the cheapest evictable message.
+		_, err := pool.Add(ctx, newMessage(carol, 0, 1))
+		assert.Equal(t, ErrMessagePoolFull, errors.Cause(err))
+
+		c0 := newMessage(carol, 0, 2)
+		MustAdd(pool, c0)
+		requirePending(pool, a0, b0, c0)
+	})
+}
+
+func TestMessagePoolReplaceByFee(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+
+	newMessage := func(gasPrice int64) *types.SignedMessage {
+		msg := types.NewMessage(mockSigner.Addresses[0], address.MakeTestAddress("to"), 3, types.NewAttoFILFromFIL(0), "", nil)
+		smsg, err := types.NewSignedMessage(*msg, &mockSigner, types.NewGasPrice(gasPrice), types.NewGasUnits(0))
+		require.NoError(err)
+		return smsg
+	}
+
+	pool := NewConfiguredMessagePool(&config.MessagePoolConfig{ReplaceByFeePercent: 10}, nil)
+	original := newMessage(100)
+	MustAdd(pool, original)
+
+	// 10% higher is required
+	_, err := pool.Add(ctx, newMessage(109))
+	assert.Equal(ErrReplacementUnderpriced, errors.Cause(err))
+	require.Len(pool.Pending(), 1)
+	assert.True(types.SmsgCidsEqual(original, pool.Pending()[0]))
+
+	replacement := newMessage(110)
+	c, err := pool.Add(ctx, replacement)
+	require.NoError(err)
+	require.Len(pool.Pending(), 1)
+	assert.True(types.SmsgCidsEqual(replacement, pool.Pending()[0]))
+	got, ok := pool.Get(c)
+	assert.True(ok)
+	assert.True(types.SmsgCidsEqual(replacement, got))
+
+	// An unconfigured pool keeps both.
+	pool = NewMessagePool()
+	MustAdd(pool, original, replacement)
+	assert.Len(pool.Pending(), 2)
+}
+
+func TestMessagePoolExpiry(t *testing.T) {
+	assert := assert.New(t)
+
+	newSignedMessage := types.NewSignedMessageForTestGetter(mockSigner)
+	pool := NewConfiguredMessagePool(&config.MessagePoolConfig{MessageTTL: 2}, nil)
+	pool.UpdateHeight(10)
+	m1 := newSignedMessage()
+	MustAdd(pool, m1)
+	pool.UpdateHeight(11)
+	m2 := newSignedMessage()
+	MustAdd(pool, m2)
+
+	pool.UpdateHeight(12)
+	assertPoolEquals(assert, pool, m1, m2)
+	pool.UpdateHeight(13)
+	assertPoolEquals(assert, pool, m2)
+	pool.UpdateHeight(14)
+	assertPoolEquals(assert, pool)
+
+	// Messages of an unconfigured pool never expire.
+	pool = NewMessagePool()
+	MustAdd(pool, m1)
+	pool.UpdateHeight(100)
+	assertPoolEquals(assert, pool, m1)
+}
+
+func TestMessagePoolEvents(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	newMessage := func(gasPrice int64) *types.SignedMessage {
+		msg := types.NewMessage(mockSigner.Addresses[0], address.MakeTestAddress("to"), 0, types.NewAttoFILFromFIL(0), "", nil)
+		smsg, err := types.NewSignedMessage(*msg, &mockSigner, types.NewGasPrice(gasPrice), types.NewGasUnits(0))
+		require.NoError(err)
+		return smsg
+	}
+	requireEvent := func(ch chan interface{}, typ MessagePoolEventType, msg *types.SignedMessage) {
+		event := (<-ch).(MessagePoolEvent)
+		assert.Equal(typ, event.Type)
+		assert.True(types.SmsgCidsEqual(msg, event.Message))
+		c, err := msg.Cid()
+		require.NoError(err)
+		assert.Equal(c, event.Cid)
+	}
+
+	pool := NewConfiguredMessagePool(&config.MessagePoolConfig{ReplaceByFeePercent: 10, MessageTTL: 1}, nil)
+	ch := pool.Events().Sub(MessagePoolTopic)
+	defer pool.Events().Unsub(ch, MessagePoolTopic)
+
+	m1, m2, m3 := newMessage(100), newMessage(200), newMessage(300)
+	MustAdd(pool, m1)
+	requireEvent(ch, MessageAdded, m1)
+	MustAdd(pool, m2)
+	requireEvent(ch, MessageEvicted, m1)
+	requireEvent(ch, MessageAdded, m2)
+
+	c2, err := m2.Cid()
+	require.NoError(err)
+	pool.Remove(c2)
+	requireEvent(ch, MessageRemoved, m2)
+	// Removing a message that is not pending publishes nothing.
+	pool.Remove(c2)
+
+	MustAdd(pool, m3)
+	requireEvent(ch, MessageAdded, m3)
+	pool.UpdateHeight(2)
+	requireEvent(ch, MessageEvicted, m3)
+}
+
 func TestMessagePoolAsync(t *testing.T) {
 	assert := assert.New(t)
 
@@ -87,7 +259,7 @@ func TestMessagePoolAsync(t *testing.T) {
 		wg.Add(1)
 		go func(i int) {
 			for j := 0; j < count/4; j++ {
-				_, err := pool.Add(msgs[j+(count/4)*i])
+				_, err := pool.Add(context.Background(), msgs[j+(count/4)*i])
 				assert.NoError(err)
 			}
 			wg.Done()
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"context"
+	"math/big"
+
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+
+	"github.com/filecoin-project/go-filecoin/config"
+	"github.com/filecoin-project/go-filecoin/state"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// ErrNonceTooLow is returned when a message is not added to the pool because
+// its nonce is below the nonce of its sender, which means that it or another
+// message with the same nonce has been mined.
+var ErrNonceTooLow = errors.New("nonce is already used")
+
+// MessagePoolValidator validates messages before they are admitted to the
+// message pool.
+type MessagePoolValidator interface {
+	// Validate returns an error if msg should not be added to the pool.
+	Validate(ctx context.Context, msg *types.SignedMessage) error
+}
+
+// DefaultMessagePoolValidator validates messages against the state of the
+// head of the chain.
+type DefaultMessagePoolValidator struct {
+	latestState func(context.Context) (state.Tree, error)
+	maxNonceGap uint64
+}
+
+var _ MessagePoolValidator = (*DefaultMessagePoolValidator)(nil)
+
+// NewDefaultMessagePoolValidator creates a validator checking messages
+// against the state returned by latestState.
+func NewDefaultMessagePoolValidator(cfg *config.MessagePoolConfig, latestState func(context.Context) (state.Tree, error)) *DefaultMessagePoolValidator {
+	return &DefaultMessagePoolValidator{
+		latestState: latestState,
+		maxNonceGap: cfg.MaxNonceGap,
+	}
+}
+
+// Validate checks that the sender of msg exists, that the nonce of msg is not
+// below the sender's nonce nor too far above it, and that the sender's
+// balance covers the value of msg and its maximum gas charge.
+func (v *DefaultMessagePoolValidator) Validate(ctx context.Context, msg *types.SignedMessage) error {
+	st, err := v.latestState(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to load head state")
+	}
+	fromActor, err := st.GetActor(ctx, msg.From)
+	if state.IsActorNotFoundError(err) {
+		return errors.Errorf("sender %s does not exist", msg.From)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to get sender %s", msg.From)
+	}
+
+	nonce := uint64(fromActor.Nonce)
+	if uint64(msg.Nonce) < nonce {
+		return errors.Wrapf(ErrNonceTooLow, "nonce %d is below the sender's nonce %d", msg.Nonce, nonce)
+	}
+	if uint64(msg.Nonce)-nonce > v.maxNonceGap {
+		return errors.Errorf("nonce %d is more than %d above the sender's nonce %d", msg.Nonce, v.maxNonceGap, nonce)
+	}
+
+	cost := msg.GasPrice.MulBigInt(big.NewInt(int64(msg.GasLimit)))
+	if msg.Value != nil {
+		cost = cost.Add(msg.Value)
+	}
+	if fromActor.Balance == nil || fromActor.Balance.LessThan(cost) {
+		return errors.Errorf("sender %s balance does not cover value and gas of %s", msg.From, cost)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"gx/ipfs/QmRXf2uUSdGSunRJsM9wXSUNVwLUGCY3So5fAs7h2CBJVf/go-hamt-ipld"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+
+	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/config"
+	"github.com/filecoin-project/go-filecoin/state"
+	"github.com/filecoin-project/go-filecoin/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultMessagePoolValidator(t *testing.T) {
+	ctx := context.Background()
+	require := require.New(t)
+
+	alice, bob := mockSigner.Addresses[0], mockSigner.Addresses[1]
+	st := state.NewEmptyStateTree(hamt.NewCborStore())
+	aliceActor := actor.NewActor(types.AccountActorCodeCid, types.NewAttoFILFromFIL(1000))
+	aliceActor.Nonce = 5
+	require.NoError(st.SetActor(ctx, alice, aliceActor))
+
+	cfg := &config.MessagePoolConfig{MaxNonceGap: 10}
+	validator := NewDefaultMessagePoolValidator(cfg, func(context.Context) (state.Tree, error) {
+		return st, nil
+	})
+
+	newMessage := func(from address.Address, nonce uint64, value uint64, gasPrice int64, gasLimit uint64) *types.SignedMessage {
+		msg := types.NewMessage(from, address.MakeTestAddress("to"), nonce, types.NewAttoFILFromFIL(value), "", nil)
+		smsg, err := types.NewSignedMessage(*msg, &mockSigner, types.NewGasPrice(gasPrice), types.NewGasUnits(gasLimit))
+		require.NoError(err)
+		return smsg
+	}
+
+	t.Run("valid message", func(t *testing.T) {
+		assert.NoError(t, validator.Validate(ctx, newMessage(alice, 5, 10, 0, 0)))
+		assert.NoError(t, validator.Validate(ctx, newMessage(alice, 15, 10, 0, 0)))
+	})
+
+	t.Run("unknown sender", func(t *testing.T) {
+		err := validator.Validate(ctx, newMessage(bob, 0, 0, 0, 0))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "does not exist")
+	})
+
+	t.Run("nonce too low", func(t *testing.T) {
+		err := validator.Validate(ctx, newMessage(alice, 4, 0, 0, 0))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "below the sender's nonce")
+		assert.Equal(t, ErrNonceTooLow, errors.Cause(err))
+	})
+
+	t.Run("nonce gap too large", func(t *testing.T) {
+		err := validator.Validate(ctx, newMessage(alice, 16, 0, 0, 0))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "more than 10 above")
+	})
+
+	t.Run("insufficient balance", func(t *testing.T) {
+		assert.Error(t, validator.Validate(ctx, newMessage(alice, 5, 1001, 0, 0)))
+		// 1000 FIL covers the value but not the gas
+		assert.Error(t, validator.Validate(ctx, newMessage(alice, 5, 1000, 1, 1)))
+	})
+}
@@ -36,7 +36,7 @@ func MustGetNonce(st state.Tree, a address.Address) uint64 {
 // cannot.
 func MustAdd(p *MessagePool, msgs ...*types.SignedMessage) {
 	for _, m := range msgs {
-		if _, err := p.Add(m); err != nil {
+		if _, err := p.Add(context.Background(), m); err != nil {
 			panic(err)
 		}
 	}
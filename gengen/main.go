@@ -48,10 +48,18 @@ $ cat setup.json
 			"owner": 1,
 			"power": 1000
 		}
-	]
+	],
+	"rewardSchedule": {
+		"initialReward": "1000",
+		"decayPercent": 50,
+		"decayInterval": 100000
+	}
 }
 $ cat setup.json | gengen > genesis.car
 
+The optional rewardSchedule halves the block reward of 1000 FIL every 100000
+blocks here, without it the reward stays at 1000 FIL.
+
 The outputted file can be used by go-filecoin during init to
 set the initial genesis block:
 $ go-filecoin init --genesisfile=genesis.car
@@ -11,6 +11,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/actor"
 	"github.com/filecoin-project/go-filecoin/actor/builtin"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/account"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/reward"
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/crypto"
@@ -59,6 +60,10 @@ type GenesisCfg struct {
 
 	// Miners is a list of miners that should be set up at the start of the network
 	Miners []Miner
+
+	// RewardSchedule is the block reward schedule of the network, the
+	// default flat one if it is nil
+	RewardSchedule *reward.Schedule
 }
 
 // RenderedGenInfo contains information about a genesis block creation
@@ -100,7 +105,7 @@ func GenGen(ctx context.Context, cfg *GenesisCfg, cst *hamt.CborIpldStore, bs bl
 	st := state.NewEmptyStateTreeWithActors(cst, builtin.Actors)
 	storageMap := vm.NewStorageMap(bs)
 
-	if err := consensus.SetupDefaultActors(ctx, st, storageMap); err != nil {
+	if err := consensus.SetupDefaultActors(ctx, st, storageMap, cfg.RewardSchedule); err != nil {
 		return nil, err
 	}
 
@@ -128,6 +133,9 @@ func GenGen(ctx context.Context, cfg *GenesisCfg, cst *hamt.CborIpldStore, bs bl
 	if err := cst.Blocks.AddBlock(types.PaymentBrokerActorCodeObj); err != nil {
 		return nil, err
 	}
+	if err := cst.Blocks.AddBlock(types.RewardActorCodeObj); err != nil {
+		return nil, err
+	}
 
 	stateRoot, err := st.Flush(ctx)
 	if err != nil {
@@ -363,7 +371,7 @@ type blockRewarder struct{}
 var _ consensus.BlockRewarder = (*blockRewarder)(nil)
 
 // BlockReward is a noop
-func (gbr *blockRewarder) BlockReward(ctx context.Context, st state.Tree, minerAddr address.Address) error {
+func (gbr *blockRewarder) BlockReward(ctx context.Context, st state.Tree, vms vm.StorageMap, minerAddr address.Address, bh *types.BlockHeight) error {
 	return nil
 }
 
@@ -15,6 +15,7 @@ import (
 	logging "gx/ipfs/QmcuXC5cxs79ro2cUuHs4HQ2bkDLJUYokwL8aivcX6HW3C/go-log"
 
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/chain"
 	"github.com/filecoin-project/go-filecoin/config"
 	"github.com/filecoin-project/go-filecoin/types"
 )
@@ -34,9 +35,10 @@ type Heartbeat struct {
 	Height uint64
 	// Nickname is the nickname given to the filecoin node by the user
 	Nickname string
-	// TODO: add when implemented
 	// Syncing is `true` iff the node is currently syncing its chain with the network.
-	// Syncing bool
+	Syncing bool
+	// SyncTargetHeight is the height of the tipset the node is (or was last) syncing to.
+	SyncTargetHeight uint64
 
 	// Address of this node's active miner. Can be empty - will return the zero address
 	MinerAddress address.Address
@@ -53,6 +55,9 @@ type HeartbeatService struct {
 	// A function that returns the miner's address
 	MinerAddressGetter func() address.Address
 
+	// A function that returns the status of the chain syncer
+	SyncStatusGetter func() chain.SyncStatus
+
 	streamMu sync.Mutex
 	stream   net.Stream
 }
@@ -67,10 +72,21 @@ func WithMinerAddressGetter(ag func() address.Address) HeartbeatServiceOption {
 	}
 }
 
+// WithSyncStatusGetter returns an option that can be used to set the sync status getter.
+func WithSyncStatusGetter(sg func() chain.SyncStatus) HeartbeatServiceOption {
+	return func(service *HeartbeatService) {
+		service.SyncStatusGetter = sg
+	}
+}
+
 func defaultMinerAddressGetter() address.Address {
 	return address.Address{}
 }
 
+func defaultSyncStatusGetter() chain.SyncStatus {
+	return chain.SyncStatus{}
+}
+
 // NewHeartbeatService returns a HeartbeatService
 func NewHeartbeatService(h host.Host, hbc *config.HeartbeatConfig, hg func() types.TipSet, options ...HeartbeatServiceOption) *HeartbeatService {
 	srv := &HeartbeatService{
@@ -78,6 +94,7 @@ func NewHeartbeatService(h host.Host, hbc *config.HeartbeatConfig, hg func() typ
 		Config:             hbc,
 		HeadGetter:         hg,
 		MinerAddressGetter: defaultMinerAddressGetter,
+		SyncStatusGetter:   defaultSyncStatusGetter,
 	}
 
 	for _, option := range options {
@@ -177,11 +194,14 @@ func (hbs *HeartbeatService) Beat() Heartbeat {
 		log.Warningf("heartbeat service failed to get chain height: %s", err)
 	}
 	addr := hbs.MinerAddressGetter()
+	syncStatus := hbs.SyncStatusGetter()
 	return Heartbeat{
-		Head:         tipset,
-		Height:       height,
-		Nickname:     nick,
-		MinerAddress: addr,
+		Head:             tipset,
+		Height:           height,
+		Nickname:         nick,
+		Syncing:          syncStatus.Syncing,
+		SyncTargetHeight: syncStatus.TargetHeight,
+		MinerAddress:     addr,
 	}
 }
 
@@ -17,6 +17,7 @@ import (
 	"gx/ipfs/QmaoXrM4Z41PD48JY36YqQGKQpLGjyLA2cKcLsES7YddAq/go-libp2p-host"
 
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/chain"
 	"github.com/filecoin-project/go-filecoin/config"
 	"github.com/filecoin-project/go-filecoin/fixtures"
 	"github.com/filecoin-project/go-filecoin/types"
@@ -141,6 +142,8 @@ func TestHeartbeatRunSuccess(t *testing.T) {
 		assert.Equal(uint64(444), hb.Height)
 		assert.Equal("BobHoblaw", hb.Nickname)
 		assert.Equal(addr, hb.MinerAddress)
+		assert.True(hb.Syncing)
+		assert.Equal(uint64(500), hb.SyncTargetHeight)
 		cancel()
 	})
 
@@ -158,6 +161,9 @@ func TestHeartbeatRunSuccess(t *testing.T) {
 		WithMinerAddressGetter(func() address.Address {
 			return addr
 		}),
+		WithSyncStatusGetter(func() chain.SyncStatus {
+			return chain.SyncStatus{Syncing: true, TargetHeight: 500}
+		}),
 	)
 
 	require.NoError(hbs.Connect(ctx))
@@ -10,7 +10,6 @@ import (
 
 	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
 
-	"github.com/filecoin-project/go-filecoin/core"
 	"github.com/filecoin-project/go-filecoin/proofs"
 	"github.com/filecoin-project/go-filecoin/types"
 	"github.com/filecoin-project/go-filecoin/vm"
@@ -54,10 +53,13 @@ func (w *DefaultWorker) Generate(ctx context.Context,
 		return nil, errors.Wrap(err, "get base tip set ancestors")
 	}
 
-	pending := w.messagePool.Pending()
-	messages := make([]*types.SignedMessage, len(pending))
-
-	copy(messages, core.OrderMessagesByNonce(pending))
+	// Previews get their own storage map so that none of their changes are
+	// seen when applying the messages.
+	estimate := NewPreviewGasEstimator(vm.NewStorageMap(w.blockstore), types.NewBlockHeight(blockHeight))
+	messages, err := SelectMessages(ctx, stateTree, w.messagePool.Pending(), types.BlockGasLimit, estimate)
+	if err != nil {
+		return nil, errors.Wrap(err, "select messages")
+	}
 
 	vms := vm.NewStorageMap(w.blockstore)
 	res, err := w.processor.ApplyMessagesAndPayRewards(ctx, stateTree, vms, messages, w.minerAddr, types.NewBlockHeight(blockHeight), ancestors)
@@ -0,0 +1,83 @@
+package mining
+
+import (
+	"sync"
+	"time"
+
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// DefaultHistorySize is the number of rounds a History returned by NewHistory
+// keeps by default.
+const DefaultHistorySize = 1000
+
+// Round is the outcome of a mining round of a worker.
+type Round struct {
+	Time time.Time
+	// Miner is the miner the worker mined for.
+	Miner address.Address
+	// Base is the tipset the worker mined on.
+	Base types.SortedCidSet
+	// Height is the height of the block the worker tried to mine.
+	Height     uint64
+	NullBlocks int
+	Ticket     types.Signature
+	Won        bool
+	// Block is the cid of the block mined in the round, if any.
+	Block *cid.Cid `json:",omitempty"`
+	// Superseded is true if the block was never published because the
+	// scheduler mined again on a wider or newer base instead.
+	Superseded bool `json:",omitempty"`
+	// Err is the error that ended the round, if any.
+	Err string `json:",omitempty"`
+}
+
+// History keeps the last rounds of a worker. It is safe for concurrent use.
+type History struct {
+	lk     sync.Mutex
+	size   int
+	rounds []Round
+}
+
+// NewHistory returns a History keeping the last size rounds.
+func NewHistory(size int) *History {
+	return &History{size: size}
+}
+
+// Record adds a round to the history, forgetting the oldest one if the history
+// is full.
+func (h *History) Record(r Round) {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+
+	h.rounds = append(h.rounds, r)
+	if len(h.rounds) > h.size {
+		h.rounds = h.rounds[len(h.rounds)-h.size:]
+	}
+}
+
+// Supersede marks the round in which block was mined as superseded.
+func (h *History) Supersede(block cid.Cid) {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+
+	for i := len(h.rounds) - 1; i >= 0; i-- {
+		if r := &h.rounds[i]; r.Block != nil && r.Block.Equals(block) {
+			r.Superseded = true
+			return
+		}
+	}
+}
+
+// Rounds returns the rounds in the history, oldest first.
+func (h *History) Rounds() []Round {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+
+	rounds := make([]Round, len(h.rounds))
+	copy(rounds, h.rounds)
+	return rounds
+}
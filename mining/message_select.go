@@ -0,0 +1,191 @@
+package mining
+
+import (
+	"container/heap"
+	"context"
+	"math/big"
+	"sort"
+
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/filecoin-project/go-filecoin/state"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm"
+)
+
+// GasEstimator returns an estimate of the gas msg uses when applied on top of
+// the state st.
+type GasEstimator func(ctx context.Context, st state.Tree, msg *types.SignedMessage) (types.GasUnits, error)
+
+// NewPreviewGasEstimator returns a GasEstimator previewing messages at block
+// height bh, without changing the state or storing anything in vms.
+func NewPreviewGasEstimator(vms vm.StorageMap, bh *types.BlockHeight) GasEstimator {
+	return func(ctx context.Context, st state.Tree, msg *types.SignedMessage) (types.GasUnits, error) {
+		return consensus.PreviewQueryMethod(ctx, st, vms, msg.To, msg.Method, msg.Params, msg.From, bh)
+	}
+}
+
+// SelectMessages returns the messages of pending to include in a block on
+// top of the state st, ordered for application, whose gas fits in
+// gasLimit.
+//
+// The messages of each sender form a chain of consecutive nonces starting at
+// the sender's nonce in st; messages below it, after a gap or from unknown
+// senders are left out since they could not be applied.  Chains are merged
+// greedily by their effective gas price: the best average gas price, weighted
+// by the estimated gas of the messages, of a run of messages from the start of
+// the chain, so that a message paying a high price pulls in the cheaper
+// messages it depends on.  The run of the chain with the highest effective
+// price is taken next.
+//
+// The gas of a message is estimated with estimate, or taken to be its gas
+// limit when it cannot be estimated.  The processor rejects a message whose
+// limit does not fit in what is left of the block once the gas used by the
+// previous messages is taken out, so a sender whose next message does not
+// fit is dropped rather than executing messages bound to fail.
+func SelectMessages(ctx context.Context, st state.Tree, pending []*types.SignedMessage, gasLimit types.GasUnits, estimate GasEstimator) ([]*types.SignedMessage, error) {
+	bySender := make(map[address.Address][]*types.SignedMessage)
+	for _, msg := range pending {
+		bySender[msg.From] = append(bySender[msg.From], msg)
+	}
+
+	chains := &messageChains{}
+	for from, msgs := range bySender {
+		fromActor, err := st.GetActor(ctx, from)
+		if state.IsActorNotFoundError(err) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get sender %s", from)
+		}
+
+		sort.Slice(msgs, func(i, j int) bool { return msgs[i].Nonce < msgs[j].Nonce })
+		nonce := fromActor.Nonce
+		chain := &messageChain{}
+		for _, msg := range msgs {
+			if msg.Nonce < nonce {
+				continue
+			}
+			if msg.Nonce > nonce {
+				break
+			}
+			gas, err := estimate(ctx, st, msg)
+			if err != nil || gas > msg.GasLimit {
+				gas = msg.GasLimit
+			}
+			chain.msgs = append(chain.msgs, msg)
+			chain.gas = append(chain.gas, gas)
+			nonce++
+		}
+		if len(chain.msgs) > 0 {
+			chain.updateBestRun()
+			chains.chains = append(chains.chains, chain)
+		}
+	}
+	heap.Init(chains)
+
+	var selected []*types.SignedMessage
+	gasUsed := types.NewGasUnits(0)
+	for chains.Len() > 0 {
+		chain := chains.chains[0]
+		fits := true
+		for i := 0; i < chain.run; i++ {
+			next := chain.msgs[0]
+			if next.GasLimit > gasLimit || gasUsed > gasLimit-next.GasLimit {
+				fits = false
+				break
+			}
+			selected = append(selected, next)
+			gasUsed += chain.gas[0]
+			chain.msgs, chain.gas = chain.msgs[1:], chain.gas[1:]
+		}
+		if !fits || len(chain.msgs) == 0 {
+			heap.Pop(chains)
+			continue
+		}
+		chain.updateBestRun()
+		heap.Fix(chains, 0)
+	}
+	return selected, nil
+}
+
+// messageChain is the nonce ordered messages of a sender with their estimated
+// gas, and the run of messages from its start with the best effective price.
+type messageChain struct {
+	msgs []*types.SignedMessage
+	gas  []types.GasUnits
+
+	// run is the number of messages of the run, fees what they pay for
+	// their estimated gas and weight the gas they are weighted by.
+	run    int
+	fees   *types.AttoFIL
+	weight uint64
+}
+
+// updateBestRun finds the run of messages from the start of the chain with
+// the highest effective price.  Messages count for at least a unit of gas so
+// that messages estimated to use none still count.
+func (mc *messageChain) updateBestRun() {
+	fees := types.NewZeroAttoFIL()
+	var weight uint64
+	mc.run = 0
+	for i, msg := range mc.msgs {
+		w := uint64(mc.gas[i])
+		if w == 0 {
+			w = 1
+		}
+		fees = fees.Add(msg.GasPrice.MulBigInt(new(big.Int).SetUint64(w)))
+		weight += w
+		if mc.run == 0 || isHigherPrice(fees, weight, mc.fees, mc.weight) {
+			mc.run, mc.fees, mc.weight = i+1, fees, weight
+		}
+	}
+}
+
+// isHigherPrice returns true if feesA/weightA > feesB/weightB.
+func isHigherPrice(feesA *types.AttoFIL, weightA uint64, feesB *types.AttoFIL, weightB uint64) bool {
+	a := feesA.MulBigInt(new(big.Int).SetUint64(weightB))
+	b := feesB.MulBigInt(new(big.Int).SetUint64(weightA))
+	return a.GreaterThan(b)
+}
+
+// messageChains is a max-heap of the message chains of senders, ordered by
+// their effective price.
+type messageChains struct {
+	chains []*messageChain
+}
+
+var _ heap.Interface = (*messageChains)(nil)
+
+func (mc *messageChains) Len() int {
+	return len(mc.chains)
+}
+
+func (mc *messageChains) Less(i, j int) bool {
+	a, b := mc.chains[i], mc.chains[j]
+	if isHigherPrice(a.fees, a.weight, b.fees, b.weight) {
+		return true
+	}
+	if isHigherPrice(b.fees, b.weight, a.fees, a.weight) {
+		return false
+	}
+	// Break ties deterministically so that the selection does not depend on
+	// map iteration order.
+	return a.msgs[0].From.String() < b.msgs[0].From.String()
+}
+
+func (mc *messageChains) Swap(i, j int) {
+	mc.chains[i], mc.chains[j] = mc.chains[j], mc.chains[i]
+}
+
+func (mc *messageChains) Push(x interface{}) {
+	mc.chains = append(mc.chains, x.(*messageChain))
+}
+
+func (mc *messageChains) Pop() interface{} {
+	last := mc.chains[len(mc.chains)-1]
+	mc.chains = mc.chains[:len(mc.chains)-1]
+	return last
+}
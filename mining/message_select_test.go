@@ -0,0 +1,103 @@
+package mining
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"gx/ipfs/QmRXf2uUSdGSunRJsM9wXSUNVwLUGCY3So5fAs7h2CBJVf/go-hamt-ipld"
+
+	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/state"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	"github.com/filecoin-project/go-filecoin/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectMessages(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+
+	alice, bob, carol := mockSigner.Addresses[0], mockSigner.Addresses[1], mockSigner.Addresses[2]
+	unknown := mockSigner.Addresses[3]
+	aliceActor := th.RequireNewAccountActor(require, types.NewAttoFILFromFIL(1000))
+	aliceActor.Nonce = 2
+	_, st := th.RequireMakeStateTree(require, hamt.NewCborStore(), map[address.Address]*actor.Actor{
+		alice: aliceActor,
+		bob:   th.RequireNewAccountActor(require, types.NewAttoFILFromFIL(1000)),
+		carol: th.RequireNewAccountActor(require, types.NewAttoFILFromFIL(1000)),
+	})
+
+	// the estimates are the gas limits unless set in estimates
+	estimates := make(map[*types.SignedMessage]types.GasUnits)
+	estimate := func(ctx context.Context, st state.Tree, msg *types.SignedMessage) (types.GasUnits, error) {
+		if gas, ok := estimates[msg]; ok {
+			return gas, nil
+		}
+		return types.NewGasUnits(0), errors.New("no estimate")
+	}
+
+	newMessage := func(from address.Address, nonce uint64, price int64, limit uint64) *types.SignedMessage {
+		msg := types.NewMessage(from, carol, nonce, nil, "", nil)
+		smsg, err := types.NewSignedMessage(*msg, &mockSigner, types.NewGasPrice(price), types.NewGasUnits(limit))
+		require.NoError(err)
+		return smsg
+	}
+
+	t.Run("orders senders by gas price and respects nonces", func(t *testing.T) {
+		a1, a2, a3 := newMessage(alice, 1, 9, 10), newMessage(alice, 2, 1, 10), newMessage(alice, 3, 5, 10)
+		aGap := newMessage(alice, 5, 9, 10)
+		b0, b1 := newMessage(bob, 0, 3, 10), newMessage(bob, 1, 7, 10)
+		u0 := newMessage(unknown, 0, 9, 10)
+
+		selected, err := SelectMessages(ctx, st, []*types.SignedMessage{aGap, a3, b1, u0, a2, b0, a1}, types.BlockGasLimit, estimate)
+		require.NoError(err)
+		// a1 is below alice's nonce, aGap follows a gap and the sender of u0
+		// does not exist.
+		assert.Equal([]*types.SignedMessage{b0, b1, a2, a3}, selected)
+	})
+
+	t.Run("packs up to the gas limit", func(t *testing.T) {
+		a2, a3 := newMessage(alice, 2, 9, 60), newMessage(alice, 3, 9, 10)
+		b0, b1 := newMessage(bob, 0, 5, 50), newMessage(bob, 1, 5, 10)
+		c0 := newMessage(carol, 0, 1, 30)
+
+		selected, err := SelectMessages(ctx, st, []*types.SignedMessage{a2, a3, b0, b1, c0}, types.NewGasUnits(100), estimate)
+		require.NoError(err)
+		// b0 does not fit after alice's messages, which leaves out bob's
+		// remaining messages, but c0 still does.
+		assert.Equal([]*types.SignedMessage{a2, a3, c0}, selected)
+
+		selected, err = SelectMessages(ctx, st, []*types.SignedMessage{a2}, types.NewGasUnits(50), estimate)
+		require.NoError(err)
+		assert.Empty(selected)
+	})
+
+	t.Run("orders senders by the effective price of their chains", func(t *testing.T) {
+		// alice's cheap message is paid for by the next one
+		a2, a3 := newMessage(alice, 2, 1, 10), newMessage(alice, 3, 20, 10)
+		b0 := newMessage(bob, 0, 5, 10)
+
+		selected, err := SelectMessages(ctx, st, []*types.SignedMessage{b0, a2, a3}, types.BlockGasLimit, estimate)
+		require.NoError(err)
+		assert.Equal([]*types.SignedMessage{a2, a3, b0}, selected)
+	})
+
+	t.Run("packs using estimated gas", func(t *testing.T) {
+		var msgs []*types.SignedMessage
+		for nonce := uint64(0); nonce < 4; nonce++ {
+			msg := newMessage(bob, nonce, 1, 50)
+			estimates[msg] = types.NewGasUnits(10)
+			msgs = append(msgs, msg)
+		}
+
+		// only two of them would fit going by their gas limits
+		selected, err := SelectMessages(ctx, st, msgs, types.NewGasUnits(100), estimate)
+		require.NoError(err)
+		assert.Equal(msgs, selected)
+	})
+}
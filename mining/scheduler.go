@@ -25,6 +25,13 @@ package mining
 // best interest to wait for the collection period so that they can wait to
 // work on a base tipset made up of all blocks mined at the new height.
 //
+// Blocks mined at the height of the base can still arrive after the collect
+// state. While it mines, the scheduler keeps polling the head: when the head
+// widens the base with such sibling blocks, the run is canceled and restarted
+// on the wider, heavier base, which is also checked for right before a won
+// block is published. When a tipset with a greater height arrives the base is
+// stale and the run is abandoned.
+//
 // The current approach is limited. It does not prevent wasted work from all
 // strategic block witholding attacks.  This is also going to be effected by
 // current unknowns surrounding the specifics of the mining protocol (i.e. how
@@ -37,6 +44,7 @@ import (
 	"sync"
 	"time"
 
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
 	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
 
 	"github.com/filecoin-project/go-filecoin/types"
@@ -47,8 +55,8 @@ import (
 //   - inCh: the caller sends Inputs to mine on to this channel.
 //   - outCh: the scheduler sends Outputs to the caller on this channel.
 //   - doneWg: signals that the scheduler and any goroutines it launched
-//             have stopped. (Context cancelation happens async, so you
-//             need some way to know when it has actually stopped.)
+//     have stopped. (Context cancelation happens async, so you
+//     need some way to know when it has actually stopped.)
 //
 // Once Start()ed, the Scheduler can be stopped by canceling its miningCtx,
 // which will signal on doneWg when it's actually done. Canceling miningCtx
@@ -66,6 +74,9 @@ type timingScheduler struct {
 	// pollHeadFunc is the function the scheduler uses to poll for the
 	// current heaviest tipset
 	pollHeadFunc func() types.TipSet
+	// rebaseInterval is how often the scheduler polls the head while mining
+	// to re-base or abandon the run in progress.
+	rebaseInterval time.Duration
 
 	isStarted bool
 }
@@ -123,9 +134,8 @@ func (s *timingScheduler) Start(miningCtx context.Context) (<-chan Output, *sync
 			// Determine how many null blocks we should mine with.
 			nullBlkCount = nextNullBlkCount(nullBlkCount, prevBase, base)
 
-			// Mine synchronously! Ignore all new tipsets.
-			prevWon = s.worker.Mine(miningCtx, base, nullBlkCount, outCh)
-			prevBase = base
+			// Mine synchronously! Only follow the tipsets widening the base.
+			prevWon, prevBase = s.mine(miningCtx, base, nullBlkCount, outCh)
 		}
 	}()
 
@@ -141,6 +151,145 @@ func (s *timingScheduler) Start(miningCtx context.Context) (<-chan Output, *sync
 	return outCh, &extDoneWg
 }
 
+// mine runs the worker on base and forwards its outputs to outCh. The run is
+// restarted on the head when the head widens base, and abandoned without
+// output when the head supersedes base. It returns whether the worker won and
+// the base it mined on last.
+func (s *timingScheduler) mine(miningCtx context.Context, base types.TipSet, nullBlkCount int, outCh chan<- Output) (bool, types.TipSet) {
+	for {
+		runCtx, cancelRun := context.WithCancel(miningCtx)
+		runOutCh := make(chan Output, 1)
+		wonCh := make(chan bool, 1)
+		go func(base types.TipSet) {
+			wonCh <- s.worker.Mine(runCtx, base, nullBlkCount, runOutCh)
+		}(base)
+
+		var outputs []Output
+		var won, stale bool
+		var widerBase types.TipSet
+		ticker := time.NewTicker(s.rebaseInterval)
+	run:
+		for {
+			select {
+			case out := <-runOutCh:
+				outputs = append(outputs, out)
+			case won = <-wonCh:
+				break run
+			case <-ticker.C:
+				if widerBase != nil || stale {
+					continue
+				}
+				head := s.pollHeadFunc()
+				if widens(base, head) {
+					log.Infof("Re-basing mining run from %s onto %s", base.String(), head.String())
+					widerBase = head
+					cancelRun()
+				} else if supersedes(base, head) {
+					log.Infof("Abandoning mining run on stale base %s, head is %s", base.String(), head.String())
+					stale = true
+					cancelRun()
+				}
+			}
+		}
+		ticker.Stop()
+		cancelRun()
+		// Collect the outputs sent right before the worker returned.
+	drain:
+		for {
+			select {
+			case out := <-runOutCh:
+				outputs = append(outputs, out)
+			default:
+				break drain
+			}
+		}
+
+		if stale {
+			s.supersede(outputs)
+			return false, base
+		}
+		if widerBase == nil && won {
+			// Don't publish a block on a base that is known to be too narrow.
+			if head := s.pollHeadFunc(); widens(base, head) {
+				log.Infof("Re-basing won mining run from %s onto %s before publishing", base.String(), head.String())
+				widerBase = head
+			}
+		}
+		if widerBase != nil {
+			s.supersede(outputs)
+			base = widerBase
+			continue
+		}
+
+		for _, out := range outputs {
+			select {
+			case outCh <- out:
+			case <-miningCtx.Done():
+				return won, base
+			}
+		}
+		return won, base
+	}
+}
+
+// superseder is implemented by the workers that want to know of the blocks
+// they mined that the scheduler dropped.
+type superseder interface {
+	Supersede(block cid.Cid)
+}
+
+// supersede tells the worker, if it wants to know, of the blocks of outputs,
+// which are dropped.
+func (s *timingScheduler) supersede(outputs []Output) {
+	sw, ok := s.worker.(superseder)
+	if !ok {
+		return
+	}
+	for _, out := range outputs {
+		if out.NewBlock != nil {
+			sw.Supersede(out.NewBlock.Cid())
+		}
+	}
+}
+
+// widens returns true if head is made of the blocks of base and of sibling
+// blocks at the same height.
+func widens(base, head types.TipSet) bool {
+	if len(head) <= len(base) {
+		return false
+	}
+	baseHeight, err := base.Height()
+	if err != nil {
+		return false
+	}
+	headHeight, err := head.Height()
+	if err != nil || headHeight != baseHeight {
+		return false
+	}
+	for id := range base {
+		if _, ok := head[id]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// supersedes returns true if head has a greater height than base.
+func supersedes(base, head types.TipSet) bool {
+	if len(head) == 0 {
+		return false
+	}
+	baseHeight, err := base.Height()
+	if err != nil {
+		return false
+	}
+	headHeight, err := head.Height()
+	if err != nil {
+		return false
+	}
+	return headHeight > baseHeight
+}
+
 // IsStarted is called when starting mining to tell whether the scheduler should be
 // started
 func (s *timingScheduler) IsStarted() bool {
@@ -165,7 +314,7 @@ func nextNullBlkCount(prevNullBlkCount int, prevBase, currBase types.TipSet) int
 // NewScheduler returns a new timingScheduler to schedule mining work on the
 // input worker.
 func NewScheduler(w Worker, md time.Duration, f func() types.TipSet) Scheduler {
-	return &timingScheduler{worker: w, mineDelay: md, pollHeadFunc: f}
+	return &timingScheduler{worker: w, mineDelay: md, pollHeadFunc: f, rebaseInterval: md}
 }
 
 // MineOnce is a convenience function that presents a synchronous blocking
@@ -2,9 +2,12 @@ package mining
 
 import (
 	"context"
+	"sync"
 	"testing"
 	"time"
 
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+
 	th "github.com/filecoin-project/go-filecoin/testhelpers"
 	"github.com/filecoin-project/go-filecoin/types"
 	"github.com/stretchr/testify/assert"
@@ -35,10 +38,11 @@ func TestMineOnce(t *testing.T) {
 
 func TestSchedulerPassesValue(t *testing.T) {
 	assert, _, ts := newTestUtils(t)
-	ctx, cancel := context.WithCancel(context.Background())
+	type ctxKey struct{}
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), ctxKey{}, "mining"))
 
 	checkValsMine := func(c context.Context, inTS types.TipSet, nBC int, outCh chan<- Output) bool {
-		assert.Equal(ctx, c) // individual run ctx splits off from mining ctx
+		assert.Equal("mining", c.Value(ctxKey{})) // individual run ctx splits off from mining ctx
 		assert.Equal(inTS, ts)
 		outCh <- Output{}
 		return true
@@ -281,3 +285,132 @@ func TestSchedulerMultiRoundWithCollect(t *testing.T) {
 
 	assert.Equal(ChannelClosed, ReceiveOutCh(outCh))
 }
+
+// syncHead is a head safe to change while the scheduler polls it.
+type syncHead struct {
+	lk sync.Mutex
+	ts types.TipSet
+}
+
+func (h *syncHead) get() types.TipSet {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+	return h.ts
+}
+
+func (h *syncHead) set(ts types.TipSet) {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+	h.ts = ts
+}
+
+func TestSchedulerRebasesOntoSiblings(t *testing.T) {
+	assert, require, ts1 := newTestUtils(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sibling := &types.Block{StateRoot: types.SomeCid()}
+	wider := th.RequireNewTipSet(require, ts1.ToSlice()[0], sibling)
+
+	head := &syncHead{ts: ts1}
+	canceled := make(chan struct{})
+	rebaseMine := func(c context.Context, inTS types.TipSet, nBC int, outCh chan<- Output) bool {
+		if inTS.Equals(ts1) {
+			head.set(wider)
+			<-c.Done()
+			close(canceled)
+			return false
+		}
+		assert.Equal(wider, inTS)
+		assert.Equal(0, nBC)
+		outCh <- Output{NewBlock: sibling}
+		return true
+	}
+	worker := NewTestWorkerWithDeps(rebaseMine)
+	scheduler := NewScheduler(worker, MineDelayTest, head.get)
+	outCh, _ := scheduler.Start(ctx)
+
+	out := <-outCh
+	assert.Equal(sibling, out.NewBlock)
+	select {
+	case <-canceled:
+	default:
+		t.Fatal("the run on the narrower base was not canceled")
+	}
+}
+
+func TestSchedulerRebasesBeforePublishing(t *testing.T) {
+	assert, require, ts1 := newTestUtils(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sibling := &types.Block{StateRoot: types.SomeCid()}
+	wider := th.RequireNewTipSet(require, ts1.ToSlice()[0], sibling)
+
+	head := &syncHead{ts: ts1}
+	var bases []types.TipSet
+	winningMine := func(c context.Context, inTS types.TipSet, nBC int, outCh chan<- Output) bool {
+		bases = append(bases, inTS)
+		// the sibling arrives while the block is generated
+		head.set(wider)
+		outCh <- Output{NewBlock: inTS.ToSlice()[0]}
+		return true
+	}
+	worker := NewTestWorkerWithDeps(winningMine)
+	scheduler := NewScheduler(worker, MineDelayTest, head.get)
+	outCh, _ := scheduler.Start(ctx)
+
+	<-outCh
+	require.Len(bases, 2)
+	assert.Equal(ts1, bases[0])
+	assert.Equal(wider, bases[1])
+	assert.Equal([]cid.Cid{ts1.ToSlice()[0].Cid()}, worker.Superseded)
+}
+
+func TestSchedulerAbandonsStaleBase(t *testing.T) {
+	assert, require, ts1 := newTestUtils(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	blk2 := &types.Block{StateRoot: types.SomeCid(), Height: 1}
+	ts2 := th.RequireNewTipSet(require, blk2)
+
+	head := &syncHead{ts: ts1}
+	staleMine := func(c context.Context, inTS types.TipSet, nBC int, outCh chan<- Output) bool {
+		if inTS.Equals(ts1) {
+			head.set(ts2)
+			<-c.Done()
+			// outputs of abandoned runs are not published
+			outCh <- Output{NewBlock: ts1.ToSlice()[0]}
+			return true
+		}
+		outCh <- Output{NewBlock: blk2}
+		return false
+	}
+	worker := NewTestWorkerWithDeps(staleMine)
+	scheduler := NewScheduler(worker, MineDelayTest, head.get)
+	outCh, _ := scheduler.Start(ctx)
+
+	out := <-outCh
+	assert.Equal(blk2, out.NewBlock)
+	assert.Equal([]cid.Cid{ts1.ToSlice()[0].Cid()}, worker.Superseded)
+}
+
+func TestWidens(t *testing.T) {
+	assert, require, ts1 := newTestUtils(t)
+	blk1 := ts1.ToSlice()[0]
+	sibling := &types.Block{StateRoot: types.SomeCid()}
+	other := &types.Block{StateRoot: types.SomeCid()}
+	wider := th.RequireNewTipSet(require, blk1, sibling)
+	fork := th.RequireNewTipSet(require, sibling, other)
+	higher := th.RequireNewTipSet(require, &types.Block{StateRoot: types.SomeCid(), Height: 1})
+
+	assert.True(widens(ts1, wider))
+	assert.False(widens(ts1, ts1))
+	assert.False(widens(wider, ts1))
+	assert.False(widens(ts1, fork))
+	assert.False(widens(ts1, higher))
+	assert.False(widens(ts1, nil))
+
+	assert.True(supersedes(ts1, higher))
+	assert.False(supersedes(ts1, wider))
+	assert.False(supersedes(higher, ts1))
+	assert.False(supersedes(ts1, nil))
+}
@@ -5,6 +5,7 @@ import (
 	"sync"
 	"time"
 
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
 	"gx/ipfs/QmS2aqUZLJp8kF1ihE5rvDGE5LvmKDPnx32w9Z1BW9xLV5/go-ipfs-blockstore"
 
 	"github.com/filecoin-project/go-filecoin/address"
@@ -42,6 +43,8 @@ func (s *MockScheduler) IsStarted() bool {
 // easy testing.
 type TestWorker struct {
 	WorkFunc func(context.Context, types.TipSet, int, chan<- Output) bool
+	// Superseded are the blocks the scheduler told the worker it dropped.
+	Superseded []cid.Cid
 }
 
 // Mine is the TestWorker's Work function.  It simply calls the WorkFunc
@@ -53,6 +56,11 @@ func (w *TestWorker) Mine(ctx context.Context, ts types.TipSet, nullBlkCount int
 	return w.WorkFunc(ctx, ts, nullBlkCount, outCh)
 }
 
+// Supersede records that the scheduler dropped block.
+func (w *TestWorker) Supersede(block cid.Cid) {
+	w.Superseded = append(w.Superseded, block)
+}
+
 // NewTestWorkerWithDeps creates a worker that calls the provided input
 // function when Mine() is called.
 func NewTestWorkerWithDeps(f func(context.Context, types.TipSet, int, chan<- Output) bool) *TestWorker {
@@ -12,10 +12,12 @@ import (
 	"github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/core"
 	"github.com/filecoin-project/go-filecoin/proofs"
+	"github.com/filecoin-project/go-filecoin/proofs/sectorbuilder"
 	"github.com/filecoin-project/go-filecoin/state"
 	"github.com/filecoin-project/go-filecoin/types"
 	"github.com/filecoin-project/go-filecoin/vm"
 
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
 	"gx/ipfs/QmRXf2uUSdGSunRJsM9wXSUNVwLUGCY3So5fAs7h2CBJVf/go-hamt-ipld"
 	"gx/ipfs/QmS2aqUZLJp8kF1ihE5rvDGE5LvmKDPnx32w9Z1BW9xLV5/go-ipfs-blockstore"
 	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
@@ -67,7 +69,7 @@ type MessageApplier interface {
 
 // DefaultWorker runs a mining job.
 type DefaultWorker struct {
-	createPoST DoSomeWorkFunc  // TODO: rename createPoSTFunc
+	createPoST CreatePoSTFunc
 	minerAddr  address.Address // TODO: needs to be a key in the near future
 
 	// consensus things
@@ -82,17 +84,21 @@ type DefaultWorker struct {
 	blockstore  blockstore.Blockstore
 	cstore      *hamt.CborIpldStore
 	blockTime   time.Duration
+
+	// history records the outcome of the rounds, if set.
+	history *History
 }
 
-// NewDefaultWorker instantiates a new Worker.
+// NewDefaultWorker instantiates a new Worker that fakes its proofs of
+// spacetime.
 func NewDefaultWorker(messagePool *core.MessagePool, getStateTree GetStateTree, getWeight GetWeight, getAncestors GetAncestors, processor MessageApplier, powerTable consensus.PowerTableView, bs blockstore.Blockstore, cst *hamt.CborIpldStore, miner address.Address, bt time.Duration) *DefaultWorker {
-	w := NewDefaultWorkerWithDeps(messagePool, getStateTree, getWeight, getAncestors, processor, powerTable, bs, cst, miner, bt, func() {})
+	w := NewDefaultWorkerWithDeps(messagePool, getStateTree, getWeight, getAncestors, processor, powerTable, bs, cst, miner, bt, nil)
 	w.createPoST = w.fakeCreatePoST
 	return w
 }
 
 // NewDefaultWorkerWithDeps instantiates a new Worker with custom functions.
-func NewDefaultWorkerWithDeps(messagePool *core.MessagePool, getStateTree GetStateTree, getWeight GetWeight, getAncestors GetAncestors, processor MessageApplier, powerTable consensus.PowerTableView, bs blockstore.Blockstore, cst *hamt.CborIpldStore, miner address.Address, bt time.Duration, createPoST DoSomeWorkFunc) *DefaultWorker {
+func NewDefaultWorkerWithDeps(messagePool *core.MessagePool, getStateTree GetStateTree, getWeight GetWeight, getAncestors GetAncestors, processor MessageApplier, powerTable consensus.PowerTableView, bs blockstore.Blockstore, cst *hamt.CborIpldStore, miner address.Address, bt time.Duration, createPoST CreatePoSTFunc) *DefaultWorker {
 	return &DefaultWorker{
 		getStateTree: getStateTree,
 		getWeight:    getWeight,
@@ -108,10 +114,56 @@ func NewDefaultWorkerWithDeps(messagePool *core.MessagePool, getStateTree GetSta
 	}
 }
 
-// DoSomeWorkFunc is a dummy function that mimics doing something time-consuming
-// in the mining loop such as computing proofs. Pass a function that calls Sleep()
-// is a good idea for now.
-type DoSomeWorkFunc func()
+// RecordRounds makes the worker record the outcome of each of its rounds in h.
+func (w *DefaultWorker) RecordRounds(h *History) {
+	w.history = h
+}
+
+// Supersede records that the scheduler dropped block, mined by the worker,
+// without publishing it.
+func (w *DefaultWorker) Supersede(block cid.Cid) {
+	if w.history != nil {
+		w.history.Supersede(block)
+	}
+}
+
+// CreatePoSTFunc generates a proof of spacetime over the replicas committed
+// as commRs for the challenge seed.
+type CreatePoSTFunc func(commRs []proofs.CommR, challengeSeed proofs.PoStChallengeSeed) (proofs.PoStProof, error)
+
+// PoSTGenerator generates proofs of spacetime over the replicas it sealed. A
+// sectorbuilder.SectorBuilder is one.
+type PoSTGenerator interface {
+	GeneratePoST(sectorbuilder.GeneratePoSTRequest) (sectorbuilder.GeneratePoSTResponse, error)
+}
+
+// NewPoSTFunc returns a CreatePoSTFunc generating the proofs of spacetime
+// with g. Creating a proof takes at least blockTime, which paces the mining
+// rounds. Blocks don't carry faults and are verified as fault-free, so a
+// proof reporting faults is an error rather than a block nobody accepts.
+func NewPoSTFunc(g PoSTGenerator, blockTime time.Duration) CreatePoSTFunc {
+	return func(commRs []proofs.CommR, challengeSeed proofs.PoStChallengeSeed) (proofs.PoStProof, error) {
+		done := time.Now().Add(blockTime)
+		defer func() { time.Sleep(time.Until(done)) }()
+
+		if len(commRs) == 0 {
+			// Miners without committed sectors, like the bootstrap miners,
+			// have nothing to prove.
+			return seedProof(challengeSeed), nil
+		}
+		res, err := g.GeneratePoST(sectorbuilder.GeneratePoSTRequest{
+			CommRs:        commRs,
+			ChallengeSeed: challengeSeed,
+		})
+		if err != nil {
+			return proofs.PoStProof{}, errors.Wrap(err, "failed to generate PoSt")
+		}
+		if len(res.Faults) > 0 {
+			return proofs.PoStProof{}, errors.Errorf("PoSt found faults in sectors %v", res.Faults)
+		}
+		return res.Proof, nil
+	}
+}
 
 // Mine implements the DefaultWorkers main mining function..
 // The returned bool indicates if this miner created a new block or not.
@@ -143,7 +195,13 @@ func (w *DefaultWorker) Mine(ctx context.Context, base types.TipSet, nullBlkCoun
 		outCh <- Output{Err: err}
 		return false
 	}
-	prCh := createProof(challenge, w.createPoST)
+	commRs, err := consensus.MinerCommRs(ctx, st, w.blockstore, w.minerAddr)
+	if err != nil {
+		log.Errorf("Worker.Mine couldn't get the sector commitments of the miner: %s", err.Error())
+		outCh <- Output{Err: err}
+		return false
+	}
+	prCh := createProof(commRs, challenge, w.createPoST)
 
 	var proof proofs.PoStProof
 	var ticket []byte
@@ -151,28 +209,47 @@ func (w *DefaultWorker) Mine(ctx context.Context, base types.TipSet, nullBlkCoun
 	case <-ctx.Done():
 		log.Infof("Mining run on base %s with %d null blocks canceled.", base.String(), nullBlkCount)
 		return false
-	case prChRead, more := <-prCh:
-		if !more {
-			log.Errorf("Worker.Mine got zero value from channel prChRead")
+	case pr := <-prCh:
+		if pr.err != nil {
+			log.Errorf("Worker.Mine couldn't create a proof of spacetime: %s", pr.err.Error())
+			outCh <- Output{Err: pr.err}
 			return false
 		}
-		copy(proof[:], prChRead[:])
+		proof = pr.proof
 		ticket = consensus.CreateTicket(proof, w.minerAddr)
 	}
 
+	round := Round{
+		Time:       time.Now(),
+		Miner:      w.minerAddr,
+		Base:       base.ToSortedCidSet(),
+		NullBlocks: nullBlkCount,
+		Ticket:     ticket,
+	}
+	if baseHeight, err := base.Height(); err == nil {
+		round.Height = baseHeight + uint64(nullBlkCount) + 1
+	}
+	defer w.record(&round)
+
 	// TODO: Test the interplay of isWinningTicket() and createPoST()
 	weHaveAWinner, err := consensus.IsWinningTicket(ctx, w.blockstore, w.powerTable, st, ticket, w.minerAddr)
 
 	if err != nil {
 		log.Errorf("Worker.Mine couldn't compute ticket: %s", err.Error())
+		round.Err = err.Error()
 		outCh <- Output{Err: err}
 		return false
 	}
 
 	if weHaveAWinner {
+		round.Won = true
 		next, err := w.Generate(ctx, base, ticket, proof, uint64(nullBlkCount))
 		if err == nil {
 			log.SetTag(ctx, "block", next)
+			blkCid := next.Cid()
+			round.Block = &blkCid
+		} else {
+			round.Err = err.Error()
 		}
 		log.Debugf("Worker.Mine generates new winning block! %s", next.Cid().String())
 		outCh <- NewOutput(next, err)
@@ -182,19 +259,40 @@ func (w *DefaultWorker) Mine(ctx context.Context, base types.TipSet, nullBlkCoun
 	return false
 }
 
-// TODO: Actually use the results of the PoST once it is implemented.
-// Currently createProof just passes the challenge seed through.
-func createProof(challengeSeed proofs.PoStChallengeSeed, createPoST DoSomeWorkFunc) <-chan proofs.PoStChallengeSeed {
-	c := make(chan proofs.PoStChallengeSeed)
+// record adds round to the history of the worker, if it keeps one.
+func (w *DefaultWorker) record(round *Round) {
+	if w.history != nil {
+		w.history.Record(*round)
+	}
+}
+
+type proofResult struct {
+	proof proofs.PoStProof
+	err   error
+}
+
+// createProof creates the proof of spacetime in the background, so that the
+// mining run can be canceled meanwhile.
+func createProof(commRs []proofs.CommR, challengeSeed proofs.PoStChallengeSeed, createPoST CreatePoSTFunc) <-chan proofResult {
+	// buffered so that the goroutine ends when the run was canceled
+	c := make(chan proofResult, 1)
 	go func() {
-		createPoST() // TODO send new PoST on channel once we can create it
-		c <- challengeSeed
+		proof, err := createPoST(commRs, challengeSeed)
+		c <- proofResult{proof: proof, err: err}
 	}()
 	return c
 }
 
-// fakeCreatePoST is the default implementation of DoSomeWorkFunc.
-// It simply sleeps for the blockTime.
-func (w *DefaultWorker) fakeCreatePoST() {
+// fakeCreatePoST is the CreatePoSTFunc of workers without a PoSTGenerator.
+// It sleeps for the blockTime and passes the challenge seed through.
+func (w *DefaultWorker) fakeCreatePoST(commRs []proofs.CommR, challengeSeed proofs.PoStChallengeSeed) (proofs.PoStProof, error) {
 	time.Sleep(w.blockTime)
+	return seedProof(challengeSeed), nil
+}
+
+// seedProof returns the challenge seed as a proof.
+func seedProof(challengeSeed proofs.PoStChallengeSeed) proofs.PoStProof {
+	var proof proofs.PoStProof
+	copy(proof[:], challengeSeed[:])
+	return proof
 }
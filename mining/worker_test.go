@@ -5,14 +5,17 @@ import (
 	"errors"
 	"github.com/filecoin-project/go-filecoin/proofs"
 	"testing"
+	"time"
 
 	"github.com/filecoin-project/go-filecoin/actor"
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/core"
+	"github.com/filecoin-project/go-filecoin/proofs/sectorbuilder"
 	"github.com/filecoin-project/go-filecoin/state"
 	th "github.com/filecoin-project/go-filecoin/testhelpers"
 	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -45,7 +48,10 @@ func Test_Mine(t *testing.T) {
 
 	outCh := make(chan Output)
 	doSomeWorkCalled := false
-	worker.createPoST = func() { doSomeWorkCalled = true }
+	worker.createPoST = func([]proofs.CommR, proofs.PoStChallengeSeed) (proofs.PoStProof, error) {
+		doSomeWorkCalled = true
+		return proofs.PoStProof{}, nil
+	}
 	go worker.Mine(ctx, tipSet, 0, outCh)
 	r := <-outCh
 	assert.NoError(r.Err)
@@ -56,7 +62,10 @@ func Test_Mine(t *testing.T) {
 	worker = NewDefaultWorker(pool, makeExplodingGetStateTree(st), getWeightTest, getAncestors, th.NewTestProcessor(), NewTestPowerTableView(1), bs, cst, addrs[3], th.BlockTimeTest)
 	outCh = make(chan Output)
 	doSomeWorkCalled = false
-	worker.createPoST = func() { doSomeWorkCalled = true }
+	worker.createPoST = func([]proofs.CommR, proofs.PoStChallengeSeed) (proofs.PoStProof, error) {
+		doSomeWorkCalled = true
+		return proofs.PoStProof{}, nil
+	}
 	go worker.Mine(ctx, tipSet, 0, outCh)
 	r = <-outCh
 	assert.Error(r.Err)
@@ -68,7 +77,10 @@ func Test_Mine(t *testing.T) {
 	worker = NewDefaultWorker(pool, getStateTree, getWeightTest, getAncestors, th.NewTestProcessor(), NewTestPowerTableView(1), bs, cst, addrs[3], th.BlockTimeTest)
 	outCh = make(chan Output)
 	doSomeWorkCalled = false
-	worker.createPoST = func() { doSomeWorkCalled = true }
+	worker.createPoST = func([]proofs.CommR, proofs.PoStChallengeSeed) (proofs.PoStProof, error) {
+		doSomeWorkCalled = true
+		return proofs.PoStProof{}, nil
+	}
 	input := types.TipSet{}
 	go worker.Mine(ctx, input, 0, outCh)
 	r = <-outCh
@@ -77,6 +89,145 @@ func Test_Mine(t *testing.T) {
 	cancel()
 }
 
+func TestMineUsesTheProofOfSpacetime(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	baseBlock := &types.Block{Height: 2, StateRoot: types.SomeCid()}
+	tipSet := th.RequireNewTipSet(require, baseBlock)
+
+	st, pool, addrs, cst, bs := sharedSetup(t)
+	getStateTree := func(c context.Context, ts types.TipSet) (state.Tree, error) {
+		return st, nil
+	}
+	getAncestors := func(ctx context.Context, ts types.TipSet, newBlockHeight *types.BlockHeight) ([]types.TipSet, error) {
+		return nil, nil
+	}
+
+	challenge, err := consensus.CreateChallengeSeed(tipSet, 0)
+	require.NoError(err)
+	proof := th.MakeRandomPoSTProofForTest()
+	createPoST := func(commRs []proofs.CommR, challengeSeed proofs.PoStChallengeSeed) (proofs.PoStProof, error) {
+		assert.Empty(commRs) // the miner has not committed sectors
+		assert.Equal(challenge, challengeSeed)
+		return proof, nil
+	}
+	worker := NewDefaultWorkerWithDeps(pool, getStateTree, getWeightTest, getAncestors, th.NewTestProcessor(), NewTestPowerTableView(1), bs, cst, addrs[3], th.BlockTimeTest, createPoST)
+
+	outCh := make(chan Output, 1)
+	require.True(worker.Mine(context.Background(), tipSet, 0, outCh))
+	r := <-outCh
+	require.NoError(r.Err)
+	assert.Equal(proof, r.NewBlock.Proof)
+	assert.Equal(types.Signature(consensus.CreateTicket(proof, addrs[3])), r.NewBlock.Ticket)
+
+	// failing to create a proof fails the run
+	worker.createPoST = func([]proofs.CommR, proofs.PoStChallengeSeed) (proofs.PoStProof, error) {
+		return proofs.PoStProof{}, errors.New("no proof")
+	}
+	assert.False(worker.Mine(context.Background(), tipSet, 0, outCh))
+	r = <-outCh
+	assert.EqualError(r.Err, "no proof")
+}
+
+type testPoSTGenerator struct {
+	req    sectorbuilder.GeneratePoSTRequest
+	proof  proofs.PoStProof
+	faults []uint64
+	err    error
+}
+
+func (g *testPoSTGenerator) GeneratePoST(req sectorbuilder.GeneratePoSTRequest) (sectorbuilder.GeneratePoSTResponse, error) {
+	g.req = req
+	return sectorbuilder.GeneratePoSTResponse{Proof: g.proof, Faults: g.faults}, g.err
+}
+
+func TestNewPoSTFunc(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	g := &testPoSTGenerator{proof: th.MakeRandomPoSTProofForTest()}
+	createPoST := NewPoSTFunc(g, 10*time.Millisecond)
+	commRs := []proofs.CommR{{1}, {2}}
+	challengeSeed := proofs.PoStChallengeSeed{3}
+
+	start := time.Now()
+	proof, err := createPoST(commRs, challengeSeed)
+	require.NoError(err)
+	assert.Equal(g.proof, proof)
+	assert.Equal(commRs, g.req.CommRs)
+	assert.Equal(challengeSeed, g.req.ChallengeSeed)
+	assert.True(time.Since(start) >= 10*time.Millisecond)
+
+	// without commitments there is nothing to prove
+	g.req = sectorbuilder.GeneratePoSTRequest{}
+	proof, err = createPoST(nil, challengeSeed)
+	require.NoError(err)
+	assert.Equal(proofs.PoStProof{3}, proof)
+	assert.Nil(g.req.CommRs)
+
+	// blocks can't carry faults
+	g.faults = []uint64{2}
+	_, err = createPoST(commRs, challengeSeed)
+	assert.EqualError(err, "PoSt found faults in sectors [2]")
+
+	g.faults = nil
+	g.err = errors.New("boom")
+	_, err = createPoST(commRs, challengeSeed)
+	assert.Error(err)
+}
+
+func TestMineRecordsRounds(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	newCid := types.NewCidForTestGetter()
+	baseBlock := &types.Block{Height: 2, StateRoot: newCid()}
+	tipSet := th.RequireNewTipSet(require, baseBlock)
+
+	st, pool, addrs, cst, bs := sharedSetup(t)
+	getStateTree := func(c context.Context, ts types.TipSet) (state.Tree, error) {
+		return st, nil
+	}
+	getAncestors := func(ctx context.Context, ts types.TipSet, newBlockHeight *types.BlockHeight) ([]types.TipSet, error) {
+		return nil, nil
+	}
+
+	worker := NewDefaultWorker(pool, getStateTree, getWeightTest, getAncestors, th.NewTestProcessor(), NewTestPowerTableView(1), bs, cst, addrs[3], th.BlockTimeTest)
+	worker.createPoST = func([]proofs.CommR, proofs.PoStChallengeSeed) (proofs.PoStProof, error) {
+		return proofs.PoStProof{}, nil
+	}
+	history := NewHistory(1)
+	worker.RecordRounds(history)
+
+	outCh := make(chan Output, 1)
+	require.True(worker.Mine(context.Background(), tipSet, 1, outCh))
+	r := <-outCh
+	require.NoError(r.Err)
+
+	rounds := history.Rounds()
+	require.Len(rounds, 1)
+	assert.True(rounds[0].Won)
+	assert.Equal(addrs[3], rounds[0].Miner)
+	assert.Equal(tipSet.ToSortedCidSet(), rounds[0].Base)
+	assert.Equal(uint64(4), rounds[0].Height)
+	assert.Equal(1, rounds[0].NullBlocks)
+	assert.Equal(r.NewBlock.Ticket, rounds[0].Ticket)
+	require.NotNil(rounds[0].Block)
+	assert.Equal(r.NewBlock.Cid(), *rounds[0].Block)
+	assert.False(rounds[0].Superseded)
+
+	// The scheduler dropped the block.
+	worker.Supersede(r.NewBlock.Cid())
+	assert.True(history.Rounds()[0].Superseded)
+
+	// The history only keeps the last rounds.
+	require.True(worker.Mine(context.Background(), tipSet, 2, outCh))
+	<-outCh
+	rounds = history.Rounds()
+	require.Len(rounds, 1)
+	assert.Equal(2, rounds[0].NullBlocks)
+	assert.False(rounds[0].Superseded)
+}
+
 var seed = types.GenerateKeyInfoSeed()
 var ki = types.MustGenerateKeyInfo(10, seed)
 var mockSigner = types.NewMockSigner(ki)
@@ -98,9 +249,10 @@ func sharedSetupInitial() (*hamt.CborIpldStore, *core.MessagePool, cid.Cid) {
 func sharedSetup(t *testing.T) (state.Tree, *core.MessagePool, []address.Address, *hamt.CborIpldStore, blockstore.Blockstore) {
 	require := require.New(t)
 	cst, pool, fakeActorCodeCid := sharedSetupInitial()
-	vms := th.VMStorage()
 	d := datastore.NewMapDatastore()
 	bs := blockstore.NewBlockstore(d)
+	// the worker reads the miner actor through bs
+	vms := vm.NewStorageMap(bs)
 
 	// TODO: We don't need fake actors here, so these could be made real.
 	//       And the NetworkAddress actor can/should be the real one.
@@ -254,10 +406,10 @@ func TestGeneratePoolBlockResults(t *testing.T) {
 	smsg4, err := types.NewSignedMessage(*msg4, &mockSigner, types.NewGasPrice(0), types.NewGasUnits(0))
 	require.NoError(err)
 
-	pool.Add(smsg1)
-	pool.Add(smsg2)
-	pool.Add(smsg3)
-	pool.Add(smsg4)
+	pool.Add(context.Background(), smsg1)
+	pool.Add(context.Background(), smsg2)
+	pool.Add(context.Background(), smsg3)
+	pool.Add(context.Background(), smsg4)
 
 	assert.Len(pool.Pending(), 4)
 	baseBlock := types.Block{
@@ -366,7 +518,7 @@ func TestGenerateError(t *testing.T) {
 	msg := types.NewMessage(addrs[0], addrs[1], 0, nil, "", nil)
 	smsg, err := types.NewSignedMessage(*msg, &mockSigner, types.NewGasPrice(0), types.NewGasUnits(0))
 	require.NoError(err)
-	pool.Add(smsg)
+	pool.Add(context.Background(), smsg)
 
 	assert.Len(pool.Pending(), 1)
 	baseBlock := types.Block{
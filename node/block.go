@@ -24,7 +24,7 @@ func (node *Node) AddNewBlock(ctx context.Context, b *types.Block) (err error) {
 	}
 
 	log.Debugf("syncing new block: %s", b.Cid().String())
-	if err := node.Syncer.HandleNewBlocks(ctx, []cid.Cid{blkCid}); err != nil {
+	if err := node.Syncer.HandleNewBlocks(ctx, "", []cid.Cid{blkCid}); err != nil {
 		return err
 	}
 
@@ -47,7 +47,7 @@ func (node *Node) processBlock(ctx context.Context, pubSubMsg *pubsub.Message) (
 	log.Infof("Received new block from network cid: %s", blk.Cid().String())
 	log.Debugf("Received new block from network: %s", blk)
 
-	err = node.Syncer.HandleNewBlocks(ctx, []cid.Cid{blk.Cid()})
+	err = node.Syncer.HandleNewBlocks(ctx, pubSubMsg.GetFrom(), []cid.Cid{blk.Cid()})
 	if err != nil {
 		return errors.Wrap(err, "processing block from network")
 	}
@@ -13,6 +13,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/protocol/storage"
 	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -131,7 +132,7 @@ func TestChainSync(t *testing.T) {
 
 type zeroRewarder struct{}
 
-func (r *zeroRewarder) BlockReward(ctx context.Context, st state.Tree, minerAddr address.Address) error {
+func (r *zeroRewarder) BlockReward(ctx context.Context, st state.Tree, vms vm.StorageMap, minerAddr address.Address, bh *types.BlockHeight) error {
 	return nil
 }
 
@@ -2,6 +2,7 @@ package node
 
 import (
 	"context"
+	"io"
 
 	ci "gx/ipfs/QmNiJiXwWE3kRhZrC5ej3kSjWHm337pYfhjLGSCDNKJP2s/go-libp2p-crypto"
 	"gx/ipfs/QmRXf2uUSdGSunRJsM9wXSUNVwLUGCY3So5fAs7h2CBJVf/go-hamt-ipld"
@@ -14,6 +15,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/chain"
 	"github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/repo"
+	"github.com/filecoin-project/go-filecoin/types"
 	"github.com/filecoin-project/go-filecoin/wallet"
 )
 
@@ -44,7 +46,7 @@ func DefaultWalletAddressOpt(addr address.Address) InitOpt {
 	}
 }
 
-// AutoSealIntervalSecondsOpt configures the daemon to check for and seal any staged sectors on an interval.
+// AutoSealIntervalSecondsOpt configures the daemon to seal staged sectors once they have waited that many seconds for pieces.
 func AutoSealIntervalSecondsOpt(autoSealIntervalSeconds uint) InitOpt {
 	return func(c *InitCfg) {
 		c.AutoSealIntervalSeconds = autoSealIntervalSeconds
@@ -104,6 +106,30 @@ func Init(ctx context.Context, r repo.Repo, gen consensus.GenesisInitFunc, opts
 	return nil
 }
 
+// ImportChain loads a chain snapshot written by chain export into the given
+// repo, which must have been initialized with the snapshot's genesis block.
+// It returns the new head of the repo's chain.
+func ImportChain(ctx context.Context, r repo.Repo, snapshot io.Reader) (types.TipSet, error) {
+	bs := bstore.NewBlockstore(r.Datastore())
+	cst := &hamt.CborIpldStore{Blocks: bserv.New(bs, offline.Exchange(bs))}
+	genCid, err := readGenesisCid(r.Datastore())
+	if err != nil {
+		return nil, err
+	}
+
+	chainStore := chain.NewDefaultStore(r.ChainDatastore(), cst, genCid)
+	defer chainStore.Stop()
+	if err := chainStore.Load(ctx); err != nil {
+		return nil, errors.Wrap(err, "failed to load chain store")
+	}
+
+	head, err := chain.Import(ctx, chainStore, bs, snapshot)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to import chain")
+	}
+	return head, nil
+}
+
 // makePrivateKey generates a new private key, which is the basis for a libp2p identity.
 // borrowed from go-ipfs: `repo/config/init.go`
 func makePrivateKey(nbits int) (ci.PrivKey, error) {
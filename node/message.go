@@ -21,6 +21,10 @@ func (node *Node) processMessage(ctx context.Context, pubSubMsg *pubsub.Message)
 
 	log.Debugf("Received new message from network: %s", unmarshaled)
 
-	_, err = node.MsgPool.Add(unmarshaled)
-	return err
+	// Messages rejected by the pool's admission policy are expected from the
+	// network and are not an error of the node.
+	if _, err := node.MsgPool.Add(ctx, unmarshaled); err != nil {
+		log.Infof("rejected message from network: %s", err)
+	}
+	return nil
 }
@@ -0,0 +1,440 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+	bserv "gx/ipfs/QmYPZzd9VqmJDwxUnThfeSbV1Y5o53aVPDijTB7j7rS9Ep/go-blockservice"
+	"gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore"
+	"gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore/namespace"
+
+	"github.com/filecoin-project/go-filecoin/actor/builtin"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/filecoin-project/go-filecoin/mining"
+	"github.com/filecoin-project/go-filecoin/proofs"
+	"github.com/filecoin-project/go-filecoin/proofs/sectorbuilder"
+	"github.com/filecoin-project/go-filecoin/protocol/storage"
+	"github.com/filecoin-project/go-filecoin/repo"
+	"github.com/filecoin-project/go-filecoin/state"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// nodeMiner is a miner actor of the node. Each miner has its own sector
+// builder, storage miner and mining scheduler, and keeps its deals, sectors
+// and commitments apart from those of the other miners.
+type nodeMiner struct {
+	node *Node
+	addr address.Address
+
+	sectorBuilder sectorbuilder.SectorBuilder
+	sealer        *sectorbuilder.SealingScheduler
+	storageMiner  *storage.Miner
+	scheduler     mining.Scheduler
+
+	lk           sync.Mutex
+	isMining     bool
+	miningCtx    context.Context
+	cancelMining context.CancelFunc
+	miningDoneWg *sync.WaitGroup
+}
+
+// The storage miner of a nodeMiner uses the node through it, to get the
+// sector builder of the miner.
+
+func (m *nodeMiner) BlockHeight() (*types.BlockHeight, error) {
+	return m.node.BlockHeight()
+}
+
+func (m *nodeMiner) BlockService() bserv.BlockService {
+	return m.node.BlockService()
+}
+
+func (m *nodeMiner) GetBlockTime() time.Duration {
+	return m.node.GetBlockTime()
+}
+
+func (m *nodeMiner) SectorBuilder() sectorbuilder.SectorBuilder {
+	return m.sectorBuilder
+}
+
+func (m *nodeMiner) setIsMining(isMining bool) {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	m.isMining = isMining
+}
+
+// setStorageMiner sets the storage miner of m and returns the previous one.
+func (m *nodeMiner) setStorageMiner(sm *storage.Miner) *storage.Miner {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	prev := m.storageMiner
+	m.storageMiner = sm
+	return prev
+}
+
+// getStorageMiner returns the storage miner of m, nil when it isn't mining.
+func (m *nodeMiner) getStorageMiner() *storage.Miner {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	return m.storageMiner
+}
+
+func (m *nodeMiner) miningStarted() bool {
+	m.lk.Lock()
+	defer m.lk.Unlock()
+	return m.isMining
+}
+
+// MiningAddresses returns the addresses of the miners of the node, the
+// default miner first.
+func (node *Node) MiningAddresses() []address.Address {
+	cfg := node.Repo.Config().Mining
+
+	var addrs []address.Address
+	if cfg.MinerAddress != (address.Address{}) {
+		addrs = append(addrs, cfg.MinerAddress)
+	}
+	for _, addr := range cfg.MinerAddresses {
+		if addr != cfg.MinerAddress {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+func (node *Node) isMinerAddress(addr address.Address) bool {
+	for _, a := range node.MiningAddresses() {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// isDefaultMiner returns true if addr is the default miner of the node, whose
+// data is stored where it was before nodes could have several miners.
+func (node *Node) isDefaultMiner(addr address.Address) bool {
+	return addr == node.Repo.Config().Mining.MinerAddress
+}
+
+// miner returns the miner of the node with address addr, initializing its
+// sector builder the first time.
+func (node *Node) miner(ctx context.Context, addr address.Address) (*nodeMiner, error) {
+	node.mining.Lock()
+	defer node.mining.Unlock()
+
+	if m, ok := node.mining.miners[addr]; ok {
+		return m, nil
+	}
+	if !node.isMinerAddress(addr) {
+		return nil, fmt.Errorf("%s is not a miner of the node", addr)
+	}
+
+	// configure the underlying sector store, defaulting to the non-test version
+	sectorStoreType := proofs.Live
+	if os.Getenv("FIL_USE_SMALL_SECTORS") == "true" {
+		sectorStoreType = proofs.Test
+	}
+
+	sectorBuilder, err := initSectorBuilderForMiner(ctx, node, addr, sectorStoreType)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to initialize sector builder for miner %s", addr)
+	}
+
+	sealer, err := node.newSealingScheduler(addr, sectorBuilder)
+	if err != nil {
+		sectorBuilder.Close() // nolint: errcheck
+		return nil, errors.Wrapf(err, "failed to initialize sealing scheduler for miner %s", addr)
+	}
+
+	m := &nodeMiner{
+		node:          node,
+		addr:          addr,
+		sectorBuilder: sealer,
+		sealer:        sealer,
+	}
+	node.mining.miners[addr] = m
+	return m, nil
+}
+
+// newSealingScheduler returns a scheduler sealing the staged sectors of sb,
+// the sector builder of the miner with address addr, as configured.
+func (node *Node) newSealingScheduler(addr address.Address, sb sectorbuilder.SectorBuilder) (*sectorbuilder.SealingScheduler, error) {
+	cfg := node.Repo.Config().Mining
+
+	policy, err := sectorbuilder.ParseSealPolicy(cfg.SealPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	// the sectors that were staged when the node stopped
+	sectors, err := storage.NewSectorStore(node.MinerDatastore(addr)).List()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list sectors")
+	}
+	var staged []uint64
+	for _, sector := range sectors {
+		if sector.State == storage.SectorStaged {
+			staged = append(staged, sector.SectorID)
+		}
+	}
+
+	return sectorbuilder.NewSealingScheduler(sb, sectorbuilder.SealingSchedulerConfig{
+		Policy:       policy,
+		Timeout:      time.Duration(cfg.AutoSealIntervalSeconds) * time.Second,
+		UrgentWindow: time.Duration(cfg.SealUrgencySeconds) * time.Second,
+	}, staged), nil
+}
+
+// SealingStats returns the numbers and the queue of the sealing scheduler of
+// the miner with address addr.
+func (node *Node) SealingStats(ctx context.Context, addr address.Address) (sectorbuilder.SealingStats, []sectorbuilder.QueuedSector, error) {
+	m, err := node.miner(ctx, addr)
+	if err != nil {
+		return sectorbuilder.SealingStats{}, nil, err
+	}
+	return m.sealer.Stats(), m.sealer.Queue(), nil
+}
+
+// miners returns the miners of the node that have been set up.
+func (node *Node) miners() []*nodeMiner {
+	node.mining.Lock()
+	defer node.mining.Unlock()
+
+	var miners []*nodeMiner
+	for _, addr := range node.MiningAddresses() {
+		if m, ok := node.mining.miners[addr]; ok {
+			miners = append(miners, m)
+		}
+	}
+	return miners
+}
+
+// MinerDatastore returns the datastore holding the deals, sectors and
+// commitments of the miner with address addr.
+func (node *Node) MinerDatastore(addr address.Address) repo.Datastore {
+	if node.isDefaultMiner(addr) {
+		return node.Repo.DealsDatastore()
+	}
+	return namespace.Wrap(node.Repo.DealsDatastore(), datastore.NewKey(addr.String()))
+}
+
+// minerDir returns the directory under dir where the miner with address addr
+// keeps its sectors, creating it if needed.
+func (node *Node) minerDir(dir string, addr address.Address) (string, error) {
+	if node.isDefaultMiner(addr) {
+		return dir, nil
+	}
+	dir = filepath.Join(dir, addr.String())
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// StartMiner starts mining and storing data for the miner with address addr.
+func (node *Node) StartMiner(ctx context.Context, addr address.Address) error {
+	m, err := node.miner(ctx, addr)
+	if err != nil {
+		return err
+	}
+	if m.miningStarted() {
+		return fmt.Errorf("Node is already mining for %s", addr)
+	}
+
+	minerOwnerAddr, err := node.MiningOwnerAddress(ctx, addr)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get mining owner address for miner %s", addr)
+	}
+
+	blockTime, mineDelay := node.MiningTimes()
+
+	if m.scheduler == nil {
+		getStateFromKey := func(ctx context.Context, tsKey string) (state.Tree, error) {
+			tsas, err := node.ChainReader.GetTipSetAndState(ctx, tsKey)
+			if err != nil {
+				return nil, err
+			}
+			return state.LoadStateTree(ctx, node.CborStore(), tsas.TipSetStateRoot, builtin.Actors)
+		}
+		getState := func(ctx context.Context, ts types.TipSet) (state.Tree, error) {
+			return getStateFromKey(ctx, ts.String())
+		}
+		getWeight := func(ctx context.Context, ts types.TipSet) (uint64, error) {
+			parent, err := ts.Parents()
+			if err != nil {
+				return uint64(0), err
+			}
+			// TODO handle genesis cid more gracefully
+			if parent.Len() == 0 {
+				return node.Consensus.Weight(ctx, ts, nil)
+			}
+			pSt, err := getStateFromKey(ctx, parent.String())
+			if err != nil {
+				return uint64(0), err
+			}
+			return node.Consensus.Weight(ctx, ts, pSt)
+		}
+		getAncestors := func(ctx context.Context, ts types.TipSet, newBlockHeight *types.BlockHeight) ([]types.TipSet, error) {
+			return chain.GetRecentAncestors(ctx, ts, node.ChainReader, newBlockHeight, consensus.AncestorRoundsNeeded, consensus.LookBackParameter)
+		}
+		processor := consensus.NewDefaultProcessor()
+		createPoST := mining.NewPoSTFunc(m.sectorBuilder, blockTime)
+		worker := mining.NewDefaultWorkerWithDeps(node.MsgPool, getState, getWeight, getAncestors, processor, node.PowerTable, node.Blockstore, node.CborStore(), addr, blockTime, createPoST)
+		worker.RecordRounds(node.MiningHistory)
+		m.scheduler = mining.NewScheduler(worker, mineDelay, node.ChainReader.Head)
+	}
+
+	// the storage miner, its commitments and its sealing loop run until
+	// mining stops
+	miningCtx, cancelMining := context.WithCancel(context.Background())
+
+	// initialize a storage miner
+	storageMiner, err := storage.NewMiner(ctx, addr, minerOwnerAddr, m, node.MinerDatastore(addr), node.PorcelainAPI)
+	if err != nil {
+		cancelMining()
+		return errors.Wrap(err, "failed to initialize storage miner")
+	}
+
+	// commits sealed sectors, resuming the commitments of a previous run, and
+	// reports them to the storage miner once they are included in the chain
+	committer := storage.NewCommitter(addr, minerOwnerAddr, node.MinerDatastore(addr), node.PorcelainAPI, blockTime, storageMiner)
+	if err := committer.Start(miningCtx); err != nil {
+		cancelMining()
+		return errors.Wrap(err, "failed to resume sector commitments")
+	}
+
+	m.setStorageMiner(storageMiner)
+	node.StorageMiners.Add(storageMiner)
+	m.sealer.OnSealing(storageMiner.OnSectorSealing)
+	m.sealer.OnRequeued(storageMiner.OnSectorSealRequeued)
+
+	// loop, turning sealing-results into commitSector messages to be included
+	// in the chain
+	go func() {
+		for {
+			select {
+			case result := <-m.sectorBuilder.SectorSealResults():
+				storageMiner.OnSectorSealed(result.SectorID, result.SealingErr)
+				if result.SealingErr != nil {
+					log.Errorf("failed to seal sector with id %d: %s", result.SectorID, result.SealingErr.Error())
+				} else if result.SealingResult != nil {
+					if err := committer.Commit(miningCtx, result.SealingResult); err != nil {
+						log.Errorf("failed to commit sector with id %d: %s", result.SectorID, err)
+					}
+				}
+			case <-miningCtx.Done():
+				return
+			}
+		}
+	}()
+
+	// The scheduler is started last so that it never runs for a miner that
+	// failed to start.
+	m.miningCtx, m.cancelMining = miningCtx, cancelMining
+	// paranoid check
+	if !m.scheduler.IsStarted() {
+		outCh, doneWg := m.scheduler.Start(m.miningCtx)
+
+		m.miningDoneWg = doneWg
+		node.AddNewlyMinedBlock = node.addNewlyMinedBlock
+		m.miningDoneWg.Add(1)
+		go node.handleNewMiningOutput(m, outCh)
+	}
+
+	m.setIsMining(true)
+
+	return nil
+}
+
+// StopMiner stops mining for the miner with address addr.
+func (node *Node) StopMiner(ctx context.Context, addr address.Address) error {
+	node.mining.Lock()
+	m, ok := node.mining.miners[addr]
+	node.mining.Unlock()
+	if !ok {
+		if !node.isMinerAddress(addr) {
+			return fmt.Errorf("%s is not a miner of the node", addr)
+		}
+		return nil
+	}
+
+	m.setIsMining(false)
+
+	if m.cancelMining != nil {
+		m.cancelMining()
+	}
+
+	if m.miningDoneWg != nil {
+		m.miningDoneWg.Wait()
+	}
+
+	// the storage miner's commitments and sealing loop stopped with the
+	// mining context, it no longer takes deals either
+	if sm := m.setStorageMiner(nil); sm != nil {
+		m.sealer.OnSealing(nil)
+		m.sealer.OnRequeued(nil)
+		node.StorageMiners.Remove(sm)
+	}
+	return nil
+}
+
+// ReadPieceFromSealedSector returns a reader for the piece with cid pieceCid
+// from the sealed sectors of the first miner of the node that has it.
+func (node *Node) ReadPieceFromSealedSector(pieceCid cid.Cid) (io.Reader, error) {
+	err := errors.New("no miner to read the piece from")
+	for _, m := range node.miners() {
+		var reader io.Reader
+		reader, err = m.sectorBuilder.ReadPieceFromSealedSector(pieceCid)
+		if err == nil {
+			return reader, nil
+		}
+	}
+	return nil, err
+}
+
+func initSectorBuilderForMiner(ctx context.Context, node *Node, minerAddr address.Address, sectorStoreType proofs.SectorStoreType) (sectorbuilder.SectorBuilder, error) {
+	lastUsedSectorID, err := node.getLastUsedSectorID(ctx, minerAddr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get last used sector id for miner w/address %s", minerAddr.String())
+	}
+
+	stagingDir, err := node.minerDir(node.Repo.StagingDir(), minerAddr)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create staging directory")
+	}
+	sealedDir, err := node.minerDir(node.Repo.SealedDir(), minerAddr)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create sealed directory")
+	}
+
+	// TODO: Where should we store the RustSectorBuilder metadata? Currently, we
+	// configure the RustSectorBuilder to store its metadata in the staging
+	// directory.
+
+	cfg := sectorbuilder.RustSectorBuilderConfig{
+		BlockService:     node.blockservice,
+		LastUsedSectorID: lastUsedSectorID,
+		MetadataDir:      stagingDir,
+		MinerAddr:        minerAddr,
+		SealedSectorDir:  sealedDir,
+		SectorStoreType:  sectorStoreType,
+		StagedSectorDir:  stagingDir,
+	}
+
+	sb, err := sectorbuilder.NewRustSectorBuilder(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("failed to initialize sector builder for miner %s", minerAddr.String()))
+	}
+
+	return sb, nil
+}
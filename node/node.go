@@ -37,7 +37,6 @@ import (
 	"gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore"
 
 	"github.com/filecoin-project/go-filecoin/abi"
-	"github.com/filecoin-project/go-filecoin/actor/builtin"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/storagemarket"
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/chain"
@@ -54,6 +53,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/plumbing/msg"
 	"github.com/filecoin-project/go-filecoin/plumbing/mthdsig"
 	"github.com/filecoin-project/go-filecoin/plumbing/ntwk"
+	"github.com/filecoin-project/go-filecoin/plumbing/supply"
 	"github.com/filecoin-project/go-filecoin/porcelain"
 	"github.com/filecoin-project/go-filecoin/proofs"
 	"github.com/filecoin-project/go-filecoin/proofs/sectorbuilder"
@@ -61,7 +61,6 @@ import (
 	"github.com/filecoin-project/go-filecoin/protocol/retrieval"
 	"github.com/filecoin-project/go-filecoin/protocol/storage"
 	"github.com/filecoin-project/go-filecoin/repo"
-	"github.com/filecoin-project/go-filecoin/state"
 	"github.com/filecoin-project/go-filecoin/types"
 	vmErrors "github.com/filecoin-project/go-filecoin/vm/errors"
 	"github.com/filecoin-project/go-filecoin/wallet"
@@ -98,24 +97,25 @@ type Node struct {
 	// arrive async. It's called after handling a new heaviest tipset.
 	HeaviestTipSetHandled func()
 	MsgPool               *core.MessagePool
+	// MsgOutbox keeps the messages sent by the node until they are mined.
+	MsgOutbox *msg.Outbox
 
 	Wallet *wallet.Wallet
 
 	// Mining stuff.
-	MiningScheduler mining.Scheduler
-	mining          struct {
+	MiningHistory *mining.History
+	mining        struct {
 		sync.Mutex
-		isMining bool
+		// miners are the miners of the node set up so far, by address.
+		miners map[address.Address]*nodeMiner
 	}
-	miningCtx          context.Context
-	cancelMining       context.CancelFunc
-	miningDoneWg       *sync.WaitGroup
 	AddNewlyMinedBlock newBlockFunc
 	blockTime          time.Duration
 
 	// Storage Market Interfaces
 	StorageMinerClient *storage.Client
-	StorageMiner       *storage.Miner
+	// StorageMiners serves storage deals for the miners of the node.
+	StorageMiners *storage.Miners
 
 	// Retrieval Interfaces
 	RetrievalClient *retrieval.Client
@@ -136,9 +136,6 @@ type Node struct {
 	// it contains all persistent artifacts of the filecoin node
 	Repo repo.Repo
 
-	// SectorBuilder is used by the miner to fill and seal sectors.
-	sectorBuilder sectorbuilder.SectorBuilder
-
 	// Exchange is the interface for fetching data from other nodes.
 	Exchange exchange.Interface
 
@@ -368,7 +365,11 @@ func (nc *Config) Build(ctx context.Context) (*Node, error) {
 		return nil, err
 	}
 
-	var chainStore chain.Store = chain.NewDefaultStore(nc.Repo.ChainDatastore(), &cstOffline, genCid)
+	defaultStore := chain.NewDefaultStore(nc.Repo.ChainDatastore(), &cstOffline, genCid)
+	if nc.Repo.Config().Chain.IndexAddresses {
+		defaultStore.EnableAddressIndex()
+	}
+	var chainStore chain.Store = defaultStore
 	powerTable := &consensus.MarketView{}
 
 	var processor consensus.Processor
@@ -385,13 +386,19 @@ func (nc *Config) Build(ctx context.Context) (*Node, error) {
 		nodeConsensus = consensus.NewExpected(&cstOffline, bs, processor, powerTable, genCid, nc.Verifier)
 	}
 
+	badTipSets, err := chain.NewBadTipSetCache(nc.Repo.ChainDatastore(), chain.DefaultBadTipSetCacheSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load bad tipset cache")
+	}
+
 	// only the syncer gets the storage which is online connected
-	chainSyncer := chain.NewDefaultSyncer(&cstOnline, &cstOffline, nodeConsensus, chainStore)
+	chainSyncer := chain.NewDefaultSyncer(&cstOnline, &cstOffline, nodeConsensus, chainStore, badTipSets)
 	chainReader, ok := chainStore.(chain.ReadStore)
 	if !ok {
 		return nil, errors.New("failed to cast chain.Store to chain.ReadStore")
 	}
-	msgPool := core.NewMessagePool()
+	mpoolCfg := nc.Repo.Config().Mpool
+	msgPool := core.NewConfiguredMessagePool(mpoolCfg, core.NewDefaultMessagePoolValidator(mpoolCfg, chainReader.LatestState))
 
 	// Set up libp2p pubsub
 	fsub, err := pubsub.NewFloodSub(ctx, peerHost)
@@ -403,42 +410,55 @@ func (nc *Config) Build(ctx context.Context) (*Node, error) {
 		return nil, errors.Wrap(err, "failed to set up wallet backend")
 	}
 	fcWallet := wallet.New(backend)
+	msgOutbox := msg.NewOutbox(nc.Repo.Datastore(), msgPool, fsub.Publish)
+
+	miningHistory := mining.NewHistory(mining.DefaultHistorySize)
 
 	PorcelainAPI := porcelain.New(plumbing.New(&plumbing.APIDeps{
-		Chain:        chn.New(chainReader),
-		Config:       cfg.NewConfig(nc.Repo),
-		MessagePool:  msgPool,
-		MsgPreviewer: msg.NewPreviewer(fcWallet, chainReader, &cstOffline, bs),
-		MsgQueryer:   msg.NewQueryer(nc.Repo, fcWallet, chainReader, &cstOffline, bs),
-		MsgSender:    msg.NewSender(nc.Repo, fcWallet, chainReader, msgPool, fsub.Publish),
-		MsgWaiter:    msg.NewWaiter(chainReader, bs, &cstOffline),
-		Network:      ntwk.NewNetwork(peerHost),
-		SigGetter:    mthdsig.NewGetter(chainReader),
-		Wallet:       fcWallet,
+		BadTipSets:    badTipSets,
+		Blockstore:    bs,
+		Chain:         chn.New(chainReader),
+		ChainReader:   chainReader,
+		Config:        cfg.NewConfig(nc.Repo),
+		MessagePool:   msgPool,
+		MiningHistory: miningHistory,
+		MsgGasPricer:  msg.NewGasPricer(chainReader),
+		MsgPreviewer:  msg.NewPreviewer(fcWallet, chainReader, &cstOffline, bs),
+		MsgQueryer:    msg.NewQueryer(nc.Repo, fcWallet, chainReader, &cstOffline, bs),
+		MsgSender:     msg.NewSender(nc.Repo, fcWallet, chainReader, msgPool, msgOutbox),
+		MsgWaiter:     msg.NewWaiter(chainReader, bs, &cstOffline),
+		Network:       ntwk.NewNetwork(peerHost),
+		SigGetter:     mthdsig.NewGetter(chainReader),
+		Supply:        supply.NewTracker(chainReader, bs),
+		Syncer:        chainSyncer,
+		Wallet:        fcWallet,
 	}))
 
 	nd := &Node{
-		blockservice: bservice,
-		Blockstore:   bs,
-		cborStore:    &cstOffline,
-		OnlineStore:  &cstOnline,
-		Consensus:    nodeConsensus,
-		ChainReader:  chainReader,
-		Syncer:       chainSyncer,
-		PowerTable:   powerTable,
-		PorcelainAPI: PorcelainAPI,
-		Exchange:     bswap,
-		host:         peerHost,
-		MsgPool:      msgPool,
-		OfflineMode:  nc.OfflineMode,
-		PeerHost:     peerHost,
-		Ping:         pinger,
-		PubSub:       fsub,
-		Repo:         nc.Repo,
-		Wallet:       fcWallet,
-		blockTime:    nc.BlockTime,
-		Router:       router,
-	}
+		blockservice:  bservice,
+		Blockstore:    bs,
+		cborStore:     &cstOffline,
+		OnlineStore:   &cstOnline,
+		Consensus:     nodeConsensus,
+		ChainReader:   chainReader,
+		Syncer:        chainSyncer,
+		PowerTable:    powerTable,
+		PorcelainAPI:  PorcelainAPI,
+		Exchange:      bswap,
+		host:          peerHost,
+		MsgPool:       msgPool,
+		MsgOutbox:     msgOutbox,
+		MiningHistory: miningHistory,
+		OfflineMode:   nc.OfflineMode,
+		PeerHost:      peerHost,
+		Ping:          pinger,
+		PubSub:        fsub,
+		Repo:          nc.Repo,
+		Wallet:        fcWallet,
+		blockTime:     nc.BlockTime,
+		Router:        router,
+	}
+	nd.mining.miners = make(map[address.Address]*nodeMiner)
 
 	// Bootstrapping network peers.
 	periodStr := nd.Repo.Config().Bootstrap.Period
@@ -471,6 +491,15 @@ func (node *Node) Start(ctx context.Context) error {
 		return err
 	}
 
+	headHeight, err := node.ChainReader.Head().Height()
+	if err != nil {
+		return errors.Wrap(err, "failed to get head height")
+	}
+	node.MsgPool.UpdateHeight(headHeight)
+	if err := node.MsgOutbox.Load(ctx); err != nil {
+		return errors.Wrap(err, "failed to load sent messages")
+	}
+
 	// Only set these up, if there is a miner configured.
 	if _, err := node.MiningAddress(); err == nil {
 		if err := node.setupMining(ctx); err != nil {
@@ -482,9 +511,9 @@ func (node *Node) Start(ctx context.Context) error {
 	// Start up 'hello' handshake service
 	syncCallBack := func(pid libp2ppeer.ID, cids []cid.Cid, height uint64) {
 		// TODO it is possible the syncer interface should be modified to
-		// make use of the additional context not used here (from addr + height).
+		// make use of the additional context not used here (height).
 		// To keep things simple for now this info is not used.
-		err := node.Syncer.HandleNewBlocks(context.Background(), cids)
+		err := node.Syncer.HandleNewBlocks(context.Background(), pid, cids)
 		if err != nil {
 			log.Infof("error handling blocks: %s", types.NewSortedCidSet(cids...).String())
 		}
@@ -492,12 +521,13 @@ func (node *Node) Start(ctx context.Context) error {
 	node.HelloSvc = hello.New(node.Host(), node.ChainReader.GenesisCid(), syncCallBack, node.ChainReader.Head)
 
 	cni := storage.NewClientNodeImpl(dag.NewDAGService(node.BlockService()), node.Host(), node.GetBlockTime())
-	var err error
 	node.StorageMinerClient, err = storage.NewClient(cni, node.PorcelainAPI, node.Repo.DealsDatastore())
 	if err != nil {
 		return errors.Wrap(err, "Could not make new storage client")
 	}
 
+	node.StorageMiners = storage.NewMiners(node.Host())
+
 	node.RetrievalClient = retrieval.NewClient(node)
 	node.RetrievalMiner = retrieval.NewMiner(node)
 
@@ -525,6 +555,22 @@ func (node *Node) Start(ctx context.Context) error {
 	node.HeaviestTipSetCh = node.ChainReader.HeadEvents().Sub(chain.NewHeadTopic)
 	go node.handleNewHeaviestTipSet(cctx, node.ChainReader.Head())
 
+	if gcPeriod := node.Repo.Config().Chain.GCPeriod; gcPeriod != "" {
+		period, err := time.ParseDuration(gcPeriod)
+		if err != nil {
+			return errors.Wrapf(err, "couldn't parse gc period %s", gcPeriod)
+		}
+		go node.collectGarbagePeriodically(cctx, period)
+	}
+
+	if rebroadcastPeriod := node.Repo.Config().Mpool.RebroadcastPeriod; rebroadcastPeriod != "" {
+		period, err := time.ParseDuration(rebroadcastPeriod)
+		if err != nil {
+			return errors.Wrapf(err, "couldn't parse rebroadcast period %s", rebroadcastPeriod)
+		}
+		go node.rebroadcastMessagesPeriodically(cctx, period)
+	}
+
 	if !node.OfflineMode {
 		node.Bootstrapper.Start(context.Background())
 	}
@@ -541,7 +587,7 @@ func (node *Node) Start(ctx context.Context) error {
 		return addr
 	}
 	// start the primary heartbeat service
-	hbs := metrics.NewHeartbeatService(node.Host(), node.Repo.Config().Heartbeat, node.ChainReader.Head, metrics.WithMinerAddressGetter(mag))
+	hbs := metrics.NewHeartbeatService(node.Host(), node.Repo.Config().Heartbeat, node.ChainReader.Head, metrics.WithMinerAddressGetter(mag), metrics.WithSyncStatusGetter(node.Syncer.Status))
 	go hbs.Start(ctx)
 
 	// check if we want to connect to an alert service. An alerting service is a heartbeat
@@ -552,62 +598,83 @@ func (node *Node) Start(ctx context.Context) error {
 			BeatPeriod:      "10s",
 			ReconnectPeriod: "10s",
 			Nickname:        node.Repo.Config().Heartbeat.Nickname,
-		}, node.ChainReader.Head, metrics.WithMinerAddressGetter(mag))
+		}, node.ChainReader.Head, metrics.WithMinerAddressGetter(mag), metrics.WithSyncStatusGetter(node.Syncer.Status))
 		go ahbs.Start(ctx)
 	}
 	return nil
 }
 
-func (node *Node) setupMining(ctx context.Context) error {
-	// configure the underlying sector store, defaulting to the non-test version
-	sectorStoreType := proofs.Live
-	if os.Getenv("FIL_USE_SMALL_SECTORS") == "true" {
-		sectorStoreType = proofs.Test
-	}
-
-	// initialize a sector builder
-	sectorBuilder, err := initSectorBuilderForNode(ctx, node, sectorStoreType)
-	if err != nil {
-		return errors.Wrap(err, "failed to initialize sector builder")
+// collectGarbagePeriodically prunes old chain state every period, using the
+// pruning options in the node's config at that time.
+func (node *Node) collectGarbagePeriodically(ctx context.Context, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cfg := node.Repo.Config().Chain
+			_, err := node.PorcelainAPI.ChainCollectGarbage(ctx, chain.GCOptions{
+				KeepRecent:         cfg.PruneKeepRecent,
+				CheckpointInterval: cfg.PruneCheckpointInterval,
+			})
+			if err != nil {
+				log.Errorf("garbage collection failed: %s", err)
+			}
+		}
 	}
-	node.sectorBuilder = sectorBuilder
-
-	return nil
 }
 
-func (node *Node) setIsMining(isMining bool) {
-	node.mining.Lock()
-	defer node.mining.Unlock()
-	node.mining.isMining = isMining
+// rebroadcastMessagesPeriodically publishes the pending messages sent by the
+// node right away, to announce those sent before a restart, and then every
+// period.
+func (node *Node) rebroadcastMessagesPeriodically(ctx context.Context, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		if err := node.MsgOutbox.Rebroadcast(ctx); err != nil && ctx.Err() == nil {
+			log.Warningf("message rebroadcast failed: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
 }
 
-func (node *Node) isMining() bool {
-	node.mining.Lock()
-	defer node.mining.Unlock()
-	return node.mining.isMining
+// setupMining initializes the sector builders of the miners of the node.
+func (node *Node) setupMining(ctx context.Context) error {
+	for _, addr := range node.MiningAddresses() {
+		if _, err := node.miner(ctx, addr); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (node *Node) handleNewMiningOutput(miningOutCh <-chan mining.Output) {
+func (node *Node) handleNewMiningOutput(m *nodeMiner, miningOutCh <-chan mining.Output) {
 	defer func() {
-		node.miningDoneWg.Done()
+		m.miningDoneWg.Done()
 	}()
 	for {
 		select {
-		case <-node.miningCtx.Done():
+		case <-m.miningCtx.Done():
 			return
 		case output, ok := <-miningOutCh:
 			if !ok {
 				return
 			}
 			if output.Err != nil {
-				log.Errorf("problem mining a block: %s", output.Err.Error())
+				log.Errorf("problem mining a block for %s: %s", m.addr, output.Err.Error())
 			} else {
-				node.miningDoneWg.Add(1)
+				m.miningDoneWg.Add(1)
 				go func() {
-					if node.isMining() {
-						node.AddNewlyMinedBlock(node.miningCtx, output.NewBlock)
+					if m.miningStarted() {
+						node.AddNewlyMinedBlock(m.miningCtx, output.NewBlock)
 					}
-					node.miningDoneWg.Done()
+					m.miningDoneWg.Done()
 				}()
 			}
 		}
@@ -640,8 +707,10 @@ func (node *Node) handleNewHeaviestTipSet(ctx context.Context, head types.TipSet
 			}
 			head = newHead
 
-			if node.StorageMiner != nil {
-				node.StorageMiner.OnNewHeaviestTipSet(newHead)
+			for _, m := range node.miners() {
+				if sm := m.getStorageMiner(); sm != nil {
+					sm.OnNewHeaviestTipSet(newHead)
+				}
 			}
 			node.HeaviestTipSetHandled()
 		case <-ctx.Done():
@@ -674,12 +743,14 @@ func (node *Node) Stop(ctx context.Context) {
 	node.cancelSubscriptions()
 	node.ChainReader.Stop()
 
-	if node.SectorBuilder() != nil {
-		if err := node.SectorBuilder().Close(); err != nil {
-			fmt.Printf("error closing sector builder: %s\n", err)
+	node.mining.Lock()
+	for addr, m := range node.mining.miners {
+		if err := m.sectorBuilder.Close(); err != nil {
+			fmt.Printf("error closing sector builder of miner %s: %s\n", addr, err)
 		}
-		node.sectorBuilder = nil
+		delete(node.mining.miners, addr)
 	}
+	node.mining.Unlock()
 
 	if err := node.Host().Close(); err != nil {
 		fmt.Printf("error closing host: %s\n", err)
@@ -740,148 +811,18 @@ func StartMining(ctx context.Context, node *Node) error {
 	return node.StartMining(ctx)
 }
 
-// StartMining causes the node to start feeding blocks to the mining worker and initializes
-// the SectorBuilder for the mining address.
+// StartMining causes the node to start feeding blocks to the mining workers of
+// all its miners and initializes their SectorBuilders.
 func (node *Node) StartMining(ctx context.Context) error {
-	if node.isMining() {
-		return errors.New("Node is already mining")
-	}
-	minerAddr, err := node.MiningAddress()
-	if err != nil {
-		return errors.Wrap(err, "failed to get mining address")
+	addrs := node.MiningAddresses()
+	if len(addrs) == 0 {
+		return errors.Wrap(ErrNoMinerAddress, "failed to get mining address")
 	}
-
-	// ensure we have a sector builder
-	if node.SectorBuilder() == nil {
-		if err := node.setupMining(ctx); err != nil {
+	for _, addr := range addrs {
+		if err := node.StartMiner(ctx, addr); err != nil {
 			return err
 		}
 	}
-
-	minerOwnerAddr, err := node.MiningOwnerAddress(ctx, minerAddr)
-	if err != nil {
-		return errors.Wrapf(err, "failed to get mining owner address for miner %s", minerAddr)
-	}
-
-	blockTime, mineDelay := node.MiningTimes()
-
-	if node.MiningScheduler == nil {
-		getStateFromKey := func(ctx context.Context, tsKey string) (state.Tree, error) {
-			tsas, err := node.ChainReader.GetTipSetAndState(ctx, tsKey)
-			if err != nil {
-				return nil, err
-			}
-			return state.LoadStateTree(ctx, node.CborStore(), tsas.TipSetStateRoot, builtin.Actors)
-		}
-		getState := func(ctx context.Context, ts types.TipSet) (state.Tree, error) {
-			return getStateFromKey(ctx, ts.String())
-		}
-		getWeight := func(ctx context.Context, ts types.TipSet) (uint64, error) {
-			parent, err := ts.Parents()
-			if err != nil {
-				return uint64(0), err
-			}
-			// TODO handle genesis cid more gracefully
-			if parent.Len() == 0 {
-				return node.Consensus.Weight(ctx, ts, nil)
-			}
-			pSt, err := getStateFromKey(ctx, parent.String())
-			if err != nil {
-				return uint64(0), err
-			}
-			return node.Consensus.Weight(ctx, ts, pSt)
-		}
-		getAncestors := func(ctx context.Context, ts types.TipSet, newBlockHeight *types.BlockHeight) ([]types.TipSet, error) {
-			return chain.GetRecentAncestors(ctx, ts, node.ChainReader, newBlockHeight, consensus.AncestorRoundsNeeded, consensus.LookBackParameter)
-		}
-		processor := consensus.NewDefaultProcessor()
-		worker := mining.NewDefaultWorker(node.MsgPool, getState, getWeight, getAncestors, processor, node.PowerTable, node.Blockstore, node.CborStore(), minerAddr, blockTime)
-		node.MiningScheduler = mining.NewScheduler(worker, mineDelay, node.ChainReader.Head)
-	}
-
-	// paranoid check
-	if !node.MiningScheduler.IsStarted() {
-		node.miningCtx, node.cancelMining = context.WithCancel(context.Background())
-		outCh, doneWg := node.MiningScheduler.Start(node.miningCtx)
-
-		node.miningDoneWg = doneWg
-		node.AddNewlyMinedBlock = node.addNewlyMinedBlock
-		node.miningDoneWg.Add(1)
-		go node.handleNewMiningOutput(outCh)
-	}
-
-	// initialize a storage miner
-	storageMiner, err := initStorageMinerForNode(ctx, node)
-	if err != nil {
-		return errors.Wrap(err, "failed to initialize storage miner")
-	}
-	node.StorageMiner = storageMiner
-
-	// loop, turning sealing-results into commitSector messages to be included
-	// in the chain
-	go func() {
-		for {
-			select {
-			case result := <-node.SectorBuilder().SectorSealResults():
-				if result.SealingErr != nil {
-					log.Errorf("failed to seal sector with id %d: %s", result.SectorID, result.SealingErr.Error())
-				} else if result.SealingResult != nil {
-
-					// TODO: determine these algorithmically by simulating call and querying historical prices
-					gasPrice := types.NewGasPrice(0)
-					gasUnits := types.NewGasUnits(300)
-
-					val := result.SealingResult
-					// This call can fail due to, e.g. nonce collisions. Our miners existence depends on this.
-					// We should deal with this, but MessageSendWithRetry is problematic.
-					_, err := node.PorcelainAPI.MessageSend(
-						node.miningCtx,
-						minerOwnerAddr,
-						minerAddr,
-						nil,
-						gasPrice,
-						gasUnits,
-						"commitSector",
-						val.SectorID,
-						val.CommD[:],
-						val.CommR[:],
-						val.CommRStar[:],
-						val.Proof[:],
-					)
-					if err != nil {
-						log.Errorf("failed to send commitSector message from %s to %s for sector with id %d: %s", minerOwnerAddr, minerAddr, val.SectorID, err)
-						continue
-					}
-
-					node.StorageMiner.OnCommitmentAddedToChain(val, nil)
-				}
-			case <-node.miningCtx.Done():
-				return
-			}
-		}
-	}()
-
-	// schedules sealing of staged piece-data
-	if node.Repo.Config().Mining.AutoSealIntervalSeconds > 0 {
-		go func() {
-			for {
-				select {
-				case <-node.miningCtx.Done():
-					return
-				case <-time.After(time.Duration(node.Repo.Config().Mining.AutoSealIntervalSeconds) * time.Second):
-					log.Info("auto-seal has been triggered")
-					if err := node.SectorBuilder().SealAllStagedSectors(node.miningCtx); err != nil {
-						log.Errorf("scheduler received error from node.SectorBuilder.SealAllStagedSectors (%s) - exiting", err.Error())
-						return
-					}
-				}
-			}
-		}()
-	} else {
-		log.Debug("auto-seal is disabled")
-	}
-	node.setIsMining(true)
-
 	return nil
 }
 
@@ -908,71 +849,13 @@ func (node *Node) getLastUsedSectorID(ctx context.Context, minerAddr address.Add
 	return lastUsedSectorID, nil
 }
 
-func initSectorBuilderForNode(ctx context.Context, node *Node, sectorStoreType proofs.SectorStoreType) (sectorbuilder.SectorBuilder, error) {
-	minerAddr, err := node.MiningAddress()
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to get node's mining address")
-	}
-
-	lastUsedSectorID, err := node.getLastUsedSectorID(ctx, minerAddr)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to get last used sector id for miner w/address %s", minerAddr.String())
-	}
-
-	// TODO: Where should we store the RustSectorBuilder metadata? Currently, we
-	// configure the RustSectorBuilder to store its metadata in the staging
-	// directory.
-
-	cfg := sectorbuilder.RustSectorBuilderConfig{
-		BlockService:     node.blockservice,
-		LastUsedSectorID: lastUsedSectorID,
-		MetadataDir:      node.Repo.StagingDir(),
-		MinerAddr:        minerAddr,
-		SealedSectorDir:  node.Repo.SealedDir(),
-		SectorStoreType:  sectorStoreType,
-		StagedSectorDir:  node.Repo.StagingDir(),
-	}
-
-	sb, err := sectorbuilder.NewRustSectorBuilder(cfg)
-	if err != nil {
-		return nil, errors.Wrap(err, fmt.Sprintf("failed to initialize sector builder for miner %s", minerAddr.String()))
-	}
-
-	return sb, nil
-}
-
-func initStorageMinerForNode(ctx context.Context, node *Node) (*storage.Miner, error) {
-	minerAddr, err := node.MiningAddress()
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to get node's mining address")
-	}
-
-	miningOwnerAddr, err := node.MiningOwnerAddress(ctx, minerAddr)
-	if err != nil {
-		return nil, errors.Wrap(err, "no mining owner available, skipping storage miner setup")
-	}
-
-	miner, err := storage.NewMiner(ctx, minerAddr, miningOwnerAddr, node, node.Repo.DealsDatastore(), node.PorcelainAPI)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to instantiate storage miner")
-	}
-
-	return miner, nil
-}
-
-// StopMining stops mining on new blocks.
+// StopMining stops mining on new blocks for all the miners of the node.
 func (node *Node) StopMining(ctx context.Context) {
-	node.setIsMining(false)
-
-	if node.cancelMining != nil {
-		node.cancelMining()
-	}
-
-	if node.miningDoneWg != nil {
-		node.miningDoneWg.Wait()
+	for _, m := range node.miners() {
+		if err := node.StopMiner(ctx, m.addr); err != nil {
+			log.Errorf("failed to stop miner %s: %s", m.addr, err)
+		}
 	}
-
-	// TODO: stop node.StorageMiner
 }
 
 // NewAddress creates a new account address on the default wallet backend.
@@ -981,14 +864,10 @@ func (node *Node) NewAddress() (address.Address, error) {
 }
 
 // CreateMiner creates a new miner actor for the given account and returns its address.
-// It will wait for the the actor to appear on-chain and add set the address to mining.minerAddress in the config.
+// It will wait for the the actor to appear on-chain and set the address to mining.minerAddress
+// in the config, or add it to mining.minerAddresses if the node already has a miner.
 // TODO: This should live in a MinerAPI or some such. It's here until we have a proper API layer.
 func (node *Node) CreateMiner(ctx context.Context, accountAddr address.Address, gasPrice types.AttoFIL, gasLimit types.GasUnits, pledge uint64, pid libp2ppeer.ID, collateral *types.AttoFIL) (_ *address.Address, err error) {
-	// Only create a miner if we don't already have one.
-	if _, err := node.MiningAddress(); err != ErrNoMinerAddress {
-		return nil, fmt.Errorf("can only have one miner per node")
-	}
-
 	ctx = log.Start(ctx, "Node.CreateMiner")
 	defer func() {
 		log.FinishWithErr(ctx, err)
@@ -1042,7 +921,7 @@ func (node *Node) CreateMiner(ctx context.Context, accountAddr address.Address,
 		return &minerAddress, err
 	}
 
-	err = node.setupMining(ctx)
+	_, err = node.miner(ctx, minerAddress)
 
 	return &minerAddress, err
 }
@@ -1050,7 +929,11 @@ func (node *Node) CreateMiner(ctx context.Context, accountAddr address.Address,
 func (node *Node) saveMinerAddressToConfig(addr address.Address) error {
 	r := node.Repo
 	newConfig := r.Config()
-	newConfig.Mining.MinerAddress = addr
+	if newConfig.Mining.MinerAddress == (address.Address{}) {
+		newConfig.Mining.MinerAddress = addr
+	} else {
+		newConfig.Mining.MinerAddresses = append(newConfig.Mining.MinerAddresses, addr)
+	}
 
 	return r.ReplaceConfig(newConfig)
 }
@@ -1105,9 +988,15 @@ func (node *Node) Host() host.Host {
 	return node.host
 }
 
-// SectorBuilder returns the nodes sectorBuilder.
+// SectorBuilder returns the sectorBuilder of the default miner of the node,
+// or nil if it is not set up.
 func (node *Node) SectorBuilder() sectorbuilder.SectorBuilder {
-	return node.sectorBuilder
+	node.mining.Lock()
+	defer node.mining.Unlock()
+	if m, ok := node.mining.miners[node.Repo.Config().Mining.MinerAddress]; ok {
+		return m.sectorBuilder
+	}
+	return nil
 }
 
 // BlockService returns the nodes blockservice.
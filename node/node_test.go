@@ -9,10 +9,12 @@ import (
 
 	"gx/ipfs/QmPiemjiKBC9VA7vZF82m4x1oygtg2c2YVqag8PX7dN1BD/go-libp2p-peerstore"
 
+	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/chain"
 	"github.com/filecoin-project/go-filecoin/config"
 	"github.com/filecoin-project/go-filecoin/consensus"
 	"github.com/filecoin-project/go-filecoin/core"
+	"github.com/filecoin-project/go-filecoin/gengen/util"
 	"github.com/filecoin-project/go-filecoin/mining"
 	"github.com/filecoin-project/go-filecoin/plumbing"
 	pbConfig "github.com/filecoin-project/go-filecoin/plumbing/cfg"
@@ -163,7 +165,7 @@ func TestNodeStartMining(t *testing.T) {
 		SigGetter:    mthdsig.NewGetter(minerNode.ChainReader),
 		MsgPreviewer: msg.NewPreviewer(minerNode.Wallet, minerNode.ChainReader, minerNode.CborStore(), minerNode.Blockstore),
 		MsgQueryer:   msg.NewQueryer(minerNode.Repo, minerNode.Wallet, minerNode.ChainReader, minerNode.CborStore(), minerNode.Blockstore),
-		MsgSender:    msg.NewSender(minerNode.Repo, minerNode.Wallet, minerNode.ChainReader, minerNode.MsgPool, minerNode.PubSub.Publish),
+		MsgSender:    msg.NewSender(minerNode.Repo, minerNode.Wallet, minerNode.ChainReader, minerNode.MsgPool, minerNode.MsgOutbox),
 		MsgWaiter:    msg.NewWaiter(minerNode.ChainReader, minerNode.Blockstore, minerNode.CborStore()),
 		Config:       pbConfig.NewConfig(minerNode.Repo),
 		Chain:        chn.New(minerNode.ChainReader),
@@ -182,11 +184,13 @@ func TestNodeStartMining(t *testing.T) {
 	t.Run("Start/Stop/Start results in a MiningScheduler that is started", func(t *testing.T) {
 		assert.NoError(minerNode.StartMining(ctx))
 		defer minerNode.StopMining(ctx)
-		assert.True(minerNode.MiningScheduler.IsStarted())
+		m, err := minerNode.miner(ctx, mineraddr)
+		require.NoError(t, err)
+		assert.True(m.scheduler.IsStarted())
 		minerNode.StopMining(ctx)
-		assert.False(minerNode.MiningScheduler.IsStarted())
+		assert.False(m.scheduler.IsStarted())
 		assert.NoError(minerNode.StartMining(ctx))
-		assert.True(minerNode.MiningScheduler.IsStarted())
+		assert.True(m.scheduler.IsStarted())
 	})
 
 	t.Run("Start + Start gives an error message saying mining is already started", func(t *testing.T) {
@@ -198,6 +202,52 @@ func TestNodeStartMining(t *testing.T) {
 
 }
 
+func TestNodeStartStopMiner(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+
+	seed := MakeChainSeed(t, &gengen.GenesisCfg{
+		Keys: 2,
+		Miners: []gengen.Miner{
+			{Owner: 0, Power: 100, PeerID: mustPeerID(PeerKeys[0]).Pretty()},
+			{Owner: 1, Power: 100, PeerID: mustPeerID(PeerKeys[0]).Pretty()},
+		},
+		PreAlloc: []string{"10000", "10000"},
+	})
+	minerNode := MakeNodeWithChainSeed(t, seed, []ConfigOpt{}, PeerKeyOpt(PeerKeys[0]), AutoSealIntervalSecondsOpt(1))
+	seed.GiveKey(t, minerNode, 0)
+	seed.GiveKey(t, minerNode, 1)
+	addr0, _ := seed.GiveMiner(t, minerNode, 0)
+	addr1 := seed.info.Miners[1].Address
+	cfg := minerNode.Repo.Config()
+	cfg.Mining.MinerAddresses = []address.Address{addr1}
+	require.NoError(minerNode.Repo.ReplaceConfig(cfg))
+
+	require.NoError(minerNode.Start(ctx))
+	defer minerNode.Stop(ctx)
+	assert.Equal([]address.Address{addr0, addr1}, minerNode.MiningAddresses())
+
+	require.NoError(minerNode.StartMiner(ctx, addr1))
+	defer minerNode.StopMining(ctx)
+	m0, err := minerNode.miner(ctx, addr0)
+	require.NoError(err)
+	m1, err := minerNode.miner(ctx, addr1)
+	require.NoError(err)
+	assert.NotEqual(m0.sectorBuilder, m1.sectorBuilder)
+	assert.Nil(m0.scheduler)
+	assert.True(m1.scheduler.IsStarted())
+	assert.Error(minerNode.StartMiner(ctx, addr1))
+	assert.Error(minerNode.StartMiner(ctx, address.NewForTestGetter()()))
+	assert.NotNil(minerNode.StorageMiners.Get(addr1))
+
+	require.NoError(minerNode.StopMiner(ctx, addr1))
+	assert.False(m1.scheduler.IsStarted())
+	assert.Nil(minerNode.StorageMiners.Get(addr1))
+	assert.Nil(m1.getStorageMiner())
+}
+
 // skipped anyway, now commented out.  With new mining we really need something here though.
 /*
 func TestNodeMining(t *testing.T) {
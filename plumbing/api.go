@@ -2,23 +2,32 @@ package plumbing
 
 import (
 	"context"
+	"io"
 
 	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	bstore "gx/ipfs/QmS2aqUZLJp8kF1ihE5rvDGE5LvmKDPnx32w9Z1BW9xLV5/go-ipfs-blockstore"
 	"gx/ipfs/QmY5Grm8pJdiSSVsYxx4uNRgweY72EmYwuSDbRnbFok3iY/go-libp2p-peer"
 	logging "gx/ipfs/QmcuXC5cxs79ro2cUuHs4HQ2bkDLJUYokwL8aivcX6HW3C/go-log"
 
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/chain"
 	"github.com/filecoin-project/go-filecoin/core"
 	"github.com/filecoin-project/go-filecoin/exec"
+	"github.com/filecoin-project/go-filecoin/mining"
 	"github.com/filecoin-project/go-filecoin/plumbing/cfg"
 	"github.com/filecoin-project/go-filecoin/plumbing/chn"
 	"github.com/filecoin-project/go-filecoin/plumbing/msg"
 	"github.com/filecoin-project/go-filecoin/plumbing/mthdsig"
 	"github.com/filecoin-project/go-filecoin/plumbing/ntwk"
+	"github.com/filecoin-project/go-filecoin/plumbing/supply"
 	"github.com/filecoin-project/go-filecoin/types"
 	"github.com/filecoin-project/go-filecoin/wallet"
 )
 
+// messagePoolSubscriptionSize is the number of message pool events a
+// subscriber can lag behind before its subscription ends.
+const messagePoolSubscriptionSize = 128
+
 // API is the plumbing implementation, the irreducible set of calls required
 // to implement protocols and user/network-facing features. You probably should
 // depend on the higher level porcelain.API instead of this api, as it includes
@@ -27,30 +36,44 @@ import (
 type API struct {
 	logger logging.EventLogger
 
+	badTipSets   *chain.BadTipSetCache
+	blockstore   bstore.Blockstore
 	chain        *chn.Reader
+	chainReader  chain.ReadStore
 	config       *cfg.Config
 	messagePool  *core.MessagePool
+	miningHist   *mining.History
+	msgGasPricer *msg.GasPricer
 	msgPreviewer *msg.Previewer
 	msgQueryer   *msg.Queryer
 	msgSender    *msg.Sender
 	msgWaiter    *msg.Waiter
 	network      *ntwk.Network
 	sigGetter    *mthdsig.Getter
+	supply       *supply.Tracker
+	syncer       chain.Syncer
 	wallet       *wallet.Wallet
 }
 
 // APIDeps contains all the API's dependencies
 type APIDeps struct {
-	Chain        *chn.Reader
-	Config       *cfg.Config
-	MessagePool  *core.MessagePool
-	MsgPreviewer *msg.Previewer
-	MsgQueryer   *msg.Queryer
-	MsgSender    *msg.Sender
-	MsgWaiter    *msg.Waiter
-	Network      *ntwk.Network
-	SigGetter    *mthdsig.Getter
-	Wallet       *wallet.Wallet
+	BadTipSets    *chain.BadTipSetCache
+	Blockstore    bstore.Blockstore
+	Chain         *chn.Reader
+	ChainReader   chain.ReadStore
+	Config        *cfg.Config
+	MessagePool   *core.MessagePool
+	MiningHistory *mining.History
+	MsgGasPricer  *msg.GasPricer
+	MsgPreviewer  *msg.Previewer
+	MsgQueryer    *msg.Queryer
+	MsgSender     *msg.Sender
+	MsgWaiter     *msg.Waiter
+	Network       *ntwk.Network
+	SigGetter     *mthdsig.Getter
+	Supply        *supply.Tracker
+	Syncer        chain.Syncer
+	Wallet        *wallet.Wallet
 }
 
 // New constructs a new instance of the API.
@@ -58,15 +81,22 @@ func New(deps *APIDeps) *API {
 	return &API{
 		logger: logging.Logger("porcelain"),
 
+		badTipSets:   deps.BadTipSets,
+		blockstore:   deps.Blockstore,
 		chain:        deps.Chain,
+		chainReader:  deps.ChainReader,
 		config:       deps.Config,
 		messagePool:  deps.MessagePool,
+		miningHist:   deps.MiningHistory,
+		msgGasPricer: deps.MsgGasPricer,
 		msgPreviewer: deps.MsgPreviewer,
 		msgQueryer:   deps.MsgQueryer,
 		msgSender:    deps.MsgSender,
 		msgWaiter:    deps.MsgWaiter,
 		network:      deps.Network,
 		sigGetter:    deps.SigGetter,
+		supply:       deps.Supply,
+		syncer:       deps.Syncer,
 		wallet:       deps.Wallet,
 	}
 }
@@ -78,6 +108,12 @@ func (api *API) ActorGetSignature(ctx context.Context, actorAddr address.Address
 	return api.sigGetter.Get(ctx, actorAddr, method)
 }
 
+// AddressHistory returns the messages of the chain sent to or from addr at
+// height since or above, see chain.DefaultStore.AddressHistory.
+func (api *API) AddressHistory(ctx context.Context, addr address.Address, since uint64, limit int) ([]chain.AddressHistoryEntry, error) {
+	return api.chainReader.AddressHistory(ctx, addr, since, limit)
+}
+
 // ConfigSet sets the given parameters at the given path in the local config.
 // The given path may be either a single field name, or a dotted path to a field.
 // The JSON value may be either a single value or a whole data structure to be replace.
@@ -104,6 +140,62 @@ func (api *API) ChainLs(ctx context.Context) <-chan interface{} {
 	return api.chain.Ls(ctx)
 }
 
+// ChainSyncStatus returns a snapshot of the chain syncer's progress
+func (api *API) ChainSyncStatus() chain.SyncStatus {
+	return api.syncer.Status()
+}
+
+// ChainLsBadTipSets returns the tipsets the syncer has rejected, along with
+// the reasons they were rejected
+func (api *API) ChainLsBadTipSets() []chain.BadTipSet {
+	return api.badTipSets.List()
+}
+
+// ChainRemoveBadTipSet unmarks a tipset the syncer has rejected so that it
+// may be synced again
+func (api *API) ChainRemoveBadTipSet(tsKey string) error {
+	return api.badTipSets.Remove(tsKey)
+}
+
+// ChainGetTipSet returns the tipset with the given key if it is in the chain
+// store
+func (api *API) ChainGetTipSet(ctx context.Context, tsKey string) (types.TipSet, error) {
+	tsas, err := api.chainReader.GetTipSetAndState(ctx, tsKey)
+	if err != nil {
+		return nil, err
+	}
+	return tsas.TipSet, nil
+}
+
+// ChainGetTipSetByHeight returns the tipset at the given height of the chain
+// ending in the head, or the closest tipset below it if the height is a null
+// round
+func (api *API) ChainGetTipSetByHeight(ctx context.Context, h uint64) (types.TipSet, error) {
+	return api.chainReader.GetTipSetByHeight(ctx, h)
+}
+
+// ChainExport writes a CAR archive of the chain ending in head to w
+func (api *API) ChainExport(ctx context.Context, head types.TipSet, opts chain.ExportOptions, w io.Writer) error {
+	return chain.Export(ctx, api.chainReader, api.blockstore, head, opts, w)
+}
+
+// ChainCollectGarbage prunes old chain state from the node's repo
+func (api *API) ChainCollectGarbage(ctx context.Context, opts chain.GCOptions) (chain.GCResult, error) {
+	return api.syncer.CollectGarbage(ctx, api.blockstore, opts)
+}
+
+// ChainSupply returns how the FIL of the state of the head is split between
+// circulating, locked, burnt and unemitted FIL
+func (api *API) ChainSupply(ctx context.Context) (*supply.Supply, error) {
+	return api.supply.Supply(ctx)
+}
+
+// ChainBlockReward returns the reward of the blocks at the given height
+// according to the reward schedule of the chain
+func (api *API) ChainBlockReward(ctx context.Context, h uint64) (*types.AttoFIL, error) {
+	return api.supply.BlockReward(ctx, h)
+}
+
 // BlockGet gets a block by CID
 func (api *API) BlockGet(ctx context.Context, id cid.Cid) (*types.Block, error) {
 	return api.chain.BlockGet(ctx, id)
@@ -114,12 +206,73 @@ func (api *API) MessagePoolRemove(cid cid.Cid) {
 	api.messagePool.Remove(cid)
 }
 
+// MessagePoolSubscribe returns a channel receiving the changes of the message
+// pool until ctx is done.  Rather than holding up the message pool, a
+// subscription whose channel is full ends with a MessageEventsDropped event.
+func (api *API) MessagePoolSubscribe(ctx context.Context) <-chan core.MessagePoolEvent {
+	// the last slot is kept for the MessageEventsDropped event
+	out := make(chan core.MessagePoolEvent, messagePoolSubscriptionSize+1)
+	events := api.messagePool.Events().Sub(core.MessagePoolTopic)
+	go func() {
+		defer close(out)
+		defer func() {
+			// Drain the channel so that unsubscribing doesn't block a publisher.
+			go func() {
+				for range events {
+				}
+			}()
+			api.messagePool.Events().Unsub(events, core.MessagePoolTopic)
+		}()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				if len(out) < messagePoolSubscriptionSize {
+					out <- event.(core.MessagePoolEvent)
+					continue
+				}
+				api.logger.Warning("closing the message pool subscription of a lagging subscriber")
+				out <- core.MessagePoolEvent{Type: core.MessageEventsDropped}
+				return
+			}
+		}
+	}()
+	return out
+}
+
 // MessagePreview previews the Gas cost of a message by running it locally on the client and
 // recording the amount of Gas used.
 func (api *API) MessagePreview(ctx context.Context, from, to address.Address, method string, params ...interface{}) (types.GasUnits, error) {
 	return api.msgPreviewer.Preview(ctx, from, to, method, params...)
 }
 
+// MessagePreviewEncoded is like MessagePreview for parameters that are already
+// ABI encoded.
+func (api *API) MessagePreviewEncoded(ctx context.Context, from, to address.Address, method string, encodedParams []byte) (types.GasUnits, error) {
+	return api.msgPreviewer.PreviewEncoded(ctx, from, to, method, encodedParams)
+}
+
+// MessageEstimateGasLimit estimates the gas limit of a message from the gas
+// it uses in a preview, with a safety margin.
+func (api *API) MessageEstimateGasLimit(ctx context.Context, from, to address.Address, method string, params ...interface{}) (types.GasUnits, error) {
+	usedGas, err := api.msgPreviewer.Preview(ctx, from, to, method, params...)
+	if err != nil {
+		return types.NewGasUnits(0), err
+	}
+	return msg.GasLimitWithMargin(usedGas), nil
+}
+
+// MessageSuggestGasPrice suggests a gas price from the prices paid by the
+// messages included in recent blocks.
+func (api *API) MessageSuggestGasPrice(ctx context.Context) (types.AttoFIL, error) {
+	return api.msgGasPricer.SuggestGasPrice(ctx)
+}
+
 // MessageQuery calls an actor's method using the most recent chain state. It is read-only,
 // it does not change any state. It is use to interrogate actor state. The from address
 // is optional; if not provided, an address will be chosen from the node's wallet.
@@ -136,6 +289,38 @@ func (api *API) MessageSend(ctx context.Context, from, to address.Address, value
 	return api.msgSender.Send(ctx, from, to, value, gasPrice, gasLimit, method, params...)
 }
 
+// MessageSendBatch sends a message from from for each entry, with consecutive
+// nonces, and returns their cids. Unlike calling MessageSend for each entry,
+// no other message from the node can take a nonce in the middle of the batch.
+func (api *API) MessageSendBatch(ctx context.Context, from address.Address, gasPrice types.AttoFIL, gasLimit types.GasUnits, entries []msg.BatchEntry) ([]cid.Cid, error) {
+	return api.msgSender.SendBatch(ctx, from, gasPrice, gasLimit, entries)
+}
+
+// MessageReplace replaces a pending message with a copy paying a higher gas
+// price and broadcasts the replacement. The message pool only accepts the
+// replacement if its gas price is high enough, see core.MessagePool.
+func (api *API) MessageReplace(ctx context.Context, msgCid cid.Cid, gasPrice types.AttoFIL) (cid.Cid, error) {
+	return api.msgSender.Replace(ctx, msgCid, gasPrice)
+}
+
+// MessageCreate returns an unsigned message with the next nonce of from, to be
+// signed with MessageSign, possibly on another node, and broadcast with
+// MessageBroadcast.
+func (api *API) MessageCreate(ctx context.Context, from, to address.Address, value *types.AttoFIL, gasPrice types.AttoFIL, gasLimit types.GasUnits, method string, params ...interface{}) (*types.MeteredMessage, error) {
+	return api.msgSender.Create(ctx, from, to, value, gasPrice, gasLimit, method, params...)
+}
+
+// MessageSign signs a message with the key of its sender in the wallet.
+func (api *API) MessageSign(msg *types.MeteredMessage) (*types.SignedMessage, error) {
+	return api.msgSender.Sign(msg)
+}
+
+// MessageBroadcast validates a signed message, adds it to the message pool and
+// publishes it.
+func (api *API) MessageBroadcast(ctx context.Context, smsg *types.SignedMessage) (cid.Cid, error) {
+	return api.msgSender.Broadcast(ctx, smsg)
+}
+
 // MessageWait invokes the callback when a message with the given cid appears on chain.
 // It will find the message in both the case that it is already on chain and
 // the case that it appears in a newly mined block. An error is returned if one is
@@ -145,6 +330,19 @@ func (api *API) MessageWait(ctx context.Context, msgCid cid.Cid, cb func(*types.
 	return api.msgWaiter.Wait(ctx, msgCid, cb)
 }
 
+// MessageFind returns the message with the given cid along with its location
+// in the chain and its receipt, or chain.ErrMessageNotFound if the message is
+// not on chain.
+func (api *API) MessageFind(ctx context.Context, msgCid cid.Cid) (*msg.ChainMessage, error) {
+	return api.msgWaiter.Find(ctx, msgCid)
+}
+
+// MiningRounds returns the last rounds mined by the node, oldest first. See
+// mining.History.
+func (api *API) MiningRounds() []mining.Round {
+	return api.miningHist.Rounds()
+}
+
 // NetworkGetPeerID gets the current peer id from Util
 func (api *API) NetworkGetPeerID() peer.ID {
 	return api.network.GetPeerID()
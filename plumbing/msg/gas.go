@@ -0,0 +1,75 @@
+package msg
+
+import (
+	"context"
+	"sort"
+
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// GasLimitMarginPercent is the margin, in percent of the gas used by a
+// preview of a message, added to get its estimated gas limit.  The state may
+// change between the preview and the execution of the message.
+const GasLimitMarginPercent = 20
+
+// gasPriceSampleTipSets is the number of tipsets at the head of the chain
+// whose messages are sampled to suggest a gas price.
+const gasPriceSampleTipSets = 10
+
+// GasLimitWithMargin returns the gas limit of a message using gas units
+// according to a preview: used plus GasLimitMarginPercent, capped at the block
+// gas limit.
+func GasLimitWithMargin(used types.GasUnits) types.GasUnits {
+	limit := used + (used*GasLimitMarginPercent+99)/100
+	if limit > types.BlockGasLimit {
+		return types.BlockGasLimit
+	}
+	return limit
+}
+
+// GasPricer suggests gas prices from the messages of the chain.
+type GasPricer struct {
+	chainReader chain.ReadStore
+}
+
+// NewGasPricer returns a new GasPricer.
+func NewGasPricer(chainReader chain.ReadStore) *GasPricer {
+	return &GasPricer{chainReader: chainReader}
+}
+
+// SuggestGasPrice returns the median gas price of the messages included in
+// the last tipsets of the chain ending in the head, or zero if they include
+// no messages.
+func (gp *GasPricer) SuggestGasPrice(ctx context.Context) (types.AttoFIL, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var prices []*types.AttoFIL
+	sampled := 0
+	for raw := range gp.chainReader.BlockHistory(ctx, gp.chainReader.Head()) {
+		switch v := raw.(type) {
+		case error:
+			return types.AttoFIL{}, errors.Wrap(v, "failed to walk the chain")
+		case types.TipSet:
+			for _, blk := range v {
+				for _, msg := range blk.Messages {
+					price := msg.GasPrice
+					prices = append(prices, &price)
+				}
+			}
+		}
+		sampled++
+		if sampled == gasPriceSampleTipSets {
+			break
+		}
+	}
+
+	if len(prices) == 0 {
+		return *types.NewZeroAttoFIL(), nil
+	}
+	sort.Slice(prices, func(i, j int) bool { return prices[i].LessThan(prices[j]) })
+	return *prices[len(prices)/2], nil
+}
@@ -0,0 +1,33 @@
+package msg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGasLimitWithMargin(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	assert.Equal(types.NewGasUnits(0), GasLimitWithMargin(types.NewGasUnits(0)))
+	assert.Equal(types.NewGasUnits(120), GasLimitWithMargin(types.NewGasUnits(100)))
+	// The margin is rounded up.
+	assert.Equal(types.NewGasUnits(2), GasLimitWithMargin(types.NewGasUnits(1)))
+	assert.Equal(types.BlockGasLimit, GasLimitWithMargin(types.BlockGasLimit-1))
+}
+
+func TestSuggestGasPrice(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// A chain without messages suggests a zero price.
+	d := requireCommonDeps(require)
+	price, err := NewGasPricer(d.chainStore).SuggestGasPrice(context.Background())
+	require.NoError(err)
+	assert.True(price.IsZero())
+}
@@ -0,0 +1,195 @@
+package msg
+
+import (
+	"context"
+
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	cbor "gx/ipfs/QmRoARq3nkUb13HSKZGepCZSWe5GrVPwx7xURJGZ7KWv9V/go-ipld-cbor"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+	"gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore"
+	"gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore/query"
+
+	"github.com/filecoin-project/go-filecoin/core"
+	"github.com/filecoin-project/go-filecoin/repo"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// outboxPrefix is the datastore namespace of the messages sent by the node
+// that may still be pending.
+const outboxPrefix = "/mpool/outbox"
+
+// OutboxMaxAttempts is how many times the outbox adds a message back to the
+// message pool, after it expired or was rejected, before forgetting it.
+const OutboxMaxAttempts = 5
+
+func init() {
+	cbor.RegisterCborType(outboxEntry{})
+}
+
+// outboxEntry is a message kept in the outbox.
+type outboxEntry struct {
+	Message *types.SignedMessage
+	// Attempts is how many times the outbox added the message back to the
+	// message pool.
+	Attempts uint64
+}
+
+// Outbox publishes the messages sent by the node and keeps them in the repo
+// until they are mined, so that they can be published again and survive a
+// restart.  Messages the pool keeps rejecting or expiring are forgotten after
+// OutboxMaxAttempts.
+type Outbox struct {
+	ds      repo.Datastore
+	msgPool *core.MessagePool
+	publish PublishFunc
+}
+
+// NewOutbox returns a new Outbox keeping messages in ds.
+func NewOutbox(ds repo.Datastore, msgPool *core.MessagePool, publish PublishFunc) *Outbox {
+	return &Outbox{ds: ds, msgPool: msgPool, publish: publish}
+}
+
+// Publish stores smsg, which must be in the message pool, and publishes it
+// to the network.
+func (ob *Outbox) Publish(smsg *types.SignedMessage) error {
+	c, err := smsg.Cid()
+	if err != nil {
+		return errors.Wrap(err, "failed to create CID")
+	}
+	smsgdata, err := smsg.Marshal()
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal message")
+	}
+	if err := ob.put(c, &outboxEntry{Message: smsg}); err != nil {
+		return err
+	}
+	return ob.publish(Topic, smsgdata)
+}
+
+// Load adds the stored messages to the message pool, and forgets those that
+// have been mined.
+func (ob *Outbox) Load(ctx context.Context) error {
+	msgs, err := ob.stored()
+	if err != nil {
+		return err
+	}
+	for c, entry := range msgs {
+		if _, err := ob.addToPool(ctx, c, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rebroadcast publishes the stored messages again, and forgets those that
+// have been mined.  Messages that left the message pool without being mined,
+// for instance because they expired, are added back to it.
+func (ob *Outbox) Rebroadcast(ctx context.Context) error {
+	msgs, err := ob.stored()
+	if err != nil {
+		return err
+	}
+	for c, entry := range msgs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		pending, err := ob.addToPool(ctx, c, entry)
+		if err != nil {
+			return err
+		}
+		if !pending {
+			continue
+		}
+		smsgdata, err := entry.Message.Marshal()
+		if err != nil {
+			return errors.Wrapf(err, "failed to marshal message %s", c)
+		}
+		if err := ob.publish(Topic, smsgdata); err != nil {
+			return errors.Wrapf(err, "failed to publish message %s", c)
+		}
+	}
+	return nil
+}
+
+// addToPool adds the stored message with cid c to the message pool if it is
+// not there, and returns whether it is pending.  The message is forgotten if
+// the pool rejects it because its nonce has been used, which means that it or
+// another message with the same nonce has been mined, or because its
+// signature is invalid, or once it has been added OutboxMaxAttempts times.
+// Messages rejected for other reasons are kept to be added later.
+func (ob *Outbox) addToPool(ctx context.Context, c cid.Cid, entry *outboxEntry) (bool, error) {
+	if _, ok := ob.msgPool.Get(c); ok {
+		return true, nil
+	}
+	if entry.Attempts >= OutboxMaxAttempts {
+		log.Infof("forgetting message %s after %d attempts", c, entry.Attempts)
+		return false, ob.forget(c)
+	}
+	entry.Attempts++
+	if err := ob.put(c, entry); err != nil {
+		return false, err
+	}
+
+	_, err := ob.msgPool.Add(ctx, entry.Message)
+	if err == nil {
+		return true, nil
+	}
+	switch errors.Cause(err) {
+	case core.ErrNonceTooLow:
+		log.Infof("forgetting mined message %s", c)
+		return false, ob.forget(c)
+	case core.ErrInvalidSignature:
+		log.Infof("forgetting invalid message %s", c)
+		return false, ob.forget(c)
+	}
+	log.Infof("message %s is not pending: %s", c, err)
+	return false, nil
+}
+
+// stored returns the stored messages by cid.
+func (ob *Outbox) stored() (map[cid.Cid]*outboxEntry, error) {
+	res, err := ob.ds.Query(query.Query{Prefix: outboxPrefix})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query sent messages")
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read sent messages")
+	}
+
+	msgs := make(map[cid.Cid]*outboxEntry, len(entries))
+	for _, e := range entries {
+		var entry outboxEntry
+		if err := cbor.DecodeInto(e.Value, &entry); err != nil {
+			return nil, errors.Wrapf(err, "failed to unmarshal sent message %s", e.Key)
+		}
+		c, err := entry.Message.Cid()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create CID")
+		}
+		msgs[c] = &entry
+	}
+	return msgs, nil
+}
+
+func (ob *Outbox) put(c cid.Cid, entry *outboxEntry) error {
+	data, err := cbor.DumpObject(entry)
+	if err != nil {
+		return errors.Wrapf(err, "failed to marshal sent message %s", c)
+	}
+	if err := ob.ds.Put(outboxKey(c), data); err != nil {
+		return errors.Wrapf(err, "failed to store message %s", c)
+	}
+	return nil
+}
+
+func (ob *Outbox) forget(c cid.Cid) error {
+	if err := ob.ds.Delete(outboxKey(c)); err != nil && err != datastore.ErrNotFound {
+		return errors.Wrapf(err, "failed to delete sent message %s", c)
+	}
+	return nil
+}
+
+func outboxKey(c cid.Cid) datastore.Key {
+	return datastore.NewKey(outboxPrefix).ChildString(c.String())
+}
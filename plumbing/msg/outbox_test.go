@@ -0,0 +1,113 @@
+package msg
+
+import (
+	"context"
+	"testing"
+
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+
+	"github.com/filecoin-project/go-filecoin/config"
+	"github.com/filecoin-project/go-filecoin/core"
+	"github.com/filecoin-project/go-filecoin/repo"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutbox(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+
+	ki := types.MustGenerateKeyInfo(1, types.GenerateKeyInfoSeed())
+	mockSigner := types.NewMockSigner(ki)
+	newSignedMessage := types.NewSignedMessageForTestGetter(mockSigner)
+	m1, m2 := newSignedMessage(), newSignedMessage()
+	m2Cid, err := m2.Cid()
+	require.NoError(err)
+
+	var published []*types.SignedMessage
+	publish := func(topic string, data []byte) error {
+		assert.Equal(Topic, topic)
+		var smsg types.SignedMessage
+		require.NoError(smsg.Unmarshal(data))
+		published = append(published, &smsg)
+		return nil
+	}
+
+	r := repo.NewInMemoryRepo()
+	pool := core.NewMessagePool()
+	ob := NewOutbox(r.Datastore(), pool, publish)
+	core.MustAdd(pool, m1, m2)
+	require.NoError(ob.Publish(m1))
+	require.NoError(ob.Publish(m2))
+	assert.Len(published, 2)
+
+	// Messages that left the pool are added back to it.
+	pool.Remove(m2Cid)
+	published = nil
+	require.NoError(ob.Rebroadcast(ctx))
+	require.Len(published, 2)
+	_, ok := pool.Get(m2Cid)
+	assert.True(ok)
+
+	// Mined messages are forgotten.
+	pool = core.NewConfiguredMessagePool(&config.MessagePoolConfig{}, minedValidator{m2Cid})
+	ob = NewOutbox(r.Datastore(), pool, publish)
+	published = nil
+	require.NoError(ob.Rebroadcast(ctx))
+	require.Len(published, 1)
+	assert.True(types.SmsgCidsEqual(m1, published[0]))
+
+	// A new pool gets back the messages that are still pending.
+	pool = core.NewMessagePool()
+	ob = NewOutbox(r.Datastore(), pool, publish)
+	require.NoError(ob.Load(ctx))
+	require.Len(pool.Pending(), 1)
+	assert.True(types.SmsgCidsEqual(m1, pool.Pending()[0]))
+
+	// Messages that keep leaving the pool are eventually forgotten.
+	m1Cid, err := m1.Cid()
+	require.NoError(err)
+	for i := 0; i < OutboxMaxAttempts; i++ {
+		pool.Remove(m1Cid)
+		require.NoError(ob.Rebroadcast(ctx))
+	}
+	pool.Remove(m1Cid)
+	published = nil
+	require.NoError(ob.Rebroadcast(ctx))
+	assert.Empty(published)
+	msgs, err := ob.stored()
+	require.NoError(err)
+	assert.Empty(msgs)
+
+	// So are messages with an invalid signature.
+	m3 := newSignedMessage()
+	m3.Signature = []byte("forged")
+	m3Cid, err := m3.Cid()
+	require.NoError(err)
+	require.NoError(ob.put(m3Cid, &outboxEntry{Message: m3}))
+	require.NoError(ob.Rebroadcast(ctx))
+	assert.Empty(published)
+	msgs, err = ob.stored()
+	require.NoError(err)
+	assert.Empty(msgs)
+}
+
+// minedValidator rejects the message with cid mined as mined.
+type minedValidator struct {
+	mined cid.Cid
+}
+
+func (v minedValidator) Validate(ctx context.Context, msg *types.SignedMessage) error {
+	c, err := msg.Cid()
+	if err != nil {
+		return err
+	}
+	if c.Equals(v.mined) {
+		return errors.Wrap(core.ErrNonceTooLow, "mined")
+	}
+	return nil
+}
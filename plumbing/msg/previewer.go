@@ -40,7 +40,11 @@ func (p *Previewer) Preview(ctx context.Context, optFrom, to address.Address, me
 	if err != nil {
 		return types.NewGasUnits(0), errors.Wrap(err, "couldnt encode message params")
 	}
+	return p.PreviewEncoded(ctx, optFrom, to, method, encodedParams)
+}
 
+// PreviewEncoded is like Preview for parameters that are already ABI encoded.
+func (p *Previewer) PreviewEncoded(ctx context.Context, optFrom, to address.Address, method string, encodedParams []byte) (types.GasUnits, error) {
 	headTs := p.chainReader.Head()
 	tsas, err := p.chainReader.GetTipSetAndState(ctx, headTs.String())
 	if err != nil {
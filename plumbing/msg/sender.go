@@ -10,6 +10,7 @@ import (
 	"github.com/filecoin-project/go-filecoin/abi"
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/config"
 	"github.com/filecoin-project/go-filecoin/core"
 	"github.com/filecoin-project/go-filecoin/repo"
 	"github.com/filecoin-project/go-filecoin/types"
@@ -32,8 +33,9 @@ type Sender struct {
 	chainReader chain.ReadStore
 	msgPool     *core.MessagePool
 
-	// To publish the new message to the network.
-	publish PublishFunc
+	// To publish the new message to the network and keep it until it is
+	// mined.
+	outbox *Outbox
 
 	// Locking in send reduces the chance of nonce collision.
 	l sync.Mutex
@@ -41,8 +43,8 @@ type Sender struct {
 
 // NewSender returns a new Sender. There should be exactly one of these per node because
 // sending locks to reduce nonce collisions.
-func NewSender(repo repo.Repo, wallet *wallet.Wallet, chainReader chain.ReadStore, msgPool *core.MessagePool, publish PublishFunc) *Sender {
-	return &Sender{repo: repo, wallet: wallet, chainReader: chainReader, msgPool: msgPool, publish: publish}
+func NewSender(repo repo.Repo, wallet *wallet.Wallet, chainReader chain.ReadStore, msgPool *core.MessagePool, outbox *Outbox) *Sender {
+	return &Sender{repo: repo, wallet: wallet, chainReader: chainReader, msgPool: msgPool, outbox: outbox}
 }
 
 // Send sends a message. See api description.
@@ -67,16 +69,11 @@ func (s *Sender) Send(ctx context.Context, from, to address.Address, value *type
 		return cid.Undef, errors.Wrap(err, "failed to sign message")
 	}
 
-	smsgdata, err := smsg.Marshal()
-	if err != nil {
-		return cid.Undef, errors.Wrap(err, "failed to marshal message")
-	}
-
-	if _, err := s.msgPool.Add(smsg); err != nil {
+	if _, err := s.msgPool.Add(ctx, smsg); err != nil {
 		return cid.Undef, errors.Wrap(err, "failed to add message to the message pool")
 	}
 
-	if err = s.publish(Topic, smsgdata); err != nil {
+	if err = s.outbox.Publish(smsg); err != nil {
 		return cid.Undef, errors.Wrap(err, "couldnt publish new message to network")
 	}
 
@@ -85,6 +82,153 @@ func (s *Sender) Send(ctx context.Context, from, to address.Address, value *type
 	return smsg.Cid()
 }
 
+// Replace replaces the pending message with cid msgCid by a copy paying
+// gasPrice, and publishes the replacement.  The replacement keeps the nonce of
+// the original so that at most one of them is included in the chain.  The
+// original must be in the message pool and sent from an address of the wallet.
+func (s *Sender) Replace(ctx context.Context, msgCid cid.Cid, gasPrice types.AttoFIL) (cid.Cid, error) {
+	old, ok := s.msgPool.Get(msgCid)
+	if !ok {
+		return cid.Undef, errors.Errorf("message %s is not in the message pool", msgCid)
+	}
+
+	smsg, err := types.NewSignedMessage(old.Message, s.wallet, gasPrice, old.GasLimit)
+	if err != nil {
+		return cid.Undef, errors.Wrap(err, "failed to sign replacement message")
+	}
+
+	c, err := s.msgPool.Add(ctx, smsg)
+	if err != nil {
+		return cid.Undef, errors.Wrap(err, "failed to add replacement message to the message pool")
+	}
+
+	if err = s.outbox.Publish(smsg); err != nil {
+		return cid.Undef, errors.Wrap(err, "couldnt publish replacement message to network")
+	}
+
+	log.Debugf("MessageReplace %s with message: %s", msgCid, smsg)
+
+	return c, nil
+}
+
+// BatchEntry is one of the messages sent by SendBatch.
+type BatchEntry struct {
+	To     address.Address `json:"to"`
+	Value  *types.AttoFIL  `json:"value"`
+	Method string          `json:"method"`
+	// Params are the ABI encoded parameters of Method.
+	Params []byte `json:"params"`
+}
+
+// SendBatch sends a message from from for each entry, with consecutive
+// nonces, and returns their cids in the order of the entries. All messages are
+// signed before any is added to the message pool. If adding or publishing a
+// message fails, the cids of the messages in the pool are returned along with
+// the error, including the message that failed to publish since it is pending;
+// the messages after it are not sent. Batches larger than the message pool
+// accepts from one sender are rejected before any message is signed.
+func (s *Sender) SendBatch(ctx context.Context, from address.Address, gasPrice types.AttoFIL, gasLimit types.GasUnits, entries []BatchEntry) ([]cid.Cid, error) {
+	if err := checkBatchSize(s.repo.Config().Mpool, len(entries)); err != nil {
+		return nil, err
+	}
+
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	nonce, err := nextNonce(ctx, s.chainReader, s.msgPool, from)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't get next nonce")
+	}
+
+	smsgs := make([]*types.SignedMessage, len(entries))
+	for i, entry := range entries {
+		value := entry.Value
+		if value == nil {
+			value = types.NewZeroAttoFIL()
+		}
+		msg := types.NewMessage(from, entry.To, nonce+uint64(i), value, entry.Method, entry.Params)
+		smsgs[i], err = types.NewSignedMessage(*msg, s.wallet, gasPrice, gasLimit)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to sign message %d", i)
+		}
+	}
+
+	cids := make([]cid.Cid, 0, len(smsgs))
+	for i, smsg := range smsgs {
+		c, err := s.msgPool.Add(ctx, smsg)
+		if err != nil {
+			return cids, errors.Wrapf(err, "failed to add message %d to the message pool", i)
+		}
+		cids = append(cids, c)
+		if err = s.outbox.Publish(smsg); err != nil {
+			return cids, errors.Wrapf(err, "couldnt publish message %d to network", i)
+		}
+	}
+
+	log.Debugf("MessageSendBatch sent %d messages from %s", len(cids), from)
+
+	return cids, nil
+}
+
+// checkBatchSize returns an error if a batch of n messages can't all be in
+// the message pool configured by cfg, so that none of them gets a nonce.
+func checkBatchSize(cfg *config.MessagePoolConfig, n int) error {
+	if cfg.MaxSenderMessages > 0 && n > cfg.MaxSenderMessages {
+		return errors.Errorf("batch of %d messages is larger than the %d messages the pool accepts from a sender", n, cfg.MaxSenderMessages)
+	}
+	if uint64(n) > cfg.MaxNonceGap {
+		return errors.Errorf("batch of %d messages is larger than the maximum nonce gap %d", n, cfg.MaxNonceGap)
+	}
+	return nil
+}
+
+// Create returns an unsigned message from from to to with the next nonce of
+// from.  The message is not added to the message pool, so that messages
+// created before the previous one is broadcast get the same nonce.
+func (s *Sender) Create(ctx context.Context, from, to address.Address, value *types.AttoFIL, gasPrice types.AttoFIL, gasLimit types.GasUnits, method string, params ...interface{}) (*types.MeteredMessage, error) {
+	encodedParams, err := abi.ToEncodedValues(params...)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid params")
+	}
+
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	nonce, err := nextNonce(ctx, s.chainReader, s.msgPool, from)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't get next nonce")
+	}
+
+	msg := types.NewMessage(from, to, nonce, value, method, encodedParams)
+	return types.NewMeteredMessage(*msg, gasPrice, gasLimit), nil
+}
+
+// Sign signs msg with the key of its sender in the wallet.
+func (s *Sender) Sign(msg *types.MeteredMessage) (*types.SignedMessage, error) {
+	smsg, err := types.NewSignedMessage(msg.Message, s.wallet, msg.GasPrice, msg.GasLimit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sign message")
+	}
+	return smsg, nil
+}
+
+// Broadcast adds smsg, signed elsewhere, to the message pool if it is valid
+// and publishes it.
+func (s *Sender) Broadcast(ctx context.Context, smsg *types.SignedMessage) (cid.Cid, error) {
+	c, err := s.msgPool.Add(ctx, smsg)
+	if err != nil {
+		return cid.Undef, errors.Wrap(err, "failed to add message to the message pool")
+	}
+
+	if err = s.outbox.Publish(smsg); err != nil {
+		return cid.Undef, errors.Wrap(err, "couldnt publish message to network")
+	}
+
+	log.Debugf("MessageBroadcast with message: %s", smsg)
+
+	return c, nil
+}
+
 // nextNonce returns the next nonce for the given address. It checks
 // the actor's memory and also scans the message pool for any pending
 // messages.
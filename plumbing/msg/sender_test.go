@@ -7,6 +7,8 @@ import (
 
 	"testing"
 
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+
 	"github.com/filecoin-project/go-filecoin/address"
 	"github.com/filecoin-project/go-filecoin/chain"
 	"github.com/filecoin-project/go-filecoin/core"
@@ -35,7 +37,7 @@ func TestSend(t *testing.T) {
 			return nil
 		}
 
-		s := NewSender(repo, w, chainStore, msgPool, publish)
+		s := NewSender(repo, w, chainStore, msgPool, NewOutbox(repo.Datastore(), msgPool, publish))
 		require.Equal(0, len(msgPool.Pending()))
 		_, err = s.Send(context.Background(), addr, addr, types.NewAttoFILFromFIL(uint64(2)), types.NewGasPrice(0), types.NewGasUnits(0), "")
 		require.NoError(err)
@@ -52,7 +54,7 @@ func TestSend(t *testing.T) {
 		addr, err := wallet.NewAddress(w)
 		require.NoError(err)
 		nopPublish := func(string, []byte) error { return nil }
-		s := NewSender(repo, w, chainStore, msgPool, nopPublish)
+		s := NewSender(repo, w, chainStore, msgPool, NewOutbox(repo.Datastore(), msgPool, nopPublish))
 
 		var wg sync.WaitGroup
 		addTwentyMessages := func(batch int) {
@@ -82,6 +84,129 @@ func TestSend(t *testing.T) {
 		}
 	})
 
+	t.Run("send batch assigns consecutive nonces", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+		ctx := context.Background()
+
+		repo, w, chainStore, msgPool := setupSendTest(require)
+		addr, err := wallet.NewAddress(w)
+		require.NoError(err)
+		published := 0
+		publish := func(string, []byte) error {
+			published++
+			return nil
+		}
+		s := NewSender(repo, w, chainStore, msgPool, NewOutbox(repo.Datastore(), msgPool, publish))
+
+		_, err = s.Send(ctx, addr, addr, types.NewZeroAttoFIL(), types.NewGasPrice(0), types.NewGasUnits(0), "")
+		require.NoError(err)
+
+		entries := []BatchEntry{
+			{To: addr, Value: types.NewAttoFILFromFIL(1)},
+			{To: addr},
+			{To: addr, Value: types.NewAttoFILFromFIL(3), Method: "foo"},
+		}
+		cids, err := s.SendBatch(ctx, addr, types.NewGasPrice(1), types.NewGasUnits(300), entries)
+		require.NoError(err)
+		require.Len(cids, 3)
+		assert.Equal(4, published)
+
+		for i, c := range cids {
+			smsg, ok := msgPool.Get(c)
+			require.True(ok)
+			assert.Equal(uint64(i+1), uint64(smsg.Nonce))
+			assert.Equal(entries[i].Method, smsg.Method)
+			assert.True(smsg.VerifySignature())
+		}
+		second, _ := msgPool.Get(cids[1])
+		assert.True(second.Value.IsZero())
+	})
+
+	t.Run("send batch returns the message that failed to publish", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+		ctx := context.Background()
+
+		repo, w, chainStore, msgPool := setupSendTest(require)
+		addr, err := wallet.NewAddress(w)
+		require.NoError(err)
+		publish := func(string, []byte) error {
+			return errors.New("no network")
+		}
+		s := NewSender(repo, w, chainStore, msgPool, NewOutbox(repo.Datastore(), msgPool, publish))
+
+		cids, err := s.SendBatch(ctx, addr, types.NewGasPrice(1), types.NewGasUnits(300), []BatchEntry{{To: addr}, {To: addr}})
+		require.Error(err)
+		require.Len(cids, 1)
+		_, ok := msgPool.Get(cids[0])
+		assert.True(ok)
+	})
+
+	t.Run("send batch rejects batches the pool can't hold", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+		ctx := context.Background()
+
+		repo, w, chainStore, msgPool := setupSendTest(require)
+		addr, err := wallet.NewAddress(w)
+		require.NoError(err)
+		nopPublish := func(string, []byte) error { return nil }
+		s := NewSender(repo, w, chainStore, msgPool, NewOutbox(repo.Datastore(), msgPool, nopPublish))
+
+		entries := make([]BatchEntry, repo.Config().Mpool.MaxNonceGap+1)
+		for i := range entries {
+			entries[i].To = addr
+		}
+		cids, err := s.SendBatch(ctx, addr, types.NewGasPrice(1), types.NewGasUnits(300), entries)
+		require.Error(err)
+		assert.Contains(err.Error(), "maximum nonce gap")
+		assert.Len(cids, 0)
+		assert.Len(msgPool.Pending(), 0)
+
+		repo.Config().Mpool.MaxSenderMessages = 2
+		_, err = s.SendBatch(ctx, addr, types.NewGasPrice(1), types.NewGasUnits(300), entries[:3])
+		require.Error(err)
+		assert.Contains(err.Error(), "pool accepts from a sender")
+		assert.Len(msgPool.Pending(), 0)
+	})
+}
+
+func TestCreateSignBroadcast(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+
+	repo, w, chainStore, msgPool := setupSendTest(require)
+	addr, err := wallet.NewAddress(w)
+	require.NoError(err)
+	publishCalled := false
+	publish := func(topic string, data []byte) error {
+		publishCalled = true
+		return nil
+	}
+	s := NewSender(repo, w, chainStore, msgPool, NewOutbox(repo.Datastore(), msgPool, publish))
+
+	msg, err := s.Create(ctx, addr, addr, types.NewAttoFILFromFIL(2), types.NewGasPrice(1), types.NewGasUnits(300), "")
+	require.NoError(err)
+	assert.Equal(types.NewGasUnits(300), msg.GasLimit)
+	assert.Len(msgPool.Pending(), 0)
+
+	smsg, err := s.Sign(msg)
+	require.NoError(err)
+	assert.True(smsg.VerifySignature())
+
+	c, err := s.Broadcast(ctx, smsg)
+	require.NoError(err)
+	assert.True(publishCalled)
+	_, ok := msgPool.Get(c)
+	assert.True(ok)
+
+	// A tampered message is rejected.
+	smsg.Nonce++
+	_, err = s.Broadcast(ctx, smsg)
+	assert.Error(err)
 }
 
 func TestNextNonce(t *testing.T) {
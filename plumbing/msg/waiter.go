@@ -36,6 +36,21 @@ func NewWaiter(chainStore chain.ReadStore, bs bstore.Blockstore, cst *hamt.CborI
 	}
 }
 
+// ChainMessage is a message on chain along with its location and receipt.
+type ChainMessage struct {
+	Message  *types.SignedMessage
+	Location *chain.MessageLocation
+	Receipt  *types.MessageReceipt
+}
+
+// Find returns the message with the given cid if it is in the chain ending in
+// the head, or chain.ErrMessageNotFound.  It looks the message up in the
+// chain's message index and does not walk the chain.
+func (w *Waiter) Find(ctx context.Context, msgCid cid.Cid) (*ChainMessage, error) {
+	_, found, err := w.find(ctx, msgCid)
+	return found, err
+}
+
 // Wait invokes the callback when a message with the given cid appears on chain.
 // See api description.
 //
@@ -44,78 +59,77 @@ func NewWaiter(chainStore chain.ReadStore, bs bstore.Blockstore, cst *hamt.CborI
 // if in fact that's what it wants to do, using something like receiptFromTipset.
 // Something like receiptFromTipset is necessary because not every message in
 // a block will have a receipt in the tipset: it might be a duplicate message.
-//
-// TODO: This implementation will become prohibitively expensive since it
-// traverses the entire chain. We should use an index instead.
-// https://github.com/filecoin-project/go-filecoin/issues/1518
 func (w *Waiter) Wait(ctx context.Context, msgCid cid.Cid, cb func(*types.Block, *types.SignedMessage, *types.MessageReceipt) error) error {
 	ctx = log.Start(ctx, "Waiter.Wait")
 	defer log.Finish(ctx)
 	log.Infof("Calling Waiter.Wait CID: %s", msgCid.String())
-	// Ch will contain a stream of blocks to check for message (or errors).
-	// Blocks are either in new heaviest tipsets, or next oldest historical blocks.
-	ch := make(chan (interface{}))
 
-	// New blocks
+	// Subscribe before looking up the message so that it is not missed if
+	// it lands on chain in between.
 	newHeadCh := w.chainReader.HeadEvents().Sub(chain.NewHeadTopic)
-	defer w.chainReader.HeadEvents().Unsub(newHeadCh, chain.NewHeadTopic)
-
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
-
-	// Historical blocks
-	historyCh := w.chainReader.BlockHistory(ctx, w.chainReader.Head())
+	defer func() {
+		// Drain the channel so that unsubscribing doesn't block a publisher.
+		go func() {
+			for range newHeadCh {
+			}
+		}()
+		w.chainReader.HeadEvents().Unsub(newHeadCh, chain.NewHeadTopic)
+	}()
 
-	// Merge historical and new block Channels.
-	go func() {
-		for raw := range newHeadCh {
-			ch <- raw
+	for {
+		blk, found, err := w.find(ctx, msgCid)
+		if err == nil {
+			return cb(blk, found.Message, found.Receipt)
 		}
-	}()
-	go func() {
-		for raw := range historyCh {
-			ch <- raw
+		if err != chain.ErrMessageNotFound {
+			log.Errorf("Waiter.Wait: %s", err)
+			return err
 		}
-	}()
 
-	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case raw, more := <-ch:
+		case raw, more := <-newHeadCh:
 			if !more {
 				return errors.New("wait input channel closed without finding message")
 			}
-			switch raw.(type) { // nolint: staticcheck
-			case error:
-				e := raw.(error)
+			if e, ok := raw.(error); ok {
 				log.Errorf("Waiter.Wait: %s", e)
 				return e
-			case types.TipSet:
-				ts := raw.(types.TipSet)
-				for _, blk := range ts {
-					for _, msg := range blk.Messages {
-						c, err := msg.Cid()
-						if err != nil {
-							log.Errorf("Waiter.Wait: %s", err)
-							return err
-						}
-						if c.Equals(msgCid) {
-							recpt, err := w.receiptFromTipSet(ctx, msgCid, ts)
-							if err != nil {
-								return errors.Wrap(err, "error retrieving receipt from tipset")
-							}
-							return cb(blk, msg, recpt)
-						}
-					}
-				}
-			default:
-				return fmt.Errorf("unexpected type in channel: %T", raw)
 			}
 		}
 	}
 }
 
+// find looks up the message with msgCid in the chain's message index and
+// returns it along with the block containing it.
+func (w *Waiter) find(ctx context.Context, msgCid cid.Cid) (*types.Block, *ChainMessage, error) {
+	loc, err := w.chainReader.GetMessageLocation(ctx, msgCid)
+	if err != nil {
+		return nil, nil, err
+	}
+	tsas, err := w.chainReader.GetTipSetAndState(ctx, loc.TipSet.String())
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to get tipset %s", loc.TipSet.String())
+	}
+	blk, ok := tsas.TipSet[loc.Block.String()]
+	if !ok || loc.Index >= len(blk.Messages) {
+		return nil, nil, fmt.Errorf("message %s not found at its indexed location", msgCid)
+	}
+	recpt := loc.Receipt
+	if !loc.HasReceipt {
+		recpt, err = w.receiptFromTipSet(ctx, msgCid, tsas.TipSet)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "error retrieving receipt from tipset")
+		}
+	}
+	return blk, &ChainMessage{
+		Message:  blk.Messages[loc.Index],
+		Location: loc,
+		Receipt:  recpt,
+	}, nil
+}
+
 // receiptFromTipSet finds the receipt for the message with msgCid in the
 // input tipset.  This can differ from the message's receipt as stored in its
 // parent block in the case that the message is in conflict with another
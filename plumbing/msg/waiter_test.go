@@ -104,24 +104,60 @@ func testWaitNew(ctx context.Context, assert *assert.Assertions, require *requir
 	wg.Wait()
 }
 
-func TestWaitError(t *testing.T) {
+func TestFind(t *testing.T) {
 	t.Parallel()
 	assert := assert.New(t)
 	require := require.New(t)
 	ctx := context.Background()
 	cst, chainStore, waiter := setupTest(require)
 
-	testWaitError(ctx, assert, require, cst, chainStore, waiter)
+	m1, m2, m3 := newSignedMessage(), newSignedMessage(), newSignedMessage()
+	chainWithMsgs := core.NewChainWithMessages(cst, chainStore.Head(), smsgsSet{smsgs{m1, m2}})
+	ts := chainWithMsgs[len(chainWithMsgs)-1]
+	chain.RequirePutTsas(ctx, require, chainStore, &chain.TipSetAndState{
+		TipSet:          ts,
+		TipSetStateRoot: ts.ToSlice()[0].StateRoot,
+	})
+	require.NoError(chainStore.SetHead(ctx, ts))
+
+	m2Cid, err := m2.Cid()
+	require.NoError(err)
+	found, err := waiter.Find(ctx, m2Cid)
+	require.NoError(err)
+	assert.True(types.SmsgCidsEqual(m2, found.Message))
+	assert.True(ts.ToSortedCidSet().Equals(found.Location.TipSet))
+	assert.Equal(ts.ToSlice()[0].Cid(), found.Location.Block)
+	assert.Equal(1, found.Location.Index)
+
+	m3Cid, err := m3.Cid()
+	require.NoError(err)
+	_, err = waiter.Find(ctx, m3Cid)
+	assert.Equal(chain.ErrMessageNotFound, err)
 }
 
-func testWaitError(ctx context.Context, assert *assert.Assertions, require *require.Assertions, cst *hamt.CborIpldStore, chainStore *chain.DefaultStore, waiter *Waiter) {
+func TestFindUnknownAncestor(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+	cst, chainStore, waiter := setupTest(require)
+
 	m1, m2, m3, m4 := newSignedMessage(), newSignedMessage(), newSignedMessage(), newSignedMessage()
-	chain := core.NewChainWithMessages(cst, chainStore.Head(), smsgsSet{smsgs{m1, m2}}, smsgsSet{smsgs{m3, m4}})
+	chainWithMsgs := core.NewChainWithMessages(cst, chainStore.Head(), smsgsSet{smsgs{m1, m2}}, smsgsSet{smsgs{m3, m4}})
 	// set the head without putting the ancestor block in the chainStore.
-	err := chainStore.SetHead(ctx, chain[len(chain)-1])
-	assert.Nil(err)
+	head := chainWithMsgs[len(chainWithMsgs)-1]
+	chain.RequirePutTsas(ctx, require, chainStore, &chain.TipSetAndState{
+		TipSet:          head,
+		TipSetStateRoot: head.ToSlice()[0].StateRoot,
+	})
+	require.NoError(chainStore.SetHead(ctx, head))
+
+	m2Cid, err := m2.Cid()
+	require.NoError(err)
+	_, err = waiter.Find(ctx, m2Cid)
+	assert.Equal(chain.ErrMessageNotFound, err)
 
-	testWaitHelp(nil, assert, waiter, m2, true, nil)
+	testWaitHelp(nil, assert, waiter, m4, false, nil)
 }
 
 func TestWaitConflicting(t *testing.T) {
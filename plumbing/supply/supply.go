@@ -0,0 +1,90 @@
+package supply
+
+import (
+	"context"
+
+	bstore "gx/ipfs/QmS2aqUZLJp8kF1ihE5rvDGE5LvmKDPnx32w9Z1BW9xLV5/go-ipfs-blockstore"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+
+	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/filecoin-project/go-filecoin/vm"
+)
+
+// Supply is how the FIL of the state of the head of the chain is split.
+type Supply struct {
+	// Total is all the FIL there is, emitted or not.
+	Total *types.AttoFIL
+	// Unemitted is what the network actor has left to pay block rewards.
+	Unemitted *types.AttoFIL
+	// Circulating is what accounts, and actors without code, hold.
+	Circulating *types.AttoFIL
+	// Locked is what other actors hold: the collateral of miners and the
+	// funds of the storage market, payment channels and the like.
+	Locked *types.AttoFIL
+	// Burnt is what was sent to address.BurntFundsAddress, out of
+	// circulation for good.
+	Burnt *types.AttoFIL
+}
+
+// Tracker reads the FIL supply and the block rewards from the chain.
+type Tracker struct {
+	chainReader chain.ReadStore
+	bs          bstore.Blockstore
+}
+
+// NewTracker returns a new Tracker.
+func NewTracker(chainReader chain.ReadStore, bs bstore.Blockstore) *Tracker {
+	return &Tracker{chainReader: chainReader, bs: bs}
+}
+
+// Supply returns the FIL supply of the state of the head.
+func (t *Tracker) Supply(ctx context.Context) (*Supply, error) {
+	st, err := t.chainReader.LatestState(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get the state of the head")
+	}
+
+	s := &Supply{
+		Total:       types.NewZeroAttoFIL(),
+		Unemitted:   types.NewZeroAttoFIL(),
+		Circulating: types.NewZeroAttoFIL(),
+		Locked:      types.NewZeroAttoFIL(),
+		Burnt:       types.NewZeroAttoFIL(),
+	}
+	err = st.ForEachActor(ctx, func(addr address.Address, act *actor.Actor) error {
+		balance := act.Balance
+		if balance == nil {
+			return nil
+		}
+		s.Total = s.Total.Add(balance)
+		switch {
+		case addr == address.NetworkAddress:
+			s.Unemitted = s.Unemitted.Add(balance)
+		case addr == address.BurntFundsAddress:
+			s.Burnt = s.Burnt.Add(balance)
+		case !act.Code.Defined() || act.Code.Equals(types.AccountActorCodeCid):
+			s.Circulating = s.Circulating.Add(balance)
+		default:
+			s.Locked = s.Locked.Add(balance)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to walk the actors")
+	}
+	return s, nil
+}
+
+// BlockReward returns the reward of the blocks at height h according to the
+// reward schedule of the chain.
+func (t *Tracker) BlockReward(ctx context.Context, h uint64) (*types.AttoFIL, error) {
+	st, err := t.chainReader.LatestState(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get the state of the head")
+	}
+	return consensus.BlockRewardAt(ctx, st, vm.NewStorageMap(t.bs), types.NewBlockHeight(h))
+}
@@ -0,0 +1,82 @@
+package supply
+
+import (
+	"context"
+	"testing"
+
+	hamt "gx/ipfs/QmRXf2uUSdGSunRJsM9wXSUNVwLUGCY3So5fAs7h2CBJVf/go-hamt-ipld"
+	bstore "gx/ipfs/QmS2aqUZLJp8kF1ihE5rvDGE5LvmKDPnx32w9Z1BW9xLV5/go-ipfs-blockstore"
+	bserv "gx/ipfs/QmYPZzd9VqmJDwxUnThfeSbV1Y5o53aVPDijTB7j7rS9Ep/go-blockservice"
+	offline "gx/ipfs/QmYZwey1thDTynSrvd6qQkX24UpTka6TFhQ2v569UpoqxD/go-ipfs-exchange-offline"
+
+	"github.com/filecoin-project/go-filecoin/actor"
+	"github.com/filecoin-project/go-filecoin/actor/builtin/reward"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/consensus"
+	"github.com/filecoin-project/go-filecoin/repo"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func requireTracker(require *require.Assertions, opts ...consensus.GenOption) *Tracker {
+	r := repo.NewInMemoryRepo()
+	bs := bstore.NewBlockstore(r.Datastore())
+	cst := &hamt.CborIpldStore{Blocks: bserv.New(bs, offline.Exchange(bs))}
+	chainStore, err := chain.Init(context.Background(), r, bs, cst, consensus.MakeGenesisFunc(opts...))
+	require.NoError(err)
+	return NewTracker(chainStore, bs)
+}
+
+func TestSupply(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	require := require.New(t)
+
+	minerAddr := address.NewForTestGetter()()
+	tracker := requireTracker(require,
+		consensus.ActorAccount(address.BurntFundsAddress, types.NewAttoFILFromFIL(7)),
+		consensus.AddActor(minerAddr, actor.NewActor(types.MinerActorCodeCid, types.NewAttoFILFromFIL(5))),
+	)
+
+	s, err := tracker.Supply(context.Background())
+	require.NoError(err)
+	assert.True(types.NewAttoFILFromFIL(10000000000).Equal(s.Unemitted))
+	assert.True(types.NewAttoFILFromFIL(50000 + 60000).Equal(s.Circulating))
+	assert.True(types.NewAttoFILFromFIL(5).Equal(s.Locked))
+	assert.True(types.NewAttoFILFromFIL(7).Equal(s.Burnt))
+	assert.True(types.NewAttoFILFromFIL(10000000000 + 50000 + 60000 + 5 + 7).Equal(s.Total))
+}
+
+func TestBlockReward(t *testing.T) {
+	t.Parallel()
+
+	t.Run("follows the reward schedule of the chain", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		tracker := requireTracker(require, consensus.RewardSchedule(&reward.Schedule{
+			InitialReward: types.NewAttoFILFromFIL(1000),
+			DecayPercent:  50,
+			DecayInterval: 100,
+		}))
+
+		r, err := tracker.BlockReward(context.Background(), 0)
+		require.NoError(err)
+		assert.True(types.NewAttoFILFromFIL(1000).Equal(r))
+
+		r, err = tracker.BlockReward(context.Background(), 250)
+		require.NoError(err)
+		assert.True(types.NewAttoFILFromFIL(250).Equal(r))
+	})
+
+	t.Run("is flat by default", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		r, err := requireTracker(require).BlockReward(context.Background(), 1000000)
+		require.NoError(err)
+		assert.True(reward.DefaultInitialReward.Equal(r))
+	})
+}
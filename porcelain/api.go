@@ -9,6 +9,7 @@ import (
 
 	minerActor "github.com/filecoin-project/go-filecoin/actor/builtin/miner"
 	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/config"
 	"github.com/filecoin-project/go-filecoin/plumbing"
 	"github.com/filecoin-project/go-filecoin/types"
 )
@@ -51,6 +52,17 @@ func (a *API) CreatePayments(ctx context.Context, config CreatePaymentsParams) (
 	return CreatePayments(ctx, a, config)
 }
 
+// DealPolicyGet returns the policy the miners of the node apply to the deals
+// proposed to them
+func (a *API) DealPolicyGet() (*config.DealPolicyConfig, error) {
+	return DealPolicyGet(a)
+}
+
+// DealPolicyUpdate changes the deal policy of the node with update and saves it
+func (a *API) DealPolicyUpdate(update func(*config.DealPolicyConfig)) (*config.DealPolicyConfig, error) {
+	return DealPolicyUpdate(a, update)
+}
+
 // MessageSendWithDefaultAddress calls MessageSend but with a default from
 // address if none is provided
 func (a *API) MessageSendWithDefaultAddress(
@@ -76,6 +88,17 @@ func (a *API) MessageSendWithDefaultAddress(
 	)
 }
 
+// MiningHistory returns the last rounds mined by the node and the fate of
+// their blocks
+func (a *API) MiningHistory(ctx context.Context) ([]MiningRound, error) {
+	return MiningHistory(ctx, a)
+}
+
+// MiningStatus sums up the last rounds mined by the node
+func (a *API) MiningStatus(ctx context.Context) (*MiningStatus, error) {
+	return MiningStatus(ctx, a)
+}
+
 // MinerPreviewCreate previews the Gas cost of creating a miner
 func (a *API) MinerPreviewCreate(
 	ctx context.Context,
@@ -0,0 +1,59 @@
+package porcelain
+
+import (
+	"encoding/json"
+
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+
+	"github.com/filecoin-project/go-filecoin/config"
+)
+
+// dpPlumbing is the subset of the plumbing.API that DealPolicyGet and
+// DealPolicyUpdate use.
+type dpPlumbing interface {
+	ConfigGet(dottedPath string) (interface{}, error)
+	ConfigSet(dottedPath string, paramJSON string) error
+}
+
+// DealPolicyGet returns a copy of the policy the miners of the node apply to
+// the deals proposed to them.
+func DealPolicyGet(plumbing dpPlumbing) (*config.DealPolicyConfig, error) {
+	raw, err := plumbing.ConfigGet("mining.dealPolicy")
+	if err != nil {
+		return nil, err
+	}
+	policy, ok := raw.(*config.DealPolicyConfig)
+	if !ok {
+		return nil, errors.New("could not retrieve dealPolicy from config")
+	}
+
+	// copy the policy so that callers do not change the config in place
+	var res config.DealPolicyConfig
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// DealPolicyUpdate applies update to the deal policy of the node, saves it in
+// the config and returns it.
+func DealPolicyUpdate(plumbing dpPlumbing, update func(*config.DealPolicyConfig)) (*config.DealPolicyConfig, error) {
+	policy, err := DealPolicyGet(plumbing)
+	if err != nil {
+		return nil, err
+	}
+	update(policy)
+
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return nil, err
+	}
+	if err := plumbing.ConfigSet("mining.dealPolicy", string(data)); err != nil {
+		return nil, errors.Wrap(err, "failed to save the deal policy")
+	}
+	return policy, nil
+}
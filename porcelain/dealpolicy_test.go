@@ -0,0 +1,58 @@
+package porcelain
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/config"
+	"github.com/filecoin-project/go-filecoin/plumbing/cfg"
+	"github.com/filecoin-project/go-filecoin/repo"
+	"github.com/filecoin-project/go-filecoin/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type dealPolicyPlumbing struct {
+	config *cfg.Config
+}
+
+func (dpp *dealPolicyPlumbing) ConfigGet(dottedPath string) (interface{}, error) {
+	return dpp.config.Get(dottedPath)
+}
+
+func (dpp *dealPolicyPlumbing) ConfigSet(dottedPath string, paramJSON string) error {
+	return dpp.config.Set(dottedPath, paramJSON)
+}
+
+func TestDealPolicyUpdate(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	plumbing := &dealPolicyPlumbing{config: cfg.NewConfig(repo.NewInMemoryRepo())}
+
+	policy, err := DealPolicyGet(plumbing)
+	require.NoError(err)
+	assert.Equal(uint64(0), policy.MinPieceSize)
+	assert.Empty(policy.BlockedClients)
+
+	// changing the returned policy does not change the config
+	policy.MinPieceSize = 10
+	policy, err = DealPolicyGet(plumbing)
+	require.NoError(err)
+	assert.Equal(uint64(0), policy.MinPieceSize)
+
+	updated, err := DealPolicyUpdate(plumbing, func(p *config.DealPolicyConfig) {
+		p.MinDuration = 5
+		p.MinPrice = types.NewAttoFILFromFIL(2)
+		p.BlockedClients = append(p.BlockedClients, address.TestAddress)
+	})
+	require.NoError(err)
+	assert.Equal(uint64(5), updated.MinDuration)
+
+	policy, err = DealPolicyGet(plumbing)
+	require.NoError(err)
+	assert.Equal(uint64(5), policy.MinDuration)
+	assert.Equal(types.NewAttoFILFromFIL(2), policy.MinPrice)
+	assert.Equal([]address.Address{address.TestAddress}, policy.BlockedClients)
+}
@@ -3,7 +3,6 @@ package porcelain
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"math/big"
 
 	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
@@ -21,7 +20,6 @@ import (
 
 // mpcAPI is the subset of the plumbing.API that MinerPreviewCreate uses.
 type mpcAPI interface {
-	ConfigGet(dottedPath string) (interface{}, error)
 	GetAndMaybeSetDefaultSenderAddress() (address.Address, error)
 	MessagePreview(ctx context.Context, from, to address.Address, method string, params ...interface{}) (types.GasUnits, error)
 	NetworkGetPeerID() peer.ID
@@ -48,10 +46,6 @@ func MinerPreviewCreate(
 		pid = plumbing.NetworkGetPeerID()
 	}
 
-	if _, err := plumbing.ConfigGet("mining.minerAddress"); err != nil {
-		return types.NewGasUnits(0), fmt.Errorf("can only have one miner per node")
-	}
-
 	ctx = log.Start(ctx, "Node.CreateMiner")
 	defer func() {
 		log.FinishWithErr(ctx, err)
@@ -125,11 +119,8 @@ func MinerSetPrice(ctx context.Context, plumbing mspAPI, from address.Address, m
 	res.MinerAddr = miner
 
 	// set price
-	jsonPrice, err := json.Marshal(price)
+	err := setMinerStoragePrice(plumbing, miner, price)
 	if err != nil {
-		return res, errors.New("Could not marshal price")
-	}
-	if err := plumbing.ConfigSet("mining.storagePrice", string(jsonPrice)); err != nil {
 		return res, err
 	}
 
@@ -175,11 +166,7 @@ func MinerPreviewSetPrice(ctx context.Context, plumbing mpspAPI, from address.Ad
 	}
 
 	// set price
-	jsonPrice, err := json.Marshal(price)
-	if err != nil {
-		return types.NewGasUnits(0), errors.New("Could not marshal price")
-	}
-	if err := plumbing.ConfigSet("mining.storagePrice", string(jsonPrice)); err != nil {
+	if err := setMinerStoragePrice(plumbing, miner, price); err != nil {
 		return types.NewGasUnits(0), err
 	}
 
@@ -199,6 +186,21 @@ func MinerPreviewSetPrice(ctx context.Context, plumbing mpspAPI, from address.Ad
 	return usedGas, nil
 }
 
+// smspAPI is the subset of the plumbing.API that setMinerStoragePrice uses.
+type smspAPI interface {
+	ConfigSet(dottedKey string, jsonString string) error
+}
+
+// setMinerStoragePrice sets the storage price of miner in the config, leaving
+// the prices of the other miners of the node as they are.
+func setMinerStoragePrice(plumbing smspAPI, miner address.Address, price *types.AttoFIL) error {
+	jsonPrices, err := json.Marshal(map[string]*types.AttoFIL{miner.String(): price})
+	if err != nil {
+		return errors.New("Could not marshal price")
+	}
+	return plumbing.ConfigSet("mining.storagePrices", string(jsonPrices))
+}
+
 // mgoaAPI is the subset of the plumbing.API that MinerGetOwnerAddress uses.
 type mgoaAPI interface {
 	MessageQuery(ctx context.Context, optFrom, to address.Address, method string, params ...interface{}) ([][]byte, *exec.FunctionSignature, error)
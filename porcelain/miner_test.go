@@ -182,13 +182,17 @@ func TestMinerSetPrice(t *testing.T) {
 
 		ctx := context.Background()
 		price := types.NewAttoFILFromFIL(50)
-		_, err := MinerSetPrice(ctx, plumbing, address.Address{}, address.Address{}, types.NewGasPrice(0), types.NewGasUnits(0), price, big.NewInt(0))
+		minerAddr, otherAddr := address.NewForTestGetter()(), address.TestAddress
+		otherPrice := types.NewAttoFILFromFIL(20)
+		_, err := MinerSetPrice(ctx, plumbing, address.Address{}, otherAddr, types.NewGasPrice(0), types.NewGasUnits(0), otherPrice, big.NewInt(0))
 		require.NoError(err)
-
-		configPrice, err := plumbing.config.Get("mining.storagePrice")
+		_, err = MinerSetPrice(ctx, plumbing, address.Address{}, minerAddr, types.NewGasPrice(0), types.NewGasUnits(0), price, big.NewInt(0))
 		require.NoError(err)
 
-		assert.Equal(price, configPrice)
+		// each miner has its own price
+		configPrices, err := plumbing.config.Get("mining.storagePrices")
+		require.NoError(err)
+		assert.Equal(map[string]*types.AttoFIL{minerAddr.String(): price, otherAddr.String(): otherPrice}, configPrices)
 	})
 
 	t.Run("saves config and reports error when send fails", func(t *testing.T) {
@@ -204,10 +208,10 @@ func TestMinerSetPrice(t *testing.T) {
 		require.Error(err)
 		assert.Contains(err.Error(), "Test error in MessageSend")
 
-		configPrice, err := plumbing.config.Get("mining.storagePrice")
+		configPrices, err := plumbing.config.Get("mining.storagePrices")
 		require.NoError(err)
 
-		assert.Equal(price, configPrice)
+		assert.Equal(price, configPrices.(map[string]*types.AttoFIL)[address.Address{}.String()])
 	})
 
 	t.Run("sends ask to specific miner when miner is given", func(t *testing.T) {
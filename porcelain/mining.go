@@ -0,0 +1,121 @@
+package porcelain
+
+import (
+	"context"
+
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+
+	"github.com/filecoin-project/go-filecoin/mining"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// mhPlumbing is the subset of the plumbing.API that MiningHistory and
+// MiningStatus use.
+type mhPlumbing interface {
+	BlockGet(ctx context.Context, id cid.Cid) (*types.Block, error)
+	ChainBlockReward(ctx context.Context, h uint64) (*types.AttoFIL, error)
+	ChainGetTipSetByHeight(ctx context.Context, h uint64) (types.TipSet, error)
+	ChainHead(ctx context.Context) types.TipSet
+	MiningRounds() []mining.Round
+}
+
+// MiningRound is a mining round of the node and the fate of the block mined
+// in it.
+type MiningRound struct {
+	mining.Round
+	// Canonical is true if the block mined in the round is in the chain
+	// ending in the head.
+	Canonical bool
+	// Reward is what the miner earned with the block if it is canonical: the
+	// block reward and the gas paid by its messages.
+	Reward *types.AttoFIL `json:",omitempty"`
+}
+
+// MiningStatus sums up the rounds mined by the node since it started.
+type MiningStatus struct {
+	Rounds int
+	// Won is the number of rounds in which the node won a block it
+	// published.
+	Won int
+	// Canonical is the number of blocks won that are in the chain ending in
+	// the head, the others were orphaned.
+	Canonical int
+	Rewards   *types.AttoFIL
+	// Last is the last round, if any.
+	Last *MiningRound `json:",omitempty"`
+}
+
+// MiningHistory returns the last rounds mined by the node, oldest first,
+// with whether their blocks are in the chain and the rewards they earned.
+// Blocks above the head, for instance mined while the node is behind, are not
+// canonical yet.
+func MiningHistory(ctx context.Context, plumbing mhPlumbing) ([]MiningRound, error) {
+	headHeight, err := plumbing.ChainHead(ctx).Height()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get the height of the head")
+	}
+
+	rounds := plumbing.MiningRounds()
+	res := make([]MiningRound, len(rounds))
+	for i, round := range rounds {
+		res[i].Round = round
+		if round.Block == nil || round.Height > headHeight {
+			continue
+		}
+
+		ts, err := plumbing.ChainGetTipSetByHeight(ctx, round.Height)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get tipset at height %d", round.Height)
+		}
+		if !ts.ToSortedCidSet().Has(*round.Block) {
+			continue
+		}
+		res[i].Canonical = true
+
+		blk, err := plumbing.BlockGet(ctx, *round.Block)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get block %s", round.Block)
+		}
+		reward, err := plumbing.ChainBlockReward(ctx, uint64(blk.Height))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get the block reward at height %d", blk.Height)
+		}
+		res[i].Reward = blockReward(blk, reward)
+	}
+	return res, nil
+}
+
+// MiningStatus sums up the rounds returned by MiningHistory.
+func MiningStatus(ctx context.Context, plumbing mhPlumbing) (*MiningStatus, error) {
+	rounds, err := MiningHistory(ctx, plumbing)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &MiningStatus{Rounds: len(rounds), Rewards: types.NewZeroAttoFIL()}
+	for i := range rounds {
+		if rounds[i].Won && !rounds[i].Superseded {
+			status.Won++
+		}
+		if rounds[i].Canonical {
+			status.Canonical++
+			status.Rewards = status.Rewards.Add(rounds[i].Reward)
+		}
+	}
+	if len(rounds) > 0 {
+		status.Last = &rounds[len(rounds)-1]
+	}
+	return status, nil
+}
+
+// blockReward returns what the miner of blk earned with it, given the block
+// reward of its height.
+func blockReward(blk *types.Block, reward *types.AttoFIL) *types.AttoFIL {
+	for _, receipt := range blk.MessageReceipts {
+		if receipt.GasAttoFIL != nil {
+			reward = reward.Add(receipt.GasAttoFIL)
+		}
+	}
+	return reward
+}
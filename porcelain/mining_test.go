@@ -0,0 +1,97 @@
+package porcelain_test
+
+import (
+	"context"
+	"testing"
+
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+
+	"github.com/filecoin-project/go-filecoin/mining"
+	"github.com/filecoin-project/go-filecoin/porcelain"
+	th "github.com/filecoin-project/go-filecoin/testhelpers"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeMiningHistoryPlumbing struct {
+	rounds []mining.Round
+	chain  map[uint64]types.TipSet
+}
+
+func (fmhp *fakeMiningHistoryPlumbing) BlockGet(ctx context.Context, id cid.Cid) (*types.Block, error) {
+	for _, ts := range fmhp.chain {
+		for _, blk := range ts {
+			if blk.Cid().Equals(id) {
+				return blk, nil
+			}
+		}
+	}
+	return nil, errors.New("block not found")
+}
+
+func (fmhp *fakeMiningHistoryPlumbing) ChainBlockReward(ctx context.Context, h uint64) (*types.AttoFIL, error) {
+	return types.NewAttoFILFromFIL(500), nil
+}
+
+func (fmhp *fakeMiningHistoryPlumbing) ChainGetTipSetByHeight(ctx context.Context, h uint64) (types.TipSet, error) {
+	return fmhp.chain[h], nil
+}
+
+func (fmhp *fakeMiningHistoryPlumbing) ChainHead(ctx context.Context) types.TipSet {
+	var head uint64
+	for h := range fmhp.chain {
+		if h > head {
+			head = h
+		}
+	}
+	return fmhp.chain[head]
+}
+
+func (fmhp *fakeMiningHistoryPlumbing) MiningRounds() []mining.Round {
+	return fmhp.rounds
+}
+
+func TestMiningStatus(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	require := require.New(t)
+
+	canonical := &types.Block{Height: 1, Nonce: 1, MessageReceipts: []*types.MessageReceipt{{GasAttoFIL: types.NewAttoFILFromFIL(2)}}}
+	orphan := &types.Block{Height: 1, Nonce: 2}
+	// above is mined on top of the head, which does not include it yet
+	above := &types.Block{Height: 2, Nonce: 3}
+	// superseded was dropped unpublished when the run was re-based
+	superseded := &types.Block{Height: 2, Nonce: 4}
+	canonicalCid, orphanCid, aboveCid, supersededCid := canonical.Cid(), orphan.Cid(), above.Cid(), superseded.Cid()
+
+	fp := &fakeMiningHistoryPlumbing{
+		rounds: []mining.Round{
+			{Height: 1, Won: true, Block: &orphanCid},
+			{Height: 1, NullBlocks: 1},
+			{Height: 1, Won: true, Block: &canonicalCid},
+			{Height: 2, Won: true, Block: &aboveCid},
+			{Height: 2, Won: true, Block: &supersededCid, Superseded: true},
+		},
+		chain: map[uint64]types.TipSet{1: th.RequireNewTipSet(require, canonical)},
+	}
+
+	rounds, err := porcelain.MiningHistory(context.Background(), fp)
+	require.NoError(err)
+	require.Len(rounds, 5)
+	assert.False(rounds[0].Canonical)
+	assert.False(rounds[1].Canonical)
+	assert.True(rounds[2].Canonical)
+	assert.False(rounds[3].Canonical)
+	assert.True(types.NewAttoFILFromFIL(502).Equal(rounds[2].Reward))
+
+	status, err := porcelain.MiningStatus(context.Background(), fp)
+	require.NoError(err)
+	assert.Equal(5, status.Rounds)
+	assert.Equal(3, status.Won)
+	assert.Equal(1, status.Canonical)
+	assert.True(types.NewAttoFILFromFIL(502).Equal(status.Rewards))
+	require.NotNil(status.Last)
+	assert.False(status.Last.Canonical)
+}
@@ -0,0 +1,434 @@
+package sectorbuilder
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SchedulingInterval is how often the SealingScheduler checks whether staged
+// sectors should be sealed.
+const SchedulingInterval = 1 * time.Second
+
+// SealPolicy decides when the SealingScheduler seals a staged sector.
+type SealPolicy int
+
+const (
+	// SealOnTimeout seals a staged sector once its first piece has waited
+	// for the scheduler's timeout, whether the sector is full or not.
+	SealOnTimeout = SealPolicy(iota)
+
+	// SealWhenFull leaves a staged sector open until it is full, when the
+	// sector builder seals it on its own.
+	SealWhenFull
+)
+
+func (p SealPolicy) String() string {
+	switch p {
+	case SealOnTimeout:
+		return "timeout"
+	case SealWhenFull:
+		return "full"
+	default:
+		return fmt.Sprintf("<unrecognized %d>", p)
+	}
+}
+
+// ParseSealPolicy returns the SealPolicy named s, "timeout" or "full".
+func ParseSealPolicy(s string) (SealPolicy, error) {
+	switch s {
+	case "timeout":
+		return SealOnTimeout, nil
+	case "full":
+		return SealWhenFull, nil
+	default:
+		return 0, fmt.Errorf("unknown seal policy %q, must be timeout or full", s)
+	}
+}
+
+// SealingSchedulerConfig configures a SealingScheduler.
+type SealingSchedulerConfig struct {
+	// Policy is the policy of the staged sectors.
+	Policy SealPolicy
+
+	// Timeout is how long the first piece of a SealOnTimeout sector waits
+	// before the sector is sealed, 0 to never seal on timeout.
+	Timeout time.Duration
+
+	// UrgentWindow makes a sector holding a deal whose deadline is less than
+	// UrgentWindow away sealed right away, whatever the policy.
+	UrgentWindow time.Duration
+}
+
+// SealingStats are the numbers of the sealing queue of a SealingScheduler.
+type SealingStats struct {
+	// Staged is the number of sectors accepting pieces.
+	Staged int
+	// Queued is the number of sectors due for sealing whose seal has not
+	// started yet, or failed to start.
+	Queued int
+	// Sealing is the number of sectors being sealed.
+	Sealing int
+	// Sealed and Failed count the seals that succeeded and failed since the
+	// scheduler started.
+	Sealed uint64
+	Failed uint64
+	// LongestWait is how long the sector queued first has been waiting.
+	LongestWait time.Duration
+}
+
+// QueuedSector is a sector waiting in the sealing queue.
+type QueuedSector struct {
+	SectorID uint64
+	// Deadline is the earliest deadline of the deals of the sector, if any.
+	Deadline time.Time `json:",omitempty"`
+	QueuedAt time.Time
+}
+
+type scheduledSectorState int
+
+const (
+	sectorStaged = scheduledSectorState(iota)
+	sectorQueued
+	sectorSealing
+)
+
+// scheduledSector is a sector the scheduler has not seen sealed yet.
+type scheduledSector struct {
+	id       uint64
+	state    scheduledSectorState
+	stagedAt time.Time
+	queuedAt time.Time
+	deadline time.Time
+	// forced is true if SealAllStagedSectors was asked to seal the sector.
+	forced bool
+}
+
+// SealingScheduler is a SectorBuilder that decides when the staged sectors of
+// the SectorBuilder it wraps are sealed. A staged sector is queued for sealing
+// according to the policy, or as soon as one of its deals gets close to its
+// deadline, and queued sectors are sealed right away. The sector builder has
+// no call sealing a single sector, so a seal takes all its staged sectors
+// along, and it seals sectors itself when they are full, which the scheduler
+// notices when pieces go to a new sector. It is safe for concurrent use.
+type SealingScheduler struct {
+	SectorBuilder
+
+	cfg SealingSchedulerConfig
+
+	lk         sync.Mutex
+	sectors    map[uint64]*scheduledSector
+	sealed     uint64
+	failed     uint64
+	onSealing  func(sectorID uint64)
+	onRequeued func(sectorID uint64, err error)
+
+	sectorSealResults chan SectorSealResult
+	wakeCh            chan struct{}
+	stopCh            chan struct{}
+	stopOnce          sync.Once
+
+	// now returns the current time, tests replace it.
+	now func() time.Time
+}
+
+var _ SectorBuilder = &SealingScheduler{}
+
+// NewSealingScheduler returns a SealingScheduler for sb, which already has
+// the staged sectors with ids stagedSectorIDs, and starts it.
+func NewSealingScheduler(sb SectorBuilder, cfg SealingSchedulerConfig, stagedSectorIDs []uint64) *SealingScheduler {
+	ss := newSealingScheduler(sb, cfg, time.Now)
+	for _, id := range stagedSectorIDs {
+		ss.track(id)
+	}
+
+	go ss.forwardSealResults()
+	go func() {
+		ticker := time.NewTicker(SchedulingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ss.stopCh:
+				return
+			case <-ticker.C:
+			case <-ss.wakeCh:
+			}
+			ss.schedule()
+		}
+	}()
+
+	return ss
+}
+
+func newSealingScheduler(sb SectorBuilder, cfg SealingSchedulerConfig, now func() time.Time) *SealingScheduler {
+	return &SealingScheduler{
+		SectorBuilder:     sb,
+		cfg:               cfg,
+		sectors:           make(map[uint64]*scheduledSector),
+		sectorSealResults: make(chan SectorSealResult),
+		wakeCh:            make(chan struct{}, 1),
+		stopCh:            make(chan struct{}),
+		now:               now,
+	}
+}
+
+// AddPiece adds the piece to a staged sector of the wrapped SectorBuilder and
+// starts tracking the sector.
+func (ss *SealingScheduler) AddPiece(ctx context.Context, pi *PieceInfo) (uint64, error) {
+	sectorID, err := ss.SectorBuilder.AddPiece(ctx, pi)
+	if err != nil {
+		return 0, err
+	}
+
+	ss.lk.Lock()
+	defer ss.lk.Unlock()
+
+	if _, ok := ss.sectors[sectorID]; !ok {
+		// the sector builder only moves to a new sector when the previous
+		// ones are full, and seals them
+		for _, s := range ss.sectors {
+			if s.state != sectorSealing {
+				ss.startSealing(s)
+			}
+		}
+		ss.track(sectorID)
+	}
+	ss.wake()
+
+	return sectorID, nil
+}
+
+// SetDeadline records that the sector with id sectorID holds a deal which
+// should be sealed by deadline. The sector keeps its earliest deadline.
+func (ss *SealingScheduler) SetDeadline(sectorID uint64, deadline time.Time) {
+	ss.lk.Lock()
+	defer ss.lk.Unlock()
+
+	if s, ok := ss.sectors[sectorID]; ok && (s.deadline.IsZero() || deadline.Before(s.deadline)) {
+		s.deadline = deadline
+	}
+	ss.wake()
+}
+
+// OnSealing makes the scheduler call f with the id of each sector it sees
+// starting to seal.
+func (ss *SealingScheduler) OnSealing(f func(sectorID uint64)) {
+	ss.lk.Lock()
+	defer ss.lk.Unlock()
+	ss.onSealing = f
+}
+
+// OnRequeued makes the scheduler call f with the id of each sector it saw
+// starting to seal and queues again because the seal failed to start, along
+// with the error.
+func (ss *SealingScheduler) OnRequeued(f func(sectorID uint64, err error)) {
+	ss.lk.Lock()
+	defer ss.lk.Unlock()
+	ss.onRequeued = f
+}
+
+// SealAllStagedSectors queues all the staged sectors for sealing. They are
+// sealed the next time the scheduler runs.
+func (ss *SealingScheduler) SealAllStagedSectors(ctx context.Context) error {
+	ss.lk.Lock()
+	defer ss.lk.Unlock()
+
+	for _, s := range ss.sectors {
+		s.forced = true
+	}
+	ss.wake()
+
+	return nil
+}
+
+// SectorSealResults returns the channel the seal results of the wrapped
+// SectorBuilder are forwarded to.
+func (ss *SealingScheduler) SectorSealResults() <-chan SectorSealResult {
+	return ss.sectorSealResults
+}
+
+// Stats returns the numbers of the sealing queue.
+func (ss *SealingScheduler) Stats() SealingStats {
+	ss.lk.Lock()
+	defer ss.lk.Unlock()
+
+	stats := SealingStats{Sealed: ss.sealed, Failed: ss.failed}
+	now := ss.now()
+	for _, s := range ss.sectors {
+		switch s.state {
+		case sectorStaged:
+			stats.Staged++
+		case sectorQueued:
+			stats.Queued++
+			if wait := now.Sub(s.queuedAt); wait > stats.LongestWait {
+				stats.LongestWait = wait
+			}
+		case sectorSealing:
+			stats.Sealing++
+		}
+	}
+	return stats
+}
+
+// Queue returns the sectors waiting for a seal to be started, those with the
+// earliest deadlines first.
+func (ss *SealingScheduler) Queue() []QueuedSector {
+	ss.lk.Lock()
+	defer ss.lk.Unlock()
+
+	var queue []QueuedSector
+	for _, s := range ss.queued() {
+		queue = append(queue, QueuedSector{
+			SectorID: s.id,
+			Policy:   s.policy,
+			Deadline: s.deadline,
+			QueuedAt: s.queuedAt,
+		})
+	}
+	return queue
+}
+
+// Close stops the scheduler and closes the wrapped SectorBuilder.
+func (ss *SealingScheduler) Close() error {
+	ss.stopOnce.Do(func() { close(ss.stopCh) })
+	return ss.SectorBuilder.Close()
+}
+
+// schedule queues the staged sectors that are due and seals the queued ones.
+func (ss *SealingScheduler) schedule() {
+	ss.lk.Lock()
+
+	now := ss.now()
+	for _, s := range ss.sectors {
+		if s.state == sectorStaged && ss.due(s, now) {
+			s.state = sectorQueued
+			s.queuedAt = now
+		}
+	}
+
+	if len(ss.queued()) == 0 {
+		ss.lk.Unlock()
+		return
+	}
+
+	// the sector builder seals all its staged sectors, not only the queued
+	// ones
+	var started []*scheduledSector
+	for _, s := range ss.sectors {
+		if s.state != sectorSealing {
+			ss.startSealing(s)
+			started = append(started, s)
+		}
+	}
+	ss.lk.Unlock()
+
+	if err := ss.SectorBuilder.SealAllStagedSectors(context.Background()); err != nil {
+		log.Errorf("failed to seal staged sectors: %s", err)
+
+		ss.lk.Lock()
+		defer ss.lk.Unlock()
+		for _, s := range started {
+			// a sector sealed meanwhile is no longer tracked
+			if ss.sectors[s.id] != s {
+				continue
+			}
+			if s.queuedAt.IsZero() {
+				s.queuedAt = now
+			}
+			s.state = sectorQueued
+			if ss.onRequeued != nil {
+				ss.onRequeued(s.id, err)
+			}
+		}
+	}
+}
+
+// due returns true if s should be sealed at time now.
+func (ss *SealingScheduler) due(s *scheduledSector, now time.Time) bool {
+	switch {
+	case s.forced:
+		return true
+	case !s.deadline.IsZero() && s.deadline.Sub(now) <= ss.cfg.UrgentWindow:
+		return true
+	case ss.cfg.Policy == SealOnTimeout && ss.cfg.Timeout > 0:
+		return now.Sub(s.stagedAt) >= ss.cfg.Timeout
+	default:
+		return false
+	}
+}
+
+// queued returns the queued sectors, those with the earliest deadlines first
+// and then those queued first.
+func (ss *SealingScheduler) queued() []*scheduledSector {
+	var queued []*scheduledSector
+	for _, s := range ss.sectors {
+		if s.state == sectorQueued {
+			queued = append(queued, s)
+		}
+	}
+	sort.Slice(queued, func(i, j int) bool {
+		a, b := queued[i], queued[j]
+		if !a.deadline.Equal(b.deadline) {
+			if a.deadline.IsZero() || b.deadline.IsZero() {
+				return b.deadline.IsZero()
+			}
+			return a.deadline.Before(b.deadline)
+		}
+		if !a.queuedAt.Equal(b.queuedAt) {
+			return a.queuedAt.Before(b.queuedAt)
+		}
+		return a.id < b.id
+	})
+	return queued
+}
+
+func (ss *SealingScheduler) track(sectorID uint64) {
+	ss.sectors[sectorID] = &scheduledSector{
+		id:       sectorID,
+		state:    sectorStaged,
+		stagedAt: ss.now(),
+	}
+}
+
+func (ss *SealingScheduler) startSealing(s *scheduledSector) {
+	s.state = sectorSealing
+	if ss.onSealing != nil {
+		ss.onSealing(s.id)
+	}
+}
+
+func (ss *SealingScheduler) wake() {
+	select {
+	case ss.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// forwardSealResults sends the seal results of the wrapped SectorBuilder to
+// the scheduler's channel, forgetting the sealed sectors.
+func (ss *SealingScheduler) forwardSealResults() {
+	for {
+		select {
+		case <-ss.stopCh:
+			return
+		case result := <-ss.SectorBuilder.SectorSealResults():
+			ss.lk.Lock()
+			delete(ss.sectors, result.SectorID)
+			if result.SealingErr != nil {
+				ss.failed++
+			} else {
+				ss.sealed++
+			}
+			ss.wake()
+			ss.lk.Unlock()
+
+			select {
+			case <-ss.stopCh:
+				return
+			case ss.sectorSealResults <- result:
+			}
+		}
+	}
+}
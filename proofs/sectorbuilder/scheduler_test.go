@@ -0,0 +1,199 @@
+package sectorbuilder
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+
+	"github.com/filecoin-project/go-filecoin/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSectorBuilder stages each piece in the sector nextSectorID and counts
+// the calls to SealAllStagedSectors, which fail with sealErr.
+type fakeSectorBuilder struct {
+	SectorBuilder
+
+	lk           sync.Mutex
+	nextSectorID uint64
+	seals        int
+	sealErr      error
+	results      chan SectorSealResult
+}
+
+func (fsb *fakeSectorBuilder) AddPiece(ctx context.Context, pi *PieceInfo) (uint64, error) {
+	fsb.lk.Lock()
+	defer fsb.lk.Unlock()
+	return fsb.nextSectorID, nil
+}
+
+func (fsb *fakeSectorBuilder) SealAllStagedSectors(ctx context.Context) error {
+	fsb.lk.Lock()
+	defer fsb.lk.Unlock()
+	fsb.seals++
+	return fsb.sealErr
+}
+
+func (fsb *fakeSectorBuilder) SectorSealResults() <-chan SectorSealResult {
+	return fsb.results
+}
+
+func (fsb *fakeSectorBuilder) stageIn(sectorID uint64) {
+	fsb.lk.Lock()
+	defer fsb.lk.Unlock()
+	fsb.nextSectorID = sectorID
+}
+
+func (fsb *fakeSectorBuilder) failSeals(err error) {
+	fsb.lk.Lock()
+	defer fsb.lk.Unlock()
+	fsb.sealErr = err
+}
+
+func (fsb *fakeSectorBuilder) sealCount() int {
+	fsb.lk.Lock()
+	defer fsb.lk.Unlock()
+	return fsb.seals
+}
+
+func newSchedulerTestSetup(cfg SealingSchedulerConfig) (*SealingScheduler, *fakeSectorBuilder, *time.Time) {
+	fsb := &fakeSectorBuilder{nextSectorID: 1, results: make(chan SectorSealResult)}
+	now := time.Unix(1000000, 0)
+	ss := newSealingScheduler(fsb, cfg, func() time.Time { return now })
+	return ss, fsb, &now
+}
+
+func addPiece(t *testing.T, ss *SealingScheduler) uint64 {
+	sectorID, err := ss.AddPiece(context.Background(), &PieceInfo{Ref: types.SomeCid(), Size: 10})
+	require.NoError(t, err)
+	return sectorID
+}
+
+func TestSealingSchedulerTimeout(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	ss, fsb, now := newSchedulerTestSetup(SealingSchedulerConfig{Policy: SealOnTimeout, Timeout: time.Minute})
+	addPiece(t, ss)
+
+	ss.schedule()
+	assert.Equal(0, fsb.sealCount())
+	assert.Equal(SealingStats{Staged: 1}, ss.Stats())
+
+	*now = now.Add(time.Minute)
+	ss.schedule()
+	assert.Equal(1, fsb.sealCount())
+	assert.Equal(SealingStats{Sealing: 1}, ss.Stats())
+}
+
+func TestSealingSchedulerWhenFull(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	var sealing []uint64
+	ss, fsb, now := newSchedulerTestSetup(SealingSchedulerConfig{Policy: SealWhenFull, Timeout: time.Minute})
+	ss.OnSealing(func(sectorID uint64) { sealing = append(sealing, sectorID) })
+	addPiece(t, ss)
+
+	*now = now.Add(time.Hour)
+	ss.schedule()
+	assert.Equal(0, fsb.sealCount())
+
+	// pieces going to a new sector mean the sector builder sealed the full one
+	fsb.stageIn(2)
+	assert.Equal(uint64(2), addPiece(t, ss))
+	assert.Equal([]uint64{1}, sealing)
+	assert.Equal(SealingStats{Staged: 1, Sealing: 1}, ss.Stats())
+	assert.Equal(0, fsb.sealCount())
+
+	// unless asked to
+	assert.NoError(ss.SealAllStagedSectors(context.Background()))
+	ss.schedule()
+	assert.Equal(1, fsb.sealCount())
+	assert.Equal([]uint64{1, 2}, sealing)
+}
+
+func TestSealingSchedulerRequeuesFailedSeals(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var sealing []uint64
+	requeued := make(map[uint64]error)
+	ss, fsb, now := newSchedulerTestSetup(SealingSchedulerConfig{Policy: SealOnTimeout, Timeout: time.Minute})
+	ss.OnSealing(func(sectorID uint64) { sealing = append(sealing, sectorID) })
+	ss.OnRequeued(func(sectorID uint64, err error) { requeued[sectorID] = err })
+	addPiece(t, ss)
+
+	fsb.failSeals(errors.New("no space left"))
+	*now = now.Add(time.Minute)
+	ss.schedule()
+	assert.Equal(1, fsb.sealCount())
+	assert.Equal([]uint64{1}, sealing)
+	require.Contains(requeued, uint64(1))
+	assert.EqualError(requeued[1], "no space left")
+	assert.Equal(SealingStats{Queued: 1}, ss.Stats())
+
+	fsb.failSeals(nil)
+	*now = now.Add(time.Minute)
+	ss.schedule()
+	assert.Equal(2, fsb.sealCount())
+	assert.Equal([]uint64{1, 1}, sealing)
+	assert.Equal(SealingStats{Sealing: 1}, ss.Stats())
+}
+
+func TestSealingSchedulerDeadlines(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	ss, fsb, now := newSchedulerTestSetup(SealingSchedulerConfig{
+		Policy:       SealWhenFull,
+		UrgentWindow: 10 * time.Minute,
+	})
+	// seals fail so that sectors stay queued
+	fsb.failSeals(errors.New("busy"))
+
+	for id := uint64(1); id <= 3; id++ {
+		ss.track(id)
+	}
+	ss.SetDeadline(1, now.Add(time.Hour))
+	ss.SetDeadline(2, now.Add(time.Hour))
+	ss.schedule()
+	assert.Equal(0, fsb.sealCount())
+
+	// a sector close to its deadline is sealed whatever the policy, taking
+	// the other staged sectors along
+	ss.SetDeadline(3, now.Add(8*time.Minute))
+	ss.SetDeadline(2, now.Add(5*time.Minute))
+	ss.schedule()
+	assert.Equal(1, fsb.sealCount())
+
+	queue := ss.Queue()
+	if assert.Len(queue, 3) {
+		assert.Equal(uint64(2), queue[0].SectorID)
+		assert.Equal(uint64(3), queue[1].SectorID)
+		assert.Equal(uint64(1), queue[2].SectorID)
+	}
+
+	*now = now.Add(5 * time.Minute)
+	assert.Equal(5*time.Minute, ss.Stats().LongestWait)
+}
+
+func TestParseSealPolicy(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	for _, p := range []SealPolicy{SealOnTimeout, SealWhenFull} {
+		parsed, err := ParseSealPolicy(p.String())
+		assert.NoError(err)
+		assert.Equal(p, parsed)
+	}
+
+	_, err := ParseSealPolicy("never")
+	assert.Error(err)
+}
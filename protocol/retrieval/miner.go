@@ -1,15 +1,16 @@
 package retrieval
 
 import (
+	"io"
 	"io/ioutil"
 
 	inet "gx/ipfs/QmNgLg1NTw37iWbYPKcyK85YJ9Whs1MkPtJwhfqbNYAyKg/go-libp2p-net"
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
 	"gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
 	host "gx/ipfs/QmaoXrM4Z41PD48JY36YqQGKQpLGjyLA2cKcLsES7YddAq/go-libp2p-host"
 	logging "gx/ipfs/QmcuXC5cxs79ro2cUuHs4HQ2bkDLJUYokwL8aivcX6HW3C/go-log"
 
 	cbu "github.com/filecoin-project/go-filecoin/cborutil"
-	"github.com/filecoin-project/go-filecoin/proofs/sectorbuilder"
 )
 
 var log = logging.Logger("/fil/retrieval")
@@ -19,7 +20,9 @@ const retrievalFreeProtocol = protocol.ID("/fil/retrieval/free/0.0.0")
 // TODO: better name
 type minerNode interface {
 	Host() host.Host
-	SectorBuilder() sectorbuilder.SectorBuilder
+	// ReadPieceFromSealedSector reads a piece from the sealed sectors of any
+	// of the miners of the node.
+	ReadPieceFromSealedSector(pieceCid cid.Cid) (io.Reader, error)
 }
 
 // Miner serves requests for pieces from RetrievalClients.
@@ -47,7 +50,7 @@ func (rm *Miner) handleRetrievePieceForFree(s inet.Stream) {
 		return
 	}
 
-	reader, err := rm.node.SectorBuilder().ReadPieceFromSealedSector(req.PieceRef)
+	reader, err := rm.node.ReadPieceFromSealedSector(req.PieceRef)
 	if err != nil {
 		log.Warningf("failed to obtain a reader for piece with CID %s: %s", req.PieceRef.String(), err)
 
@@ -0,0 +1,357 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+	"gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore"
+	"gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore/query"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/exec"
+	"github.com/filecoin-project/go-filecoin/plumbing/msg"
+	"github.com/filecoin-project/go-filecoin/proofs/sectorbuilder"
+	"github.com/filecoin-project/go-filecoin/repo"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+const commitmentsDatastorePrefix = "commitments"
+
+// CommitMaxAttempts is the number of commitSector messages, counting
+// replacements and failed sends, tried for a sector before giving up on
+// committing it.
+const CommitMaxAttempts = 8
+
+// CommitGasBumpPercent is how much higher, in percent, the gas price and gas
+// limit of a commitSector message are than those of the previous attempt.
+const CommitGasBumpPercent = 25
+
+// commitWaitRounds is the number of block times a commitSector message is
+// waited for before it is replaced by one paying a higher gas price.
+const commitWaitRounds = 10
+
+// commitRetryDelay is the time waited before trying again to send a
+// commitSector message after failing to.
+const commitRetryDelay = 10 * time.Second
+
+// committerPorcelain is the subset of the porcelain API that Committer needs.
+type committerPorcelain interface {
+	MessageEstimateGasLimit(ctx context.Context, from, to address.Address, method string, params ...interface{}) (types.GasUnits, error)
+	MessageSuggestGasPrice(ctx context.Context) (types.AttoFIL, error)
+	MessageSend(ctx context.Context, from, to address.Address, value *types.AttoFIL, gasPrice types.AttoFIL, gasLimit types.GasUnits, method string, params ...interface{}) (cid.Cid, error)
+	MessageReplace(ctx context.Context, msgCid cid.Cid, gasPrice types.AttoFIL) (cid.Cid, error)
+	MessageWait(ctx context.Context, msgCid cid.Cid, cb func(*types.Block, *types.SignedMessage, *types.MessageReceipt) error) error
+	MessageFind(ctx context.Context, msgCid cid.Cid) (*msg.ChainMessage, error)
+}
+
+// commitObserver is notified of the progress of the commitments.
+type commitObserver interface {
+	OnCommitSent(sectorID uint64, msgCid cid.Cid)
+	OnCommitmentAddedToChain(sector *sectorbuilder.SealedSectorMetadata, err error)
+}
+
+// commitment is the progress of the commitment of a sealed sector.
+type commitment struct {
+	Sector *sectorbuilder.SealedSectorMetadata
+	// MsgCids are the cids of the commitSector messages that may still be
+	// included in the chain, the one waited for last.
+	MsgCids  []cid.Cid
+	GasPrice types.AttoFIL
+	GasLimit types.GasUnits
+	Attempts int
+}
+
+// Committer sends the commitSector messages of sealed sectors and follows
+// them until one is included in the chain, replacing or resending them with
+// more gas when they are not included in time or run out of gas. Commitments are kept in
+// the repo so that they are resumed after a restart. The observer is told of
+// each message sent, and of the outcome of each commitment once.
+type Committer struct {
+	minerAddr      address.Address
+	minerOwnerAddr address.Address
+
+	ds           repo.Datastore
+	porcelainAPI committerPorcelain
+	observer     commitObserver
+
+	waitTimeout time.Duration
+	retryDelay  time.Duration
+
+	// dsLk serializes the updates of the stored commitments.
+	dsLk sync.Mutex
+}
+
+// NewCommitter returns a new Committer sending the commitSector messages of
+// minerAddr from minerOwnerAddr.
+func NewCommitter(minerAddr, minerOwnerAddr address.Address, ds repo.Datastore, porcelainAPI committerPorcelain, blockTime time.Duration, observer commitObserver) *Committer {
+	return &Committer{
+		minerAddr:      minerAddr,
+		minerOwnerAddr: minerOwnerAddr,
+		ds:             ds,
+		porcelainAPI:   porcelainAPI,
+		observer:       observer,
+		waitTimeout:    commitWaitRounds * blockTime,
+		retryDelay:     commitRetryDelay,
+	}
+}
+
+// Start resumes the commitments stored in the repo. They stop when ctx is
+// done.
+func (c *Committer) Start(ctx context.Context) error {
+	res, err := c.ds.Query(query.Query{Prefix: "/" + commitmentsDatastorePrefix})
+	if err != nil {
+		return errors.Wrap(err, "failed to query commitments from datastore")
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return errors.Wrap(err, "failed to read commitments from datastore")
+	}
+
+	for _, entry := range entries {
+		var cm commitment
+		if err := json.Unmarshal(entry.Value, &cm); err != nil {
+			return errors.Wrapf(err, "failed to unmarshal commitment %s", entry.Key)
+		}
+		log.Infof("resuming commitment of sector %d", cm.Sector.SectorID)
+		go c.commit(ctx, &cm)
+	}
+	return nil
+}
+
+// Commit starts committing sector. It returns once the commitment is stored.
+func (c *Committer) Commit(ctx context.Context, sector *sectorbuilder.SealedSectorMetadata) error {
+	cm := &commitment{Sector: sector, GasPrice: *types.NewZeroAttoFIL()}
+	if err := c.save(cm); err != nil {
+		return err
+	}
+	go c.commit(ctx, cm)
+	return nil
+}
+
+func (c *Committer) commit(ctx context.Context, cm *commitment) {
+	sectorID := cm.Sector.SectorID
+	for {
+		if len(cm.MsgCids) == 0 {
+			if cm.Attempts >= CommitMaxAttempts {
+				c.finish(cm, errors.Errorf("gave up committing sector %d after %d attempts", sectorID, cm.Attempts))
+				return
+			}
+			if err := c.send(ctx, cm); err != nil {
+				log.Warningf("failed to send commitSector message for sector %d: %s", sectorID, err)
+				if !sleep(ctx, c.retryDelay) {
+					return
+				}
+				continue
+			}
+		}
+
+		receipt, err := c.wait(ctx, cm)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Warningf("failed to wait for commitSector message of sector %d: %s", sectorID, err)
+			if !sleep(ctx, c.retryDelay) {
+				return
+			}
+			continue
+		}
+
+		switch {
+		case receipt == nil:
+			if cm.Attempts >= CommitMaxAttempts {
+				c.finish(cm, errors.Errorf("commitSector message for sector %d was not included after %d attempts", sectorID, cm.Attempts))
+				return
+			}
+			c.replace(ctx, cm)
+		case receipt.ExitCode == 0:
+			c.finish(cm, nil)
+			return
+		case receipt.ExitCode != exec.ErrInsufficientGas:
+			// Only running out of gas is helped by sending the message
+			// again, other failures such as an invalid proof or a sector
+			// committed already would fail the same way.
+			c.finish(cm, errors.Errorf("commitSector message for sector %d failed with exit code %d", sectorID, receipt.ExitCode))
+			return
+		default:
+			log.Warningf("commitSector message for sector %d ran out of gas", sectorID)
+			cm.MsgCids = nil
+			if err := c.save(cm); err != nil {
+				log.Error(err)
+			}
+		}
+	}
+}
+
+// send sends a new commitSector message for cm, paying more gas than the
+// previous one if any.
+func (c *Committer) send(ctx context.Context, cm *commitment) error {
+	val := cm.Sector
+	params := []interface{}{val.SectorID, val.CommD[:], val.CommR[:], val.CommRStar[:], val.Proof[:]}
+
+	gasLimit, gasPrice := messageGas(ctx, c.porcelainAPI, c.minerOwnerAddr, c.minerAddr, "commitSector", params...)
+	if cm.Attempts > 0 {
+		if bumped := bumpGasLimit(cm.GasLimit); bumped > gasLimit {
+			gasLimit = bumped
+		}
+		if bumped := bumpGasPrice(cm.GasPrice); bumped.GreaterThan(&gasPrice) {
+			gasPrice = bumped
+		}
+	}
+
+	cm.Attempts++
+	msgCid, err := c.porcelainAPI.MessageSend(ctx, c.minerOwnerAddr, c.minerAddr, nil, gasPrice, gasLimit, "commitSector", params...)
+	if err != nil {
+		if saveErr := c.save(cm); saveErr != nil {
+			log.Error(saveErr)
+		}
+		return err
+	}
+
+	cm.MsgCids = append(cm.MsgCids, msgCid)
+	cm.GasPrice = gasPrice
+	cm.GasLimit = gasLimit
+	c.observer.OnCommitSent(val.SectorID, msgCid)
+	return c.save(cm)
+}
+
+// replace replaces the last commitSector message of cm by one paying a higher
+// gas price, or sends a new one if it is no longer in the message pool.
+func (c *Committer) replace(ctx context.Context, cm *commitment) {
+	last := cm.MsgCids[len(cm.MsgCids)-1]
+	gasPrice := bumpGasPrice(cm.GasPrice)
+
+	cm.Attempts++
+	msgCid, err := c.porcelainAPI.MessageReplace(ctx, last, gasPrice)
+	if err != nil {
+		log.Infof("sending new commitSector message for sector %d, could not replace %s: %s", cm.Sector.SectorID, last, err)
+		if err := c.send(ctx, cm); err != nil {
+			log.Warningf("failed to send commitSector message for sector %d: %s", cm.Sector.SectorID, err)
+		}
+		return
+	}
+
+	cm.MsgCids = append(cm.MsgCids, msgCid)
+	cm.GasPrice = gasPrice
+	c.observer.OnCommitSent(cm.Sector.SectorID, msgCid)
+	if err := c.save(cm); err != nil {
+		log.Error(err)
+	}
+}
+
+// wait returns the receipt of the commitSector message of cm included in the
+// chain, or nil if none is included within the wait timeout.
+func (c *Committer) wait(ctx context.Context, cm *commitment) (*types.MessageReceipt, error) {
+	receipt, err := c.find(ctx, cm)
+	if err != nil || receipt != nil {
+		return receipt, err
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, c.waitTimeout)
+	defer cancel()
+	err = c.porcelainAPI.MessageWait(waitCtx, cm.MsgCids[len(cm.MsgCids)-1], func(_ *types.Block, _ *types.SignedMessage, r *types.MessageReceipt) error {
+		receipt = r
+		return nil
+	})
+	if receipt != nil {
+		return receipt, nil
+	}
+	if waitCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		// A message replaced or resent earlier may have been included
+		// meanwhile.
+		return c.find(ctx, cm)
+	}
+	return nil, err
+}
+
+// find returns the receipt of a commitSector message of cm in the chain,
+// preferring a successful one, or nil if none is.
+func (c *Committer) find(ctx context.Context, cm *commitment) (*types.MessageReceipt, error) {
+	var failed *types.MessageReceipt
+	for _, msgCid := range cm.MsgCids {
+		found, err := c.porcelainAPI.MessageFind(ctx, msgCid)
+		if err == chain.ErrMessageNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to find message %s", msgCid)
+		}
+		if found.Receipt == nil {
+			// The receipts of the tipset holding the message are not known
+			// yet, it is waited for like a message not in the chain.
+			continue
+		}
+		if found.Receipt.ExitCode == 0 {
+			return found.Receipt, nil
+		}
+		failed = found.Receipt
+	}
+	return failed, nil
+}
+
+// finish forgets cm and reports its outcome.
+func (c *Committer) finish(cm *commitment, err error) {
+	c.dsLk.Lock()
+	if dsErr := c.ds.Delete(commitmentKey(cm.Sector.SectorID)); dsErr != nil && dsErr != datastore.ErrNotFound {
+		log.Errorf("failed to delete commitment of sector %d: %s", cm.Sector.SectorID, dsErr)
+	}
+	c.dsLk.Unlock()
+
+	c.observer.OnCommitmentAddedToChain(cm.Sector, err)
+}
+
+func (c *Committer) save(cm *commitment) error {
+	data, err := json.Marshal(cm)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal commitment")
+	}
+
+	c.dsLk.Lock()
+	defer c.dsLk.Unlock()
+	if err := c.ds.Put(commitmentKey(cm.Sector.SectorID), data); err != nil {
+		return errors.Wrapf(err, "failed to store commitment of sector %d", cm.Sector.SectorID)
+	}
+	return nil
+}
+
+func commitmentKey(sectorID uint64) datastore.Key {
+	return datastore.KeyWithNamespaces([]string{commitmentsDatastorePrefix, fmt.Sprintf("%d", sectorID)})
+}
+
+// bumpGasPrice returns price raised by CommitGasBumpPercent, and by at least
+// one attoFIL.
+func bumpGasPrice(price types.AttoFIL) types.AttoFIL {
+	old := types.NewZeroAttoFIL().Add(&price)
+	bumped := old.MulBigInt(big.NewInt(100 + CommitGasBumpPercent)).DivCeil(types.NewAttoFIL(big.NewInt(100)))
+	if !bumped.GreaterThan(old) {
+		bumped = old.Add(types.NewAttoFIL(big.NewInt(1)))
+	}
+	return *bumped
+}
+
+// bumpGasLimit returns limit raised by CommitGasBumpPercent, capped at the
+// block gas limit.
+func bumpGasLimit(limit types.GasUnits) types.GasUnits {
+	bumped := limit + (limit*CommitGasBumpPercent+99)/100
+	if bumped > types.BlockGasLimit {
+		return types.BlockGasLimit
+	}
+	return bumped
+}
+
+// sleep waits for d and returns true, or returns false if ctx is done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
@@ -0,0 +1,316 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+
+	"github.com/filecoin-project/go-filecoin/actor/builtin/miner"
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/chain"
+	"github.com/filecoin-project/go-filecoin/exec"
+	"github.com/filecoin-project/go-filecoin/plumbing/msg"
+	"github.com/filecoin-project/go-filecoin/proofs/sectorbuilder"
+	"github.com/filecoin-project/go-filecoin/repo"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reports success once the message is included", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		api := newCommitterTestPorcelain()
+		api.onSend = func(c cid.Cid) { api.include(c, 0) }
+		c, results := newTestCommitter(api, repo.NewInMemoryRepo().DealsDatastore())
+
+		require.NoError(c.Commit(context.Background(), &sectorbuilder.SealedSectorMetadata{SectorID: 42}))
+		sector, err := waitCommitted(t, results)
+		assert.NoError(err)
+		assert.Equal(uint64(42), sector.SectorID)
+		assert.Len(api.sent, 1)
+	})
+
+	t.Run("resends with more gas after a failed send or running out of gas", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		api := newCommitterTestPorcelain()
+		api.sendErrs = 1
+		api.onSend = func(c cid.Cid) {
+			if len(api.sent) == 1 {
+				api.include(c, exec.ErrInsufficientGas)
+			} else {
+				api.include(c, 0)
+			}
+		}
+		c, results := newTestCommitter(api, repo.NewInMemoryRepo().DealsDatastore())
+
+		require.NoError(c.Commit(context.Background(), &sectorbuilder.SealedSectorMetadata{SectorID: 1}))
+		_, err := waitCommitted(t, results)
+		assert.NoError(err)
+
+		api.lk.Lock()
+		defer api.lk.Unlock()
+		require.Len(api.sent, 2)
+		assert.True(api.gasPrices[1].GreaterThan(&api.gasPrices[0]))
+		assert.True(api.gasLimits[1] > api.gasLimits[0])
+	})
+
+	t.Run("gives up when the message fails for another reason", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		api := newCommitterTestPorcelain()
+		api.onSend = func(c cid.Cid) { api.include(c, miner.ErrInvalidSealProof) }
+		ds := repo.NewInMemoryRepo().DealsDatastore()
+		c, results := newTestCommitter(api, ds)
+
+		require.NoError(c.Commit(context.Background(), &sectorbuilder.SealedSectorMetadata{SectorID: 1}))
+		_, err := waitCommitted(t, results)
+		require.Error(err)
+		assert.Contains(err.Error(), "exit code 41")
+		assert.Len(api.sent, 1)
+		_, err = ds.Get(commitmentKey(1))
+		assert.Error(err)
+	})
+
+	t.Run("sends with default gas when it cannot be picked", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		api := newCommitterTestPorcelain()
+		api.gasErr = errors.New("no gas")
+		api.onSend = func(c cid.Cid) { api.include(c, 0) }
+		c, results := newTestCommitter(api, repo.NewInMemoryRepo().DealsDatastore())
+
+		require.NoError(c.Commit(context.Background(), &sectorbuilder.SealedSectorMetadata{SectorID: 1}))
+		_, err := waitCommitted(t, results)
+		assert.NoError(err)
+
+		api.lk.Lock()
+		defer api.lk.Unlock()
+		require.Len(api.sent, 1)
+		assert.Equal(types.NewGasUnits(defaultGasLimit), api.gasLimits[0])
+		assert.Equal(types.NewGasPrice(defaultGasPrice), api.gasPrices[0])
+	})
+
+	t.Run("replaces a message that is not included in time", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		api := newCommitterTestPorcelain()
+		api.onReplace = func(c cid.Cid) { api.include(c, 0) }
+		c, results := newTestCommitter(api, repo.NewInMemoryRepo().DealsDatastore())
+
+		require.NoError(c.Commit(context.Background(), &sectorbuilder.SealedSectorMetadata{SectorID: 1}))
+		_, err := waitCommitted(t, results)
+		assert.NoError(err)
+
+		api.lk.Lock()
+		defer api.lk.Unlock()
+		assert.Len(api.sent, 1)
+		assert.Len(api.replaced, 1)
+	})
+
+	t.Run("gives up after too many attempts", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		api := newCommitterTestPorcelain()
+		api.sendErrs = CommitMaxAttempts
+		ds := repo.NewInMemoryRepo().DealsDatastore()
+		c, results := newTestCommitter(api, ds)
+
+		require.NoError(c.Commit(context.Background(), &sectorbuilder.SealedSectorMetadata{SectorID: 1}))
+		_, err := waitCommitted(t, results)
+		assert.Error(err)
+		_, err = ds.Get(commitmentKey(1))
+		assert.Error(err)
+	})
+
+	t.Run("resumes stored commitments", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		api := newCommitterTestPorcelain()
+		c, results := newTestCommitter(api, repo.NewInMemoryRepo().DealsDatastore())
+
+		// The message of a previous run was included while the node was down.
+		msgCid := types.SomeCid()
+		api.include(msgCid, 0)
+		require.NoError(c.save(&commitment{
+			Sector:   &sectorbuilder.SealedSectorMetadata{SectorID: 7},
+			MsgCids:  []cid.Cid{msgCid},
+			GasPrice: types.NewGasPrice(1),
+			GasLimit: types.NewGasUnits(300),
+			Attempts: 1,
+		}))
+
+		require.NoError(c.Start(context.Background()))
+		sector, err := waitCommitted(t, results)
+		assert.NoError(err)
+		assert.Equal(uint64(7), sector.SectorID)
+		assert.Len(api.sent, 0)
+	})
+
+	t.Run("waits for a message found without a receipt", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		api := newCommitterTestPorcelain()
+		api.onReplace = func(c cid.Cid) { api.include(c, 0) }
+		c, results := newTestCommitter(api, repo.NewInMemoryRepo().DealsDatastore())
+
+		msgCid := types.SomeCid()
+		api.receipts[msgCid] = nil
+		require.NoError(c.save(&commitment{
+			Sector:   &sectorbuilder.SealedSectorMetadata{SectorID: 3},
+			MsgCids:  []cid.Cid{msgCid},
+			GasPrice: types.NewGasPrice(1),
+			GasLimit: types.NewGasUnits(300),
+			Attempts: 1,
+		}))
+
+		require.NoError(c.Start(context.Background()))
+		_, err := waitCommitted(t, results)
+		assert.NoError(err)
+		assert.Len(api.replaced, 1)
+	})
+}
+
+type committedResult struct {
+	sector *sectorbuilder.SealedSectorMetadata
+	err    error
+}
+
+// committerTestObserver sends the outcome of commitments to a channel.
+type committerTestObserver chan committedResult
+
+func (cto committerTestObserver) OnCommitSent(sectorID uint64, msgCid cid.Cid) {}
+
+func (cto committerTestObserver) OnCommitmentAddedToChain(sector *sectorbuilder.SealedSectorMetadata, err error) {
+	cto <- committedResult{sector, err}
+}
+
+func newTestCommitter(api *committerTestPorcelain, ds repo.Datastore) (*Committer, chan committedResult) {
+	results := make(chan committedResult, 1)
+	c := NewCommitter(address.TestAddress, address.TestAddress2, ds, api, time.Millisecond, committerTestObserver(results))
+	c.waitTimeout = 20 * time.Millisecond
+	c.retryDelay = time.Millisecond
+	return c, results
+}
+
+func waitCommitted(t *testing.T, results chan committedResult) (*sectorbuilder.SealedSectorMetadata, error) {
+	select {
+	case res := <-results:
+		return res.sector, res.err
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the commitment outcome")
+		return nil, nil
+	}
+}
+
+// committerTestPorcelain is a fake chain and message pool. Its callbacks are
+// called with lk held.
+type committerTestPorcelain struct {
+	lk        sync.Mutex
+	newCid    func() cid.Cid
+	receipts  map[cid.Cid]*types.MessageReceipt
+	sent      []cid.Cid
+	replaced  []cid.Cid
+	gasPrices []types.AttoFIL
+	gasLimits []types.GasUnits
+	sendErrs  int
+	gasErr    error
+
+	onSend    func(cid.Cid)
+	onReplace func(cid.Cid)
+}
+
+func newCommitterTestPorcelain() *committerTestPorcelain {
+	return &committerTestPorcelain{
+		newCid:   types.NewCidForTestGetter(),
+		receipts: make(map[cid.Cid]*types.MessageReceipt),
+	}
+}
+
+func (ctp *committerTestPorcelain) include(c cid.Cid, exitCode uint8) {
+	ctp.receipts[c] = &types.MessageReceipt{ExitCode: exitCode}
+}
+
+func (ctp *committerTestPorcelain) MessageEstimateGasLimit(ctx context.Context, from, to address.Address, method string, params ...interface{}) (types.GasUnits, error) {
+	if ctp.gasErr != nil {
+		return types.NewGasUnits(0), ctp.gasErr
+	}
+	return types.NewGasUnits(1000), nil
+}
+
+func (ctp *committerTestPorcelain) MessageSuggestGasPrice(ctx context.Context) (types.AttoFIL, error) {
+	if ctp.gasErr != nil {
+		return types.AttoFIL{}, ctp.gasErr
+	}
+	return types.NewGasPrice(1), nil
+}
+
+func (ctp *committerTestPorcelain) MessageSend(ctx context.Context, from, to address.Address, val *types.AttoFIL, gasPrice types.AttoFIL, gasLimit types.GasUnits, method string, params ...interface{}) (cid.Cid, error) {
+	ctp.lk.Lock()
+	defer ctp.lk.Unlock()
+	if ctp.sendErrs > 0 {
+		ctp.sendErrs--
+		return cid.Undef, errors.New("nonce collision")
+	}
+	c := ctp.newCid()
+	ctp.sent = append(ctp.sent, c)
+	ctp.gasPrices = append(ctp.gasPrices, gasPrice)
+	ctp.gasLimits = append(ctp.gasLimits, gasLimit)
+	if ctp.onSend != nil {
+		ctp.onSend(c)
+	}
+	return c, nil
+}
+
+func (ctp *committerTestPorcelain) MessageReplace(ctx context.Context, msgCid cid.Cid, gasPrice types.AttoFIL) (cid.Cid, error) {
+	ctp.lk.Lock()
+	defer ctp.lk.Unlock()
+	c := ctp.newCid()
+	ctp.replaced = append(ctp.replaced, c)
+	if ctp.onReplace != nil {
+		ctp.onReplace(c)
+	}
+	return c, nil
+}
+
+func (ctp *committerTestPorcelain) MessageWait(ctx context.Context, msgCid cid.Cid, cb func(*types.Block, *types.SignedMessage, *types.MessageReceipt) error) error {
+	for {
+		ctp.lk.Lock()
+		receipt, ok := ctp.receipts[msgCid]
+		ctp.lk.Unlock()
+		if ok {
+			return cb(nil, nil, receipt)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func (ctp *committerTestPorcelain) MessageFind(ctx context.Context, msgCid cid.Cid) (*msg.ChainMessage, error) {
+	ctp.lk.Lock()
+	defer ctp.lk.Unlock()
+	receipt, ok := ctp.receipts[msgCid]
+	if !ok {
+		return nil, chain.ErrMessageNotFound
+	}
+	return &msg.ChainMessage{Receipt: receipt}, nil
+}
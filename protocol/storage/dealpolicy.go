@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os/exec"
+	"strings"
+	"time"
+
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+
+	"github.com/filecoin-project/go-filecoin/config"
+	"github.com/filecoin-project/go-filecoin/types"
+)
+
+// dealFilterCommandTimeout is how long the filter command of the deal policy
+// may take to decide on a proposal before it is rejected.
+const dealFilterCommandTimeout = 30 * time.Second
+
+const (
+	// maxConcurrentDealFilters is how many filter commands may run at once.
+	// Proposals arriving while they all run wait for one to finish, until
+	// dealFilterCommandTimeout.
+	maxConcurrentDealFilters = 4
+	// maxDealFilterReason is how much of the standard output of the filter
+	// command is kept for the rejection reason.
+	maxDealFilterReason = 256
+	// maxDealFilterStderr is how much of the standard error of the filter
+	// command is kept for the log.
+	maxDealFilterStderr = 4096
+)
+
+var dealFilterSlots = make(chan struct{}, maxConcurrentDealFilters)
+
+// checkDealPolicy returns an error saying why p is rejected if it does not
+// pass the filters of policy. Deals must pay at least the minimum price of the
+// policy per byte per block, or storagePrice if it has none.
+func checkDealPolicy(policy *config.DealPolicyConfig, storagePrice *types.AttoFIL, p *DealProposal) error {
+	if p.Size == nil {
+		return fmt.Errorf("proposed deal has no size")
+	}
+
+	for _, client := range policy.BlockedClients {
+		if client == p.Payment.Payer {
+			return fmt.Errorf("deals from client %s are not accepted", p.Payment.Payer)
+		}
+	}
+	if len(policy.AllowedClients) > 0 {
+		allowed := false
+		for _, client := range policy.AllowedClients {
+			allowed = allowed || client == p.Payment.Payer
+		}
+		if !allowed {
+			return fmt.Errorf("deals from client %s are not accepted", p.Payment.Payer)
+		}
+	}
+
+	size := p.Size.Uint64()
+	if size < policy.MinPieceSize {
+		return fmt.Errorf("piece size (%d) is less than the minimum of %d bytes", size, policy.MinPieceSize)
+	}
+	if policy.MaxPieceSize != 0 && size > policy.MaxPieceSize {
+		return fmt.Errorf("piece size (%d) is more than the maximum of %d bytes", size, policy.MaxPieceSize)
+	}
+	if p.Duration < policy.MinDuration {
+		return fmt.Errorf("duration (%d) is less than the minimum of %d blocks", p.Duration, policy.MinDuration)
+	}
+
+	price := storagePrice
+	if policy.MinPrice != nil && !policy.MinPrice.IsZero() {
+		price = policy.MinPrice
+	}
+	durationBigInt := big.NewInt(0).SetUint64(p.Duration)
+	sizeBigInt := big.NewInt(0).SetUint64(size)
+	expectedPrice := price.MulBigInt(durationBigInt).MulBigInt(sizeBigInt)
+	if p.TotalPrice.LessThan(expectedPrice) {
+		return fmt.Errorf("proposed price (%s) is less than expected (%s) given asking price of %s", p.TotalPrice.String(), expectedPrice.String(), price.String())
+	}
+
+	return nil
+}
+
+// runDealFilterCommand runs command with p as JSON on its standard input and
+// returns an error if it does not exit successfully. The reason in the error
+// goes back to the client, so it is only the first line of the standard output
+// of the command; its standard error and exit status are logged here instead.
+func runDealFilterCommand(ctx context.Context, command string, p *DealProposal) error {
+	in, err := json.Marshal(p)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal proposal")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dealFilterCommandTimeout)
+	defer cancel()
+
+	// any peer can send proposals, so don't let them fork commands without limit
+	select {
+	case dealFilterSlots <- struct{}{}:
+		defer func() { <-dealFilterSlots }()
+	case <-ctx.Done():
+		log.Warningf("deal filter did not run: %s", ctx.Err())
+		return errors.New("rejected by deal filter: too many proposals")
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", command) // #nosec
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = &limitedBuffer{buf: &stdout, left: maxDealFilterReason}
+	cmd.Stderr = &limitedBuffer{buf: &stderr, left: maxDealFilterStderr}
+	if err := cmd.Run(); err != nil {
+		log.Infof("deal filter rejected proposal from %s: %s: %s", p.Payment.Payer, err, strings.TrimSpace(stderr.String()))
+		reason := strings.TrimSpace(strings.SplitN(stdout.String(), "\n", 2)[0])
+		if reason == "" {
+			return errors.New("rejected by deal filter")
+		}
+		return fmt.Errorf("rejected by deal filter: %s", reason)
+	}
+	return nil
+}
+
+// limitedBuffer writes at most left bytes to buf and discards the rest, so a
+// filter command can't grow the memory of the node with its output.
+type limitedBuffer struct {
+	buf  *bytes.Buffer
+	left int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if len(p) > b.left {
+		p = p[:b.left]
+	}
+	b.left -= len(p)
+	b.buf.Write(p) // nolint: errcheck
+	return n, nil
+}
+
+func (sm *Miner) getDealPolicy() (*config.DealPolicyConfig, error) {
+	policy, err := sm.porcelainAPI.ConfigGet("mining.dealPolicy")
+	if err != nil {
+		return nil, err
+	}
+	dealPolicy, ok := policy.(*config.DealPolicyConfig)
+	if !ok {
+		return nil, errors.New("Could not retrieve dealPolicy from config")
+	}
+	return dealPolicy, nil
+}
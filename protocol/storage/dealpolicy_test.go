@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	"github.com/filecoin-project/go-filecoin/config"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckDealPolicy(t *testing.T) {
+	t.Parallel()
+
+	// the test proposal stores 1000 bytes for 10000 blocks for 2500 FIL
+	price, _ := types.NewAttoFILFromFILString(".00025")
+	newPolicy := func() *config.DealPolicyConfig {
+		return config.NewDefaultConfig().Mining.DealPolicy
+	}
+
+	t.Run("the default policy accepts proposals paying the storage price", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, _, proposal := newMinerTestSetup()
+		assert.NoError(checkDealPolicy(newPolicy(), price, proposal))
+
+		proposal.Size = nil
+		assert.EqualError(checkDealPolicy(newPolicy(), price, proposal), "proposed deal has no size")
+	})
+
+	t.Run("piece size and duration", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, _, proposal := newMinerTestSetup()
+		policy := newPolicy()
+		policy.MinPieceSize = 1001
+		assert.EqualError(checkDealPolicy(policy, price, proposal), "piece size (1000) is less than the minimum of 1001 bytes")
+
+		policy = newPolicy()
+		policy.MaxPieceSize = 999
+		assert.EqualError(checkDealPolicy(policy, price, proposal), "piece size (1000) is more than the maximum of 999 bytes")
+
+		policy = newPolicy()
+		policy.MinDuration = 10001
+		assert.EqualError(checkDealPolicy(policy, price, proposal), "duration (10000) is less than the minimum of 10001 blocks")
+
+		policy = newPolicy()
+		policy.MinPieceSize, policy.MaxPieceSize, policy.MinDuration = 1000, 1000, 10000
+		assert.NoError(checkDealPolicy(policy, price, proposal))
+	})
+
+	t.Run("clients", func(t *testing.T) {
+		assert := assert.New(t)
+
+		porcelainAPI, _, proposal := newMinerTestSetup()
+		other := address.NewForTestGetter()()
+
+		policy := newPolicy()
+		policy.BlockedClients = []address.Address{other, porcelainAPI.payerAddress}
+		assert.Error(checkDealPolicy(policy, price, proposal))
+
+		policy = newPolicy()
+		policy.AllowedClients = []address.Address{other}
+		assert.Error(checkDealPolicy(policy, price, proposal))
+
+		policy.AllowedClients = append(policy.AllowedClients, porcelainAPI.payerAddress)
+		assert.NoError(checkDealPolicy(policy, price, proposal))
+	})
+
+	t.Run("the minimum price replaces the storage price", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, _, proposal := newMinerTestSetup()
+		policy := newPolicy()
+		policy.MinPrice, _ = types.NewAttoFILFromFILString(".0005")
+		assert.EqualError(checkDealPolicy(policy, price, proposal), "proposed price (2500) is less than expected (5000) given asking price of 0.0005")
+
+		// a miner may take less than what it asks for
+		policy.MinPrice, _ = types.NewAttoFILFromFILString(".0001")
+		highPrice, _ := types.NewAttoFILFromFILString(".0005")
+		assert.NoError(checkDealPolicy(policy, highPrice, proposal))
+	})
+}
+
+func TestRunDealFilterCommand(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	_, _, proposal := newMinerTestSetup()
+	ctx := context.Background()
+
+	assert.NoError(runDealFilterCommand(ctx, "cat > /dev/null", proposal))
+	assert.EqualError(runDealFilterCommand(ctx, "echo too cheap; exit 1", proposal), "rejected by deal filter: too cheap")
+	// only the first line of the standard output goes back to the client
+	assert.EqualError(runDealFilterCommand(ctx, "echo too cheap; echo details; exit 1", proposal), "rejected by deal filter: too cheap")
+	assert.EqualError(runDealFilterCommand(ctx, "echo /secret/path >&2; exit 1", proposal), "rejected by deal filter")
+	assert.EqualError(runDealFilterCommand(ctx, "exit 1", proposal), "rejected by deal filter")
+	long := runDealFilterCommand(ctx, "head -c 10000 /dev/zero | tr '\\0' x; exit 1", proposal)
+	assert.Len(long.Error(), len("rejected by deal filter: ")+maxDealFilterReason)
+	// the command reads the proposal
+	assert.NoError(runDealFilterCommand(ctx, "grep -q TotalPrice", proposal))
+	assert.Error(runDealFilterCommand(ctx, "grep -q NotAField", proposal))
+}
+
+func TestReceiveStorageProposalRunsTheDealFilter(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	require := require.New(t)
+
+	porcelainAPI, miner, proposal := newMinerTestSetup()
+	require.NoError(porcelainAPI.config.Set("mining.dealPolicy.filterCommand", `"echo no thanks; exit 1"`))
+
+	res, err := miner.receiveStorageProposal(context.Background(), proposal)
+	require.NoError(err)
+	assert.Equal(Rejected, res.State)
+	assert.Equal("rejected by deal filter: no thanks", res.Message)
+
+	require.NoError(porcelainAPI.config.Set("mining.dealPolicy.filterCommand", `"true"`))
+	res, err = miner.receiveStorageProposal(context.Background(), proposal)
+	require.NoError(err)
+	assert.Equal(Accepted, res.State)
+}
@@ -10,7 +10,6 @@ import (
 	"sync"
 	"time"
 
-	inet "gx/ipfs/QmNgLg1NTw37iWbYPKcyK85YJ9Whs1MkPtJwhfqbNYAyKg/go-libp2p-net"
 	"gx/ipfs/QmQXze9tG878pa4Euya4rrDpyTNX3kQe4dhCaBzBozGgpe/go-unixfs"
 	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
 	cbor "gx/ipfs/QmRoARq3nkUb13HSKZGepCZSWe5GrVPwx7xURJGZ7KWv9V/go-ipld-cbor"
@@ -18,7 +17,6 @@ import (
 	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
 	bserv "gx/ipfs/QmYPZzd9VqmJDwxUnThfeSbV1Y5o53aVPDijTB7j7rS9Ep/go-blockservice"
 	"gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
-	"gx/ipfs/QmaoXrM4Z41PD48JY36YqQGKQpLGjyLA2cKcLsES7YddAq/go-libp2p-host"
 	ipld "gx/ipfs/QmcKKBwfz6FyQdHR2jsXrrF6XeSBXYL86anmWNewpFpoF5/go-ipld-format"
 	logging "gx/ipfs/QmcuXC5cxs79ro2cUuHs4HQ2bkDLJUYokwL8aivcX6HW3C/go-log"
 	"gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore"
@@ -28,7 +26,6 @@ import (
 	"github.com/filecoin-project/go-filecoin/actor/builtin/miner"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/paymentbroker"
 	"github.com/filecoin-project/go-filecoin/address"
-	cbu "github.com/filecoin-project/go-filecoin/cborutil"
 	"github.com/filecoin-project/go-filecoin/exec"
 	"github.com/filecoin-project/go-filecoin/proofs"
 	"github.com/filecoin-project/go-filecoin/proofs/sectorbuilder"
@@ -42,9 +39,10 @@ var log = logging.Logger("/fil/storage")
 const makeDealProtocol = protocol.ID("/fil/storage/mk/1.0.0")
 const queryDealProtocol = protocol.ID("/fil/storage/qry/1.0.0")
 
-// TODO: replace this with a queries to pick reasonable gas price and limits.
-const submitPostGasPrice = 0
-const submitPostGasLimit = 300
+// defaultGasPrice and defaultGasLimit are the gas price and gas limit of the
+// messages sent by the miner when they cannot be suggested or estimated.
+const defaultGasPrice = 0
+const defaultGasLimit = 300
 
 const waitForPaymentChannelDuration = 2 * time.Minute
 
@@ -66,6 +64,9 @@ type Miner struct {
 
 	dealsAwaitingSeal *dealsAwaitingSealStruct
 
+	// sectors tracks the lifecycle of the sectors of the miner.
+	sectors *SectorStore
+
 	porcelainAPI minerPorcelain
 	node         node
 
@@ -83,6 +84,8 @@ type minerPorcelain interface {
 	ChainBlockHeight(ctx context.Context) (*types.BlockHeight, error)
 	ConfigGet(dottedPath string) (interface{}, error)
 
+	MessageEstimateGasLimit(ctx context.Context, from, to address.Address, method string, params ...interface{}) (types.GasUnits, error)
+	MessageSuggestGasPrice(ctx context.Context) (types.AttoFIL, error)
 	MessageSend(ctx context.Context, from, to address.Address, value *types.AttoFIL, gasPrice types.AttoFIL, gasLimit types.GasUnits, method string, params ...interface{}) (cid.Cid, error)
 	MessageQuery(ctx context.Context, optFrom, to address.Address, method string, params ...interface{}) ([][]byte, *exec.FunctionSignature, error)
 	MessageWait(ctx context.Context, msgCid cid.Cid, cb func(*types.Block, *types.SignedMessage, *types.MessageReceipt) error) error
@@ -95,10 +98,15 @@ type node interface {
 	BlockHeight() (*types.BlockHeight, error)
 	GetBlockTime() time.Duration
 	BlockService() bserv.BlockService
-	Host() host.Host
 	SectorBuilder() sectorbuilder.SectorBuilder
 }
 
+// sealDeadliner is implemented by sector builders that seal a sector sooner
+// when its deals need it.
+type sealDeadliner interface {
+	SetDeadline(sectorID uint64, deadline time.Time)
+}
+
 // generatePostInput is a struct containing sector id and related commitments
 // used to generate a proof-of-spacetime
 type generatePostInput struct {
@@ -121,6 +129,7 @@ func NewMiner(ctx context.Context, minerAddr, minerOwnerAddr address.Address, nd
 		deals:            make(map[cid.Cid]*storageDeal),
 		porcelainAPI:     porcelainAPI,
 		dealsDs:          dealsDs,
+		sectors:          NewSectorStore(dealsDs),
 		node:             nd,
 		proposalAcceptor: acceptProposal,
 		proposalRejector: rejectProposal,
@@ -136,63 +145,48 @@ func NewMiner(ctx context.Context, minerAddr, minerOwnerAddr address.Address, nd
 		return nil, errors.Wrap(err, "failed to load miner deals when creating miner")
 	}
 
-	nd.Host().SetStreamHandler(makeDealProtocol, sm.handleMakeDeal)
-	nd.Host().SetStreamHandler(queryDealProtocol, sm.handleQueryDeal)
-
 	return sm, nil
 }
 
-func (sm *Miner) handleMakeDeal(s inet.Stream) {
-	defer s.Close() // nolint: errcheck
-
-	var proposal DealProposal
-	if err := cbu.NewMsgReader(s).ReadMsg(&proposal); err != nil {
-		log.Errorf("received invalid proposal: %s", err)
-		return
-	}
-
-	ctx := context.Background()
-	resp, err := sm.receiveStorageProposal(ctx, &proposal)
-	if err != nil {
-		log.Errorf("failed to process proposal: %s", err)
-		return
-	}
-
-	if err := cbu.NewMsgWriter(s).WriteMsg(resp); err != nil {
-		log.Errorf("failed to write proposal response: %s", err)
-	}
+// Address returns the address of the miner actor.
+func (sm *Miner) Address() address.Address {
+	return sm.minerAddr
 }
 
 // receiveStorageProposal is the entry point for the miner storage protocol
 func (sm *Miner) receiveStorageProposal(ctx context.Context, p *DealProposal) (*DealResponse, error) {
 	// TODO: Check signature
 
-	if err := sm.validateDealPayment(ctx, p); err != nil {
-		return sm.proposalRejector(ctx, sm, p, err.Error())
+	policy, err := sm.getDealPolicy()
+	if err != nil {
+		return nil, err
 	}
-
-	// Payment is valid, everything else checks out, let's accept this proposal
-	return sm.proposalAcceptor(ctx, sm, p)
-}
-
-func (sm *Miner) validateDealPayment(ctx context.Context, p *DealProposal) error {
-	// compute expected total price for deal (storage price * duration * bytes)
 	price, err := sm.getStoragePrice()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := checkDealPolicy(policy, price, p); err != nil {
+		return sm.proposalRejector(ctx, sm, p, err.Error())
 	}
 
-	if p.Size == nil {
-		return fmt.Errorf("proposed deal has no size")
+	if err := sm.validateDealPayment(ctx, p); err != nil {
+		return sm.proposalRejector(ctx, sm, p, err.Error())
 	}
 
-	durationBigInt := big.NewInt(0).SetUint64(p.Duration)
-	priceBigInt := big.NewInt(0).SetUint64(p.Size.Uint64())
-	expectedPrice := price.MulBigInt(durationBigInt).MulBigInt(priceBigInt)
-	if p.TotalPrice.LessThan(expectedPrice) {
-		return fmt.Errorf("proposed price (%s) is less than expected (%s) given asking price of %s", p.TotalPrice.String(), expectedPrice.String(), price.String())
+	// the operator gets the last word on proposals passing every other check
+	if policy.FilterCommand != "" {
+		if err := runDealFilterCommand(ctx, policy.FilterCommand, p); err != nil {
+			return sm.proposalRejector(ctx, sm, p, err.Error())
+		}
 	}
 
+	// Payment is valid, everything else checks out, let's accept this proposal
+	return sm.proposalAcceptor(ctx, sm, p)
+}
+
+// validateDealPayment checks that the payment channel and vouchers of p pay
+// its total price, which checkDealPolicy compared to the price of the miner.
+func (sm *Miner) validateDealPayment(ctx context.Context, p *DealProposal) error {
 	// get channel
 	channel, err := sm.getPaymentChannel(ctx, p)
 	if err != nil {
@@ -205,8 +199,8 @@ func (sm *Miner) validateDealPayment(ctx context.Context, p *DealProposal) error
 	}
 
 	// confirm channel contains enough funds
-	if channel.Amount.LessThan(expectedPrice) {
-		return fmt.Errorf("payment channel does not contain enough funds (%s < %s)", channel.Amount.String(), expectedPrice.String())
+	if channel.Amount.LessThan(p.TotalPrice) {
+		return fmt.Errorf("payment channel does not contain enough funds (%s < %s)", channel.Amount.String(), p.TotalPrice.String())
 	}
 
 	// start with current block height
@@ -267,7 +261,19 @@ func (sm *Miner) validateDealPayment(ctx context.Context, p *DealProposal) error
 	return nil
 }
 
+// getStoragePrice returns the storage price set for the miner, or the storage
+// price of the node if it has none.
 func (sm *Miner) getStoragePrice() (*types.AttoFIL, error) {
+	prices, err := sm.porcelainAPI.ConfigGet("mining.storagePrices")
+	if err != nil {
+		return nil, err
+	}
+	if prices, ok := prices.(map[string]*types.AttoFIL); ok {
+		if price, ok := prices[sm.minerAddr.String()]; ok && price != nil {
+			return price, nil
+		}
+	}
+
 	storagePrice, err := sm.porcelainAPI.ConfigGet("mining.storagePrice")
 	if err != nil {
 		return nil, err
@@ -461,6 +467,14 @@ func (sm *Miner) processStorageDeal(c cid.Cid) {
 	if err != nil {
 		log.Errorf("could update to 'Staged': %s", err)
 	}
+	if err := sm.sectors.addDeal(sectorID, c); err != nil {
+		log.Errorf("could not record deal %s in sector %d: %s", c, sectorID, err)
+	}
+	if sealer, ok := sm.node.SectorBuilder().(sealDeadliner); ok {
+		if deadline, ok := sm.dealDeadline(d.Proposal); ok {
+			sealer.SetDeadline(sectorID, deadline)
+		}
+	}
 
 	// Careful: this might update state to success or failure so it should go after
 	// updating state to Staged.
@@ -572,7 +586,8 @@ func (dealsAwaitingSeal *dealsAwaitingSealStruct) fail(sectorID uint64, message
 	delete(dealsAwaitingSeal.SectorsToDeals, sectorID)
 }
 
-// OnCommitmentAddedToChain is a callback, called when a sector seal message was posted to the chain.
+// OnCommitmentAddedToChain is a callback, called when the commitSector message of a sector was
+// included in the chain, or with an error when the sector could not be committed.
 func (sm *Miner) OnCommitmentAddedToChain(sector *sectorbuilder.SealedSectorMetadata, err error) {
 	sectorID := sector.SectorID
 	log.Debug("Miner.OnCommitmentAddedToChain")
@@ -581,8 +596,12 @@ func (sm *Miner) OnCommitmentAddedToChain(sector *sectorbuilder.SealedSectorMeta
 		errMsg := fmt.Sprintf("failed sealing sector: %v: %s:", sectorID, err)
 		log.Error(errMsg)
 		sm.dealsAwaitingSeal.fail(sector.SectorID, errMsg)
+		if err := sm.sectors.transition(sectorID, SectorFaulty, errMsg); err != nil {
+			log.Errorf("could not update sector %d to 'faulty': %s", sectorID, err)
+		}
 	} else {
 		sm.dealsAwaitingSeal.success(sector)
+		sm.onSectorCommitted(sectorID)
 	}
 	if err := sm.saveDealsAwaitingSeal(); err != nil {
 		errMsg := fmt.Sprintf("failed persisting deals awaiting seal: %s", err)
@@ -591,6 +610,148 @@ func (sm *Miner) OnCommitmentAddedToChain(sector *sectorbuilder.SealedSectorMeta
 	}
 }
 
+// OnCommitSent is a callback, called when a commitSector message was sent for
+// a sector.
+func (sm *Miner) OnCommitSent(sectorID uint64, msgCid cid.Cid) {
+	err := sm.sectors.update(sectorID, func(sector *SectorInfo) error {
+		sector.CommitMessages = append(sector.CommitMessages, msgCid)
+		return setState(sector, SectorCommitSent, "")
+	})
+	if err != nil {
+		log.Errorf("could not update sector %d to 'commit-sent': %s", sectorID, err)
+	}
+}
+
+// OnSectorSealed is a callback, called when the sealing of a sector
+// completed, with the error that made it fail if any.
+func (sm *Miner) OnSectorSealed(sectorID uint64, err error) {
+	state, message := SectorSealed, ""
+	if err != nil {
+		state, message = SectorFaulty, fmt.Sprintf("failed sealing sector: %s", err)
+	}
+	if err := sm.sectors.transition(sectorID, state, message); err != nil {
+		log.Errorf("could not update sector %d to '%s': %s", sectorID, state, err)
+	}
+}
+
+// OnSectorSealing is a callback, called when the sealing of a sector starts.
+func (sm *Miner) OnSectorSealing(sectorID uint64) {
+	if err := sm.sectors.transition(sectorID, SectorSealing, ""); err != nil {
+		log.Errorf("could not update sector %d to '%s': %s", sectorID, SectorSealing, err)
+	}
+}
+
+// OnSectorSealRequeued is a callback, called when the sealing of a sector
+// failed to start and it waits to be sealed again.
+func (sm *Miner) OnSectorSealRequeued(sectorID uint64, sealErr error) {
+	message := fmt.Sprintf("failed to start sealing: %s", sealErr)
+	if err := sm.sectors.transition(sectorID, SectorStaged, message); err != nil {
+		log.Errorf("could not update sector %d to '%s': %s", sectorID, SectorStaged, err)
+	}
+}
+
+// dealDeadline returns when the sector holding the deal of p should be
+// sealed, which is when the first payment voucher of the deal becomes valid.
+// It returns false if the deal has no vouchers.
+func (sm *Miner) dealDeadline(p *DealProposal) (time.Time, bool) {
+	var validAt *types.BlockHeight
+	for _, v := range p.Payment.Vouchers {
+		if validAt == nil || v.ValidAt.LessThan(validAt) {
+			validAt = &v.ValidAt
+		}
+	}
+	if validAt == nil {
+		return time.Time{}, false
+	}
+
+	height, err := sm.node.BlockHeight()
+	if err != nil {
+		log.Errorf("could not get block height for deal deadline: %s", err)
+		return time.Time{}, false
+	}
+	if validAt.LessEqual(height) {
+		return time.Now(), true
+	}
+	blocks := validAt.Sub(height).AsBigInt().Int64()
+	return time.Now().Add(time.Duration(blocks) * sm.node.GetBlockTime()), true
+}
+
+// SealAllStagedSectors seals all the staged sectors.  The sectors are staged
+// again if their sealing cannot be started.
+func (sm *Miner) SealAllStagedSectors(ctx context.Context) error {
+	sectors, err := sm.sectors.List()
+	if err != nil {
+		return err
+	}
+	var sealing []uint64
+	for _, sector := range sectors {
+		if sector.State != SectorStaged {
+			continue
+		}
+		if err := sm.sectors.transition(sector.SectorID, SectorSealing, ""); err != nil {
+			return err
+		}
+		sealing = append(sealing, sector.SectorID)
+	}
+
+	if err := sm.node.SectorBuilder().SealAllStagedSectors(ctx); err != nil {
+		message := fmt.Sprintf("failed to start sealing: %s", err)
+		for _, sectorID := range sealing {
+			if err := sm.sectors.transition(sectorID, SectorStaged, message); err != nil {
+				log.Errorf("could not update sector %d to '%s': %s", sectorID, SectorStaged, err)
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// onSectorCommitted marks the sector committed until the end of its last
+// deal.
+func (sm *Miner) onSectorCommitted(sectorID uint64) {
+	var height uint64
+	if h, err := sm.porcelainAPI.ChainBlockHeight(context.Background()); err != nil {
+		log.Errorf("could not get the block height the sector %d was committed at: %s", sectorID, err)
+	} else {
+		height = h.AsBigInt().Uint64()
+	}
+
+	err := sm.sectors.update(sectorID, func(sector *SectorInfo) error {
+		sm.dealsLk.Lock()
+		for _, dealCid := range sector.Deals {
+			if deal, ok := sm.deals[dealCid]; ok && height+deal.Proposal.Duration > sector.ExpiresAt {
+				sector.ExpiresAt = height + deal.Proposal.Duration
+			}
+		}
+		sm.dealsLk.Unlock()
+		return setState(sector, SectorCommitted, "")
+	})
+	if err != nil {
+		log.Errorf("could not update sector %d to 'committed': %s", sectorID, err)
+	}
+}
+
+// expireSectors marks the committed sectors whose deals have all ended at
+// height expired.
+func (sm *Miner) expireSectors(height uint64) {
+	sectors, err := sm.sectors.List()
+	if err != nil {
+		log.Errorf("failed to list sectors: %s", err)
+		return
+	}
+	for _, sector := range sectors {
+		if sector.State != SectorCommitted && sector.State != SectorProving {
+			continue
+		}
+		if sector.ExpiresAt == 0 || sector.ExpiresAt > height {
+			continue
+		}
+		if err := sm.sectors.transition(sector.SectorID, SectorExpired, ""); err != nil {
+			log.Errorf("could not update sector %d to 'expired': %s", sector.SectorID, err)
+		}
+	}
+}
+
 func (sm *Miner) onCommitSuccess(dealCid cid.Cid, sector *sectorbuilder.SealedSectorMetadata) {
 	err := sm.updateDealResponse(dealCid, func(resp *DealResponse) {
 		resp.State = Posted
@@ -618,6 +779,10 @@ func (sm *Miner) onCommitFail(dealCid cid.Cid, message string) {
 func (sm *Miner) OnNewHeaviestTipSet(ts types.TipSet) {
 	ctx := context.Background()
 
+	if height, err := ts.Height(); err == nil {
+		sm.expireSectors(height)
+	}
+
 	rets, sig, err := sm.porcelainAPI.MessageQuery(
 		ctx,
 		address.Address{},
@@ -772,19 +937,63 @@ func (sm *Miner) submitPoSt(start, end *types.BlockHeight, inputs []generatePost
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
 	defer cancel()
 
-	// TODO: algorithmically determine appropriate values for these
-	gasPrice := types.NewGasPrice(submitPostGasPrice)
-	gasLimit := types.NewGasUnits(submitPostGasLimit)
-
+	gasLimit, gasPrice := messageGas(ctx, sm.porcelainAPI, sm.minerOwnerAddr, sm.minerAddr, "submitPoSt", proof[:])
 	_, err = sm.porcelainAPI.MessageSend(ctx, sm.minerOwnerAddr, sm.minerAddr, types.ZeroAttoFIL, gasPrice, gasLimit, "submitPoSt", proof[:])
 	if err != nil {
 		log.Errorf("failed to submit PoSt: %s", err)
 		return
 	}
 
+	faulty := make(map[uint64]bool, len(faults))
+	for _, sectorID := range faults {
+		faulty[sectorID] = true
+	}
+	for _, input := range inputs {
+		state, message := SectorProving, ""
+		if faulty[input.sectorID] {
+			state, message = SectorFaulty, "faulted in proof-of-spacetime"
+		}
+		err := sm.sectors.transition(input.sectorID, state, message)
+		if errors.Cause(err) == ErrInvalidSectorTransition {
+			// expired sectors are proven while their commitment is in the chain
+			log.Debugf("not updating sector %d: %s", input.sectorID, err)
+		} else if err != nil {
+			log.Errorf("could not update sector %d to '%s': %s", input.sectorID, state, err)
+		}
+	}
+
 	log.Debug("submitted PoSt")
 }
 
+// gasPorcelain is the subset of the porcelain API picking the gas of
+// messages.
+type gasPorcelain interface {
+	MessageEstimateGasLimit(ctx context.Context, from, to address.Address, method string, params ...interface{}) (types.GasUnits, error)
+	MessageSuggestGasPrice(ctx context.Context) (types.AttoFIL, error)
+}
+
+// messageGas returns the estimated gas limit and the suggested gas price of a
+// message, or defaultGasLimit and defaultGasPrice when they cannot be
+// determined, so that the message is still sent.
+func messageGas(ctx context.Context, api gasPorcelain, from, to address.Address, method string, params ...interface{}) (types.GasUnits, types.AttoFIL) {
+	gasLimit, err := api.MessageEstimateGasLimit(ctx, from, to, method, params...)
+	if err != nil {
+		log.Warningf("using the default gas limit for %s, could not estimate it: %s", method, err)
+		gasLimit = types.NewGasUnits(defaultGasLimit)
+	}
+	gasPrice, err := api.MessageSuggestGasPrice(ctx)
+	if err != nil {
+		log.Warningf("using the default gas price for %s, could not suggest one: %s", method, err)
+		gasPrice = types.NewGasPrice(defaultGasPrice)
+	}
+	return gasLimit, gasPrice
+}
+
+// Sectors returns the sectors of the miner ordered by id.
+func (sm *Miner) Sectors() ([]*SectorInfo, error) {
+	return sm.sectors.List()
+}
+
 // Query responds to a query for the proposal referenced by the given cid
 func (sm *Miner) Query(ctx context.Context, c cid.Cid) *DealResponse {
 	sm.dealsLk.Lock()
@@ -800,23 +1009,6 @@ func (sm *Miner) Query(ctx context.Context, c cid.Cid) *DealResponse {
 	return d.Response
 }
 
-func (sm *Miner) handleQueryDeal(s inet.Stream) {
-	defer s.Close() // nolint: errcheck
-
-	var q queryRequest
-	if err := cbu.NewMsgReader(s).ReadMsg(&q); err != nil {
-		log.Errorf("received invalid query: %s", err)
-		return
-	}
-
-	ctx := context.Background()
-	resp := sm.Query(ctx, q.Cid)
-
-	if err := cbu.NewMsgWriter(s).WriteMsg(resp); err != nil {
-		log.Errorf("failed to write query response: %s", err)
-	}
-}
-
 func getFileSize(ctx context.Context, c cid.Cid, dserv ipld.DAGService) (uint64, error) {
 	fnode, err := dserv.Get(ctx, c)
 	if err != nil {
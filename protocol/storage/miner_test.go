@@ -2,9 +2,12 @@ package storage
 
 import (
 	"context"
+	"fmt"
 	"testing"
+	"time"
 
 	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	bserv "gx/ipfs/QmYPZzd9VqmJDwxUnThfeSbV1Y5o53aVPDijTB7j7rS9Ep/go-blockservice"
 
 	"github.com/filecoin-project/go-filecoin/actor"
 	"github.com/filecoin-project/go-filecoin/actor/builtin/paymentbroker"
@@ -68,6 +71,22 @@ func TestReceiveStorageProposal(t *testing.T) {
 		assert.Equal("proposed price (2500) is less than expected (5000) given asking price of 0.0005", res.Message)
 	})
 
+	t.Run("Rejects proposals below the price of the miner", func(t *testing.T) {
+		assert := assert.New(t)
+		require := require.New(t)
+
+		porcelainAPI, miner, proposal := newMinerTestSetup()
+
+		// the price of the miner overrides the price of the node
+		porcelainAPI.config.Set("mining.storagePrices", fmt.Sprintf(`{"%s": ".0005"}`, miner.minerAddr))
+
+		res, err := miner.receiveStorageProposal(context.Background(), proposal)
+		require.NoError(err)
+
+		assert.Equal(Rejected, res.State)
+		assert.Equal("proposed price (2500) is less than expected (5000) given asking price of 0.0005", res.Message)
+	})
+
 	t.Run("Rejects proposals with invalid payment channel", func(t *testing.T) {
 		assert := assert.New(t)
 		require := require.New(t)
@@ -355,6 +374,14 @@ func newMinerTestPorcelain() *minerTestPorcelain {
 	}
 }
 
+func (mtp *minerTestPorcelain) MessageEstimateGasLimit(ctx context.Context, from, to address.Address, method string, params ...interface{}) (types.GasUnits, error) {
+	return types.NewGasUnits(300), nil
+}
+
+func (mtp *minerTestPorcelain) MessageSuggestGasPrice(ctx context.Context) (types.AttoFIL, error) {
+	return types.NewGasPrice(0), nil
+}
+
 func (mtp *minerTestPorcelain) MessageSend(ctx context.Context, from, to address.Address, val *types.AttoFIL, gasPrice types.AttoFIL, gasLimit types.GasUnits, method string, params ...interface{}) (cid.Cid, error) {
 	return cid.Cid{}, nil
 }
@@ -391,6 +418,46 @@ func (mtp *minerTestPorcelain) MessageWait(ctx context.Context, msgCid cid.Cid,
 	return nil
 }
 
+func TestDealDeadline(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+
+	porcelainAPI, miner, proposal := newMinerTestSetup()
+	miner.node = &minerTestNode{blockHeight: porcelainAPI.blockHeight, blockTime: time.Second}
+
+	// the first voucher is valid VoucherInterval blocks from now
+	before := time.Now()
+	deadline, ok := miner.dealDeadline(proposal)
+	assert.True(ok)
+	assert.False(deadline.Before(before.Add(VoucherInterval * time.Second)))
+	assert.False(deadline.After(time.Now().Add(VoucherInterval * time.Second)))
+
+	proposal.Payment.Vouchers = nil
+	_, ok = miner.dealDeadline(proposal)
+	assert.False(ok)
+}
+
+type minerTestNode struct {
+	blockHeight *types.BlockHeight
+	blockTime   time.Duration
+}
+
+func (mtn *minerTestNode) BlockHeight() (*types.BlockHeight, error) {
+	return mtn.blockHeight, nil
+}
+
+func (mtn *minerTestNode) GetBlockTime() time.Duration {
+	return mtn.blockTime
+}
+
+func (mtn *minerTestNode) BlockService() bserv.BlockService {
+	return nil
+}
+
+func (mtn *minerTestNode) SectorBuilder() sectorbuilder.SectorBuilder {
+	return nil
+}
+
 func newTestMiner(api *minerTestPorcelain) *Miner {
 	return &Miner{
 		porcelainAPI:   api,
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	inet "gx/ipfs/QmNgLg1NTw37iWbYPKcyK85YJ9Whs1MkPtJwhfqbNYAyKg/go-libp2p-net"
+	"gx/ipfs/QmaoXrM4Z41PD48JY36YqQGKQpLGjyLA2cKcLsES7YddAq/go-libp2p-host"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	cbu "github.com/filecoin-project/go-filecoin/cborutil"
+)
+
+// Miners serves the storage protocols of a host for all the storage miners of
+// a node. Proposals go to the miner they are addressed to and queries to the
+// miner that holds the deal. It is safe for concurrent use.
+type Miners struct {
+	lk     sync.RWMutex
+	miners map[address.Address]*Miner
+}
+
+// NewMiners returns a Miners without miners and binds it to the storage
+// protocols of h.
+func NewMiners(h host.Host) *Miners {
+	ms := &Miners{
+		miners: make(map[address.Address]*Miner),
+	}
+
+	h.SetStreamHandler(makeDealProtocol, ms.handleMakeDeal)
+	h.SetStreamHandler(queryDealProtocol, ms.handleQueryDeal)
+
+	return ms
+}
+
+// Add makes sm serve the deals addressed to its miner, replacing the storage
+// miner previously added for it if any.
+func (ms *Miners) Add(sm *Miner) {
+	ms.lk.Lock()
+	defer ms.lk.Unlock()
+	ms.miners[sm.minerAddr] = sm
+}
+
+// Remove stops sm from serving deals. Proposals to its miner are rejected
+// until a storage miner is added for it again.
+func (ms *Miners) Remove(sm *Miner) {
+	ms.lk.Lock()
+	defer ms.lk.Unlock()
+	if ms.miners[sm.minerAddr] == sm {
+		delete(ms.miners, sm.minerAddr)
+	}
+}
+
+// Get returns the storage miner of minerAddr, or nil if there is none.
+func (ms *Miners) Get(minerAddr address.Address) *Miner {
+	ms.lk.RLock()
+	defer ms.lk.RUnlock()
+	return ms.miners[minerAddr]
+}
+
+// List returns the storage miners, in no particular order.
+func (ms *Miners) List() []*Miner {
+	ms.lk.RLock()
+	defer ms.lk.RUnlock()
+
+	miners := make([]*Miner, 0, len(ms.miners))
+	for _, sm := range ms.miners {
+		miners = append(miners, sm)
+	}
+	return miners
+}
+
+func (ms *Miners) handleMakeDeal(s inet.Stream) {
+	defer s.Close() // nolint: errcheck
+
+	var proposal DealProposal
+	if err := cbu.NewMsgReader(s).ReadMsg(&proposal); err != nil {
+		log.Errorf("received invalid proposal: %s", err)
+		return
+	}
+
+	var resp *DealResponse
+	if sm := ms.Get(proposal.MinerAddress); sm != nil {
+		var err error
+		resp, err = sm.receiveStorageProposal(context.Background(), &proposal)
+		if err != nil {
+			log.Errorf("failed to process proposal: %s", err)
+			return
+		}
+	} else {
+		resp = &DealResponse{
+			State:   Rejected,
+			Message: fmt.Sprintf("no storage miner for %s", proposal.MinerAddress),
+		}
+	}
+
+	if err := cbu.NewMsgWriter(s).WriteMsg(resp); err != nil {
+		log.Errorf("failed to write proposal response: %s", err)
+	}
+}
+
+func (ms *Miners) handleQueryDeal(s inet.Stream) {
+	defer s.Close() // nolint: errcheck
+
+	var q queryRequest
+	if err := cbu.NewMsgReader(s).ReadMsg(&q); err != nil {
+		log.Errorf("received invalid query: %s", err)
+		return
+	}
+
+	resp := &DealResponse{
+		State:   Unknown,
+		Message: "no such deal",
+	}
+	for _, sm := range ms.List() {
+		if sm.getStorageDeal(q.Cid) != nil {
+			resp = sm.Query(context.Background(), q.Cid)
+			break
+		}
+	}
+
+	if err := cbu.NewMsgWriter(s).WriteMsg(resp); err != nil {
+		log.Errorf("failed to write query response: %s", err)
+	}
+}
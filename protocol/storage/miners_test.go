@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	"gx/ipfs/QmYxivS34F2M2n44WQQnRHGAKS8aoRUxwGpi9wk4Cdn4Jf/go-libp2p/p2p/net/mock"
+	"gx/ipfs/QmZNkThpqfVXs9GNbexPrfBbXSLNYeKrE7jwFM2oqHbyqN/go-libp2p-protocol"
+
+	"github.com/filecoin-project/go-filecoin/address"
+	cbu "github.com/filecoin-project/go-filecoin/cborutil"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMinersRoutesDeals(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+
+	mn, err := mocknet.WithNPeers(ctx, 2)
+	require.NoError(err)
+	require.NoError(mn.LinkAll())
+	require.NoError(mn.ConnectAllButSelf())
+	minerHost, clientHost := mn.Hosts()[0], mn.Hosts()[1]
+
+	addrGetter := address.NewForTestGetter()
+	cidGetter := types.NewCidForTestGetter()
+
+	_, sm1, _ := newMinerTestSetup()
+	sm1.minerAddr = addrGetter()
+	sm1.deals = make(map[cid.Cid]*storageDeal)
+	_, sm2, _ := newMinerTestSetup()
+	sm2.minerAddr = addrGetter()
+	sm2.deals = make(map[cid.Cid]*storageDeal)
+
+	dealCid := cidGetter()
+	sm2.deals[dealCid] = &storageDeal{Response: &DealResponse{State: Staged, ProposalCid: dealCid}}
+
+	ms := NewMiners(minerHost)
+	ms.Add(sm1)
+	ms.Add(sm2)
+	assert.Equal(sm2, ms.Get(sm2.minerAddr))
+	assert.Len(ms.List(), 2)
+
+	request := func(pid protocol.ID, req interface{}) *DealResponse {
+		s, err := clientHost.NewStream(ctx, minerHost.ID(), pid)
+		require.NoError(err)
+		defer s.Close() // nolint: errcheck
+		require.NoError(cbu.NewMsgWriter(s).WriteMsg(req))
+		var resp DealResponse
+		require.NoError(cbu.NewMsgReader(s).ReadMsg(&resp))
+		return &resp
+	}
+
+	t.Run("queries go to the miner holding the deal", func(t *testing.T) {
+		resp := request(queryDealProtocol, &queryRequest{Cid: dealCid})
+		assert.Equal(Staged, resp.State)
+		assert.Equal(dealCid, resp.ProposalCid)
+
+		resp = request(queryDealProtocol, &queryRequest{Cid: cidGetter()})
+		assert.Equal(Unknown, resp.State)
+	})
+
+	t.Run("proposals to another miner are rejected", func(t *testing.T) {
+		resp := request(makeDealProtocol, &DealProposal{MinerAddress: addrGetter()})
+		assert.Equal(Rejected, resp.State)
+		assert.Contains(resp.Message, "no storage miner")
+	})
+
+	t.Run("removed miners no longer serve their deals", func(t *testing.T) {
+		ms.Remove(sm2)
+		assert.Nil(ms.Get(sm2.minerAddr))
+		assert.Len(ms.List(), 1)
+
+		resp := request(queryDealProtocol, &queryRequest{Cid: dealCid})
+		assert.Equal(Unknown, resp.State)
+
+		resp = request(makeDealProtocol, &DealProposal{MinerAddress: sm2.minerAddr})
+		assert.Equal(Rejected, resp.State)
+	})
+}
@@ -0,0 +1,259 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+	"gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore"
+	"gx/ipfs/Qmf4xQhNomPNhrtZc67qSnfJSjxjXs9LWvknJtSXwimPrM/go-datastore/query"
+
+	"github.com/filecoin-project/go-filecoin/repo"
+)
+
+const sectorsDatastorePrefix = "sectors"
+
+// ErrSectorNotFound is returned when the miner has no record of a sector.
+var ErrSectorNotFound = errors.New("sector not found")
+
+// ErrInvalidSectorTransition is returned when a sector is moved to a state it
+// cannot reach from its current state.
+var ErrInvalidSectorTransition = errors.New("invalid sector state transition")
+
+// SectorState signifies the state of a sector of the miner
+type SectorState int
+
+const (
+	// SectorStaged means pieces of deals are being added to the sector
+	SectorStaged = SectorState(iota)
+
+	// SectorSealing means the sector is being sealed
+	SectorSealing
+
+	// SectorSealed means the sector is sealed but its commitment has not
+	// been sent yet
+	SectorSealed
+
+	// SectorCommitSent means the commitSector message of the sector was sent
+	// and is not in the chain yet
+	SectorCommitSent
+
+	// SectorCommitted means the commitment of the sector is in the chain
+	SectorCommitted
+
+	// SectorProving means the sector was included in a proof-of-spacetime
+	SectorProving
+
+	// SectorFaulty means the sector failed to be sealed, committed or proven
+	SectorFaulty
+
+	// SectorExpired means all the deals of the sector have ended
+	SectorExpired
+)
+
+// sectorTransitions are the states each state can move to, besides itself.
+// A sector goes back to staged if its sealing could not be started, and may be
+// sealed without the start of its sealing being reported.  Faulty sectors are
+// proving again once they are included in a proof-of-spacetime without fault.
+// Expired sectors stay expired.
+var sectorTransitions = map[SectorState][]SectorState{
+	SectorStaged:     {SectorSealing, SectorSealed, SectorFaulty},
+	SectorSealing:    {SectorStaged, SectorSealed, SectorFaulty},
+	SectorSealed:     {SectorCommitSent, SectorCommitted, SectorFaulty},
+	SectorCommitSent: {SectorCommitted, SectorFaulty},
+	SectorCommitted:  {SectorProving, SectorFaulty, SectorExpired},
+	SectorProving:    {SectorFaulty, SectorExpired},
+	SectorFaulty:     {SectorProving, SectorExpired},
+}
+
+// canTransition returns true if a sector in state from can move to state to.
+func canTransition(from, to SectorState) bool {
+	if from == to {
+		return true
+	}
+	for _, state := range sectorTransitions[from] {
+		if state == to {
+			return true
+		}
+	}
+	return false
+}
+
+func (s SectorState) String() string {
+	switch s {
+	case SectorStaged:
+		return "staged"
+	case SectorSealing:
+		return "sealing"
+	case SectorSealed:
+		return "sealed"
+	case SectorCommitSent:
+		return "commit-sent"
+	case SectorCommitted:
+		return "committed"
+	case SectorProving:
+		return "proving"
+	case SectorFaulty:
+		return "faulty"
+	case SectorExpired:
+		return "expired"
+	default:
+		return fmt.Sprintf("<unrecognized %d>", s)
+	}
+}
+
+// SectorTransition records a sector entering a state.
+type SectorTransition struct {
+	State SectorState
+	Time  time.Time
+	// Message explains the transition, e.g. the error that made the sector
+	// faulty.
+	Message string `json:",omitempty"`
+}
+
+// SectorInfo is what the miner knows about one of its sectors.
+type SectorInfo struct {
+	SectorID uint64
+	State    SectorState
+	// Deals are the proposal cids of the deals with pieces in the sector.
+	Deals []cid.Cid
+	// CommitMessages are the cids of the commitSector messages sent for the
+	// sector.
+	CommitMessages []cid.Cid `json:",omitempty"`
+	// ExpiresAt is the block height at which the last deal of the sector
+	// ends, once the sector is committed.
+	ExpiresAt uint64 `json:",omitempty"`
+	// History is the list of the states the sector went through, oldest
+	// first.
+	History []SectorTransition
+}
+
+// SectorStore keeps the SectorInfo of the sectors of the miner in the repo.
+type SectorStore struct {
+	ds repo.Datastore
+	lk sync.Mutex
+}
+
+// NewSectorStore returns a SectorStore keeping sectors in ds.
+func NewSectorStore(ds repo.Datastore) *SectorStore {
+	return &SectorStore{ds: ds}
+}
+
+// Get returns the sector with id sectorID, or ErrSectorNotFound.
+func (ss *SectorStore) Get(sectorID uint64) (*SectorInfo, error) {
+	ss.lk.Lock()
+	defer ss.lk.Unlock()
+	return ss.get(sectorID)
+}
+
+// List returns all the sectors ordered by id.
+func (ss *SectorStore) List() ([]*SectorInfo, error) {
+	res, err := ss.ds.Query(query.Query{Prefix: "/" + sectorsDatastorePrefix})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query sectors from datastore")
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read sectors from datastore")
+	}
+
+	sectors := make([]*SectorInfo, 0, len(entries))
+	for _, entry := range entries {
+		var sector SectorInfo
+		if err := json.Unmarshal(entry.Value, &sector); err != nil {
+			return nil, errors.Wrapf(err, "failed to unmarshal sector %s", entry.Key)
+		}
+		sectors = append(sectors, &sector)
+	}
+	sort.Slice(sectors, func(i, j int) bool { return sectors[i].SectorID < sectors[j].SectorID })
+	return sectors, nil
+}
+
+// addDeal records that a piece of the deal with proposal dealCid was added to
+// the sector, which is staged if it is new.
+func (ss *SectorStore) addDeal(sectorID uint64, dealCid cid.Cid) error {
+	return ss.update(sectorID, func(sector *SectorInfo) error {
+		for _, c := range sector.Deals {
+			if c.Equals(dealCid) {
+				return nil
+			}
+		}
+		sector.Deals = append(sector.Deals, dealCid)
+		return nil
+	})
+}
+
+// transition moves the sector to state, unless it is already in it, see
+// setState.
+func (ss *SectorStore) transition(sectorID uint64, state SectorState, message string) error {
+	return ss.update(sectorID, func(sector *SectorInfo) error {
+		return setState(sector, state, message)
+	})
+}
+
+// update applies f to the sector, which is created as staged if it is new,
+// and stores it unless f fails.
+func (ss *SectorStore) update(sectorID uint64, f func(*SectorInfo) error) error {
+	ss.lk.Lock()
+	defer ss.lk.Unlock()
+
+	sector, err := ss.get(sectorID)
+	if err == ErrSectorNotFound {
+		sector = &SectorInfo{SectorID: sectorID}
+		sector.History = []SectorTransition{{State: SectorStaged, Time: time.Now()}}
+	} else if err != nil {
+		return err
+	}
+
+	if err := f(sector); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(sector)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal sector")
+	}
+	if err := ss.ds.Put(sectorKey(sectorID), data); err != nil {
+		return errors.Wrapf(err, "failed to store sector %d", sectorID)
+	}
+	return nil
+}
+
+func (ss *SectorStore) get(sectorID uint64) (*SectorInfo, error) {
+	data, err := ss.ds.Get(sectorKey(sectorID))
+	if err == datastore.ErrNotFound {
+		return nil, ErrSectorNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read sector %d", sectorID)
+	}
+
+	var sector SectorInfo
+	if err := json.Unmarshal(data, &sector); err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal sector %d", sectorID)
+	}
+	return &sector, nil
+}
+
+// setState moves sector to state, unless it is already in it.  It returns
+// ErrInvalidSectorTransition if the sector cannot reach state from its
+// current state.
+func setState(sector *SectorInfo, state SectorState, message string) error {
+	if sector.State == state && len(sector.History) > 0 {
+		return nil
+	}
+	if !canTransition(sector.State, state) {
+		return errors.Wrapf(ErrInvalidSectorTransition, "sector %d cannot go from '%s' to '%s'", sector.SectorID, sector.State, state)
+	}
+	sector.State = state
+	sector.History = append(sector.History, SectorTransition{State: state, Time: time.Now(), Message: message})
+	return nil
+}
+
+func sectorKey(sectorID uint64) datastore.Key {
+	return datastore.KeyWithNamespaces([]string{sectorsDatastorePrefix, fmt.Sprintf("%d", sectorID)})
+}
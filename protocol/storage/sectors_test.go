@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"testing"
+
+	"gx/ipfs/QmR8BauakNcBa3RbE4nbQu76PDiJgoQgz8AJdhJuiU4TAw/go-cid"
+	"gx/ipfs/QmVmDhyTTUcQXFD1rRQ64fGLMSAoaQvNH3hwuaCFAPq2hy/errors"
+
+	"github.com/filecoin-project/go-filecoin/repo"
+	"github.com/filecoin-project/go-filecoin/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSectorStore(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ds := repo.NewInMemoryRepo().DealsDatastore()
+	ss := NewSectorStore(ds)
+	newCid := types.NewCidForTestGetter()
+
+	_, err := ss.Get(1)
+	assert.Equal(ErrSectorNotFound, err)
+
+	deal1, deal2 := newCid(), newCid()
+	require.NoError(ss.addDeal(2, deal1))
+	require.NoError(ss.addDeal(1, deal1))
+	require.NoError(ss.addDeal(1, deal2))
+	require.NoError(ss.addDeal(1, deal2))
+	require.NoError(ss.transition(1, SectorSealing, ""))
+	require.NoError(ss.transition(1, SectorSealing, ""))
+	require.NoError(ss.transition(1, SectorFaulty, "boom"))
+
+	// Faulty sectors are not sealed again.
+	err = ss.transition(1, SectorSealing, "")
+	assert.Equal(ErrInvalidSectorTransition, errors.Cause(err))
+
+	// Sectors are read back from the datastore.
+	sector, err := NewSectorStore(ds).Get(1)
+	require.NoError(err)
+	assert.Equal(SectorFaulty, sector.State)
+	assert.Len(sector.Deals, 2)
+	require.Len(sector.History, 3)
+	assert.Equal(SectorStaged, sector.History[0].State)
+	assert.Equal(SectorSealing, sector.History[1].State)
+	assert.Equal("boom", sector.History[2].Message)
+
+	sectors, err := ss.List()
+	require.NoError(err)
+	require.Len(sectors, 2)
+	assert.Equal(uint64(1), sectors[0].SectorID)
+	assert.Equal(uint64(2), sectors[1].SectorID)
+	assert.Equal(SectorStaged, sectors[1].State)
+}
+
+func TestSectorExpiry(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	require := require.New(t)
+
+	sm := &Miner{
+		sectors:      NewSectorStore(repo.NewInMemoryRepo().DealsDatastore()),
+		deals:        make(map[cid.Cid]*storageDeal),
+		porcelainAPI: newMinerTestPorcelain(),
+	}
+	dealCid := types.SomeCid()
+	sm.deals[dealCid] = &storageDeal{Proposal: &DealProposal{Duration: 10}}
+	require.NoError(sm.sectors.addDeal(3, dealCid))
+	require.NoError(sm.sectors.transition(3, SectorSealed, ""))
+
+	sm.OnCommitSent(3, types.SomeCid())
+	sm.onSectorCommitted(3)
+	sector, err := sm.sectors.Get(3)
+	require.NoError(err)
+	assert.Equal(SectorCommitted, sector.State)
+	assert.Len(sector.CommitMessages, 1)
+	assert.Equal(uint64(783), sector.ExpiresAt)
+
+	sm.expireSectors(782)
+	sector, err = sm.sectors.Get(3)
+	require.NoError(err)
+	assert.Equal(SectorCommitted, sector.State)
+
+	sm.expireSectors(783)
+	sector, err = sm.sectors.Get(3)
+	require.NoError(err)
+	assert.Equal(SectorExpired, sector.State)
+
+	// Expired sectors stay expired.
+	err = sm.sectors.transition(3, SectorProving, "")
+	assert.Equal(ErrInvalidSectorTransition, errors.Cause(err))
+}
+
+func TestSectorSealRequeued(t *testing.T) {
+	t.Parallel()
+	assert := assert.New(t)
+	require := require.New(t)
+
+	sm := &Miner{sectors: NewSectorStore(repo.NewInMemoryRepo().DealsDatastore())}
+	require.NoError(sm.sectors.addDeal(5, types.SomeCid()))
+
+	sm.OnSectorSealing(5)
+	sm.OnSectorSealRequeued(5, errors.New("no space left"))
+	sector, err := sm.sectors.Get(5)
+	require.NoError(err)
+	assert.Equal(SectorStaged, sector.State)
+	assert.Contains(sector.History[len(sector.History)-1].Message, "no space left")
+}
@@ -57,6 +57,20 @@ const (
 		"beatPeriod": "3s",
 		"reconnectPeriod": "10s",
 		"nickname": ""
+	},
+	"chain": {
+		"pruneKeepRecent": 0,
+		"pruneCheckpointInterval": 0,
+		"gcPeriod": "",
+		"indexAddresses": false
+	},
+	"mpool": {
+		"maxPoolSize": 10000,
+		"maxSenderMessages": 256,
+		"maxNonceGap": 100,
+		"replaceByFeePercent": 10,
+		"messageTTL": 100,
+		"rebroadcastPeriod": "1m"
 	}
 }`
 )
@@ -174,6 +174,15 @@ func TestMulInt(t *testing.T) {
 	})
 }
 
+func TestDivBigInt(t *testing.T) {
+	assert := assert.New(t)
+	x := AttoFIL{val: big.NewInt(200)}
+
+	assert.Equal(NewAttoFIL(big.NewInt(20)), x.DivBigInt(big.NewInt(10)))
+	// the quotient is rounded down
+	assert.Equal(NewAttoFIL(big.NewInt(22)), x.DivBigInt(big.NewInt(9)))
+}
+
 func TestDivCeil(t *testing.T) {
 	x := AttoFIL{val: big.NewInt(200)}
 
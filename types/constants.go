@@ -40,6 +40,12 @@ var BootstrapMinerActorCodeObj ipld.Node
 // BootstrapMinerActorCodeCid is the cid of the above object
 var BootstrapMinerActorCodeCid cid.Cid
 
+// RewardActorCodeObj is the code representation of the builtin reward actor.
+var RewardActorCodeObj ipld.Node
+
+// RewardActorCodeCid is the cid of the above object
+var RewardActorCodeCid cid.Cid
+
 // ActorCodeCidTypeNames maps Actor codeCid's to the name of the associated Actor type.
 var ActorCodeCidTypeNames = make(map[cid.Cid]string)
 
@@ -54,6 +60,8 @@ func init() {
 	MinerActorCodeCid = MinerActorCodeObj.Cid()
 	BootstrapMinerActorCodeObj = dag.NewRawNode([]byte("bootstrapmineractor"))
 	BootstrapMinerActorCodeCid = BootstrapMinerActorCodeObj.Cid()
+	RewardActorCodeObj = dag.NewRawNode([]byte("rewardactor"))
+	RewardActorCodeCid = RewardActorCodeObj.Cid()
 
 	// New Actors need to be added here.
 	// TODO: Make this work with reflection -- but note that nasty import cycles lie on that path.
@@ -63,6 +71,7 @@ func init() {
 	ActorCodeCidTypeNames[PaymentBrokerActorCodeCid] = "PaymentBrokerActor"
 	ActorCodeCidTypeNames[MinerActorCodeCid] = "MinerActor"
 	ActorCodeCidTypeNames[BootstrapMinerActorCodeCid] = "MinerActor"
+	ActorCodeCidTypeNames[RewardActorCodeCid] = "RewardActor"
 }
 
 // ActorCodeTypeName returns the (string) name of the Go type of the actor with cid, code.